@@ -4,15 +4,38 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 )
 
 // Config 应用配置结构
 type Config struct {
-	Database   DatabaseConfig `json:"database"`
-	Security   SecurityConfig `json:"security"`
-	Server     ServerConfig   `json:"server"`
-	WeCom      WeComConfig    `json:"wecom"`
-	RBACConfig string         `json:"rbac_config"`
+	Database   DatabaseConfig   `json:"database"`
+	Security   SecurityConfig   `json:"security"`
+	Server     ServerConfig     `json:"server"`
+	WeCom      WeComConfig      `json:"wecom"`
+	Storage    StorageConfig    `json:"storage"`
+	Rotation   RotationConfig   `json:"rotation"`
+	RecycleBin RecycleBinConfig `json:"recycle_bin"`
+	RBACConfig string           `json:"rbac_config"`
+}
+
+// RotationConfig 自动轮换配置
+type RotationConfig struct {
+	ScriptDir    string   `json:"script_dir"`    // 脚本轮换器可以执行的脚本所在目录，为空时禁用脚本轮换器
+	WebhookHosts []string `json:"webhook_hosts"` // Webhook轮换器允许请求的主机（host或host:port），为空时禁用Webhook轮换器
+}
+
+// RecycleBinConfig 回收站配置
+type RecycleBinConfig struct {
+	RetentionDays int `json:"retention_days"` // 删除的信息项在回收站中保留的天数，超过后自动清除，默认30天
+}
+
+// StorageConfig 敏感数据存储配置
+type StorageConfig struct {
+	Backend      string `json:"backend"`        // 存储后端：database（默认，加密后存入数据库）, vault_kv（存入Vault KV v2）
+	KVMount      string `json:"kv_mount"`       // KV v2引擎挂载路径，默认为"secret"
+	KVPathPrefix string `json:"kv_path_prefix"` // 密钥项在KV中的路径前缀，默认为"hysaif/items"
 }
 
 // DatabaseConfig 数据库配置
@@ -29,10 +52,65 @@ type DatabaseConfig struct {
 
 // SecurityConfig 安全配置
 type SecurityConfig struct {
-	EncryptionKey string         `json:"encryption_key"`
+	EncryptionKey string         `json:"encryption_key"` // 旧版单一主密钥，作为版本0保留用于解密历史数据
+	Keyring       KeyringConfig  `json:"keyring"`        // 本地主密钥环
 	JWTSecret     string         `json:"jwt_secret"`
+	KeyProvider   string         `json:"key_provider"` // 主密钥提供者：aes, vault, file, pkcs11，为空时根据Vault开关选择
 	WebAuthn      WebAuthnConfig `json:"webauthn"`
 	Vault         VaultConfig    `json:"vault"`
+	FileKMS       FileKMSConfig  `json:"file_kms"`
+	PKCS11        PKCS11Config   `json:"pkcs11"`
+	Seal          SealConfig     `json:"seal"`            // 密封模式
+	BlindIndexKey string         `json:"blind_index_key"` // 盲索引HMAC密钥，至少32字节，为空时由版本0的本地主密钥派生
+
+	RequireBoundCiphertext bool `json:"require_bound_ciphertext"` // 拒绝读取未与数据行绑定的旧密文，主密钥轮换任务完成后启用
+
+	EncryptionPolicy    string            `json:"encryption_policy"`    // 加密策略：require_vault, prefer_vault, local_only，默认为prefer_vault
+	EnvironmentPolicies map[string]string `json:"environment_policies"` // 按密钥项环境覆盖加密策略，如 {"production": "require_vault"}
+
+	EnvironmentKeys map[string]EnvironmentKeyConfig `json:"environment_keys"` // 按密钥项环境隔离主密钥，如 {"production": {...}}
+
+	PasswordPolicy           *PasswordPolicyConfig           `json:"password_policy"`            // 密码类型信息项的默认强度策略，为空时使用内置策略
+	CategoryPasswordPolicies map[string]PasswordPolicyConfig `json:"category_password_policies"` // 按信息项分类覆盖强度策略，如 {"aws": {...}}
+}
+
+// PasswordPolicyConfig 密码强度策略，创建和更新密码类型信息项时校验
+type PasswordPolicyConfig struct {
+	MinLength        int  `json:"min_length"`        // 最小长度
+	MinScore         int  `json:"min_score"`         // 最低强度评分，0-4，评分越高越难破解
+	RequireUppercase bool `json:"require_uppercase"` // 必须包含大写字母
+	RequireLowercase bool `json:"require_lowercase"` // 必须包含小写字母
+	RequireDigits    bool `json:"require_digits"`    // 必须包含数字
+	RequireSymbols   bool `json:"require_symbols"`   // 必须包含特殊字符
+}
+
+// EnvironmentKeyConfig 环境独立的主密钥配置，配置后该环境的数据只使用这里的主密钥包装，不使用共享主密钥
+//
+// 不持有某环境密钥的部署应保留该环境的空配置，使该环境的数据无法解密，写入时也不会回退到共享主密钥
+type EnvironmentKeyConfig struct {
+	Keyring      KeyringConfig `json:"keyring"`        // 环境独立的本地主密钥环
+	VaultKeyName string        `json:"vault_key_name"` // 环境独立的Transit密钥名称，与全局Transit密钥位于同一挂载路径
+}
+
+// SealConfig 密封模式配置，启用后本地主密钥不再写入配置文件，而是由持有者提交Shamir分片恢复
+type SealConfig struct {
+	Enabled    bool   `json:"enabled"`     // 是否启用密封模式，启用后服务以密封状态启动
+	Shares     int    `json:"shares"`      // 分片总数
+	Threshold  int    `json:"threshold"`   // 解封所需的分片数
+	KeyCheck   string `json:"key_check"`   // 主密钥校验值，由 operator init 命令生成
+	KeyVersion int    `json:"key_version"` // 主密钥在本地密钥环中的版本，0表示替代 encryption_key
+}
+
+// KeyringConfig 本地主密钥环配置
+type KeyringConfig struct {
+	ActiveVersion int                `json:"active_version"` // 用于加密的活动密钥版本
+	Keys          []KeyringKeyConfig `json:"keys"`           // 所有可用于解密的密钥
+}
+
+// KeyringKeyConfig 密钥环中的单个版本化密钥
+type KeyringKeyConfig struct {
+	Version int    `json:"version"` // 密钥版本，必须大于0
+	Key     string `json:"key"`     // 32字节密钥
 }
 
 // WebAuthnConfig WebAuthn 配置
@@ -58,6 +136,28 @@ type VaultConfig struct {
 	MountPath string         `json:"mount_path"`          // Transit引擎挂载路径，默认为"transit"
 	Namespace string         `json:"namespace,omitempty"` // Vault命名空间（企业版功能）
 	TLSConfig VaultTLSConfig `json:"tls_config"`          // TLS配置
+	Derived   bool           `json:"derived"`             // Transit密钥是否启用密钥派生，启用后包装数据密钥时传递数据行context
+
+	AuthMethod string             `json:"auth_method"` // 认证方式：token, approle, kubernetes, jwt，默认为token
+	AppRole    VaultAppRoleConfig `json:"approle"`     // AppRole认证配置
+	Kubernetes VaultJWTConfig     `json:"kubernetes"`  // Kubernetes认证配置
+	JWT        VaultJWTConfig     `json:"jwt"`         // JWT认证配置
+}
+
+// VaultAppRoleConfig Vault AppRole认证配置
+type VaultAppRoleConfig struct {
+	RoleID       string `json:"role_id"`        // Role ID
+	SecretID     string `json:"secret_id"`      // Secret ID
+	SecretIDFile string `json:"secret_id_file"` // Secret ID文件路径，每次登录时重新读取
+	MountPath    string `json:"mount_path"`     // 认证挂载路径，默认为"approle"
+}
+
+// VaultJWTConfig Vault Kubernetes/JWT认证配置
+type VaultJWTConfig struct {
+	Role      string `json:"role"`       // Vault角色名称
+	Token     string `json:"token"`      // JWT令牌
+	TokenPath string `json:"token_path"` // JWT令牌文件路径，每次登录时重新读取；Kubernetes默认为服务账户令牌路径
+	MountPath string `json:"mount_path"` // 认证挂载路径，默认为"kubernetes"或"jwt"
 }
 
 // VaultTLSConfig Vault TLS配置
@@ -68,6 +168,19 @@ type VaultTLSConfig struct {
 	ClientKey  string `json:"client_key"`  // 客户端私钥路径
 }
 
+// FileKMSConfig 文件密钥环配置
+type FileKMSConfig struct {
+	Path string `json:"path"` // 密钥环JSON文件路径
+}
+
+// PKCS11Config PKCS#11 HSM配置
+type PKCS11Config struct {
+	Library    string `json:"library"`     // PKCS#11 动态库路径，如 /usr/lib/softhsm/libsofthsm2.so
+	TokenLabel string `json:"token_label"` // 令牌标签
+	PIN        string `json:"pin"`         // 用户PIN
+	KeyLabel   string `json:"key_label"`   // AES密钥对象标签
+}
+
 // WeComConfig 企业微信配置
 type WeComConfig struct {
 	Enabled      bool   `json:"enabled"`        // 是否启用企业微信通知
@@ -106,16 +219,92 @@ func LoadConfig(configPath string) error {
 	return nil
 }
 
+// parseKeyringKeys 解析 "版本:密钥,版本:密钥" 格式的密钥环，忽略格式错误的条目
+func parseKeyringKeys(value string) []KeyringKeyConfig {
+	var keys []KeyringKeyConfig
+	for _, entry := range strings.Split(value, ",") {
+		version, key, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok {
+			continue
+		}
+		v, err := strconv.Atoi(version)
+		if err != nil {
+			continue
+		}
+		keys = append(keys, KeyringKeyConfig{Version: v, Key: key})
+	}
+	return keys
+}
+
 // loadFromEnv 从环境变量加载敏感配置
 func loadFromEnv() {
 	if key := os.Getenv("SIMS_ENCRYPTION_KEY"); key != "" {
 		AppConfig.Security.EncryptionKey = key
 	}
 
+	// 密钥环，格式为 "版本:密钥,版本:密钥"
+	if keys := os.Getenv("SIMS_ENCRYPTION_KEYS"); keys != "" {
+		AppConfig.Security.Keyring.Keys = parseKeyringKeys(keys)
+	}
+
+	// 环境独立的密钥环，如 SIMS_ENCRYPTION_KEYS_PRODUCTION，格式与 SIMS_ENCRYPTION_KEYS 相同
+	for _, entry := range os.Environ() {
+		name, keys, _ := strings.Cut(entry, "=")
+		environment, ok := strings.CutPrefix(name, "SIMS_ENCRYPTION_KEYS_")
+		if !ok || environment == "" || keys == "" {
+			continue
+		}
+		environment = strings.ToLower(environment)
+		if AppConfig.Security.EnvironmentKeys == nil {
+			AppConfig.Security.EnvironmentKeys = make(map[string]EnvironmentKeyConfig)
+		}
+		environmentKey := AppConfig.Security.EnvironmentKeys[environment]
+		environmentKey.Keyring.Keys = parseKeyringKeys(keys)
+		AppConfig.Security.EnvironmentKeys[environment] = environmentKey
+	}
+
+	if activeVersion := os.Getenv("SIMS_ACTIVE_KEY_VERSION"); activeVersion != "" {
+		if v, err := strconv.Atoi(activeVersion); err == nil {
+			AppConfig.Security.Keyring.ActiveVersion = v
+		}
+	}
+
+	if sealEnabled := os.Getenv("SIMS_SEAL_ENABLED"); sealEnabled != "" {
+		AppConfig.Security.Seal.Enabled = sealEnabled == "true"
+	}
+
+	if sealKeyCheck := os.Getenv("SIMS_SEAL_KEY_CHECK"); sealKeyCheck != "" {
+		AppConfig.Security.Seal.KeyCheck = sealKeyCheck
+	}
+
+	if policy := os.Getenv("SIMS_ENCRYPTION_POLICY"); policy != "" {
+		AppConfig.Security.EncryptionPolicy = policy
+	}
+
+	// 按环境覆盖的加密策略，格式为 "环境:策略,环境:策略"
+	if policies := os.Getenv("SIMS_ENVIRONMENT_POLICIES"); policies != "" {
+		AppConfig.Security.EnvironmentPolicies = make(map[string]string)
+		for _, entry := range strings.Split(policies, ",") {
+			environment, policy, ok := strings.Cut(strings.TrimSpace(entry), ":")
+			if !ok {
+				continue
+			}
+			AppConfig.Security.EnvironmentPolicies[environment] = policy
+		}
+	}
+
 	if secret := os.Getenv("SIMS_JWT_SECRET"); secret != "" {
 		AppConfig.Security.JWTSecret = secret
 	}
 
+	if blindIndexKey := os.Getenv("SIMS_BLIND_INDEX_KEY"); blindIndexKey != "" {
+		AppConfig.Security.BlindIndexKey = blindIndexKey
+	}
+
+	if requireBound := os.Getenv("SIMS_REQUIRE_BOUND_CIPHERTEXT"); requireBound != "" {
+		AppConfig.Security.RequireBoundCiphertext = requireBound == "true"
+	}
+
 	if dbHost := os.Getenv("SIMS_DB_HOST"); dbHost != "" {
 		AppConfig.Database.Host = dbHost
 	}
@@ -132,6 +321,31 @@ func loadFromEnv() {
 		AppConfig.Database.Database = dbName
 	}
 
+	if keyProvider := os.Getenv("SIMS_KEY_PROVIDER"); keyProvider != "" {
+		AppConfig.Security.KeyProvider = keyProvider
+	}
+
+	if fileKMSPath := os.Getenv("SIMS_FILE_KMS_PATH"); fileKMSPath != "" {
+		AppConfig.Security.FileKMS.Path = fileKMSPath
+	}
+
+	// PKCS#11 相关环境变量
+	if pkcs11Library := os.Getenv("SIMS_PKCS11_LIBRARY"); pkcs11Library != "" {
+		AppConfig.Security.PKCS11.Library = pkcs11Library
+	}
+
+	if pkcs11TokenLabel := os.Getenv("SIMS_PKCS11_TOKEN_LABEL"); pkcs11TokenLabel != "" {
+		AppConfig.Security.PKCS11.TokenLabel = pkcs11TokenLabel
+	}
+
+	if pkcs11PIN := os.Getenv("SIMS_PKCS11_PIN"); pkcs11PIN != "" {
+		AppConfig.Security.PKCS11.PIN = pkcs11PIN
+	}
+
+	if pkcs11KeyLabel := os.Getenv("SIMS_PKCS11_KEY_LABEL"); pkcs11KeyLabel != "" {
+		AppConfig.Security.PKCS11.KeyLabel = pkcs11KeyLabel
+	}
+
 	// Vault相关环境变量
 	if vaultEnabled := os.Getenv("SIMS_VAULT_ENABLED"); vaultEnabled != "" {
 		AppConfig.Security.Vault.Enabled = vaultEnabled == "true"
@@ -145,6 +359,34 @@ func loadFromEnv() {
 		AppConfig.Security.Vault.Token = vaultToken
 	}
 
+	if vaultAuthMethod := os.Getenv("SIMS_VAULT_AUTH_METHOD"); vaultAuthMethod != "" {
+		AppConfig.Security.Vault.AuthMethod = vaultAuthMethod
+	}
+
+	if vaultRoleID := os.Getenv("SIMS_VAULT_ROLE_ID"); vaultRoleID != "" {
+		AppConfig.Security.Vault.AppRole.RoleID = vaultRoleID
+	}
+
+	if vaultSecretID := os.Getenv("SIMS_VAULT_SECRET_ID"); vaultSecretID != "" {
+		AppConfig.Security.Vault.AppRole.SecretID = vaultSecretID
+	}
+
+	if vaultSecretIDFile := os.Getenv("SIMS_VAULT_SECRET_ID_FILE"); vaultSecretIDFile != "" {
+		AppConfig.Security.Vault.AppRole.SecretIDFile = vaultSecretIDFile
+	}
+
+	if vaultK8sRole := os.Getenv("SIMS_VAULT_K8S_ROLE"); vaultK8sRole != "" {
+		AppConfig.Security.Vault.Kubernetes.Role = vaultK8sRole
+	}
+
+	if vaultJWTRole := os.Getenv("SIMS_VAULT_JWT_ROLE"); vaultJWTRole != "" {
+		AppConfig.Security.Vault.JWT.Role = vaultJWTRole
+	}
+
+	if vaultJWTPath := os.Getenv("SIMS_VAULT_JWT_PATH"); vaultJWTPath != "" {
+		AppConfig.Security.Vault.JWT.TokenPath = vaultJWTPath
+	}
+
 	if vaultKeyName := os.Getenv("SIMS_VAULT_KEY_NAME"); vaultKeyName != "" {
 		AppConfig.Security.Vault.KeyName = vaultKeyName
 	}
@@ -157,6 +399,10 @@ func loadFromEnv() {
 		AppConfig.Security.Vault.Namespace = vaultNamespace
 	}
 
+	if vaultDerived := os.Getenv("SIMS_VAULT_DERIVED"); vaultDerived != "" {
+		AppConfig.Security.Vault.Derived = vaultDerived == "true"
+	}
+
 	// Vault TLS配置
 	if vaultInsecure := os.Getenv("SIMS_VAULT_TLS_INSECURE"); vaultInsecure != "" {
 		AppConfig.Security.Vault.TLSConfig.Insecure = vaultInsecure == "true"
@@ -174,6 +420,39 @@ func loadFromEnv() {
 		AppConfig.Security.Vault.TLSConfig.ClientKey = vaultClientKey
 	}
 
+	// 存储后端
+	if storageBackend := os.Getenv("SIMS_STORAGE_BACKEND"); storageBackend != "" {
+		AppConfig.Storage.Backend = storageBackend
+	}
+
+	if kvMount := os.Getenv("SIMS_VAULT_KV_MOUNT"); kvMount != "" {
+		AppConfig.Storage.KVMount = kvMount
+	}
+
+	if kvPathPrefix := os.Getenv("SIMS_VAULT_KV_PATH_PREFIX"); kvPathPrefix != "" {
+		AppConfig.Storage.KVPathPrefix = kvPathPrefix
+	}
+
+	// 自动轮换
+	if rotationScriptDir := os.Getenv("SIMS_ROTATION_SCRIPT_DIR"); rotationScriptDir != "" {
+		AppConfig.Rotation.ScriptDir = rotationScriptDir
+	}
+	if webhookHosts := os.Getenv("SIMS_ROTATION_WEBHOOK_HOSTS"); webhookHosts != "" {
+		AppConfig.Rotation.WebhookHosts = nil
+		for _, host := range strings.Split(webhookHosts, ",") {
+			if host = strings.TrimSpace(host); host != "" {
+				AppConfig.Rotation.WebhookHosts = append(AppConfig.Rotation.WebhookHosts, host)
+			}
+		}
+	}
+
+	// 回收站
+	if retentionDays := os.Getenv("SIMS_RECYCLE_BIN_RETENTION_DAYS"); retentionDays != "" {
+		if v, err := strconv.Atoi(retentionDays); err == nil {
+			AppConfig.RecycleBin.RetentionDays = v
+		}
+	}
+
 	// 企微
 	if wecomEnabled := os.Getenv("SIMS_WECOM_ENABLED"); wecomEnabled != "" {
 		AppConfig.WeCom.Enabled = wecomEnabled == "true"
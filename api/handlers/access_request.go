@@ -1,11 +1,15 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/akinoccc/hysaif/api/models"
 	"github.com/akinoccc/hysaif/api/packages/context"
@@ -25,22 +29,31 @@ func CreateAccessRequest(c *gin.Context) {
 		return
 	}
 
-	// 检查密钥项是否存在
-	var secretItem models.SecretItem
-	if err := models.DB.Where("id = ?", req.SecretItemID).First(&secretItem).Error; err != nil {
-		c.JSON(http.StatusNotFound, types.ErrorResponse{Error: "密钥项不存在"})
-		return
+	// 检查申请访问的密钥项或文件夹是否存在
+	targetField, targetID := "secret_item_id", req.SecretItemID
+	if req.FolderID != "" {
+		targetField, targetID = "folder_id", req.FolderID
+		if _, err := models.GetFolder(req.FolderID); err != nil {
+			c.JSON(http.StatusNotFound, types.ErrorResponse{Error: "文件夹不存在"})
+			return
+		}
+	} else {
+		var secretItem models.SecretItem
+		if err := models.DB.Where("id = ?", req.SecretItemID).First(&secretItem).Error; err != nil {
+			c.JSON(http.StatusNotFound, types.ErrorResponse{Error: "密钥项不存在"})
+			return
+		}
 	}
 
 	// 检查是否已有待审批的申请
 	var existingRequest models.AccessRequest
-	baseQuery := "secret_item_id = ? AND applicant_id = ?"
+	baseQuery := targetField + " = ? AND applicant_id = ?"
 	if err := models.DB.Where(baseQuery+" AND status = ?",
-		req.SecretItemID, user.ID, models.RequestStatusPending).
+		targetID, user.ID, models.RequestStatusPending).
 		Or(baseQuery+" AND status = ? AND valid_until > ?",
-			req.SecretItemID, user.ID, models.RequestStatusApproved, uint64(time.Now().UnixMilli())).
+			targetID, user.ID, models.RequestStatusApproved, uint64(time.Now().UnixMilli())).
 		Or(baseQuery+" AND status = ? AND valid_until < ?",
-			req.SecretItemID, user.ID, models.RequestStatusApproved, uint64(time.Now().UnixMilli())).
+			targetID, user.ID, models.RequestStatusApproved, uint64(time.Now().UnixMilli())).
 		First(&existingRequest).
 		Error; err == nil {
 		c.JSON(http.StatusConflict, types.ErrorResponse{
@@ -54,6 +67,7 @@ func CreateAccessRequest(c *gin.Context) {
 	// 创建申请
 	accessRequest := models.AccessRequest{
 		SecretItemID: req.SecretItemID,
+		FolderID:     req.FolderID,
 		ApplicantID:  user.ID,
 		Reason:       req.Reason,
 		Status:       models.RequestStatusPending,
@@ -71,7 +85,7 @@ func CreateAccessRequest(c *gin.Context) {
 	}
 
 	// 重新查询以获取关联数据
-	models.DB.Preload("SecretItem").Preload("Applicant").First(&accessRequest, "id = ?", accessRequest.ID)
+	models.DB.Preload("SecretItem").Preload("Folder").Preload("Applicant").First(&accessRequest, "id = ?", accessRequest.ID)
 
 	c.JSON(http.StatusCreated, accessRequest)
 }
@@ -83,7 +97,7 @@ func GetAccessRequests(c *gin.Context) {
 	// 使用查询构建器
 	qb := query.NewQueryBuilder(models.DB, c, &models.AccessRequest{}).
 		ApplyAccessRequestFilters().
-		Preload("SecretItem", "Applicant", "Approver").
+		Preload("SecretItem", "Folder", "Applicant", "Approver").
 		OrderBy("created_at DESC")
 
 	// 权限控制：普通用户只能查看自己的申请
@@ -128,11 +142,43 @@ func ApproveAccessRequest(c *gin.Context) {
 		return
 	}
 
+	// 信息项已移入回收站时不能批准，预加载的信息项不包含已删除的记录
+	if accessRequest.SecretItemID != "" && accessRequest.SecretItem.ID == "" {
+		c.JSON(http.StatusGone, types.ErrorResponse{Error: "信息项已删除，无法批准申请"})
+		return
+	}
+
+	// 端到端加密信息项需要审批人客户端为申请人包装内容密钥；
+	// 文件夹申请不包含端到端加密信息项的内容密钥，需要对这些信息项单独申请
+	e2e := accessRequest.SecretItem.E2E
+	if e2e {
+		if req.WrappedKey == "" {
+			c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: "端到端加密信息项需要提供为申请人包装的内容密钥"})
+			return
+		}
+		if accessRequest.Applicant.PublicKey == "" {
+			c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: "申请人尚未注册公钥"})
+			return
+		}
+		wrappedKey, err := models.GetWrappedKey(accessRequest.SecretItemID, user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "查询失败"})
+			return
+		}
+		if wrappedKey == "" {
+			c.JSON(http.StatusForbidden, types.ErrorResponse{Error: "你不是该信息项的接收者，无法为申请人包装内容密钥"})
+			return
+		}
+	}
+
 	// 计算有效期
 	now := uint64(time.Now().UnixMilli())
 	validUntil := now + uint64(req.ValidDuration*3600*1000) // 转换为毫秒
 	log.Println("validUntil", validUntil, uint64(req.ValidDuration*3600*1000))
 
+	// 数据库连接在批准时签发临时用户，对文件夹的申请不签发
+	database := accessRequest.SecretItem.Type == models.SecretTypeDatabase
+
 	// 更新申请状态
 	accessRequest.Status = models.RequestStatusApproved
 	accessRequest.ApprovedByID = user.ID
@@ -141,11 +187,47 @@ func ApproveAccessRequest(c *gin.Context) {
 	accessRequest.ValidUntil = validUntil
 	accessRequest.Note = req.Note
 
-	if err := models.DB.Save(&accessRequest).Error; err != nil {
+	var lease *models.DatabaseLease
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(&accessRequest).Error; err != nil {
+			return err
+		}
+		if database {
+			var err error
+			lease, err = models.IssueDatabaseLease(tx, &accessRequest, &accessRequest.SecretItem)
+			return err
+		}
+		if !e2e {
+			return nil
+		}
+		return models.AddSecretItemRecipient(tx, &models.SecretItemRecipient{
+			SecretItemID:    accessRequest.SecretItemID,
+			UserID:          accessRequest.ApplicantID,
+			WrappedKey:      req.WrappedKey,
+			AddedByID:       user.ID,
+			AccessRequestID: accessRequest.ID,
+		})
+	})
+	if err != nil {
+		if errors.Is(err, models.ErrDatabaseUnavailable) {
+			c.JSON(http.StatusBadGateway, types.ErrorResponse{Error: "创建数据库临时用户失败: " + err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "审批失败"})
 		return
 	}
 
+	// 临时用户记录提交后才在目标数据库中创建用户，失败时申请恢复为待审批状态
+	if lease != nil {
+		if err := lease.Provision(&accessRequest.SecretItem); err != nil {
+			if revertErr := accessRequest.RevertApproval(); revertErr != nil {
+				log.Printf("撤回访问申请 %s 的批准失败: %v", accessRequest.ID, revertErr)
+			}
+			c.JSON(http.StatusBadGateway, types.ErrorResponse{Error: "创建数据库临时用户失败: " + err.Error()})
+			return
+		}
+	}
+
 	// 发送通知
 	if err := notification.NotifyAccessRequestApproved(&accessRequest); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "发送通知失败: " + err.Error()})
@@ -153,7 +235,7 @@ func ApproveAccessRequest(c *gin.Context) {
 	}
 
 	// 重新查询以获取关联数据
-	models.DB.Preload("SecretItem").Preload("Applicant").Preload("Approver").First(&accessRequest, "id = ?", accessRequest.ID)
+	models.DB.Preload("SecretItem").Preload("Folder").Preload("Applicant").Preload("Approver").First(&accessRequest, "id = ?", accessRequest.ID)
 
 	c.JSON(http.StatusOK, accessRequest)
 }
@@ -185,11 +267,23 @@ func RevokeAccessRequest(c *gin.Context) {
 	accessRequest.Status = models.RequestStatusRevoked
 	accessRequest.RejectReason = req.Reason // 复用拒绝理由字段存储作废理由
 
-	if err := models.DB.Save(&accessRequest).Error; err != nil {
+	// 同时删除通过该申请添加的接收者，之后不再向申请人返回包装密钥
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(&accessRequest).Error; err != nil {
+			return err
+		}
+		return models.RemoveAccessRequestRecipient(tx, accessRequest.ID)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "作废失败"})
 		return
 	}
 
+	// 删除签发的数据库临时用户，失败时由定时任务重试
+	if err := models.RevokeAccessRequestLease(accessRequest.ID); err != nil {
+		log.Printf("删除数据库临时用户失败 (申请ID: %s): %v", accessRequest.ID, err)
+	}
+
 	// 发送通知
 	if err := notification.NotifyAccessRequestRevoked(&accessRequest); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "发送通知失败: " + err.Error()})
@@ -197,7 +291,7 @@ func RevokeAccessRequest(c *gin.Context) {
 	}
 
 	// 重新查询以获取关联数据
-	models.DB.Preload("SecretItem").Preload("Applicant").Preload("Approver").First(&accessRequest, "id = ?", accessRequest.ID)
+	models.DB.Preload("SecretItem").Preload("Folder").Preload("Applicant").Preload("Approver").First(&accessRequest, "id = ?", accessRequest.ID)
 
 	c.JSON(http.StatusOK, accessRequest)
 }
@@ -244,7 +338,7 @@ func RejectAccessRequest(c *gin.Context) {
 	}
 
 	// 重新查询以获取关联数据
-	models.DB.Preload("SecretItem").Preload("Applicant").Preload("Approver").First(&accessRequest, "id = ?", accessRequest.ID)
+	models.DB.Preload("SecretItem").Preload("Folder").Preload("Applicant").Preload("Approver").First(&accessRequest, "id = ?", accessRequest.ID)
 
 	c.JSON(http.StatusOK, accessRequest)
 }
@@ -259,27 +353,29 @@ func GetItemWithAccessCheck(c *gin.Context) {
 		return
 	}
 
-	// 检查是否有有效的访问申请
-	var accessRequest models.AccessRequest
-	if err := models.DB.Where("secret_item_id = ? AND applicant_id = ? AND status = ?",
-		id, user.ID, models.RequestStatusApproved).First(&accessRequest).Error; err != nil {
-		c.JSON(http.StatusForbidden, types.ErrorResponse{Error: "无访问权限，请先申请访问"})
+	var item models.SecretItem
+	if err := models.DB.Select("id", "folder_id").Where("id = ?", id).First(&item).Error; err != nil {
+		c.JSON(http.StatusNotFound, types.ErrorResponse{Error: "信息项不存在"})
 		return
 	}
 
-	// 检查申请是否有效
-	if !accessRequest.CanAccess() {
-		c.JSON(http.StatusForbidden, types.ErrorResponse{Error: "访问权限已过期，请重新申请"})
+	// 检查是否有有效的访问申请，对所在文件夹及其上级文件夹的申请同样有效
+	accessRequest, err := models.FindValidAccessRequest(user.ID, &item)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "查询失败"})
+		return
+	}
+	if accessRequest == nil {
+		c.JSON(http.StatusForbidden, types.ErrorResponse{Error: "无访问权限或访问权限已过期，请先申请访问"})
 		return
 	}
 
 	// 更新访问记录
 	accessRequest.AccessCount++
 	accessRequest.LastAccessed = uint64(time.Now().Unix())
-	models.DB.Save(&accessRequest)
+	models.DB.Save(accessRequest)
 
 	// 获取密钥项详情
-	var item models.SecretItem
 	if err := models.DB.
 		Preload("Creator").
 		Preload("Updater").
@@ -289,6 +385,49 @@ func GetItemWithAccessCheck(c *gin.Context) {
 		c.JSON(http.StatusNotFound, types.ErrorResponse{Error: "信息项不存在"})
 		return
 	}
+	if err := item.LoadBreadcrumbs(); err != nil {
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "查询失败"})
+		return
+	}
+
+	if item.DataUnavailable() {
+		c.JSON(http.StatusServiceUnavailable, types.ErrorResponse{Error: fmt.Sprintf("当前部署未持有环境 '%s' 的主密钥", item.Environment)})
+		return
+	}
+
+	// 端到端加密信息项返回申请人的包装密钥
+	if err := item.LoadWrappedKey(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "查询失败"})
+		return
+	}
+
+	// 数据库连接只返回签发给申请人的临时用户，不返回管理员凭据
+	if item.Type == models.SecretTypeDatabase {
+		lease, err := models.FindActiveDatabaseLease(user.ID, item.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "查询失败"})
+			return
+		}
+		if lease == nil {
+			c.JSON(http.StatusForbidden, types.ErrorResponse{Error: "数据库连接需要对信息项单独申请访问，批准后签发临时数据库用户"})
+			return
+		}
+		if item.Data, err = lease.Credentials(&item); err != nil {
+			c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "查询失败"})
+			return
+		}
+		item.Lease = lease
+	}
+
+	// TOTP种子不返回给申请人，申请人通过 /items/:id/totp 获取当前验证码
+	if item.Type == models.SecretTypeTOTP {
+		item.Data = nil
+	}
+
+	// 被引用的信息项同样需要申请人有访问权限
+	if !resolveSecretReferences(c, user, &item) {
+		return
+	}
 
 	c.JSON(http.StatusOK, item)
 }
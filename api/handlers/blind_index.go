@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/akinoccc/hysaif/api/models"
+	"github.com/akinoccc/hysaif/api/packages/context"
+	"github.com/akinoccc/hysaif/api/packages/crypto"
+	"github.com/akinoccc/hysaif/api/packages/validation"
+	"github.com/akinoccc/hysaif/api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LookupSecretItems 通过盲索引精确查找包含指定字段值的信息项，只返回信息项元数据，不解密也不返回明文；
+// 除超级管理员外只返回用户可以查看的信息项，避免通过查找结果确认其他信息项中的密码
+func LookupSecretItems(c *gin.Context) {
+	var req types.LookupSecretItemsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validation.HandleValidationErrors(c, err)
+		return
+	}
+
+	if !crypto.BlindIndexEnabled() {
+		c.JSON(http.StatusServiceUnavailable, types.ErrorResponse{Error: "未配置盲索引密钥"})
+		return
+	}
+
+	matches, err := models.FindSecretItemsByBlindIndex(req.Fields, req.Value)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "查找失败"})
+		return
+	}
+
+	results := []types.SecretItemLookupResult{}
+	if len(matches) > 0 {
+		ids := make([]string, 0, len(matches))
+		for id := range matches {
+			ids = append(ids, id)
+		}
+
+		// 不查询data字段，避免解密敏感数据
+		var items []models.SecretItem
+		if err := models.DB.
+			Select("id", "name", "type", "category", "environment", "created_by_id", "folder_id").
+			Where("id IN ?", ids).
+			Order("name").
+			Find(&items).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "查找失败"})
+			return
+		}
+
+		user := context.GetCurrentUser(c)
+		for _, item := range items {
+			if !user.IsAdmin() {
+				canAccess, err := user.CanAccessSecretItem(&item)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "查找失败"})
+					return
+				}
+				if !canAccess {
+					continue
+				}
+			}
+			results = append(results, types.SecretItemLookupResult{
+				ID:            item.ID,
+				Name:          item.Name,
+				Type:          item.Type,
+				Category:      item.Category,
+				Environment:   item.Environment,
+				MatchedFields: matches[item.ID],
+			})
+		}
+	}
+
+	c.JSON(http.StatusOK, types.LookupSecretItemsResponse{Data: results})
+}
+
+// RebuildBlindIndexes 为所有信息项重建盲索引
+func RebuildBlindIndexes(c *gin.Context) {
+	if !crypto.BlindIndexEnabled() {
+		c.JSON(http.StatusServiceUnavailable, types.ErrorResponse{Error: "未配置盲索引密钥"})
+		return
+	}
+
+	count, err := models.RebuildBlindIndexes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "重建盲索引失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, types.RebuildBlindIndexResponse{Items: count})
+}
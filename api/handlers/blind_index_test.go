@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/akinoccc/hysaif/api/models"
+	"github.com/akinoccc/hysaif/api/types"
+)
+
+func TestLookupSecretItemsOnlyReturnsAccessibleItems(t *testing.T) {
+	creator := createTestUser(t, "sec_mgr")
+	other := createTestUser(t, "sec_mgr")
+	admin := createTestUser(t, models.RoleSuperAdmin)
+
+	const password = "Lk3#vQ9!zP2$wT7mR"
+	item := map[string]any{
+		"name": "lookup-password", "type": "password", "category": "lookup-test", "environment": "development",
+		"data": map[string]any{"username": "deploy", "password": password},
+	}
+	code, body := performRequest(t, creator, http.MethodPost, "/items", "/items", item, CreateSecretItem)
+	if code != http.StatusCreated {
+		t.Fatalf("创建信息项失败: %d %s", code, body)
+	}
+
+	tests := []struct {
+		name  string
+		user  *models.User
+		value string
+		count int
+	}{
+		{"创建者可以查找", creator, password, 1},
+		{"查找时去除首尾空白", creator, "  " + password + "\n", 1},
+		{"密码区分大小写", creator, strings.ToLower(password), 0},
+		{"超级管理员可以查找", admin, password, 1},
+		{"无权查看的用户查找不到", other, password, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := types.LookupSecretItemsRequest{Value: tt.value, Fields: []string{"password"}}
+			code, body := performRequest(t, tt.user, http.MethodPost, "/items/lookup", "/items/lookup", req, LookupSecretItems)
+			if code != http.StatusOK {
+				t.Fatalf("查找失败: %d %s", code, body)
+			}
+			var resp types.LookupSecretItemsResponse
+			if err := json.Unmarshal(body, &resp); err != nil {
+				t.Fatalf("解析响应失败: %v", err)
+			}
+			if len(resp.Data) != tt.count {
+				t.Fatalf("查找到 %d 个信息项，期望 %d 个: %s", len(resp.Data), tt.count, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/akinoccc/hysaif/api/models"
+	"github.com/akinoccc/hysaif/api/packages/context"
+	"github.com/akinoccc/hysaif/api/packages/validation"
+	"github.com/akinoccc/hysaif/api/types"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetFolders 获取全部文件夹，按路径排序，用于渲染文件夹树
+func GetFolders(c *gin.Context) {
+	var folders []models.Folder
+	if err := models.DB.Preload("Creator").Order("path").Find(&folders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "获取文件夹列表失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, types.FolderListResponse{Data: folders})
+}
+
+// GetFolder 获取文件夹详情及其面包屑路径
+func GetFolder(c *gin.Context) {
+	folder, ok := loadFolder(c)
+	if !ok {
+		return
+	}
+
+	breadcrumbs, err := folder.Breadcrumbs()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "获取文件夹失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, types.FolderResponse{Folder: *folder, Breadcrumbs: breadcrumbs})
+}
+
+// CreateFolder 创建文件夹，需要对上级文件夹的创建权限，在根目录创建需要 folder 资源的创建权限
+func CreateFolder(c *gin.Context) {
+	user := context.GetCurrentUser(c)
+
+	var req types.CreateFolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validation.HandleValidationErrors(c, err)
+		return
+	}
+
+	parent, ok := loadTargetFolder(c, req.ParentID)
+	if !ok {
+		return
+	}
+	path := ""
+	if parent != nil {
+		path = parent.Path
+	}
+	if !user.HasFolderPermission(path, "create") {
+		c.JSON(http.StatusForbidden, types.ErrorResponse{Error: "权限不足，无法在该文件夹下创建文件夹"})
+		return
+	}
+
+	folder, err := models.CreateFolder(req.Name, req.Description, parent, user.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrFolderExists) {
+			c.JSON(http.StatusConflict, types.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, folder)
+}
+
+// DeleteFolder 删除空文件夹，仍有子文件夹或信息项时返回409
+func DeleteFolder(c *gin.Context) {
+	user := context.GetCurrentUser(c)
+
+	folder, ok := loadFolder(c)
+	if !ok {
+		return
+	}
+	if !user.HasFolderPermission(folder.Path, "delete") {
+		c.JSON(http.StatusForbidden, types.ErrorResponse{Error: "权限不足，无法删除该文件夹"})
+		return
+	}
+
+	if err := folder.Delete(); err != nil {
+		if errors.Is(err, models.ErrFolderNotEmpty) {
+			c.JSON(http.StatusConflict, types.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "删除文件夹失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, types.MessageResponse{Message: "删除成功"})
+}
+
+// loadFolder 根据路径中的ID加载文件夹，不存在时返回404
+func loadFolder(c *gin.Context) (*models.Folder, bool) {
+	folder, err := models.GetFolder(c.Param("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, types.ErrorResponse{Error: "文件夹不存在"})
+		} else {
+			c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "获取文件夹失败"})
+		}
+		return nil, false
+	}
+	return folder, true
+}
+
+// loadTargetFolder 加载请求中指定的文件夹，ID为空表示根目录，返回 nil；文件夹不存在时返回400
+func loadTargetFolder(c *gin.Context, id string) (*models.Folder, bool) {
+	if id == "" {
+		return nil, true
+	}
+	folder, err := models.GetFolder(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: "文件夹不存在"})
+		} else {
+			c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "获取文件夹失败"})
+		}
+		return nil, false
+	}
+	return folder, true
+}
+
+// folderPath 获取文件夹的显示路径，用于变更记录和审计日志
+func folderPath(folder *models.Folder) string {
+	if folder == nil {
+		return models.FolderRootName
+	}
+	return folder.Path
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/akinoccc/hysaif/api/models"
+	"github.com/akinoccc/hysaif/api/packages/password"
+	"github.com/akinoccc/hysaif/api/packages/validation"
+	"github.com/akinoccc/hysaif/api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GenerateSSHKey 生成SSH密钥对，生成的密钥不会保存在服务端
+func GenerateSSHKey(c *gin.Context) {
+	var req types.GenerateSSHKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validation.HandleValidationErrors(c, err)
+		return
+	}
+
+	keyPair, err := models.GenerateSSHKey(req.Algorithm, req.Bits, req.Comment, req.Passphrase)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, keyPair)
+}
+
+// GeneratePassword 生成随机密码或口令短语并返回估算的强度，生成的密码不会保存在服务端
+func GeneratePassword(c *gin.Context) {
+	var req types.GeneratePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validation.HandleValidationErrors(c, err)
+		return
+	}
+
+	var (
+		generated string
+		err       error
+	)
+	if req.Mode == "passphrase" {
+		opts := password.PassphraseOptions{
+			Words:         req.Words,
+			Separator:     req.Separator,
+			Capitalize:    req.Capitalize,
+			IncludeNumber: req.IncludeNumber,
+		}
+		if opts.Words == 0 {
+			opts.Words = password.DefaultWords
+		}
+		if opts.Separator == "" {
+			opts.Separator = password.DefaultSeparator
+		}
+		generated, err = password.GeneratePassphrase(opts)
+	} else {
+		opts := password.GenerateOptions{Length: req.Length, ExcludeAmbiguous: req.ExcludeAmbiguous}
+		if opts.Length == 0 {
+			opts.Length = password.DefaultLength
+		}
+		classes := req.Classes
+		if len(classes) == 0 {
+			classes = []string{"lowercase", "uppercase", "digits", "symbols"}
+		}
+		for _, class := range classes {
+			switch class {
+			case "lowercase":
+				opts.Lowercase = true
+			case "uppercase":
+				opts.Uppercase = true
+			case "digits":
+				opts.Digits = true
+			case "symbols":
+				opts.Symbols = true
+			}
+		}
+		generated, err = password.Generate(opts)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, types.GeneratePasswordResponse{
+		Password: generated,
+		Strength: password.Estimate(generated, nil),
+	})
+}
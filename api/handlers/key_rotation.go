@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+
+	"github.com/akinoccc/hysaif/api/models"
+	"github.com/akinoccc/hysaif/api/packages/context"
+	"github.com/akinoccc/hysaif/api/packages/crypto"
+	"github.com/akinoccc/hysaif/api/packages/rekey"
+	"github.com/akinoccc/hysaif/api/packages/validation"
+	"github.com/akinoccc/hysaif/api/types"
+	"gorm.io/gorm"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetKeyRotationStatus 获取主密钥轮换进度
+func GetKeyRotationStatus(c *gin.Context) {
+	activeKey, err := crypto.ActiveKeyInfo()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "获取活动主密钥失败: " + err.Error()})
+		return
+	}
+
+	response := types.KeyRotationStatusResponse{
+		ActiveKey:        activeKey,
+		Running:          rekey.IsRunning(),
+		EncryptionPolicy: crypto.PolicyFor(""),
+		FallbackCount:    crypto.FallbackCount(),
+	}
+
+	job, err := rekey.GetLatestJob(c.Query("type"))
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "查询密钥轮换任务失败"})
+		return
+	}
+	if job != nil {
+		response.Job = job
+		response.Progress = job.Progress()
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// StartKeyRotation 启动主密钥轮换任务，将所有加密数据重新包装到当前活动主密钥
+func StartKeyRotation(c *gin.Context) {
+	user := context.GetCurrentUser(c)
+
+	job, err := rekey.Start(user.ID)
+	if err != nil {
+		if errors.Is(err, rekey.ErrJobRunning) {
+			c.JSON(http.StatusConflict, types.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// GetVaultKeyStatus 获取全局及各环境独立的Transit密钥版本和重新包装进度
+func GetVaultKeyStatus(c *gin.Context) {
+	if !crypto.IsVaultEnabled() {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: "未启用Vault"})
+		return
+	}
+
+	job, err := rekey.GetLatestJob(models.KeyRotationTypeVaultRewrap)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "查询重新包装任务失败"})
+		return
+	}
+
+	response := types.VaultKeyStatusResponse{Running: rekey.IsRunning()}
+	for _, keyName := range crypto.TransitKeyNames() {
+		keyInfo, err := crypto.GetTransitKeyInfo(keyName)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: err.Error()})
+			return
+		}
+		status := types.VaultTransitKeyStatus{TransitKeyInfo: keyInfo}
+		if job != nil {
+			status.SafeMinDecryptionVersion = rekey.SafeMinDecryptionVersion(job, keyName)
+		}
+		response.TransitKeys = append(response.TransitKeys, status)
+	}
+	if job != nil {
+		response.Job = job
+		response.Progress = job.Progress()
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// RotateVaultKey 轮换Transit密钥并启动后台任务，通过 transit/rewrap 重新包装所有Vault密文
+func RotateVaultKey(c *gin.Context) {
+	user := context.GetCurrentUser(c)
+
+	if !crypto.IsVaultEnabled() {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: "未启用Vault"})
+		return
+	}
+
+	var req types.VaultRewrapRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		validation.HandleValidationErrors(c, err)
+		return
+	}
+	if req.KeyName != "" && !slices.Contains(crypto.TransitKeyNames(), req.KeyName) {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: "未配置的Transit密钥: " + req.KeyName})
+		return
+	}
+
+	job, err := rekey.StartVaultRewrap(user.ID, req.KeyName, !req.SkipRotate)
+	if err != nil {
+		if errors.Is(err, rekey.ErrJobRunning) {
+			c.JSON(http.StatusConflict, types.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// SetVaultMinDecryptionVersion 设置Transit密钥的最低解密版本，版本不能高于最近一次完整重新包装后该密钥仍在使用的最低版本
+func SetVaultMinDecryptionVersion(c *gin.Context) {
+	if !crypto.IsVaultEnabled() {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: "未启用Vault"})
+		return
+	}
+
+	var req types.SetMinDecryptionVersionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validation.HandleValidationErrors(c, err)
+		return
+	}
+
+	keyNames := crypto.TransitKeyNames()
+	keyName := req.KeyName
+	if keyName == "" {
+		keyName = keyNames[0]
+	}
+	if !slices.Contains(keyNames, keyName) {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: "未配置的Transit密钥: " + keyName})
+		return
+	}
+
+	if rekey.IsRunning() {
+		c.JSON(http.StatusConflict, types.ErrorResponse{Error: rekey.ErrJobRunning.Error()})
+		return
+	}
+
+	job, err := rekey.GetLatestJob(models.KeyRotationTypeVaultRewrap)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: "尚未完成重新包装任务，无法确定安全的最低解密版本"})
+		return
+	}
+
+	safeVersion := rekey.SafeMinDecryptionVersion(job, keyName)
+	if safeVersion == 0 || req.Version > safeVersion {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error: fmt.Sprintf("Transit密钥 %s 的最低解密版本不能高于 %d，否则部分数据将无法解密", keyName, safeVersion),
+		})
+		return
+	}
+
+	if err := crypto.SetTransitMinDecryptionVersion(keyName, req.Version); err != nil {
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	keyInfo, err := crypto.GetTransitKeyInfo(keyName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, keyInfo)
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/akinoccc/hysaif/api/config"
+	"github.com/akinoccc/hysaif/api/models"
+	"github.com/akinoccc/hysaif/api/packages/permission"
+	"github.com/akinoccc/hysaif/api/packages/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm/logger"
+)
+
+// TestMain 使用内存SQLite数据库和本地AES主密钥初始化处理函数测试
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+
+	config.AppConfig = &config.Config{}
+	config.AppConfig.Database.Type = "sqlite"
+	config.AppConfig.Database.Path = "file:handlers_test?mode=memory&cache=shared"
+	config.AppConfig.Security.EncryptionKey = "0123456789abcdef0123456789abcdef"
+	config.AppConfig.Security.KeyProvider = "aes"
+	config.AppConfig.RBACConfig = "../rbac_model.conf"
+
+	models.InitDB()
+	models.DB.Logger = logger.Discard
+	if err := validation.RegisterValidators(); err != nil {
+		panic(err)
+	}
+	permission.GetCasbinManager(models.DB)
+
+	os.Exit(m.Run())
+}
+
+// createTestUser 创建指定角色的用户
+func createTestUser(t *testing.T, role string) *models.User {
+	t.Helper()
+	name := role + "-" + uuid.NewString()[:8]
+	user := &models.User{Name: name, Email: name + "@example.com", Role: role, Status: "active"}
+	if err := models.DB.Omit("created_by", "updated_by").Create(user).Error; err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	return user
+}
+
+// performRequest 以指定用户调用处理函数，返回状态码和响应体
+func performRequest(t *testing.T, user *models.User, method, route, path string, body any, handlers ...gin.HandlerFunc) (int, []byte) {
+	t.Helper()
+	r := gin.New()
+	chain := append([]gin.HandlerFunc{func(c *gin.Context) {
+		c.Set("user", *user)
+		c.Next()
+	}}, handlers...)
+	r.Handle(method, route, chain...)
+
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("序列化请求体失败: %v", err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code, w.Body.Bytes()
+}
+
+// grantPermissions 为角色添加权限策略，测试结束后移除
+func grantPermissions(t *testing.T, role string, policies ...[2]string) {
+	t.Helper()
+	manager := permission.GetCasbinManager(models.DB)
+	for _, policy := range policies {
+		if err := manager.AddPolicy(role, policy[0], policy[1]); err != nil {
+			t.Fatalf("添加权限策略失败: %v", err)
+		}
+		t.Cleanup(func() { _ = manager.RemovePolicy(role, policy[0], policy[1]) })
+	}
+}
@@ -347,6 +347,7 @@ func GetUserAllPermissions(c *gin.Context) {
 		{Role: user.Role, Resource: "secret", Action: "delete"},
 		{Role: user.Role, Resource: "secret", Action: "request"},
 		{Role: user.Role, Resource: "secret", Action: "temp"},
+		{Role: user.Role, Resource: "secret", Action: "lookup"},
 
 		// 访问申请权限
 		{Role: user.Role, Resource: "access_request", Action: "read"},
@@ -362,6 +363,32 @@ func GetUserAllPermissions(c *gin.Context) {
 		{Role: user.Role, Resource: "notification", Action: "bulk_send"},
 		{Role: user.Role, Resource: "notification", Action: "view_templates"},
 
+		// 密钥管理权限
+		{Role: user.Role, Resource: "key_management", Action: "read"},
+		{Role: user.Role, Resource: "key_management", Action: "rotate"},
+
+		// 托管密钥权限（单个密钥的权限使用 transit:<密钥名> 资源授予）
+		{Role: user.Role, Resource: "transit", Action: "read"},
+		{Role: user.Role, Resource: "transit", Action: "create"},
+		{Role: user.Role, Resource: "transit", Action: "rotate"},
+		{Role: user.Role, Resource: "transit", Action: "update"},
+		{Role: user.Role, Resource: "transit", Action: "encrypt"},
+		{Role: user.Role, Resource: "transit", Action: "decrypt"},
+		{Role: user.Role, Resource: "transit", Action: "rewrap"},
+
+		// 自定义信息项类型管理权限
+		{Role: user.Role, Resource: "secret_type", Action: "create"},
+		{Role: user.Role, Resource: "secret_type", Action: "update"},
+		{Role: user.Role, Resource: "secret_type", Action: "delete"},
+
+		// 文件夹权限（单个文件夹的权限使用 folder:<路径> 资源授予，由子文件夹和其中的信息项继承）
+		{Role: user.Role, Resource: "folder", Action: "read"},
+		{Role: user.Role, Resource: "folder", Action: "create"},
+		{Role: user.Role, Resource: "folder", Action: "delete"},
+
+		// 系统密封权限
+		{Role: user.Role, Resource: "system", Action: "seal"},
+
 		// KV 键值对资源权限
 		{Role: user.Role, Resource: "kv", Action: "read"},
 		{Role: user.Role, Resource: "kv", Action: "create"},
@@ -462,6 +489,25 @@ func GetUserAccessibleMenus(c *gin.Context) {
 		"/notifications":   {resource: "notification", action: "read"},
 	}
 
+	// 管理员定义的自定义类型按创建顺序追加在内置类型之后
+	secretTypes, err := models.ListSecretTypes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取信息项类型失败"})
+		return
+	}
+	for _, secretType := range secretTypes {
+		icon := secretType.Icon
+		if icon == "" {
+			icon = "Braces"
+		}
+		path := "/" + secretType.Name
+		allMenus = append(allMenus, MenuItemResponse{Path: path, Title: secretType.Title, Icon: icon, Order: len(allMenus) + 1})
+		menuPermissions[path] = struct {
+			resource string
+			action   string
+		}{resource: "secret", action: "read"}
+	}
+
 	// 过滤用户可访问的菜单 - 完全基于Casbin动态权限检查
 	var accessibleMenus []MenuItemResponse
 	for _, menu := range allMenus {
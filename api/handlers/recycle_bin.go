@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/akinoccc/hysaif/api/middleware"
+	"github.com/akinoccc/hysaif/api/models"
+	"github.com/akinoccc/hysaif/api/packages/context"
+	"github.com/akinoccc/hysaif/api/packages/crypto"
+	"github.com/akinoccc/hysaif/api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetRecycleBinItems 获取回收站中的信息项，管理员可以查看所有信息项，其他用户只能查看自己创建的信息项
+func GetRecycleBinItems(c *gin.Context) {
+	user := context.GetCurrentUser(c)
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+
+	offset := (page - 1) * pageSize
+
+	query := models.DeletedSecretItems()
+	if !user.IsAdmin() {
+		query = query.Where("created_by_id = ?", user.ID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	// 回收站列表不返回敏感数据
+	var items []models.SecretItem
+	if err := query.
+		Omit("data", "payload").
+		Preload("Creator").
+		Preload("Deleter").
+		Order("deleted_at DESC").
+		Offset(offset).
+		Limit(pageSize).
+		Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "查询失败"})
+		return
+	}
+
+	data := make([]types.RecycleBinItem, 0, len(items))
+	for _, item := range items {
+		data = append(data, types.RecycleBinItem{
+			ID:          item.ID,
+			Name:        item.Name,
+			Type:        item.Type,
+			Category:    item.Category,
+			Environment: item.Environment,
+			FolderID:    item.FolderID,
+			Creator:     item.Creator,
+			DeletedBy:   item.Deleter,
+			DeletedAt:   uint64(item.DeletedAt.Time.UnixMilli()),
+			PurgeAt:     uint64(item.PurgeAt().UnixMilli()),
+		})
+	}
+
+	c.JSON(http.StatusOK, types.ListResponse[types.RecycleBinItem]{
+		Data: data,
+		Pagination: types.Pagination{
+			Page:       page,
+			PageSize:   pageSize,
+			Total:      int(total),
+			TotalPages: int(math.Ceil(float64(total) / float64(pageSize))),
+		},
+	})
+}
+
+// RestoreRecycleBinItem 从回收站恢复信息项，只有创建者和管理员可以恢复
+func RestoreRecycleBinItem(c *gin.Context) {
+	user := context.GetCurrentUser(c)
+
+	item, ok := loadRecycleBinItem(c, user)
+	if !ok {
+		return
+	}
+
+	if err := item.Restore(); err != nil {
+		if errors.Is(err, models.ErrRetentionExpired) {
+			c.JSON(http.StatusGone, types.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "恢复失败"})
+		return
+	}
+
+	// 创建恢复历史版本
+	item.CreateHistory(models.HistoryChangeTypeRestored, "从回收站恢复", user.ID)
+
+	middleware.AuditLog(types.AuditLogActionRestore, middleware.GetSecretResourceType(item.Type))(c)
+
+	models.DB.Preload("Creator").Preload("Updater").First(item, "id = ?", item.ID)
+	item.LoadBreadcrumbs()
+	c.JSON(http.StatusOK, item)
+}
+
+// PurgeRecycleBinItem 立即清除回收站中的信息项及其所有历史版本，用于处理已泄露的敏感数据，只有创建者和管理员可以清除
+func PurgeRecycleBinItem(c *gin.Context) {
+	user := context.GetCurrentUser(c)
+
+	item, ok := loadRecycleBinItem(c, user)
+	if !ok {
+		return
+	}
+
+	// 清除失败同样记录审计日志
+	err := item.Purge()
+	auditDetails := map[string]interface{}{
+		"name":          item.Name,
+		"deleted_by_id": item.DeletedByID,
+		"deleted_at":    uint64(item.DeletedAt.Time.UnixMilli()),
+	}
+	if err != nil {
+		auditDetails["error"] = err.Error()
+	}
+	details, _ := json.Marshal(auditDetails)
+	middleware.LogUserAction(user.ID, types.AuditLogActionPurge, middleware.GetSecretResourceType(item.Type),
+		item.ID, string(details), c.ClientIP(), c.GetHeader("User-Agent"))
+
+	if errors.Is(err, models.ErrVaultKVNotDestroyed) {
+		c.JSON(http.StatusOK, types.MessageResponse{Message: err.Error()})
+		return
+	}
+	if err != nil {
+		if errors.Is(err, crypto.ErrBackendUnavailable) || errors.Is(err, crypto.ErrSealed) {
+			c.JSON(http.StatusServiceUnavailable, types.ErrorResponse{Error: "清除失败: " + err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "清除失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, types.MessageResponse{Message: "清除成功"})
+}
+
+// loadRecycleBinItem 加载回收站中的信息项并检查当前用户能否恢复或清除
+func loadRecycleBinItem(c *gin.Context, user *models.User) (*models.SecretItem, bool) {
+	item, err := models.GetDeletedSecretItem(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, types.ErrorResponse{Error: "回收站中不存在该信息项"})
+		return nil, false
+	}
+	if !user.CanManageDeleted(item) {
+		c.JSON(http.StatusForbidden, types.ErrorResponse{Error: "只有创建者和管理员可以恢复或清除信息项"})
+		return nil, false
+	}
+	return item, true
+}
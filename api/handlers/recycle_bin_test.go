@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/akinoccc/hysaif/api/models"
+)
+
+func TestRecycleBinAccessRequests(t *testing.T) {
+	creator := createTestUser(t, "sec_mgr")
+	applicant := createTestUser(t, "dev")
+
+	code, body := performRequest(t, creator, http.MethodPost, "/items", "/items", map[string]any{
+		"name": "recycled-token", "type": "token", "category": "recycle-test", "environment": "development",
+		"data": map[string]any{"token": "tok-recycled"},
+	}, CreateSecretItem)
+	if code != http.StatusCreated {
+		t.Fatalf("创建信息项失败: %d %s", code, body)
+	}
+	var item models.SecretItem
+	if err := json.Unmarshal(body, &item); err != nil {
+		t.Fatalf("解析响应失败: %v", err)
+	}
+
+	request := models.AccessRequest{
+		SecretItemID: item.ID,
+		ApplicantID:  applicant.ID,
+		Reason:       "排查线上问题",
+		Status:       models.RequestStatusPending,
+	}
+	if err := models.DB.Create(&request).Error; err != nil {
+		t.Fatalf("创建访问申请失败: %v", err)
+	}
+	if err := item.MoveToRecycleBin(creator.ID); err != nil {
+		t.Fatalf("移入回收站失败: %v", err)
+	}
+
+	t.Run("回收站中的信息项不能批准访问申请", func(t *testing.T) {
+		code, body := performRequest(t, creator, http.MethodPost, "/requests/:id/approve", "/requests/"+request.ID+"/approve",
+			map[string]any{"valid_duration": 1}, ApproveAccessRequest)
+		if code != http.StatusGone {
+			t.Fatalf("期望410，实际 %d %s", code, body)
+		}
+		var stored models.AccessRequest
+		if err := models.DB.First(&stored, "id = ?", request.ID).Error; err != nil {
+			t.Fatalf("查询访问申请失败: %v", err)
+		}
+		if stored.Status != models.RequestStatusPending {
+			t.Fatalf("访问申请状态为 %s，期望保持待审批", stored.Status)
+		}
+	})
+
+	t.Run("清除时删除访问申请", func(t *testing.T) {
+		code, body := performRequest(t, creator, http.MethodDelete, "/recycle-bin/:id", "/recycle-bin/"+item.ID, nil, PurgeRecycleBinItem)
+		if code != http.StatusOK {
+			t.Fatalf("清除失败: %d %s", code, body)
+		}
+		var count int64
+		models.DB.Model(&models.AccessRequest{}).Where("secret_item_id = ?", item.ID).Count(&count)
+		if count != 0 {
+			t.Fatalf("清除后仍有 %d 条访问申请", count)
+		}
+	})
+}
+
+func TestRestoreRecycleBinItem(t *testing.T) {
+	creator := createTestUser(t, "sec_mgr")
+	other := createTestUser(t, "dev")
+
+	tests := []struct {
+		name       string
+		user       *models.User
+		deletedAgo time.Duration // 移入回收站的时长，为0时不移入回收站
+		wantCode   int
+	}{
+		{"保留期限内恢复", creator, time.Hour, http.StatusOK},
+		{"超过保留期限无法恢复", creator, models.RecycleBinRetention() + time.Hour, http.StatusGone},
+		{"非创建者不能恢复", other, time.Hour, http.StatusForbidden},
+		{"不在回收站中", creator, 0, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := performRequest(t, creator, http.MethodPost, "/items", "/items", map[string]any{
+				"name": "restore-" + tt.name, "type": "token", "category": "recycle-test", "environment": "development",
+				"data": map[string]any{"token": "tok-restore"},
+			}, CreateSecretItem)
+			if code != http.StatusCreated {
+				t.Fatalf("创建信息项失败: %d %s", code, body)
+			}
+			var item models.SecretItem
+			if err := json.Unmarshal(body, &item); err != nil {
+				t.Fatalf("解析响应失败: %v", err)
+			}
+
+			if tt.deletedAgo > 0 {
+				if err := item.MoveToRecycleBin(creator.ID); err != nil {
+					t.Fatalf("移入回收站失败: %v", err)
+				}
+				if err := models.DB.Unscoped().Model(&models.SecretItem{}).Where("id = ?", item.ID).
+					UpdateColumn("deleted_at", time.Now().Add(-tt.deletedAgo)).Error; err != nil {
+					t.Fatalf("修改删除时间失败: %v", err)
+				}
+			}
+
+			code, body = performRequest(t, tt.user, http.MethodPost, "/recycle-bin/:id/restore", "/recycle-bin/"+item.ID+"/restore", nil, RestoreRecycleBinItem)
+			if code != tt.wantCode {
+				t.Fatalf("期望%d，实际 %d %s", tt.wantCode, code, body)
+			}
+
+			// 只有恢复成功时信息项离开回收站
+			var deleted int64
+			models.DeletedSecretItems().Where("id = ?", item.ID).Count(&deleted)
+			if wantDeleted := tt.deletedAgo > 0 && tt.wantCode != http.StatusOK; (deleted == 1) != wantDeleted {
+				t.Fatalf("信息项在回收站中 = %v，期望 %v", deleted == 1, wantDeleted)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/akinoccc/hysaif/api/middleware"
+	"github.com/akinoccc/hysaif/api/models"
+	"github.com/akinoccc/hysaif/api/packages/context"
+	"github.com/akinoccc/hysaif/api/packages/rotation"
+	"github.com/akinoccc/hysaif/api/packages/validation"
+	"github.com/akinoccc/hysaif/api/types"
+	"gorm.io/gorm"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetRotationPolicy 获取信息项的轮换策略
+func GetRotationPolicy(c *gin.Context) {
+	item, ok := loadAccessibleSecretItem(c)
+	if !ok {
+		return
+	}
+
+	policy, ok := loadRotationPolicy(c, item.ID)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+// PutRotationPolicy 创建或更新信息项的轮换策略，保存前校验轮换器是否适用于信息项
+func PutRotationPolicy(c *gin.Context) {
+	user := context.GetCurrentUser(c)
+
+	var req types.PutRotationPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validation.HandleValidationErrors(c, err)
+		return
+	}
+
+	var item models.SecretItem
+	if err := models.DB.Where("id = ?", c.Param("id")).First(&item).Error; err != nil {
+		c.JSON(http.StatusNotFound, types.ErrorResponse{Error: "信息项不存在"})
+		return
+	}
+
+	action := types.AuditLogActionUpdate
+	policy, err := models.GetSecretRotationPolicy(item.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		action = types.AuditLogActionCreate
+		policy = &models.SecretRotationPolicy{SecretItemID: item.ID, CreatedByID: user.ID}
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "查询失败"})
+		return
+	}
+
+	policy.Enabled = req.Enabled
+	policy.Rotator = req.Rotator
+	policy.IntervalHours = req.IntervalHours
+	policy.WindowStart = req.WindowStart
+	policy.WindowEnd = req.WindowEnd
+	policy.Options = req.Options
+	policy.UpdatedByID = user.ID
+	if err := rotation.ValidatePolicy(&item, policy); err != nil {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	// 下次轮换时间从最近一次成功轮换开始计算，从未轮换时从现在开始计算
+	from := time.Now()
+	if policy.LastRotatedAt != 0 {
+		from = time.UnixMilli(int64(policy.LastRotatedAt))
+	}
+	policy.Schedule(from)
+
+	if err := models.DB.Save(policy).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "保存失败"})
+		return
+	}
+
+	middleware.AuditLog(action, types.AuditLogResourceRotation)(c)
+
+	c.JSON(http.StatusOK, policy)
+}
+
+// DeleteRotationPolicy 删除信息项的轮换策略
+func DeleteRotationPolicy(c *gin.Context) {
+	policy, ok := loadRotationPolicy(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	if err := models.DB.Delete(policy).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "删除失败"})
+		return
+	}
+
+	middleware.AuditLog(types.AuditLogActionDelete, types.AuditLogResourceRotation)(c)
+
+	c.JSON(http.StatusOK, types.MessageResponse{Message: "删除成功"})
+}
+
+// RotateSecretItem 按轮换策略立即轮换信息项，未启用自动轮换的策略同样可以手动轮换
+func RotateSecretItem(c *gin.Context) {
+	user := context.GetCurrentUser(c)
+
+	var req types.RotateSecretItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		validation.HandleValidationErrors(c, err)
+		return
+	}
+
+	var item models.SecretItem
+	if err := models.DB.Select("id", "type").Where("id = ?", c.Param("id")).First(&item).Error; err != nil {
+		c.JSON(http.StatusNotFound, types.ErrorResponse{Error: "信息项不存在"})
+		return
+	}
+	policy, ok := loadRotationPolicy(c, item.ID)
+	if !ok {
+		return
+	}
+
+	reason := "手动轮换"
+	if req.Reason != "" {
+		reason += "：" + req.Reason
+	}
+	rotated, err := rotation.Rotate(policy, user.ID, reason)
+
+	// 失败的轮换同样记录审计日志
+	auditDetails := map[string]interface{}{
+		"policy_id": policy.ID,
+		"rotator":   policy.Rotator,
+		"reason":    req.Reason,
+	}
+	if err != nil {
+		auditDetails["error"] = err.Error()
+	} else {
+		auditDetails["version"] = rotated.Version
+	}
+	details, _ := json.Marshal(auditDetails)
+	middleware.LogUserAction(user.ID, types.AuditLogActionRotate, middleware.GetSecretResourceType(item.Type),
+		item.ID, string(details), c.ClientIP(), c.GetHeader("User-Agent"))
+
+	if err != nil {
+		respondRotationError(c, err)
+		return
+	}
+
+	// 重新查询以获取关联数据
+	models.DB.Preload("Creator").Preload("Updater").First(rotated, "id = ?", rotated.ID)
+
+	c.JSON(http.StatusOK, rotated)
+}
+
+// loadRotationPolicy 加载信息项的轮换策略，不存在时返回404
+func loadRotationPolicy(c *gin.Context, secretItemID string) (*models.SecretRotationPolicy, bool) {
+	policy, err := models.GetSecretRotationPolicy(secretItemID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, types.ErrorResponse{Error: "信息项未配置轮换策略"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "查询失败"})
+		return nil, false
+	}
+	return policy, true
+}
+
+// respondRotationError 返回轮换失败的响应，外部系统未能应用新值时返回502，轮换期间信息项被修改时返回409
+func respondRotationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, rotation.ErrNotRotatable):
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, rotation.ErrApplyFailed):
+		c.JSON(http.StatusBadGateway, types.ErrorResponse{Error: "轮换失败: " + err.Error()})
+	case errors.Is(err, models.ErrRotationConflict):
+		c.JSON(http.StatusConflict, types.ErrorResponse{Error: "轮换失败: " + err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, types.ErrorResponse{Error: "信息项不存在"})
+	default:
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "轮换失败: " + err.Error()})
+	}
+}
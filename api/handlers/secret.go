@@ -1,16 +1,17 @@
 package handlers
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
 	"net/http"
 	"strconv"
-	"time"
 
 	"github.com/akinoccc/hysaif/api/middleware"
 	"github.com/akinoccc/hysaif/api/models"
 	"github.com/akinoccc/hysaif/api/packages/context"
+	"github.com/akinoccc/hysaif/api/packages/crypto"
 	"github.com/akinoccc/hysaif/api/packages/query"
 	"github.com/akinoccc/hysaif/api/packages/validation"
 	"github.com/akinoccc/hysaif/api/types"
@@ -36,39 +37,23 @@ func GetSecretItems(c *gin.Context) {
 		return
 	}
 
-	// 批量查询用户对这些密钥项的访问权限
+	// 批量查询用户对这些密钥项的访问权限，包括通过文件夹申请继承的访问权限
 	if len(items) > 0 {
-		itemIDs := make([]string, len(items))
-		for i, item := range items {
-			itemIDs[i] = item.ID
+		// 查询失败时，默认设置为无访问权限
+		accessMap, _ := models.GetApprovedAccessSecretItemIDs(user.ID, items)
+
+		// 设置每个密钥项的访问权限标志
+		for i := range items {
+			items[i].HasApprovedAccess = accessMap[items[i].ID]
+			// 加载历史信息
+			items[i].LoadHistoryInfo()
 		}
 
-		var accessRequests []models.AccessRequest
-		now := uint64(time.Now().UnixMilli())
-		err := models.DB.Where(`secret_item_id IN (?) AND applicant_id = ? AND status = ? AND ? BETWEEN valid_from AND valid_until`,
-			itemIDs, user.ID, models.RequestStatusApproved, now).
-			Find(&accessRequests).Error
-
-		if err == nil {
-			// 创建映射表，快速查找用户对每个密钥项的访问权限
-			accessMap := make(map[string]bool)
-			for _, req := range accessRequests {
-				accessMap[req.SecretItemID] = true
-			}
+		redactListData(items)
 
-			// 设置每个密钥项的访问权限标志
-			for i := range items {
-				items[i].HasApprovedAccess = accessMap[items[i].ID]
-				// 加载历史信息
-				items[i].LoadHistoryInfo()
-			}
-		} else {
-			// 查询失败时，默认设置为无访问权限
-			for i := range items {
-				items[i].HasApprovedAccess = false
-				// 加载历史信息
-				items[i].LoadHistoryInfo()
-			}
+		if err := models.LoadFolderBreadcrumbs(items); err != nil {
+			c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "查询失败"})
+			return
 		}
 	}
 
@@ -105,7 +90,58 @@ func CreateSecretItem(c *gin.Context) {
 		UpdatedByID: user.ID,
 	}
 
-	if err := models.DB.Create(&item).Error; err != nil {
+	folder, ok := loadTargetFolder(c, req.FolderID)
+	if !ok {
+		return
+	}
+	if folder != nil {
+		if !user.HasFolderPermission(folder.Path, "create") {
+			c.JSON(http.StatusForbidden, types.ErrorResponse{Error: "权限不足，无法在该文件夹中创建信息项"})
+			return
+		}
+		item.FolderID = folder.ID
+	}
+
+	// 端到端加密信息项只保存客户端加密的数据，创建者必须是接收者之一
+	if req.E2E {
+		if err := validateE2ERequest(&req, user.ID, true); err != nil {
+			c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: err.Error()})
+			return
+		}
+		item.E2E = true
+		item.Payload = req.Payload
+		item.ContentKeyVersion = 1
+		item.Data = nil
+	}
+
+	// 按类型校验敏感数据并解析元数据
+	if err := item.RefreshMetadata(); err != nil {
+		respondItemDataError(c, err)
+		return
+	}
+	if violations := item.CheckPasswordPolicy(); len(violations) > 0 {
+		validation.HandleValidationErrors(c, validation.FieldErrors{"Password": violations})
+		return
+	}
+	if err := item.ValidateReferences(user); err != nil {
+		respondReferenceError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&item).Error; err != nil {
+			return err
+		}
+		if item.E2E {
+			return models.ReplaceSecretItemRecipients(tx, item.ID, user.ID, recipientsFromRequest(req.Recipients))
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, crypto.ErrBackendUnavailable) {
+			c.JSON(http.StatusServiceUnavailable, types.ErrorResponse{Error: "创建失败: " + err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "创建失败"})
 		return
 	}
@@ -115,6 +151,7 @@ func CreateSecretItem(c *gin.Context) {
 
 	// 重新查询以获取关联数据
 	models.DB.Preload("Creator").Preload("Updater").First(&item, "id = ?", item.ID)
+	item.LoadBreadcrumbs()
 
 	middleware.AuditLog(types.AuditLogActionCreate, middleware.GetSecretResourceType(item.Type))(c)
 
@@ -123,43 +160,35 @@ func CreateSecretItem(c *gin.Context) {
 
 // GetSecretItem 获取单个信息项
 func GetSecretItem(c *gin.Context) {
-	id := c.Param("id")
-
 	user := context.GetCurrentUser(c)
-	var approvedRequests []models.AccessRequest
-	now := uint64(time.Now().UnixMilli())
-	err := models.DB.Where(`secret_item_id IN (?) AND applicant_id = ? AND status = ? AND ? BETWEEN valid_from AND valid_until`,
-		id, user.ID, models.RequestStatusApproved, now).
-		Find(&approvedRequests).Error
 
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "查询失败"})
+	item, ok := loadAccessibleSecretItem(c, "Creator", "Updater")
+	if !ok {
 		return
 	}
 
-	var item models.SecretItem
-	query := models.DB.
-		Preload("Creator").
-		Preload("Updater").
-		Where("id = ?", id)
+	if item.DataUnavailable() {
+		c.JSON(http.StatusServiceUnavailable, types.ErrorResponse{Error: fmt.Sprintf("当前部署未持有环境 '%s' 的主密钥", item.Environment)})
+		return
+	}
 
-	if len(approvedRequests) == 0 {
-		query = query.Where("created_by_id = ?", user.ID)
+	// 加载历史信息和所在文件夹
+	item.LoadHistoryInfo()
+	if err := item.LoadBreadcrumbs(); err != nil {
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "查询失败"})
+		return
 	}
 
-	if err := query.
-		First(&item).
-		Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusForbidden, types.ErrorResponse{Error: "你无法访问此信息项"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "信息项不存在"})
+	// 端到端加密信息项返回当前用户的包装密钥
+	if err := item.LoadWrappedKey(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "查询失败"})
 		return
 	}
 
-	// 加载历史信息
-	item.LoadHistoryInfo()
+	// raw=true 时返回未解析的引用，用于编辑
+	if c.Query("raw") != "true" && !resolveSecretReferences(c, user, item) {
+		return
+	}
 
 	middleware.AuditLog(types.AuditLogActionRead, middleware.GetSecretResourceType(item.Type))(c)
 
@@ -184,20 +213,78 @@ func UpdateSecretItem(c *gin.Context) {
 		return
 	}
 
+	// 端到端加密模式在创建后不能切换
+	if item.E2E != req.E2E {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: "信息项创建后不能切换端到端加密模式"})
+		return
+	}
+	if item.E2E {
+		if err := validateE2ERequest(&req, user.ID, false); err != nil {
+			c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: err.Error()})
+			return
+		}
+		// 未更换内容密钥时，更新者必须是接收者，否则无法使用原内容密钥加密
+		if len(req.Recipients) == 0 {
+			wrappedKey, err := models.GetWrappedKey(item.ID, user.ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "查询失败"})
+				return
+			}
+			if wrappedKey == "" {
+				c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: "你不是该信息项的接收者，更新时需要提供全部接收者的包装密钥"})
+				return
+			}
+		}
+	}
+
 	// 更新字段
 	item.Name = req.Name
 	item.Description = req.Description
 	item.Type = req.Type
 	item.Category = req.Category
-	item.Environment = req.Environment
+	item.Environment = req.Environment // 环境变更时敏感数据在保存时使用新环境的主密钥重新加密
 	item.Tags = req.Tags
 	item.Data = &req.Data // 这里会触发自定义序列化器
 	item.ExpiresAt = req.ExpiresAt
 	item.UpdatedByID = user.ID
 	item.Version++ // 增加版本号
+	if item.E2E {
+		item.Data = nil
+		item.Payload = req.Payload
+		// 提交接收者表示客户端更换了内容密钥
+		if len(req.Recipients) > 0 {
+			item.ContentKeyVersion++
+		}
+	}
+	if err := item.RefreshMetadata(); err != nil {
+		respondItemDataError(c, err)
+		return
+	}
+	if violations := item.CheckPasswordPolicy(); len(violations) > 0 {
+		validation.HandleValidationErrors(c, validation.FieldErrors{"Password": violations})
+		return
+	}
+	if err := item.ValidateReferences(user); err != nil {
+		respondReferenceError(c, err, http.StatusBadRequest)
+		return
+	}
 
 	// 保存更新
-	if err := models.DB.Save(&item).Error; err != nil {
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&item).Error; err != nil {
+			return err
+		}
+		// 客户端更换了内容密钥，替换全部接收者
+		if item.E2E && len(req.Recipients) > 0 {
+			return models.ReplaceSecretItemRecipients(tx, item.ID, user.ID, recipientsFromRequest(req.Recipients))
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, crypto.ErrBackendUnavailable) {
+			c.JSON(http.StatusServiceUnavailable, types.ErrorResponse{Error: "更新失败: " + err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "更新失败"})
 		return
 	}
@@ -210,13 +297,14 @@ func UpdateSecretItem(c *gin.Context) {
 		Preload("Creator").
 		Preload("Updater").
 		First(&item, "id = ?", id)
+	item.LoadBreadcrumbs()
 
 	middleware.AuditLog(types.AuditLogActionUpdate, middleware.GetSecretResourceType(item.Type))(c)
 
 	c.JSON(http.StatusOK, item)
 }
 
-// DeleteSecretItem 删除信息项
+// DeleteSecretItem 删除信息项，信息项移入回收站，在保留期限内可以恢复
 func DeleteSecretItem(c *gin.Context) {
 	id := c.Param("id")
 	user := context.GetCurrentUser(c)
@@ -227,10 +315,18 @@ func DeleteSecretItem(c *gin.Context) {
 		return
 	}
 
+	// 删除数据库连接前删除其签发的所有临时用户，避免目标数据库中遗留无法撤销的用户
+	if item.Type == models.SecretTypeDatabase {
+		if err := models.RevokeSecretItemLeases(item.ID); err != nil {
+			c.JSON(http.StatusBadGateway, types.ErrorResponse{Error: "删除失败: " + err.Error()})
+			return
+		}
+	}
+
 	// 创建删除历史版本
 	item.CreateHistory(models.HistoryChangeTypeDeleted, "删除密钥项", user.ID)
 
-	if err := models.DB.Delete(&item).Error; err != nil {
+	if err := item.MoveToRecycleBin(user.ID); err != nil {
 		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "删除失败"})
 		return
 	}
@@ -248,18 +344,13 @@ func GetAccessedSecretItems(c *gin.Context) {
 
 	offset := (page - 1) * pageSize
 
-	var approvedSecretIDs []string
-	now := uint64(time.Now().UnixMilli())
-	err := models.DB.Model(&models.AccessRequest{}).Where(`applicant_id = ? AND status = ? AND ? BETWEEN valid_from AND valid_until`,
-		user.ID, models.RequestStatusApproved, now).Pluck("secret_item_id", &approvedSecretIDs).Error
-
+	// 包括通过文件夹申请继承访问权限的信息项
+	query, err := models.ApprovedAccessSecretItems(user.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "查询失败"})
 		return
 	}
 
-	query := models.DB.Model(&models.SecretItem{}).Where("id IN (?)", approvedSecretIDs)
-
 	var total int64
 	query.Count(&total)
 
@@ -271,6 +362,7 @@ func GetAccessedSecretItems(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "查询失败"})
 		return
 	}
+	redactListData(items)
 
 	c.JSON(http.StatusOK, types.ListResponse[models.SecretItem]{
 		Data: items,
@@ -290,27 +382,8 @@ func GetSecretItemHistory(c *gin.Context) {
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
 
 	// 检查用户对该密钥项的访问权限
-	user := context.GetCurrentUser(c)
-	var item models.SecretItem
-	query := models.DB.Where("id = ?", id)
-
-	// 如果用户不是创建者，需要检查是否有访问权限
-	var approvedRequests []models.AccessRequest
-	now := uint64(time.Now().UnixMilli())
-	err := models.DB.Where(`secret_item_id = ? AND applicant_id = ? AND status = ? AND ? BETWEEN valid_from AND valid_until`,
-		id, user.ID, models.RequestStatusApproved, now).
-		Find(&approvedRequests).Error
-
-	if err == nil && len(approvedRequests) == 0 {
-		query = query.Where("created_by_id = ?", user.ID)
-	}
-
-	if err := query.First(&item).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusForbidden, types.ErrorResponse{Error: "你无法访问此信息项"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "信息项不存在"})
+	item, ok := loadAccessibleSecretItem(c)
+	if !ok {
 		return
 	}
 
@@ -358,27 +431,8 @@ func GetSecretItemHistoryByVersion(c *gin.Context) {
 	}
 
 	// 检查用户对该密钥项的访问权限
-	user := context.GetCurrentUser(c)
-	var item models.SecretItem
-	query := models.DB.Where("id = ?", id)
-
-	// 如果用户不是创建者，需要检查是否有访问权限
-	var approvedRequests []models.AccessRequest
-	now := uint64(time.Now().UnixMilli())
-	err = models.DB.Where(`secret_item_id = ? AND applicant_id = ? AND status = ? AND ? BETWEEN valid_from AND valid_until`,
-		id, user.ID, models.RequestStatusApproved, now).
-		Find(&approvedRequests).Error
-
-	if err == nil && len(approvedRequests) == 0 {
-		query = query.Where("created_by_id = ?", user.ID)
-	}
-
-	if err := query.First(&item).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusForbidden, types.ErrorResponse{Error: "你无法访问此信息项"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "信息项不存在"})
+	item, ok := loadAccessibleSecretItem(c)
+	if !ok {
 		return
 	}
 
@@ -392,6 +446,10 @@ func GetSecretItemHistoryByVersion(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "获取历史版本失败"})
 		return
 	}
+	if history.DataUnavailable() {
+		c.JSON(http.StatusServiceUnavailable, types.ErrorResponse{Error: fmt.Sprintf("当前部署未持有环境 '%s' 的主密钥", history.Environment)})
+		return
+	}
 
 	middleware.AuditLog(types.AuditLogActionRead, middleware.GetSecretResourceType(item.Type))(c)
 
@@ -423,6 +481,10 @@ func RestoreSecretItemFromHistory(c *gin.Context) {
 	// 恢复历史版本
 	restoredItem, err := models.RestoreSecretItemFromHistory(id, req.Version, user.ID)
 	if err != nil {
+		if errors.Is(err, models.ErrContentKeyChanged) {
+			c.JSON(http.StatusConflict, types.ErrorResponse{Error: err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: fmt.Sprintf("恢复失败: %v", err)})
 		return
 	}
@@ -432,38 +494,86 @@ func RestoreSecretItemFromHistory(c *gin.Context) {
 	c.JSON(http.StatusOK, restoredItem)
 }
 
-// CompareSecretItemVersions 比较两个版本的差异
-func CompareSecretItemVersions(c *gin.Context) {
+// MoveSecretItem 移动信息项到其他文件夹，移动记录在变更历史和审计日志中
+func MoveSecretItem(c *gin.Context) {
 	id := c.Param("id")
 	user := context.GetCurrentUser(c)
 
-	var req types.CompareVersionsRequest
+	var req types.MoveSecretItemRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		validation.HandleValidationErrors(c, err)
 		return
 	}
 
-	// 检查用户对该密钥项的访问权限
 	var item models.SecretItem
-	query := models.DB.Where("id = ?", id)
+	if err := models.DB.Where("id = ?", id).First(&item).Error; err != nil {
+		c.JSON(http.StatusNotFound, types.ErrorResponse{Error: "信息项不存在"})
+		return
+	}
+	if item.FolderID == req.FolderID {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: "信息项已在目标文件夹中"})
+		return
+	}
 
-	// 如果用户不是创建者，需要检查是否有访问权限
-	var approvedRequests []models.AccessRequest
-	now := uint64(time.Now().UnixMilli())
-	err := models.DB.Where(`secret_item_id = ? AND applicant_id = ? AND status = ? AND ? BETWEEN valid_from AND valid_until`,
-		id, user.ID, models.RequestStatusApproved, now).
-		Find(&approvedRequests).Error
+	source, ok := loadTargetFolder(c, item.FolderID)
+	if !ok {
+		return
+	}
+	target, ok := loadTargetFolder(c, req.FolderID)
+	if !ok {
+		return
+	}
+	// 移出文件夹需要该文件夹的 delete 权限，移入需要目标文件夹的 create 权限；根目录只需要 secret:update 权限
+	if source != nil && !user.HasFolderPermission(source.Path, "delete") {
+		c.JSON(http.StatusForbidden, types.ErrorResponse{Error: "权限不足，无法从该文件夹中移出信息项"})
+		return
+	}
+	if target != nil && !user.HasFolderPermission(target.Path, "create") {
+		c.JSON(http.StatusForbidden, types.ErrorResponse{Error: "权限不足，无法移动到目标文件夹"})
+		return
+	}
 
-	if err == nil && len(approvedRequests) == 0 {
-		query = query.Where("created_by_id = ?", user.ID)
+	reason := fmt.Sprintf("从 %s 移动到 %s", folderPath(source), folderPath(target))
+	if req.Reason != "" {
+		reason += "：" + req.Reason
+	}
+	sourceID := item.FolderID
+	if err := item.MoveTo(target, reason, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: fmt.Sprintf("移动失败: %v", err)})
+		return
 	}
 
-	if err := query.First(&item).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusForbidden, types.ErrorResponse{Error: "你无法访问此信息项"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "信息项不存在"})
+	// 审计日志记录移动前后的文件夹
+	details, _ := json.Marshal(map[string]string{
+		"from_folder_id": sourceID,
+		"from":           folderPath(source),
+		"to_folder_id":   req.FolderID,
+		"to":             folderPath(target),
+		"reason":         req.Reason,
+	})
+	middleware.LogUserAction(user.ID, types.AuditLogActionMove, middleware.GetSecretResourceType(item.Type),
+		item.ID, string(details), c.ClientIP(), c.GetHeader("User-Agent"))
+
+	// 重新查询以获取关联数据
+	models.DB.Preload("Creator").Preload("Updater").First(&item, "id = ?", id)
+	item.LoadBreadcrumbs()
+
+	c.JSON(http.StatusOK, item)
+}
+
+// CompareSecretItemVersions 比较两个版本的差异
+func CompareSecretItemVersions(c *gin.Context) {
+	id := c.Param("id")
+
+	var req types.CompareVersionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validation.HandleValidationErrors(c, err)
+		return
+	}
+
+	// 检查用户对该密钥项的访问权限
+	item, ok := loadAccessibleSecretItem(c)
+	if !ok {
 		return
 	}
 
@@ -482,3 +592,120 @@ func CompareSecretItemVersions(c *gin.Context) {
 		Changes:  changes,
 	})
 }
+
+// validateE2ERequest 校验端到端加密信息项的请求，提供接收者时必须包含当前用户且接收者均已注册公钥
+func validateE2ERequest(req *types.PostItemRequest, userID string, requireRecipients bool) error {
+	if req.Payload == "" {
+		return fmt.Errorf("端到端加密信息项的加密数据不能为空")
+	}
+	if len(req.Recipients) == 0 {
+		if requireRecipients {
+			return fmt.Errorf("端到端加密信息项至少需要一个接收者")
+		}
+		return nil
+	}
+
+	userIDs := make([]string, 0, len(req.Recipients))
+	seen := make(map[string]bool, len(req.Recipients))
+	for _, recipient := range req.Recipients {
+		if seen[recipient.UserID] {
+			return fmt.Errorf("接收者 %s 重复", recipient.UserID)
+		}
+		seen[recipient.UserID] = true
+		userIDs = append(userIDs, recipient.UserID)
+	}
+	if !seen[userID] {
+		return fmt.Errorf("接收者中必须包含当前用户")
+	}
+
+	return models.ValidateRecipientUsers(userIDs)
+}
+
+// recipientsFromRequest 将请求中的接收者转换为模型
+func recipientsFromRequest(keys []types.RecipientKey) []models.SecretItemRecipient {
+	recipients := make([]models.SecretItemRecipient, len(keys))
+	for i, key := range keys {
+		recipients[i] = models.SecretItemRecipient{UserID: key.UserID, WrappedKey: key.WrappedKey}
+	}
+	return recipients
+}
+
+// redactListData TOTP种子和数据库连接的管理员凭据不在列表中返回，通过详情接口读取当前验证码或临时用户
+func redactListData(items []models.SecretItem) {
+	for i := range items {
+		if items[i].Type == models.SecretTypeTOTP || items[i].Type == models.SecretTypeDatabase {
+			items[i].Data = nil
+		}
+	}
+}
+
+// loadAccessibleSecretItem 加载当前用户可以查看的信息项，先检查访问权限再读取敏感数据；不存在或无权访问时返回403
+func loadAccessibleSecretItem(c *gin.Context, preloads ...string) (*models.SecretItem, bool) {
+	user := context.GetCurrentUser(c)
+
+	var item models.SecretItem
+	if err := models.DB.Select("id", "type", "created_by_id", "folder_id").Where("id = ?", c.Param("id")).First(&item).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusForbidden, types.ErrorResponse{Error: "你无法访问此信息项"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "查询失败"})
+		return nil, false
+	}
+
+	canAccess, err := user.CanAccessSecretItem(&item)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "查询失败"})
+		return nil, false
+	}
+	if !canAccess {
+		c.JSON(http.StatusForbidden, types.ErrorResponse{Error: "你无法访问此信息项"})
+		return nil, false
+	}
+
+	query := models.DB
+	for _, preload := range preloads {
+		query = query.Preload(preload)
+	}
+	var accessible models.SecretItem
+	if err := query.Where("id = ?", item.ID).First(&accessible).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "查询失败"})
+		return nil, false
+	}
+	return &accessible, true
+}
+
+// resolveSecretReferences 解析信息项数据中的引用，并为每个被读取的信息项记录审计日志
+func resolveSecretReferences(c *gin.Context, user *models.User, item *models.SecretItem) bool {
+	references, err := item.ResolveReferences(user)
+	if err != nil {
+		respondReferenceError(c, err, http.StatusConflict)
+		return false
+	}
+	item.References = references
+
+	details, _ := json.Marshal(map[string]string{"referenced_by": item.ID})
+	for _, reference := range references {
+		middleware.LogUserAction(user.ID, types.AuditLogActionRefer, middleware.GetSecretResourceType(reference.Type),
+			reference.ID, string(details), c.ClientIP(), c.GetHeader("User-Agent"))
+	}
+	return true
+}
+
+// respondReferenceError 返回引用解析失败的响应，无权访问被引用的信息项时返回403
+func respondReferenceError(c *gin.Context, err error, status int) {
+	if errors.Is(err, models.ErrSecretReferenceForbidden) {
+		status = http.StatusForbidden
+	}
+	c.JSON(status, types.ErrorResponse{Error: "引用解析失败: " + err.Error()})
+}
+
+// respondItemDataError 返回信息项数据校验失败的响应，不符合自定义类型字段定义的错误按字段返回
+func respondItemDataError(c *gin.Context, err error) {
+	var fieldErrors models.SecretTypeFieldErrors
+	if errors.As(err, &fieldErrors) {
+		validation.HandleValidationErrors(c, validation.FieldErrors(fieldErrors))
+		return
+	}
+	c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: err.Error()})
+}
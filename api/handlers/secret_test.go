@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akinoccc/hysaif/api/config"
+	"github.com/akinoccc/hysaif/api/middleware"
+	"github.com/akinoccc/hysaif/api/models"
+	"github.com/akinoccc/hysaif/api/packages/crypto"
+	"github.com/akinoccc/hysaif/api/types"
+)
+
+func TestGetSecretItemsHidesRestrictedData(t *testing.T) {
+	creator := createTestUser(t, "sec_mgr")
+	reader := createTestUser(t, "dev")
+
+	const seed = "JBSWY3DPEHPK3PXP"
+	items := []map[string]any{
+		{
+			"name": "shared-admin-2fa", "type": "totp", "category": "list-test", "environment": "development",
+			"data": map[string]any{"seed": seed},
+		},
+		{
+			"name": "list-password", "type": "password", "category": "list-test", "environment": "development",
+			"data": map[string]any{"username": "root", "password": "Xk9#mQ2!vL7$pR4zT"},
+		},
+	}
+	for _, item := range items {
+		code, body := performRequest(t, creator, http.MethodPost, "/items", "/items", item, CreateSecretItem)
+		if code != http.StatusCreated {
+			t.Fatalf("创建信息项失败: %d %s", code, body)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		user     *models.User
+		wantData map[string]bool
+	}{
+		{"非创建者不返回TOTP种子", reader, map[string]bool{"totp": false}},
+		{"创建者不返回TOTP种子", creator, map[string]bool{"totp": false, "password": true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := performRequest(t, tt.user, http.MethodGet, "/items", "/items?category=list-test", nil, GetSecretItems)
+			if code != http.StatusOK {
+				t.Fatalf("获取列表失败: %d %s", code, body)
+			}
+			if strings.Contains(string(body), seed) {
+				t.Fatalf("列表中包含TOTP种子: %s", body)
+			}
+
+			var resp types.ListResponse[models.SecretItem]
+			if err := json.Unmarshal(body, &resp); err != nil {
+				t.Fatalf("解析响应失败: %v", err)
+			}
+			if len(resp.Data) != len(items) {
+				t.Fatalf("列表包含 %d 个信息项，期望 %d 个", len(resp.Data), len(items))
+			}
+			for _, item := range resp.Data {
+				want, ok := tt.wantData[item.Type]
+				if !ok {
+					continue
+				}
+				if got := item.Data != nil; got != want {
+					t.Errorf("%s 类型信息项返回敏感数据 = %v，期望 %v", item.Type, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFolderPermissionsForCreateAndMove(t *testing.T) {
+	admin := createTestUser(t, models.RoleSuperAdmin)
+	source, err := models.CreateFolder("move-src", "", nil, admin.ID)
+	if err != nil {
+		t.Fatalf("创建文件夹失败: %v", err)
+	}
+	target, err := models.CreateFolder("move-dst", "", nil, admin.ID)
+	if err != nil {
+		t.Fatalf("创建文件夹失败: %v", err)
+	}
+
+	const role = "folder-test"
+	grantPermissions(t, role,
+		[2]string{"secret", "create"},
+		[2]string{"secret", "update"},
+		[2]string{models.FolderPathPermissionResource(target.Path), "create"},
+	)
+	user := createTestUser(t, role)
+
+	newItem := func(folderID string) map[string]any {
+		return map[string]any{
+			"name": "move-" + folderID[:8], "type": "token", "category": "move-test", "environment": "development",
+			"folder_id": folderID, "data": map[string]any{"token": "tok-123"},
+		}
+	}
+
+	t.Run("没有文件夹的create权限不能创建", func(t *testing.T) {
+		code, body := performRequest(t, user, http.MethodPost, "/items", "/items", newItem(source.ID), CreateSecretItem)
+		if code != http.StatusForbidden {
+			t.Fatalf("期望403，实际 %d %s", code, body)
+		}
+	})
+
+	code, body := performRequest(t, admin, http.MethodPost, "/items", "/items", newItem(source.ID), CreateSecretItem)
+	if code != http.StatusCreated {
+		t.Fatalf("创建信息项失败: %d %s", code, body)
+	}
+	var item models.SecretItem
+	if err := json.Unmarshal(body, &item); err != nil {
+		t.Fatalf("解析响应失败: %v", err)
+	}
+	move := func(u *models.User, folderID string) (int, []byte) {
+		return performRequest(t, u, http.MethodPost, "/items/:id/move", "/items/"+item.ID+"/move",
+			map[string]any{"folder_id": folderID}, MoveSecretItem)
+	}
+
+	t.Run("没有原文件夹的delete权限不能移出", func(t *testing.T) {
+		if code, body := move(user, target.ID); code != http.StatusForbidden {
+			t.Fatalf("期望403，实际 %d %s", code, body)
+		}
+	})
+
+	grantPermissions(t, role, [2]string{models.FolderPathPermissionResource(source.Path), "delete"})
+
+	t.Run("没有目标文件夹的create权限不能移入", func(t *testing.T) {
+		other, err := models.CreateFolder("move-other", "", nil, admin.ID)
+		if err != nil {
+			t.Fatalf("创建文件夹失败: %v", err)
+		}
+		if code, body := move(user, other.ID); code != http.StatusForbidden {
+			t.Fatalf("期望403，实际 %d %s", code, body)
+		}
+	})
+
+	t.Run("移动并记录历史版本", func(t *testing.T) {
+		if code, body := move(user, target.ID); code != http.StatusOK {
+			t.Fatalf("移动失败: %d %s", code, body)
+		}
+		var moved models.SecretItem
+		if err := models.DB.First(&moved, "id = ?", item.ID).Error; err != nil {
+			t.Fatalf("查询信息项失败: %v", err)
+		}
+		if moved.FolderID != target.ID {
+			t.Fatalf("信息项所在文件夹为 %s，期望 %s", moved.FolderID, target.ID)
+		}
+		var count int64
+		models.DB.Model(&models.SecretItemHistory{}).
+			Where("secret_item_id = ? AND change_type = ?", item.ID, models.HistoryChangeTypeMoved).Count(&count)
+		if count != 1 {
+			t.Fatalf("期望1条移动历史版本，实际 %d 条", count)
+		}
+	})
+}
+
+func TestRestoreE2EHistoryAcrossContentKeyChange(t *testing.T) {
+	creator := createTestUser(t, "sec_mgr")
+	publicKey := base64.StdEncoding.EncodeToString(make([]byte, 32))
+	if err := models.DB.Model(creator).Update("public_key", publicKey).Error; err != nil {
+		t.Fatalf("注册公钥失败: %v", err)
+	}
+
+	payload := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	request := func(p string, rekey bool) map[string]any {
+		req := map[string]any{
+			"name": "e2e-restore", "type": "token", "category": "e2e-test", "environment": "development",
+			"data": map[string]any{}, "e2e": true, "payload": payload(p),
+		}
+		if rekey {
+			req["recipients"] = []map[string]any{{"user_id": creator.ID, "wrapped_key": payload("key-" + p)}}
+		}
+		return req
+	}
+
+	code, body := performRequest(t, creator, http.MethodPost, "/items", "/items", request("v1", true), CreateSecretItem)
+	if code != http.StatusCreated {
+		t.Fatalf("创建信息项失败: %d %s", code, body)
+	}
+	var item models.SecretItem
+	if err := json.Unmarshal(body, &item); err != nil {
+		t.Fatalf("解析响应失败: %v", err)
+	}
+	update := func(p string, rekey bool) {
+		t.Helper()
+		code, body := performRequest(t, creator, http.MethodPut, "/items/:id", "/items/"+item.ID, request(p, rekey), UpdateSecretItem)
+		if code != http.StatusOK {
+			t.Fatalf("更新信息项失败: %d %s", code, body)
+		}
+	}
+	restore := func(version int) (int, []byte) {
+		return performRequest(t, creator, http.MethodPost, "/items/:id/restore", "/items/"+item.ID+"/restore",
+			map[string]any{"version": version}, RestoreSecretItemFromHistory)
+	}
+	historyVersion := func(p string) int {
+		t.Helper()
+		var history models.SecretItemHistory
+		if err := models.DB.Where("secret_item_id = ? AND payload = ?", item.ID, payload(p)).
+			Order("version").First(&history).Error; err != nil {
+			t.Fatalf("查询历史版本失败: %v", err)
+		}
+		return history.Version
+	}
+
+	update("v2", false)
+	t.Run("同一内容密钥的历史版本可以恢复", func(t *testing.T) {
+		if code, body := restore(historyVersion("v1")); code != http.StatusOK {
+			t.Fatalf("恢复失败: %d %s", code, body)
+		}
+	})
+
+	update("v3", true)
+	t.Run("更换内容密钥前的历史版本不能恢复", func(t *testing.T) {
+		if code, body := restore(historyVersion("v2")); code != http.StatusConflict {
+			t.Fatalf("期望409，实际 %d %s", code, body)
+		}
+		var current models.SecretItem
+		if err := models.DB.First(&current, "id = ?", item.ID).Error; err != nil {
+			t.Fatalf("查询信息项失败: %v", err)
+		}
+		if current.Payload != payload("v3") || current.ContentKeyVersion != 2 {
+			t.Fatalf("信息项被修改: payload=%s content_key_version=%d", current.Payload, current.ContentKeyVersion)
+		}
+	})
+}
+
+func TestEncryptionFallbackAuditLogRecordsUser(t *testing.T) {
+	crypto.OnFallback(middleware.RecordEncryptionFallback)
+
+	// 文件KMS密钥环不存在，prefer_vault 策略下回退到本地AES
+	security := &config.AppConfig.Security
+	security.KeyProvider, security.FileKMS.Path = crypto.ProviderFile, filepath.Join(t.TempDir(), "missing.json")
+	t.Cleanup(func() { security.KeyProvider, security.FileKMS.Path = crypto.ProviderAES, "" })
+
+	creator := createTestUser(t, "sec_mgr")
+	item := map[string]any{
+		"name": "fallback-password", "type": "password", "category": "fallback-test", "environment": "development",
+		"data": map[string]any{"username": "root", "password": "Qm7#xV2!pL9$kT4wZ"},
+	}
+	code, body := performRequest(t, creator, http.MethodPost, "/items", "/items", item, CreateSecretItem)
+	if code != http.StatusCreated {
+		t.Fatalf("创建信息项失败: %d %s", code, body)
+	}
+	var created models.SecretItem
+	if err := json.Unmarshal(body, &created); err != nil {
+		t.Fatalf("解析响应失败: %v", err)
+	}
+
+	// 审计日志异步保存
+	var auditLog models.AuditLog
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		err := models.DB.Where("action = ? AND resource_id = ?", types.AuditLogActionFallback, created.ID).First(&auditLog).Error
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("未找到加密回退的审计日志: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if auditLog.UserID != creator.ID {
+		t.Fatalf("审计日志的操作用户为 %q，期望 %q", auditLog.UserID, creator.ID)
+	}
+}
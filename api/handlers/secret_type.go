@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/akinoccc/hysaif/api/models"
+	"github.com/akinoccc/hysaif/api/packages/context"
+	"github.com/akinoccc/hysaif/api/packages/validation"
+	"github.com/akinoccc/hysaif/api/types"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetSecretTypes 获取内置类型和所有自定义类型的字段定义，用于渲染信息项表单
+func GetSecretTypes(c *gin.Context) {
+	secretTypes, err := models.ListSecretTypes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "获取信息项类型失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, types.SecretTypeListResponse{
+		Builtin: models.BuiltinSecretTypes,
+		Data:    secretTypes,
+	})
+}
+
+// GetSecretType 获取自定义类型详情
+func GetSecretType(c *gin.Context) {
+	secretType, ok := loadSecretType(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, secretType)
+}
+
+// CreateSecretType 创建自定义类型
+func CreateSecretType(c *gin.Context) {
+	user := context.GetCurrentUser(c)
+
+	var req types.CreateSecretTypeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validation.HandleValidationErrors(c, err)
+		return
+	}
+
+	secretType := models.SecretType{
+		Name:        req.Name,
+		Title:       req.Title,
+		Icon:        req.Icon,
+		Description: req.Description,
+		Schema:      req.Schema,
+		CreatedByID: user.ID,
+		UpdatedByID: user.ID,
+	}
+	if err := models.CreateSecretType(&secretType); err != nil {
+		if errors.Is(err, models.ErrSecretTypeExists) {
+			c.JSON(http.StatusConflict, types.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, secretType)
+}
+
+// UpdateSecretType 更新自定义类型的显示信息和字段定义，已有信息项在下次更新时按新的字段定义校验
+func UpdateSecretType(c *gin.Context) {
+	user := context.GetCurrentUser(c)
+
+	var req types.UpdateSecretTypeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validation.HandleValidationErrors(c, err)
+		return
+	}
+	if err := req.Schema.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	secretType, ok := loadSecretType(c)
+	if !ok {
+		return
+	}
+	if err := req.Schema.ValidateUpdate(secretType.Schema); err != nil {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	secretType.Title = req.Title
+	secretType.Icon = req.Icon
+	secretType.Description = req.Description
+	secretType.Schema = req.Schema
+	secretType.UpdatedByID = user.ID
+	if err := models.DB.Save(secretType).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "更新信息项类型失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, secretType)
+}
+
+// DeleteSecretType 删除自定义类型，仍有信息项使用时返回409
+func DeleteSecretType(c *gin.Context) {
+	secretType, ok := loadSecretType(c)
+	if !ok {
+		return
+	}
+
+	if err := secretType.Delete(); err != nil {
+		if errors.Is(err, models.ErrSecretTypeInUse) {
+			c.JSON(http.StatusConflict, types.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "删除信息项类型失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, types.MessageResponse{Message: "删除成功"})
+}
+
+// loadSecretType 根据路径中的名称加载自定义类型，不存在时返回404
+func loadSecretType(c *gin.Context) (*models.SecretType, bool) {
+	secretType, err := models.GetSecretType(c.Param("name"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, types.ErrorResponse{Error: "信息项类型不存在"})
+		} else {
+			c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "获取信息项类型失败"})
+		}
+		return nil, false
+	}
+	return secretType, true
+}
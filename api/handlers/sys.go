@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/akinoccc/hysaif/api/packages/crypto"
+	"github.com/akinoccc/hysaif/api/packages/validation"
+	"github.com/akinoccc/hysaif/api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSealStatus 获取密封状态和解封进度
+func GetSealStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, crypto.GetSealStatus())
+}
+
+// Unseal 提交解封分片，分片数达到阈值后解封
+func Unseal(c *gin.Context) {
+	var req types.UnsealRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validation.HandleValidationErrors(c, err)
+		return
+	}
+
+	if !crypto.SealEnabled() {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: "未启用密封模式"})
+		return
+	}
+
+	status, err := crypto.SubmitUnsealShare(req.Share)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// ResetUnseal 清空已提交的解封分片，需要密封权限，避免持有分片的用户打断其他持有者的解封
+func ResetUnseal(c *gin.Context) {
+	if !crypto.SealEnabled() {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: "未启用密封模式"})
+		return
+	}
+
+	crypto.ResetUnseal()
+	c.JSON(http.StatusOK, crypto.GetSealStatus())
+}
+
+// Seal 紧急密封，清除内存中的主密钥
+func Seal(c *gin.Context) {
+	if err := crypto.Seal(); err != nil {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, crypto.GetSealStatus())
+}
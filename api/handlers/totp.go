@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/akinoccc/hysaif/api/models"
+	"github.com/akinoccc/hysaif/api/packages/context"
+	"github.com/akinoccc/hysaif/api/types"
+	"gorm.io/gorm"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTOTPCode 获取TOTP信息项的当前验证码及剩余有效时间，不返回种子
+func GetTOTPCode(c *gin.Context) {
+	user := context.GetCurrentUser(c)
+
+	var item models.SecretItem
+	if err := models.DB.Select("id", "type", "created_by_id", "folder_id").Where("id = ?", c.Param("id")).First(&item).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, types.ErrorResponse{Error: "信息项不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "查询失败"})
+		return
+	}
+	if item.Type != models.SecretTypeTOTP {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: "信息项不是TOTP类型"})
+		return
+	}
+
+	allowed, err := user.CanGetTOTPCode(&item)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "查询失败"})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, types.ErrorResponse{Error: "无访问权限或访问权限已过期，请先申请访问"})
+		return
+	}
+
+	// 通过访问申请获取验证码时更新访问记录
+	accessRequest, err := models.FindValidAccessRequest(user.ID, &item)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "查询失败"})
+		return
+	}
+	if accessRequest != nil {
+		accessRequest.AccessCount++
+		accessRequest.LastAccessed = uint64(time.Now().Unix())
+		models.DB.Save(accessRequest)
+	}
+
+	if err := models.DB.Where("id = ?", item.ID).First(&item).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "查询失败"})
+		return
+	}
+	if item.DataUnavailable() {
+		c.JSON(http.StatusServiceUnavailable, types.ErrorResponse{Error: fmt.Sprintf("当前部署未持有环境 '%s' 的主密钥", item.Environment)})
+		return
+	}
+	key, err := item.TOTPKey()
+	if err != nil {
+		c.JSON(http.StatusConflict, types.ErrorResponse{Error: "无法生成验证码: " + err.Error()})
+		return
+	}
+
+	now := time.Now()
+	code, expiresAt := key.Code(now)
+	c.JSON(http.StatusOK, types.TOTPCodeResponse{
+		Code:             code,
+		Period:           key.Period,
+		RemainingSeconds: int(math.Ceil(expiresAt.Sub(now).Seconds())),
+		ExpiresAt:        uint64(expiresAt.UnixMilli()),
+	})
+}
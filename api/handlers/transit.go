@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+
+	"github.com/akinoccc/hysaif/api/models"
+	"github.com/akinoccc/hysaif/api/packages/context"
+	"github.com/akinoccc/hysaif/api/packages/crypto"
+	"github.com/akinoccc/hysaif/api/packages/validation"
+	"github.com/akinoccc/hysaif/api/types"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetTransitKeys 获取当前用户有读取权限的托管密钥列表
+func GetTransitKeys(c *gin.Context) {
+	user := context.GetCurrentUser(c)
+
+	var keys []models.TransitKey
+	if err := models.DB.Preload("Creator").Order("name").Find(&keys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "获取托管密钥列表失败"})
+		return
+	}
+
+	visible := make([]models.TransitKey, 0, len(keys))
+	for _, key := range keys {
+		if user.HasTransitKeyPermission(key.Name, "read") {
+			visible = append(visible, key)
+		}
+	}
+
+	c.JSON(http.StatusOK, types.TransitKeyListResponse{Data: visible})
+}
+
+// CreateTransitKey 创建托管密钥
+func CreateTransitKey(c *gin.Context) {
+	user := context.GetCurrentUser(c)
+
+	var req types.CreateTransitKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validation.HandleValidationErrors(c, err)
+		return
+	}
+	if err := models.ValidateTransitKeyName(req.Name); err != nil {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	key, err := models.CreateTransitKey(req.Name, user.ID)
+	if err != nil {
+		respondTransitError(c, "创建托管密钥失败", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, key)
+}
+
+// GetTransitKey 获取托管密钥详情
+func GetTransitKey(c *gin.Context) {
+	key, ok := loadTransitKey(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, key)
+}
+
+// RotateTransitKey 轮换托管密钥，之后的加密使用新版本
+func RotateTransitKey(c *gin.Context) {
+	key, ok := loadTransitKey(c)
+	if !ok {
+		return
+	}
+
+	if err := key.Rotate(context.GetCurrentUser(c).ID); err != nil {
+		respondTransitError(c, "轮换托管密钥失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, key)
+}
+
+// UpdateTransitKeyConfig 更新托管密钥配置
+func UpdateTransitKeyConfig(c *gin.Context) {
+	var req types.UpdateTransitKeyConfigRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validation.HandleValidationErrors(c, err)
+		return
+	}
+
+	key, ok := loadTransitKey(c)
+	if !ok {
+		return
+	}
+
+	if err := key.SetMinDecryptionVersion(req.MinDecryptionVersion); err != nil {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	key.MinDecryptionVersion = req.MinDecryptionVersion
+	c.JSON(http.StatusOK, key)
+}
+
+// TransitEncrypt 使用托管密钥的最新版本加密数据
+func TransitEncrypt(c *gin.Context) {
+	var req types.TransitEncryptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validation.HandleValidationErrors(c, err)
+		return
+	}
+
+	key, ok := loadTransitKey(c)
+	if !ok {
+		return
+	}
+
+	// 绑定校验已保证Base64格式有效
+	plaintext, _ := base64.StdEncoding.DecodeString(req.Plaintext)
+	associatedData, _ := base64.StdEncoding.DecodeString(req.AssociatedData)
+
+	ciphertext, err := key.Encrypt(plaintext, associatedData)
+	if err != nil {
+		respondTransitError(c, "加密失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, types.TransitEncryptResponse{Ciphertext: ciphertext, KeyVersion: key.LatestVersion})
+}
+
+// TransitDecrypt 使用托管密钥解密数据
+func TransitDecrypt(c *gin.Context) {
+	var req types.TransitDecryptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validation.HandleValidationErrors(c, err)
+		return
+	}
+
+	key, ok := loadTransitKey(c)
+	if !ok {
+		return
+	}
+
+	associatedData, _ := base64.StdEncoding.DecodeString(req.AssociatedData)
+
+	plaintext, err := key.Decrypt(req.Ciphertext, associatedData)
+	if err != nil {
+		respondTransitError(c, "解密失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, types.TransitDecryptResponse{Plaintext: base64.StdEncoding.EncodeToString(plaintext)})
+}
+
+// TransitRewrap 将密文重新加密到托管密钥的最新版本，不返回明文
+func TransitRewrap(c *gin.Context) {
+	var req types.TransitDecryptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validation.HandleValidationErrors(c, err)
+		return
+	}
+
+	key, ok := loadTransitKey(c)
+	if !ok {
+		return
+	}
+
+	associatedData, _ := base64.StdEncoding.DecodeString(req.AssociatedData)
+
+	ciphertext, err := key.Rewrap(req.Ciphertext, associatedData)
+	if err != nil {
+		respondTransitError(c, "重新加密失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, types.TransitEncryptResponse{Ciphertext: ciphertext, KeyVersion: key.LatestVersion})
+}
+
+// loadTransitKey 根据路径中的名称加载托管密钥，不存在时返回404
+func loadTransitKey(c *gin.Context) (*models.TransitKey, bool) {
+	key, err := models.GetTransitKey(c.Param("name"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, types.ErrorResponse{Error: "托管密钥不存在"})
+		} else {
+			c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "获取托管密钥失败"})
+		}
+		return nil, false
+	}
+	return key, true
+}
+
+// respondTransitError 将托管密钥操作的错误映射为HTTP状态码
+func respondTransitError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, models.ErrTransitKeyExists):
+		c.JSON(http.StatusConflict, types.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, crypto.ErrSealed), errors.Is(err, crypto.ErrBackendUnavailable):
+		c.JSON(http.StatusServiceUnavailable, types.ErrorResponse{Error: message + ": " + err.Error()})
+	case errors.Is(err, crypto.ErrInvalidTransitCiphertext), errors.Is(err, models.ErrTransitKeyVersionDisabled):
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: message + ": " + err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: message + ": " + err.Error()})
+	}
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/akinoccc/hysaif/api/models"
+	"github.com/akinoccc/hysaif/api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestTransitKeyVersionChecks(t *testing.T) {
+	admin := createTestUser(t, models.RoleSuperAdmin)
+	key, err := models.CreateTransitKey("versions-"+uuid.NewString()[:8], admin.ID)
+	if err != nil {
+		t.Fatalf("创建托管密钥失败: %v", err)
+	}
+
+	encrypt := func() string {
+		t.Helper()
+		req := types.TransitEncryptRequest{Plaintext: base64.StdEncoding.EncodeToString([]byte("payload"))}
+		code, body := performRequest(t, admin, http.MethodPost, "/encrypt/:name", "/encrypt/"+key.Name, req, TransitEncrypt)
+		if code != http.StatusOK {
+			t.Fatalf("加密失败: %d %s", code, body)
+		}
+		var resp types.TransitEncryptResponse
+		if err := json.Unmarshal(body, &resp); err != nil {
+			t.Fatalf("解析响应失败: %v", err)
+		}
+		return resp.Ciphertext
+	}
+
+	v1 := encrypt()
+	for range 2 {
+		if err := key.Rotate(admin.ID); err != nil {
+			t.Fatalf("轮换托管密钥失败: %v", err)
+		}
+	}
+	v3 := encrypt()
+	if !strings.HasPrefix(v3, "hysaif:v3:") {
+		t.Fatalf("轮换后应使用版本3加密: %s", v3)
+	}
+
+	code, body := performRequest(t, admin, http.MethodPut, "/keys/:name/config", "/keys/"+key.Name+"/config",
+		types.UpdateTransitKeyConfigRequest{MinDecryptionVersion: 2}, UpdateTransitKeyConfig)
+	if code != http.StatusOK {
+		t.Fatalf("设置最低解密版本失败: %d %s", code, body)
+	}
+
+	tests := []struct {
+		name       string
+		handler    func(route, path string) (int, []byte)
+		ciphertext string
+		wantCode   int
+		wantErr    string
+	}{
+		{"解密最新版本", decryptWith(t, admin, TransitDecrypt), v3, http.StatusOK, ""},
+		{"解密低于最低解密版本", decryptWith(t, admin, TransitDecrypt), v1, http.StatusBadRequest, "不可用于解密"},
+		{"解密高于最新版本", decryptWith(t, admin, TransitDecrypt), strings.Replace(v3, "hysaif:v3:", "hysaif:v4:", 1), http.StatusBadRequest, "不可用于解密"},
+		{"版本号被改为其他可用版本", decryptWith(t, admin, TransitDecrypt), strings.Replace(v3, "hysaif:v3:", "hysaif:v2:", 1), http.StatusBadRequest, "密文或附加认证数据无效"},
+		{"重新加密低于最低解密版本", decryptWith(t, admin, TransitRewrap), v1, http.StatusBadRequest, "不可用于解密"},
+		{"重新加密最新版本", decryptWith(t, admin, TransitRewrap), v3, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := tt.handler(key.Name, tt.ciphertext)
+			if code != tt.wantCode || !strings.Contains(string(body), tt.wantErr) {
+				t.Fatalf("状态码为 %d，期望 %d（错误包含 %q）: %s", code, tt.wantCode, tt.wantErr, body)
+			}
+		})
+	}
+
+	code, body = performRequest(t, admin, http.MethodPut, "/keys/:name/config", "/keys/"+key.Name+"/config",
+		types.UpdateTransitKeyConfigRequest{MinDecryptionVersion: 4}, UpdateTransitKeyConfig)
+	if code != http.StatusBadRequest {
+		t.Fatalf("最低解密版本不能高于最新版本: %d %s", code, body)
+	}
+}
+
+// decryptWith 返回以托管密钥名和密文调用解密类处理函数的函数
+func decryptWith(t *testing.T, user *models.User, handler func(c *gin.Context)) func(name, ciphertext string) (int, []byte) {
+	return func(name, ciphertext string) (int, []byte) {
+		return performRequest(t, user, http.MethodPost, "/:name", "/"+name, types.TransitDecryptRequest{Ciphertext: ciphertext}, handler)
+	}
+}
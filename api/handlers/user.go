@@ -152,6 +152,29 @@ func UpdateProfile(c *gin.Context) {
 	c.JSON(http.StatusOK, user)
 }
 
+// UpdatePublicKey 注册或更换当前用户的X25519公钥，用于接收端到端加密信息项
+func UpdatePublicKey(c *gin.Context) {
+	user := context.GetCurrentUser(c)
+
+	var req types.UpdatePublicKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validation.HandleValidationErrors(c, err)
+		return
+	}
+
+	if err := models.ValidatePublicKey(req.PublicKey); err != nil {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := models.DB.Model(user).Update("public_key", req.PublicKey).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "更新失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
 // UpdateUser 更新指定用户信息
 func UpdateUser(c *gin.Context) {
 	// 获取当前用户信息，检查权限
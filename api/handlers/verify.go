@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/akinoccc/hysaif/api/packages/crypto"
+	"github.com/akinoccc/hysaif/api/packages/validation"
+	"github.com/akinoccc/hysaif/api/packages/verify"
+	"github.com/akinoccc/hysaif/api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VerifyEncryptedData 校验所有加密数据能否解密，修复模式下隔离无法解密的记录
+func VerifyEncryptedData(c *gin.Context) {
+	var req types.VerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		validation.HandleValidationErrors(c, err)
+		return
+	}
+
+	report, err := verify.Run(verify.Options{Repair: req.Repair})
+	if err != nil {
+		switch {
+		case errors.Is(err, verify.ErrRunning):
+			c.JSON(http.StatusConflict, types.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, crypto.ErrSealed):
+			c.JSON(http.StatusServiceUnavailable, types.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "数据完整性校验失败: " + err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -7,6 +7,9 @@ import (
 
 	"github.com/akinoccc/hysaif/api/models"
 	"github.com/akinoccc/hysaif/api/packages/context"
+	"github.com/akinoccc/hysaif/api/packages/crypto"
+	"github.com/akinoccc/hysaif/api/packages/recyclebin"
+	"github.com/akinoccc/hysaif/api/packages/rotation"
 	"github.com/akinoccc/hysaif/api/types"
 	"github.com/gin-gonic/gin"
 )
@@ -24,6 +27,10 @@ func AuditLog(action, resource string) gin.HandlerFunc {
 		}
 
 		resourceID := c.Param("id")
+		if resourceID == "" {
+			// 托管密钥等按名称访问的资源
+			resourceID = c.Param("name")
+		}
 
 		// 创建审计日志记录
 		auditLog := models.AuditLog{
@@ -44,6 +51,84 @@ func AuditLog(action, resource string) gin.HandlerFunc {
 	}
 }
 
+// RecordEncryptionFallback 记录加密回退到本地AES的审计日志，操作用户为执行写入的用户，后台任务触发的回退没有操作用户
+//
+// 回退发生在数据库写入过程中，因此异步保存，避免与当前事务争用连接
+func RecordEncryptionFallback(event crypto.FallbackEvent) {
+	details := ""
+	if jsonBytes, err := json.Marshal(map[string]interface{}{
+		"provider":    event.Provider,
+		"environment": event.Environment,
+		"policy":      event.Policy,
+		"table":       event.Table,
+		"error":       event.Error,
+	}); err == nil {
+		details = string(jsonBytes)
+	}
+
+	auditLog := models.AuditLog{
+		UserID:     event.UserID,
+		Action:     types.AuditLogActionFallback,
+		Resource:   types.AuditLogResourceEncryption,
+		ResourceID: event.ID,
+		Details:    details,
+	}
+
+	go func() {
+		if err := models.DB.Create(&auditLog).Error; err != nil {
+			fmt.Printf("保存审计日志失败: %v\n", err)
+		}
+	}()
+}
+
+// RecordScheduledRotation 记录定时轮换的审计日志，没有操作用户
+func RecordScheduledRotation(event rotation.Event) {
+	details := ""
+	if jsonBytes, err := json.Marshal(map[string]interface{}{
+		"policy_id": event.PolicyID,
+		"rotator":   event.Rotator,
+		"version":   event.Version,
+		"scheduled": true,
+		"error":     event.Error,
+	}); err == nil {
+		details = string(jsonBytes)
+	}
+
+	auditLog := models.AuditLog{
+		Action:     types.AuditLogActionRotate,
+		Resource:   GetSecretResourceType(event.SecretItemType),
+		ResourceID: event.SecretItemID,
+		Details:    details,
+	}
+	if err := models.DB.Create(&auditLog).Error; err != nil {
+		fmt.Printf("保存审计日志失败: %v\n", err)
+	}
+}
+
+// RecordScheduledPurge 记录定时清除回收站信息项的审计日志，没有操作用户
+func RecordScheduledPurge(event recyclebin.Event) {
+	details := ""
+	if jsonBytes, err := json.Marshal(map[string]interface{}{
+		"name":          event.Name,
+		"deleted_by_id": event.DeletedByID,
+		"deleted_at":    event.DeletedAt,
+		"scheduled":     true,
+		"error":         event.Error,
+	}); err == nil {
+		details = string(jsonBytes)
+	}
+
+	auditLog := models.AuditLog{
+		Action:     types.AuditLogActionPurge,
+		Resource:   GetSecretResourceType(event.SecretItemType),
+		ResourceID: event.SecretItemID,
+		Details:    details,
+	}
+	if err := models.DB.Create(&auditLog).Error; err != nil {
+		fmt.Printf("保存审计日志失败: %v\n", err)
+	}
+}
+
 // getRequestDetails 获取请求详情，用于审计日志
 func getRequestDetails(c *gin.Context) string {
 	details := map[string]interface{}{
@@ -120,7 +205,7 @@ func getActionFromMethod(method string) string {
 	}
 }
 
-// GetSecretResourceType 根据请求路径获取资源类型
+// GetSecretResourceType 根据信息项类型获取审计日志的资源类型
 func GetSecretResourceType(resourceType string) string {
 	switch resourceType {
 	case "api_key":
@@ -133,7 +218,15 @@ func GetSecretResourceType(resourceType string) string {
 		return types.AuditLogResourcePassword
 	case "token":
 		return types.AuditLogResourceToken
+	case "database":
+		return types.AuditLogResourceDatabase
+	case "totp":
+		return types.AuditLogResourceTOTP
 	default:
+		// 管理员定义的自定义类型以类型名作为资源
+		if models.IsCustomSecretType(resourceType) {
+			return resourceType
+		}
 		return types.AuditLogResourceCustom
 	}
 }
@@ -8,6 +8,7 @@ import (
 
 	"github.com/akinoccc/hysaif/api/models"
 	"github.com/akinoccc/hysaif/api/packages/context"
+	"github.com/akinoccc/hysaif/api/packages/crypto"
 	"github.com/akinoccc/hysaif/api/types"
 
 	"github.com/gin-gonic/gin"
@@ -122,6 +123,39 @@ func RequirePermission(resource, action string) gin.HandlerFunc {
 	}
 }
 
+// RequireTransitKeyPermission 检查用户对路径中托管密钥的权限
+func RequireTransitKeyPermission(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := context.GetCurrentUser(c)
+		if user == nil {
+			c.JSON(http.StatusUnauthorized, types.ErrorResponse{Error: "用户信息不存在"})
+			c.Abort()
+			return
+		}
+
+		if !user.HasTransitKeyPermission(c.Param("name"), action) {
+			c.JSON(http.StatusForbidden, types.ErrorResponse{Error: "权限不足，无法使用该密钥"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireUnsealed 系统处于密封状态时拒绝访问敏感数据
+func RequireUnsealed() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if crypto.IsSealed() {
+			c.JSON(http.StatusServiceUnavailable, types.ErrorResponse{Error: crypto.ErrSealed.Error()})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
 // GenerateJWT 生成JWT令牌
 func GenerateJWT(user models.User) (string, error) {
 	claims := Claims{
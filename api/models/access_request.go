@@ -1,6 +1,8 @@
 package models
 
 import (
+	"errors"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -19,7 +21,8 @@ const (
 // AccessRequest 访问申请模型
 type AccessRequest struct {
 	ModelBase
-	SecretItemID string `json:"-" gorm:"not null"`               // 申请访问的密钥项ID
+	SecretItemID string `json:"-" gorm:"not null"`               // 申请访问的密钥项ID，申请访问文件夹时为空
+	FolderID     string `json:"-" gorm:"type:varchar(36);index"` // 申请访问的文件夹ID，批准后可访问文件夹及其子文件夹中的所有信息项
 	ApplicantID  string `json:"-" gorm:"not null"`               // 申请人ID
 	Reason       string `json:"reason" gorm:"not null"`          // 申请理由
 	Status       string `json:"status" gorm:"default:'pending'"` // 申请状态
@@ -34,6 +37,7 @@ type AccessRequest struct {
 
 	// 关联
 	SecretItem SecretItem `json:"secret_item" gorm:"foreignKey:SecretItemID;references:ID"`
+	Folder     *Folder    `json:"folder,omitempty" gorm:"foreignKey:FolderID;references:ID"`
 	Applicant  User       `json:"applicant" gorm:"foreignKey:ApplicantID;references:ID"`
 	Approver   User       `json:"approver" gorm:"foreignKey:ApprovedByID;references:ID"`
 }
@@ -68,3 +72,174 @@ func (ar *AccessRequest) IsExpired() bool {
 func (ar *AccessRequest) CanAccess() bool {
 	return ar.IsValid() && !ar.IsExpired()
 }
+
+// RevertApproval 撤回批准，申请恢复为待审批状态；用于批准后在目标数据库中创建临时用户失败的情况
+func (ar *AccessRequest) RevertApproval() error {
+	ar.Status = RequestStatusPending
+	ar.ApprovedByID = ""
+	ar.ApprovedAt = 0
+	ar.ValidFrom = 0
+	ar.ValidUntil = 0
+	ar.Note = ""
+	return DB.Model(ar).UpdateColumns(map[string]interface{}{
+		"status":         ar.Status,
+		"approved_by_id": ar.ApprovedByID,
+		"approved_at":    ar.ApprovedAt,
+		"valid_from":     ar.ValidFrom,
+		"valid_until":    ar.ValidUntil,
+		"note":           ar.Note,
+	}).Error
+}
+
+// TargetName 获取申请访问的对象名称，用于通知内容，需要预加载 SecretItem 和 Folder
+func (ar *AccessRequest) TargetName() string {
+	if ar.FolderID != "" && ar.Folder != nil {
+		return "文件夹 " + ar.Folder.Path
+	}
+	return ar.SecretItem.Name
+}
+
+// validAccessRequests 用户当前有效的已批准访问申请
+func validAccessRequests(userID string) *gorm.DB {
+	now := uint64(time.Now().UnixMilli())
+	return DB.Model(&AccessRequest{}).Where("applicant_id = ? AND status = ? AND ? BETWEEN valid_from AND valid_until",
+		userID, RequestStatusApproved, now)
+}
+
+// FindValidAccessRequest 查找授予用户访问信息项的有效申请，对信息项所在文件夹及其上级文件夹的申请同样有效；
+// 没有有效申请时返回 nil
+func FindValidAccessRequest(userID string, item *SecretItem) (*AccessRequest, error) {
+	folderIDs, err := item.ancestorFolderIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	var accessRequest AccessRequest
+	err = validAccessRequests(userID).
+		Where("(secret_item_id = ? OR folder_id IN ?)", item.ID, folderIDs).
+		Order("valid_until DESC").
+		First(&accessRequest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &accessRequest, nil
+}
+
+// ValidGrantHolderIDs 获取持有信息项或其所在文件夹（含上级文件夹）有效访问申请的用户ID
+func ValidGrantHolderIDs(item *SecretItem) ([]string, error) {
+	folderIDs, err := item.ancestorFolderIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	var userIDs []string
+	now := uint64(time.Now().UnixMilli())
+	err = DB.Model(&AccessRequest{}).
+		Where("status = ? AND ? BETWEEN valid_from AND valid_until", RequestStatusApproved, now).
+		Where("(secret_item_id = ? OR folder_id IN ?)", item.ID, folderIDs).
+		Distinct().
+		Pluck("applicant_id", &userIDs).Error
+	return userIDs, err
+}
+
+// CanAccessSecretItem 检查用户能否查看信息项：创建者、持有信息项或其所在文件夹（含上级文件夹）的有效访问申请，
+// 或拥有所在文件夹的 folder:<路径> 读取权限；位于根目录的信息项不受文件夹权限影响
+func (u *User) CanAccessSecretItem(item *SecretItem) (bool, error) {
+	if item.CreatedByID == u.ID {
+		return true, nil
+	}
+	// 数据库连接的管理员凭据和TOTP种子只对创建者和可以修改信息项的用户开放，
+	// 申请人通过访问申请获取临时数据库用户或当前验证码
+	if item.Type == SecretTypeDatabase || item.Type == SecretTypeTOTP {
+		return u.HasPermission("secret", "update"), nil
+	}
+	return u.HasGrantedAccess(item)
+}
+
+// HasGrantedAccess 检查用户是否持有信息项或其所在文件夹的有效访问申请，或拥有所在文件夹的读取权限
+func (u *User) HasGrantedAccess(item *SecretItem) (bool, error) {
+	accessRequest, err := FindValidAccessRequest(u.ID, item)
+	if err != nil {
+		return false, err
+	}
+	if accessRequest != nil {
+		return true, nil
+	}
+
+	if item.FolderID == "" {
+		return false, nil
+	}
+	folder, err := GetFolder(item.FolderID)
+	if err != nil {
+		return false, err
+	}
+	return u.HasFolderPermission(folder.Path, "read"), nil
+}
+
+// GetApprovedAccessSecretItemIDs 从信息项中筛选出用户持有有效访问申请的信息项ID，包括通过文件夹申请继承的访问权限
+func GetApprovedAccessSecretItemIDs(userID string, items []SecretItem) (map[string]bool, error) {
+	approved := make(map[string]bool)
+	if len(items) == 0 {
+		return approved, nil
+	}
+
+	var accessRequests []AccessRequest
+	if err := validAccessRequests(userID).Preload("Folder").Find(&accessRequests).Error; err != nil {
+		return nil, err
+	}
+	var folderPaths []string
+	for _, accessRequest := range accessRequests {
+		if accessRequest.SecretItemID != "" {
+			approved[accessRequest.SecretItemID] = true
+		}
+		if accessRequest.Folder != nil {
+			folderPaths = append(folderPaths, accessRequest.Folder.Path)
+		}
+	}
+	if len(folderPaths) == 0 {
+		return approved, nil
+	}
+
+	folderIDs, err := GetSubtreeFolderIDs(folderPaths)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if slices.Contains(folderIDs, item.FolderID) {
+			approved[item.ID] = true
+		}
+	}
+	return approved, nil
+}
+
+// ApprovedAccessSecretItems 用户持有有效访问申请的信息项查询，包括通过文件夹申请继承的访问权限
+func ApprovedAccessSecretItems(userID string) (*gorm.DB, error) {
+	var accessRequests []AccessRequest
+	if err := validAccessRequests(userID).Preload("Folder").Find(&accessRequests).Error; err != nil {
+		return nil, err
+	}
+
+	itemIDs := []string{}
+	var folderPaths []string
+	for _, accessRequest := range accessRequests {
+		if accessRequest.SecretItemID != "" {
+			itemIDs = append(itemIDs, accessRequest.SecretItemID)
+		}
+		if accessRequest.Folder != nil {
+			folderPaths = append(folderPaths, accessRequest.Folder.Path)
+		}
+	}
+	folderIDs, err := GetSubtreeFolderIDs(folderPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	query := DB.Model(&SecretItem{})
+	if len(folderIDs) == 0 {
+		return query.Where("id IN ?", itemIDs), nil
+	}
+	return query.Where("id IN ? OR folder_id IN ?", itemIDs, folderIDs), nil
+}
@@ -0,0 +1,157 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/akinoccc/hysaif/api/packages/crypto"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SecretBlindIndex 敏感字段的盲索引，用于在不解密的情况下按字段值精确查找密钥项
+type SecretBlindIndex struct {
+	ModelBase
+	SecretItemID string `json:"secret_item_id" gorm:"index;not null"`     // 关联的密钥项ID
+	Field        string `json:"field" gorm:"not null"`                    // 字段名
+	Hash         string `json:"-" gorm:"type:varchar(64);index;not null"` // 字段值的HMAC
+}
+
+// 建立盲索引的字段
+const (
+	BlindIndexFieldUsername  = "username"
+	BlindIndexFieldPassword  = "password"
+	BlindIndexFieldAPIKey    = "api_key"
+	BlindIndexFieldAccessKey = "access_key"
+	BlindIndexFieldEndpoint  = "endpoint"
+)
+
+// BlindIndexFields 所有建立盲索引的字段
+var BlindIndexFields = []string{
+	BlindIndexFieldUsername,
+	BlindIndexFieldPassword,
+	BlindIndexFieldAPIKey,
+	BlindIndexFieldAccessKey,
+	BlindIndexFieldEndpoint,
+}
+
+// BeforeCreate 钩子函数，在创建记录之前设置ID
+func (bi *SecretBlindIndex) BeforeCreate(tx *gorm.DB) (err error) {
+	bi.ID = uuid.New().String()
+	return
+}
+
+// blindIndexValues 获取需要建立盲索引的字段值
+func (s *SecretItemData) blindIndexValues() map[string]string {
+	return map[string]string{
+		BlindIndexFieldUsername:  s.Username,
+		BlindIndexFieldPassword:  s.Password,
+		BlindIndexFieldAPIKey:    s.APIKey,
+		BlindIndexFieldAccessKey: s.AccessKey,
+		BlindIndexFieldEndpoint:  s.Endpoint,
+	}
+}
+
+// normalizeBlindIndexValue 规范化字段值，保存和查找时使用相同的规则
+func normalizeBlindIndexValue(field, value string) string {
+	value = strings.TrimSpace(value)
+	if field == BlindIndexFieldEndpoint {
+		value = strings.TrimRight(strings.ToLower(value), "/")
+	}
+	return value
+}
+
+// updateBlindIndexes 使用密钥项当前数据重建其盲索引
+func (si *SecretItem) updateBlindIndexes(tx *gorm.DB) error {
+	// 未解密（如已隔离）的数据保留原有索引
+	if si.Data == nil || !si.Data.opened() || !crypto.BlindIndexEnabled() {
+		return nil
+	}
+
+	values := si.Data.blindIndexValues()
+	var indexes []SecretBlindIndex
+	for _, field := range BlindIndexFields {
+		value := normalizeBlindIndexValue(field, values[field])
+		if value == "" {
+			continue
+		}
+
+		hash, err := crypto.BlindIndex(field, value)
+		if err != nil {
+			return fmt.Errorf("计算盲索引失败: %w", err)
+		}
+		indexes = append(indexes, SecretBlindIndex{SecretItemID: si.ID, Field: field, Hash: hash})
+	}
+
+	if err := tx.Where("secret_item_id = ?", si.ID).Delete(&SecretBlindIndex{}).Error; err != nil {
+		return fmt.Errorf("删除盲索引失败: %w", err)
+	}
+	if len(indexes) == 0 {
+		return nil
+	}
+	if err := tx.Create(&indexes).Error; err != nil {
+		return fmt.Errorf("保存盲索引失败: %w", err)
+	}
+	return nil
+}
+
+// FindSecretItemsByBlindIndex 按字段值精确查找密钥项，返回密钥项ID及匹配的字段；未指定字段时查找所有建立索引的字段
+func FindSecretItemsByBlindIndex(fields []string, value string) (map[string][]string, error) {
+	if len(fields) == 0 {
+		fields = BlindIndexFields
+	}
+
+	var conditions *gorm.DB
+	for _, field := range fields {
+		normalized := normalizeBlindIndexValue(field, value)
+		if normalized == "" {
+			continue
+		}
+
+		hash, err := crypto.BlindIndex(field, normalized)
+		if err != nil {
+			return nil, fmt.Errorf("计算盲索引失败: %w", err)
+		}
+		if conditions == nil {
+			conditions = DB.Where("field = ? AND hash = ?", field, hash)
+		} else {
+			conditions = conditions.Or("field = ? AND hash = ?", field, hash)
+		}
+	}
+
+	matches := make(map[string][]string)
+	if conditions == nil {
+		return matches, nil
+	}
+
+	var indexes []SecretBlindIndex
+	if err := DB.Where(conditions).Find(&indexes).Error; err != nil {
+		return nil, err
+	}
+
+	for _, index := range indexes {
+		matches[index.SecretItemID] = append(matches[index.SecretItemID], index.Field)
+	}
+	return matches, nil
+}
+
+// RebuildBlindIndexes 为所有密钥项重建盲索引，用于升级后为已有数据建立索引或更换盲索引密钥后重新计算
+func RebuildBlindIndexes() (int, error) {
+	var (
+		items []SecretItem
+		count int
+	)
+
+	// 回收站中的信息项同样重建，恢复后仍可查找
+	result := DB.Unscoped().FindInBatches(&items, 100, func(tx *gorm.DB, batch int) error {
+		for i := range items {
+			if err := items[i].updateBlindIndexes(DB); err != nil {
+				return fmt.Errorf("密钥项 %s: %w", items[i].ID, err)
+			}
+			count++
+		}
+		return nil
+	})
+	return count, result.Error
+}
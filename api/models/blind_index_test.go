@@ -0,0 +1,28 @@
+package models
+
+import "testing"
+
+func TestNormalizeBlindIndexValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		field string
+		value string
+		want  string
+	}{
+		{"去除首尾空白", BlindIndexFieldUsername, "  admin\t", "admin"},
+		{"用户名区分大小写", BlindIndexFieldUsername, "Admin", "Admin"},
+		{"密码区分大小写", BlindIndexFieldPassword, " Pa55/Word/ ", "Pa55/Word/"},
+		{"API密钥区分大小写", BlindIndexFieldAPIKey, "sk-ABC", "sk-ABC"},
+		{"访问密钥区分大小写", BlindIndexFieldAccessKey, "AKIAEXAMPLE\n", "AKIAEXAMPLE"},
+		{"端点忽略大小写", BlindIndexFieldEndpoint, "HTTPS://DB.Example.com", "https://db.example.com"},
+		{"端点去除末尾斜杠", BlindIndexFieldEndpoint, " https://db.example.com/api// ", "https://db.example.com/api"},
+		{"空白值", BlindIndexFieldEndpoint, "   ", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeBlindIndexValue(tt.field, tt.value); got != tt.want {
+				t.Fatalf("normalizeBlindIndexValue(%q, %q) = %q，期望 %q", tt.field, tt.value, got, tt.want)
+			}
+		})
+	}
+}
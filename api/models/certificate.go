@@ -0,0 +1,163 @@
+package models
+
+import (
+	"crypto"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SecretTypeCertificate 证书类型信息项
+const SecretTypeCertificate = "certificate"
+
+// CertificateMetadata 证书的非敏感元数据，保存时从叶子证书解析，可在列表中搜索
+type CertificateMetadata struct {
+	Subject           string   `json:"subject"`            // 主题
+	Issuer            string   `json:"issuer"`             // 颁发者
+	SANs              []string `json:"sans,omitempty"`     // 使用者备用名称：DNS、IP、邮箱和URI
+	SerialNumber      string   `json:"serial_number"`      // 序列号，大写十六进制
+	FingerprintSHA256 string   `json:"fingerprint_sha256"` // SHA-256指纹，格式与 openssl x509 -fingerprint 一致
+	FingerprintSHA1   string   `json:"fingerprint_sha1"`   // SHA-1指纹
+	KeyAlgorithm      string   `json:"key_algorithm"`      // 公钥算法
+	IsCA              bool     `json:"is_ca"`              // 是否为CA证书
+	NotBefore         uint64   `json:"not_before"`         // 生效时间
+	NotAfter          uint64   `json:"not_after"`          // 过期时间
+	ChainLength       int      `json:"chain_length"`       // 证书链中的证书数量，包含叶子证书
+	HasPrivateKey     bool     `json:"has_private_key"`    // 是否保存了私钥
+}
+
+// parseCertificateData 解析证书链和可选的私钥，校验证书链顺序及私钥与叶子证书是否匹配
+func parseCertificateData(data *SecretItemData) (*CertificateMetadata, error) {
+	chain, err := parseCertificateChain(data.Certificate)
+	if err != nil {
+		return nil, err
+	}
+	leaf := chain[0]
+
+	for i := 0; i+1 < len(chain); i++ {
+		if err := chain[i].CheckSignatureFrom(chain[i+1]); err != nil {
+			return nil, fmt.Errorf("证书链第 %d 个证书不是由下一个证书签发的，证书链应从叶子证书开始按顺序排列", i+1)
+		}
+	}
+
+	if data.PrivateKey != "" {
+		if err := checkCertificateKey(leaf, data.PrivateKey); err != nil {
+			return nil, err
+		}
+	}
+
+	sha256Sum := sha256.Sum256(leaf.Raw)
+	sha1Sum := sha1.Sum(leaf.Raw)
+	return &CertificateMetadata{
+		Subject:           leaf.Subject.String(),
+		Issuer:            leaf.Issuer.String(),
+		SANs:              certificateSANs(leaf),
+		SerialNumber:      fmt.Sprintf("%X", leaf.SerialNumber),
+		FingerprintSHA256: formatFingerprint(sha256Sum[:]),
+		FingerprintSHA1:   formatFingerprint(sha1Sum[:]),
+		KeyAlgorithm:      leaf.PublicKeyAlgorithm.String(),
+		IsCA:              leaf.IsCA,
+		NotBefore:         uint64(leaf.NotBefore.UnixMilli()),
+		NotAfter:          uint64(leaf.NotAfter.UnixMilli()),
+		ChainLength:       len(chain),
+		HasPrivateKey:     data.PrivateKey != "",
+	}, nil
+}
+
+// parseCertificateChain 解析PEM格式的证书链，第一个证书为叶子证书
+func parseCertificateChain(pemChain string) ([]*x509.Certificate, error) {
+	if strings.TrimSpace(pemChain) == "" {
+		return nil, errors.New("证书类型必须提供PEM格式的证书")
+	}
+
+	var chain []*x509.Certificate
+	rest := []byte(pemChain)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("证书字段只能包含证书，发现 %s", block.Type)
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("解析第 %d 个证书失败: %w", len(chain)+1, err)
+		}
+		chain = append(chain, cert)
+	}
+
+	if len(chain) == 0 {
+		return nil, errors.New("未找到PEM格式的证书")
+	}
+	if strings.TrimSpace(string(rest)) != "" {
+		return nil, errors.New("证书包含无法解析的内容")
+	}
+	return chain, nil
+}
+
+// checkCertificateKey 校验PEM格式的私钥与叶子证书的公钥是否匹配
+func checkCertificateKey(leaf *x509.Certificate, privateKeyPEM string) error {
+	block, _ := pem.Decode([]byte(privateKeyPEM))
+	if block == nil {
+		return errors.New("私钥不是有效的PEM格式")
+	}
+
+	var (
+		key any
+		err error
+	)
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "ENCRYPTED PRIVATE KEY":
+		return errors.New("不支持加密的私钥，请提供未加密的PEM私钥")
+	default:
+		return fmt.Errorf("不支持的私钥类型: %s", block.Type)
+	}
+	if err != nil {
+		return fmt.Errorf("解析私钥失败: %w", err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return errors.New("不支持的私钥类型")
+	}
+	publicKey, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !publicKey.Equal(leaf.PublicKey) {
+		return errors.New("私钥与证书不匹配")
+	}
+	return nil
+}
+
+// certificateSANs 获取证书的所有使用者备用名称
+func certificateSANs(cert *x509.Certificate) []string {
+	sans := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+	return sans
+}
+
+// formatFingerprint 将摘要格式化为冒号分隔的大写十六进制
+func formatFingerprint(sum []byte) string {
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
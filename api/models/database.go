@@ -0,0 +1,307 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/akinoccc/hysaif/api/packages/crypto"
+	"github.com/akinoccc/hysaif/api/packages/dbengine"
+	"github.com/akinoccc/hysaif/api/packages/password"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SecretTypeDatabase 数据库连接类型信息项，保存目标数据库的管理员凭据，访问申请批准后为申请人签发临时数据库用户
+const SecretTypeDatabase = "database"
+
+// DatabaseLeaseTable 数据库临时用户数据表名
+const DatabaseLeaseTable = "database_leases"
+
+// DatabaseLeasePasswordField 临时用户密码字段名，用于构造密文绑定的附加认证数据
+const DatabaseLeasePasswordField = "password"
+
+// ErrDatabaseUnavailable 无法连接目标数据库或执行语句失败
+var ErrDatabaseUnavailable = errors.New("目标数据库操作失败")
+
+// DatabaseMetadata 数据库连接的非敏感元数据，保存时从连接信息解析
+type DatabaseMetadata struct {
+	Engine   string `json:"engine"`   // 数据库引擎
+	Address  string `json:"address"`  // 地址
+	Database string `json:"database"` // 数据库名
+}
+
+// DatabaseLease 访问申请批准后在目标数据库中创建的临时用户，访问申请过期或被作废后删除
+type DatabaseLease struct {
+	ModelBase
+	AccessRequestID string `json:"access_request_id" gorm:"type:varchar(36);uniqueIndex;not null"` // 访问申请ID
+	SecretItemID    string `json:"secret_item_id" gorm:"type:varchar(36);index;not null"`          // 数据库连接信息项ID
+	UserID          string `json:"user_id" gorm:"type:varchar(36);index;not null"`                 // 申请人ID
+	Username        string `json:"username" gorm:"not null"`                                       // 临时用户名
+	Password        string `json:"-" gorm:"type:text;not null"`                                    // 主密钥加密后的临时用户密码
+	ExpiresAt       uint64 `json:"expires_at"`                                                     // 到期时间，与访问申请的有效期一致
+	RevokedAt       uint64 `json:"revoked_at"`                                                     // 删除临时用户的时间，0表示仍然有效
+	RevokeError     string `json:"revoke_error,omitempty"`                                         // 最近一次删除失败的原因
+
+	password    string // 创建时待加密的密码
+	environment string // 信息项所属环境，用于选择加密策略
+	approvedBy  string // 批准访问申请的用户，用于记录加密回退的审计日志
+}
+
+// BeforeCreate 钩子函数，设置ID并使用主密钥加密临时用户密码
+func (l *DatabaseLease) BeforeCreate(tx *gorm.DB) (err error) {
+	l.ID = uuid.New().String()
+	l.Password, err = crypto.EncryptWith([]byte(l.password), l.options())
+	if err != nil {
+		return fmt.Errorf("加密临时用户密码失败: %w", err)
+	}
+	return
+}
+
+// options 临时用户密码的加密选项，密文与数据行绑定
+func (l *DatabaseLease) options() crypto.Options {
+	return crypto.Options{Environment: l.environment, Table: DatabaseLeaseTable, ID: l.ID, Field: DatabaseLeasePasswordField, UserID: l.approvedBy}
+}
+
+// Active 临时用户是否仍然存在
+func (l *DatabaseLease) Active() bool {
+	return l.RevokedAt == 0
+}
+
+// Credentials 获取临时用户的连接信息，不包含管理员凭据和语句模板
+func (l *DatabaseLease) Credentials(item *SecretItem) (*SecretItemData, error) {
+	l.environment = item.Environment
+	plaintext, err := crypto.DecryptWith(l.Password, l.options())
+	if err != nil {
+		return nil, fmt.Errorf("解密临时用户密码失败: %w", err)
+	}
+	return &SecretItemData{
+		Engine:   item.Data.Engine,
+		Address:  item.Data.Address,
+		Database: item.Data.Database,
+		SSLMode:  item.Data.SSLMode,
+		Username: l.Username,
+		Password: string(plaintext),
+	}, nil
+}
+
+// parseDatabaseData 校验数据库连接信息和语句模板，未提供撤销语句时使用引擎默认的撤销语句
+func parseDatabaseData(data *SecretItemData) (*DatabaseMetadata, error) {
+	connection := databaseConnection(data)
+	if err := connection.Validate(); err != nil {
+		return nil, err
+	}
+	for _, field := range data.stringFields() {
+		if HasSecretReference(*field.value) {
+			return nil, fmt.Errorf("数据库连接的字段 %s 不能使用引用，需要填写实际值", field.name)
+		}
+	}
+
+	if !strings.Contains(data.CreationStatements, dbengine.PlaceholderName) ||
+		!strings.Contains(data.CreationStatements, dbengine.PlaceholderPassword) {
+		return nil, fmt.Errorf("创建语句必须包含 %s 和 %s 占位符", dbengine.PlaceholderName, dbengine.PlaceholderPassword)
+	}
+	if strings.TrimSpace(data.RevocationStatements) == "" {
+		data.RevocationStatements = dbengine.DefaultRevocationStatements(data.Engine)
+	}
+	if !strings.Contains(data.RevocationStatements, dbengine.PlaceholderName) {
+		return nil, fmt.Errorf("撤销语句必须包含 %s 占位符", dbengine.PlaceholderName)
+	}
+
+	return &DatabaseMetadata{Engine: data.Engine, Address: data.Address, Database: data.Database}, nil
+}
+
+// databaseConnection 获取数据库连接信息项中的管理员连接信息
+func databaseConnection(data *SecretItemData) dbengine.Connection {
+	return dbengine.Connection{
+		Engine:   data.Engine,
+		Address:  data.Address,
+		Database: data.Database,
+		Username: data.Username,
+		Password: data.Password,
+		SSLMode:  data.SSLMode,
+	}
+}
+
+// IssueDatabaseLease 为已批准的访问申请保存临时用户记录，有效期与访问申请一致；需要在保存访问申请的事务中调用，
+// 事务提交后再调用 Provision 在目标数据库中创建用户，避免事务回滚后遗留没有记录、无法删除的用户
+func IssueDatabaseLease(tx *gorm.DB, accessRequest *AccessRequest, item *SecretItem) (*DatabaseLease, error) {
+	if item.DataUnavailable() || item.Data == nil {
+		return nil, fmt.Errorf("当前部署未持有环境 '%s' 的主密钥，无法读取数据库连接", item.Environment)
+	}
+
+	// 之前批准失败的申请重新批准时，清理已删除的临时用户记录；仍未删除的临时用户由定时任务重试删除
+	if err := tx.Where("access_request_id = ? AND revoked_at <> 0", accessRequest.ID).Delete(&DatabaseLease{}).Error; err != nil {
+		return nil, err
+	}
+	var pending int64
+	if err := tx.Model(&DatabaseLease{}).Where("access_request_id = ?", accessRequest.ID).Count(&pending).Error; err != nil {
+		return nil, err
+	}
+	if pending > 0 {
+		return nil, fmt.Errorf("%w: 上次签发失败的临时用户尚未删除，请稍后重试", ErrDatabaseUnavailable)
+	}
+
+	// 用户名和密码只包含字母和数字，可以直接放入语句模板中的引号内
+	username, err := databaseLeaseUsername(accessRequest.ApplicantID)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := password.Generate(password.GenerateOptions{Length: 32, Lowercase: true, Uppercase: true, Digits: true})
+	if err != nil {
+		return nil, err
+	}
+
+	lease := DatabaseLease{
+		AccessRequestID: accessRequest.ID,
+		SecretItemID:    item.ID,
+		UserID:          accessRequest.ApplicantID,
+		Username:        username,
+		ExpiresAt:       accessRequest.ValidUntil,
+		password:        secret,
+		environment:     item.Environment,
+		approvedBy:      accessRequest.ApprovedByID,
+	}
+	if err := tx.Create(&lease).Error; err != nil {
+		return nil, err
+	}
+	return &lease, nil
+}
+
+// Provision 执行创建语句，在目标数据库中创建 IssueDatabaseLease 保存的临时用户；
+// 失败时删除可能已部分创建的用户，删除失败时由定时任务重试，调用方需要撤回访问申请的批准
+func (l *DatabaseLease) Provision(item *SecretItem) error {
+	connection := databaseConnection(item.Data)
+	if err := connection.Exec(l.render(item.Data.CreationStatements, item.Data, l.password)); err != nil {
+		if revokeErr := l.Revoke(); revokeErr != nil {
+			log.Printf("清理创建失败的数据库临时用户 %s 失败: %v", l.Username, revokeErr)
+		}
+		return fmt.Errorf("%w: %v", ErrDatabaseUnavailable, err)
+	}
+	return nil
+}
+
+// Revoke 在目标数据库中删除临时用户；失败时记录原因，由定时任务重试
+func (l *DatabaseLease) Revoke() error {
+	if !l.Active() {
+		return nil
+	}
+
+	// 回收站中的信息项仍保存管理员凭据，同样可以删除临时用户
+	var item SecretItem
+	err := DB.Unscoped().Where("id = ?", l.SecretItemID).First(&item).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// 信息项删除前会删除所有临时用户，这里只处理已清除的异常情况，避免无限重试
+		return l.markRevoked("信息项已清除，无法连接目标数据库删除临时用户")
+	}
+	if err != nil {
+		return err
+	}
+	if item.DataUnavailable() || item.Data == nil {
+		err = fmt.Errorf("当前部署未持有环境 '%s' 的主密钥，无法读取数据库连接", item.Environment)
+	} else {
+		err = databaseConnection(item.Data).Exec(l.render(item.Data.RevocationStatements, item.Data, ""))
+	}
+	if err != nil {
+		DB.Model(l).UpdateColumn("revoke_error", err.Error())
+		return fmt.Errorf("%w: %v", ErrDatabaseUnavailable, err)
+	}
+	return l.markRevoked("")
+}
+
+// markRevoked 标记临时用户已删除
+func (l *DatabaseLease) markRevoked(reason string) error {
+	l.RevokedAt = uint64(time.Now().UnixMilli())
+	l.RevokeError = reason
+	return DB.Model(l).UpdateColumns(map[string]interface{}{
+		"revoked_at":   l.RevokedAt,
+		"revoke_error": l.RevokeError,
+	}).Error
+}
+
+// render 替换语句模板中的占位符
+func (l *DatabaseLease) render(template string, data *SecretItemData, secret string) []string {
+	return dbengine.Render(template, map[string]string{
+		dbengine.PlaceholderName:       l.Username,
+		dbengine.PlaceholderPassword:   secret,
+		dbengine.PlaceholderExpiration: time.UnixMilli(int64(l.ExpiresAt)).UTC().Format(time.RFC3339),
+		dbengine.PlaceholderDatabase:   data.Database,
+	})
+}
+
+// GetDatabaseLease 获取访问申请签发的临时用户
+func GetDatabaseLease(accessRequestID string) (*DatabaseLease, error) {
+	var lease DatabaseLease
+	if err := DB.Where("access_request_id = ?", accessRequestID).First(&lease).Error; err != nil {
+		return nil, err
+	}
+	return &lease, nil
+}
+
+// FindActiveDatabaseLease 查找用户通过有效访问申请获得的数据库临时用户，文件夹申请不签发临时用户；没有时返回 nil
+func FindActiveDatabaseLease(userID, secretItemID string) (*DatabaseLease, error) {
+	var lease DatabaseLease
+	err := DB.Where("user_id = ? AND secret_item_id = ? AND revoked_at = 0 AND access_request_id IN (?)",
+		userID, secretItemID, validAccessRequests(userID).Select("id")).
+		Order("expires_at DESC").
+		First(&lease).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &lease, nil
+}
+
+// RevokeAccessRequestLease 删除访问申请签发的临时用户，没有临时用户时直接返回
+func RevokeAccessRequestLease(accessRequestID string) error {
+	lease, err := GetDatabaseLease(accessRequestID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return lease.Revoke()
+}
+
+// RevokeSecretItemLeases 删除数据库连接信息项签发的所有临时用户，任一删除失败时返回错误
+func RevokeSecretItemLeases(secretItemID string) error {
+	var leases []DatabaseLease
+	if err := DB.Where("secret_item_id = ? AND revoked_at = 0", secretItemID).Find(&leases).Error; err != nil {
+		return err
+	}
+	for i := range leases {
+		if err := leases[i].Revoke(); err != nil {
+			return fmt.Errorf("删除临时用户 %s 失败: %w", leases[i].Username, err)
+		}
+	}
+	return nil
+}
+
+// StaleDatabaseLeases 获取需要删除的临时用户：访问申请已过期、被作废，或已超过有效期
+func StaleDatabaseLeases() ([]DatabaseLease, error) {
+	var leases []DatabaseLease
+	err := DB.Where("revoked_at = 0 AND (expires_at < ? OR access_request_id NOT IN (?))",
+		uint64(time.Now().UnixMilli()),
+		DB.Model(&AccessRequest{}).Select("id").Where("status = ?", RequestStatusApproved),
+	).Find(&leases).Error
+	return leases, err
+}
+
+// databaseLeaseUsername 生成临时用户名，包含申请人ID前缀便于在目标数据库中识别，长度不超过MySQL的32字符限制
+func databaseLeaseUsername(applicantID string) (string, error) {
+	prefix := strings.ReplaceAll(applicantID, "-", "")
+	if len(prefix) > 8 {
+		prefix = prefix[:8]
+	}
+	suffix, err := password.Generate(password.GenerateOptions{Length: 12, Lowercase: true, Digits: true})
+	if err != nil {
+		return "", err
+	}
+	return "hysaif_" + strings.ToLower(prefix) + "_" + suffix, nil
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/akinoccc/hysaif/api/packages/dbengine"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TestDatabaseLeasePostgres 使用Postgres容器测试临时用户的签发和删除，需要先启动
+// docker run -d -p 5432:5432 -e POSTGRES_PASSWORD=postgres postgres:16，并设置 SIMS_TEST_POSTGRES_ADDR（如 127.0.0.1:5432）；
+// SIMS_TEST_POSTGRES_USER、SIMS_TEST_POSTGRES_PASSWORD 和 SIMS_TEST_POSTGRES_DATABASE 默认均为 postgres
+func TestDatabaseLeasePostgres(t *testing.T) {
+	address := os.Getenv("SIMS_TEST_POSTGRES_ADDR")
+	if address == "" {
+		t.Skip("未设置 SIMS_TEST_POSTGRES_ADDR，跳过Postgres集成测试")
+	}
+	env := func(key string) string {
+		if value := os.Getenv(key); value != "" {
+			return value
+		}
+		return "postgres"
+	}
+
+	data := &SecretItemData{
+		Engine:             dbengine.EnginePostgres,
+		Address:            address,
+		Database:           env("SIMS_TEST_POSTGRES_DATABASE"),
+		Username:           env("SIMS_TEST_POSTGRES_USER"),
+		Password:           env("SIMS_TEST_POSTGRES_PASSWORD"),
+		SSLMode:            "disable",
+		CreationStatements: `CREATE ROLE "{{name}}" WITH LOGIN PASSWORD '{{password}}' VALID UNTIL '{{expiration}}'; GRANT CONNECT ON DATABASE "{{database}}" TO "{{name}}";`,
+	}
+	if _, err := parseDatabaseData(data); err != nil {
+		t.Fatalf("数据库连接信息无效: %v", err)
+	}
+
+	owner := uuid.NewString()
+	item := &SecretItem{Name: "lease-" + owner[:8], Type: SecretTypeDatabase, Environment: "development", Data: data, CreatedByID: owner}
+	if err := DB.Create(item).Error; err != nil {
+		t.Fatalf("创建信息项失败: %v", err)
+	}
+	now := uint64(time.Now().UnixMilli())
+	request := &AccessRequest{
+		SecretItemID: item.ID,
+		ApplicantID:  uuid.NewString(),
+		Reason:       "排查线上问题",
+		Status:       RequestStatusApproved,
+		ApprovedByID: owner,
+		ValidFrom:    now,
+		ValidUntil:   now + uint64(time.Hour.Milliseconds()),
+	}
+	if err := DB.Create(request).Error; err != nil {
+		t.Fatalf("创建访问申请失败: %v", err)
+	}
+
+	var lease *DatabaseLease
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		lease, err = IssueDatabaseLease(tx, request, item)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("签发临时用户失败: %v", err)
+	}
+	if err := lease.Provision(item); err != nil {
+		t.Fatalf("创建临时用户失败: %v", err)
+	}
+	t.Cleanup(func() { _ = lease.Revoke() })
+
+	credentials, err := lease.Credentials(item)
+	if err != nil {
+		t.Fatalf("读取临时用户失败: %v", err)
+	}
+	connection := databaseConnection(credentials)
+	if err := connection.Exec([]string{"SELECT 1"}); err != nil {
+		t.Fatalf("临时用户无法连接数据库: %v", err)
+	}
+
+	if err := RevokeAccessRequestLease(request.ID); err != nil {
+		t.Fatalf("删除临时用户失败: %v", err)
+	}
+	if err := connection.Exec([]string{"SELECT 1"}); err == nil {
+		t.Fatal("删除后临时用户仍能连接数据库")
+	}
+	revoked, err := GetDatabaseLease(request.ID)
+	if err != nil || revoked.Active() {
+		t.Fatalf("临时用户记录应标记为已删除: %+v，错误: %v", revoked, err)
+	}
+}
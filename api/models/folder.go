@@ -0,0 +1,320 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// FolderPermissionResource 文件夹的Casbin资源名，folder 资源的权限适用于所有文件夹
+const FolderPermissionResource = "folder"
+
+// FolderRootName 根目录的显示名称，用于审计日志和变更记录
+const FolderRootName = "/"
+
+// maxFolderPathLength 文件夹完整路径的最大长度
+const maxFolderPathLength = 512
+
+var folderNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+
+var (
+	// ErrFolderExists 同一路径的文件夹已存在
+	ErrFolderExists = errors.New("文件夹已存在")
+	// ErrFolderNotEmpty 文件夹下仍有子文件夹或信息项
+	ErrFolderNotEmpty = errors.New("文件夹下仍有子文件夹或信息项，无法删除")
+)
+
+// Folder 信息项文件夹，按路径组成树形结构，如 payments/prod/db
+//
+// 文件夹上的访问授权和 folder:<路径> 权限由其下所有子文件夹和信息项继承。
+// 路径在创建时确定，文件夹不能重命名或移动，避免已授予的路径权限意外覆盖其他信息项
+type Folder struct {
+	ModelBase
+	Name        string `json:"name" gorm:"type:varchar(64);not null"`              // 文件夹名称，不能包含 /
+	ParentID    string `json:"parent_id" gorm:"type:varchar(36);index"`            // 上级文件夹ID，为空表示位于根目录
+	Path        string `json:"path" gorm:"type:varchar(512);uniqueIndex;not null"` // 完整路径
+	Description string `json:"description"`                                        // 描述
+	CreatedByID string `json:"-" gorm:"index"`                                     // 创建者ID
+
+	Creator *User `json:"creator,omitempty" gorm:"foreignKey:CreatedByID;references:ID"`
+}
+
+// FolderBreadcrumb 面包屑路径中的一级文件夹
+type FolderBreadcrumb struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// BeforeCreate 钩子函数，在创建记录之前设置ID
+func (f *Folder) BeforeCreate(tx *gorm.DB) (err error) {
+	f.ID = uuid.New().String()
+	return
+}
+
+// FolderPathPermissionResource 获取文件夹的Casbin资源名，权限适用于该文件夹及其所有子文件夹
+func FolderPathPermissionResource(path string) string {
+	return FolderPermissionResource + ":" + path
+}
+
+// HasFolderPermission 检查用户对文件夹的权限，上级文件夹的 folder:<路径> 权限由子文件夹继承
+func (u *User) HasFolderPermission(path, action string) bool {
+	if u.HasPermission(FolderPermissionResource, action) {
+		return true
+	}
+	if path == "" {
+		return false
+	}
+	for _, ancestor := range folderAncestorPaths(path) {
+		if u.HasPermission(FolderPathPermissionResource(ancestor), action) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateFolderName 校验文件夹名称，只允许字母、数字、下划线、点和连字符
+func ValidateFolderName(name string) error {
+	if !folderNamePattern.MatchString(name) {
+		return fmt.Errorf("文件夹名称只能包含字母、数字、下划线、点和连字符，以字母或数字开头，长度不超过64")
+	}
+	return nil
+}
+
+// GetFolder 根据ID获取文件夹
+func GetFolder(id string) (*Folder, error) {
+	var folder Folder
+	if err := DB.Where("id = ?", id).First(&folder).Error; err != nil {
+		return nil, err
+	}
+	return &folder, nil
+}
+
+// CreateFolder 在上级文件夹下创建文件夹，parent 为空时创建在根目录
+func CreateFolder(name, description string, parent *Folder, createdByID string) (*Folder, error) {
+	if err := ValidateFolderName(name); err != nil {
+		return nil, err
+	}
+
+	folder := Folder{Name: name, Path: name, Description: description, CreatedByID: createdByID}
+	if parent != nil {
+		folder.ParentID = parent.ID
+		folder.Path = parent.Path + "/" + name
+	}
+	if len(folder.Path) > maxFolderPathLength {
+		return nil, fmt.Errorf("文件夹路径长度不能超过%d", maxFolderPathLength)
+	}
+
+	var count int64
+	if err := DB.Model(&Folder{}).Where("path = ?", folder.Path).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrFolderExists
+	}
+	if err := DB.Create(&folder).Error; err != nil {
+		return nil, err
+	}
+	return &folder, nil
+}
+
+// Delete 删除文件夹，仍有子文件夹或信息项时拒绝删除；回收站中的信息项恢复时回到根目录
+func (f *Folder) Delete() error {
+	var children, items int64
+	if err := DB.Model(&Folder{}).Where("parent_id = ?", f.ID).Count(&children).Error; err != nil {
+		return err
+	}
+	if err := DB.Model(&SecretItem{}).Where("folder_id = ?", f.ID).Count(&items).Error; err != nil {
+		return err
+	}
+	if children > 0 || items > 0 {
+		return ErrFolderNotEmpty
+	}
+	return DB.Delete(f).Error
+}
+
+// Breadcrumbs 获取由根目录到当前文件夹的面包屑路径
+func (f *Folder) Breadcrumbs() ([]FolderBreadcrumb, error) {
+	folders, err := getFoldersByPath(folderAncestorPaths(f.Path))
+	if err != nil {
+		return nil, err
+	}
+	return folderBreadcrumbs(f.Path, folders), nil
+}
+
+// LoadFolderBreadcrumbs 批量设置信息项所在文件夹的面包屑路径，位于根目录的信息项为空
+func LoadFolderBreadcrumbs(items []SecretItem) error {
+	folderIDs := make([]string, 0, len(items))
+	for _, item := range items {
+		if item.FolderID != "" {
+			folderIDs = append(folderIDs, item.FolderID)
+		}
+	}
+	if len(folderIDs) == 0 {
+		return nil
+	}
+
+	var folders []Folder
+	if err := DB.Where("id IN ?", folderIDs).Find(&folders).Error; err != nil {
+		return err
+	}
+	paths := make(map[string]string, len(folders))
+	var ancestorPaths []string
+	for _, folder := range folders {
+		paths[folder.ID] = folder.Path
+		ancestorPaths = append(ancestorPaths, folderAncestorPaths(folder.Path)...)
+	}
+
+	ancestors, err := getFoldersByPath(ancestorPaths)
+	if err != nil {
+		return err
+	}
+	for i := range items {
+		if path, ok := paths[items[i].FolderID]; ok {
+			items[i].Breadcrumbs = folderBreadcrumbs(path, ancestors)
+		}
+	}
+	return nil
+}
+
+// folderAncestorPaths 获取路径自身及所有上级路径，由根目录向下排列
+func folderAncestorPaths(path string) []string {
+	parts := strings.Split(path, "/")
+	paths := make([]string, len(parts))
+	for i := range parts {
+		paths[i] = strings.Join(parts[:i+1], "/")
+	}
+	return paths
+}
+
+// isFolderPathWithin 检查路径是否为指定文件夹自身或其子文件夹
+func isFolderPathWithin(path, ancestor string) bool {
+	return path == ancestor || strings.HasPrefix(path, ancestor+"/")
+}
+
+// getFoldersByPath 根据路径批量获取文件夹
+func getFoldersByPath(paths []string) ([]Folder, error) {
+	var folders []Folder
+	if len(paths) == 0 {
+		return folders, nil
+	}
+	err := DB.Where("path IN ?", paths).Find(&folders).Error
+	return folders, err
+}
+
+// folderBreadcrumbs 从文件夹集合中取出路径的各级文件夹，由根目录向下排列
+func folderBreadcrumbs(path string, folders []Folder) []FolderBreadcrumb {
+	ancestorPaths := folderAncestorPaths(path)
+	breadcrumbs := make([]FolderBreadcrumb, 0, len(ancestorPaths))
+	for _, folder := range folders {
+		if isFolderPathWithin(path, folder.Path) {
+			breadcrumbs = append(breadcrumbs, FolderBreadcrumb{ID: folder.ID, Name: folder.Name, Path: folder.Path})
+		}
+	}
+	sort.Slice(breadcrumbs, func(i, j int) bool {
+		return len(breadcrumbs[i].Path) < len(breadcrumbs[j].Path)
+	})
+	return breadcrumbs
+}
+
+// GetSubtreeFolderIDs 获取指定文件夹及其所有子文件夹的ID
+func GetSubtreeFolderIDs(paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	query := DB.Model(&Folder{})
+	for _, path := range paths {
+		query = query.Or("path = ? OR path LIKE ?", path, path+"/%")
+	}
+	var folders []Folder
+	if err := query.Select("id", "path").Find(&folders).Error; err != nil {
+		return nil, err
+	}
+
+	// 名称中的下划线在LIKE中为通配符，按前缀重新过滤
+	var ids []string
+	for _, folder := range folders {
+		for _, path := range paths {
+			if isFolderPathWithin(folder.Path, path) {
+				ids = append(ids, folder.ID)
+				break
+			}
+		}
+	}
+	return ids, nil
+}
+
+// ancestorFolderIDs 获取信息项所在文件夹及其所有上级文件夹的ID
+func (si *SecretItem) ancestorFolderIDs() ([]string, error) {
+	ids := []string{}
+	if si.FolderID == "" {
+		return ids, nil
+	}
+	folder, err := GetFolder(si.FolderID)
+	if err != nil {
+		return nil, err
+	}
+	folders, err := getFoldersByPath(folderAncestorPaths(folder.Path))
+	if err != nil {
+		return nil, err
+	}
+	for _, ancestor := range folders {
+		ids = append(ids, ancestor.ID)
+	}
+	return ids, nil
+}
+
+// LoadBreadcrumbs 设置信息项所在文件夹的面包屑路径
+func (si *SecretItem) LoadBreadcrumbs() error {
+	items := []SecretItem{*si}
+	if err := LoadFolderBreadcrumbs(items); err != nil {
+		return err
+	}
+	si.Breadcrumbs = items[0].Breadcrumbs
+	return nil
+}
+
+// MoveTo 将信息项移动到文件夹，folder 为空时移动到根目录；移动后版本号加一并记录变更历史
+func (si *SecretItem) MoveTo(folder *Folder, reason, movedByID string) error {
+	// 历史版本需要复制敏感数据，无法解密时不能移动
+	if si.DataUnavailable() {
+		return fmt.Errorf("当前部署未持有环境 '%s' 的主密钥，无法移动信息项", si.Environment)
+	}
+
+	folderID := ""
+	if folder != nil {
+		folderID = folder.ID
+	}
+	// 只更新所在文件夹，不重新加密敏感数据；历史版本创建失败时不移动
+	moved := *si
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(si).UpdateColumns(map[string]interface{}{
+			"folder_id":        folderID,
+			"version":          gorm.Expr("version + 1"),
+			"updated_by_id":    movedByID,
+			"last_modified_at": uint64(time.Now().UnixMilli()),
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		moved.FolderID = folderID
+		moved.Version++
+		moved.UpdatedByID = movedByID
+		_, err = createSecretItemHistory(tx, &moved, HistoryChangeTypeMoved, reason, movedByID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	*si = moved
+	return nil
+}
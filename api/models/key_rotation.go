@@ -0,0 +1,57 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// 密钥轮换任务状态常量
+const (
+	KeyRotationStatusRunning   = "running"   // 执行中
+	KeyRotationStatusCompleted = "completed" // 已完成
+	KeyRotationStatusFailed    = "failed"    // 执行失败
+)
+
+// 密钥轮换任务类型常量
+const (
+	KeyRotationTypeMasterKey   = "master_key"   // 重新包装到当前活动主密钥
+	KeyRotationTypeVaultRewrap = "vault_rewrap" // 通过Vault transit/rewrap 重新包装到Transit密钥最新版本
+)
+
+// KeyRotationJob 主密钥轮换任务模型，记录重新包装加密数据的进度和断点
+type KeyRotationJob struct {
+	ModelBase
+	Type           string `json:"type" gorm:"index;default:'master_key'"` // 任务类型
+	Status         string `json:"status" gorm:"index"`                    // 任务状态
+	TargetProvider string `json:"target_provider"`                        // 目标主密钥提供者
+	TargetVersion  string `json:"target_version"`                         // 目标主密钥版本
+	CurrentTable   string `json:"current_table"`                          // 当前处理的数据表
+	LastID         string `json:"last_id"`                                // 断点：当前数据表中最后处理的记录ID
+	TotalRows      int64  `json:"total_rows"`                             // 需要检查的记录总数
+	ProcessedRows  int64  `json:"processed_rows"`                         // 已检查的记录数
+	RewrappedRows  int64  `json:"rewrapped_rows"`                         // 已重新包装的记录数
+	FailedRows     int64  `json:"failed_rows"`                            // 处理失败的记录数
+	LastError      string `json:"last_error"`                             // 最近一次错误信息
+	StartedAt      uint64 `json:"started_at"`                             // 开始时间
+	FinishedAt     uint64 `json:"finished_at"`                            // 结束时间
+	CreatedByID    string `json:"-" gorm:"index"`                         // 发起人ID
+	Creator        *User  `json:"creator,omitempty" gorm:"foreignKey:CreatedByID;references:ID"`
+
+	// 以下字段仅用于vault_rewrap任务，按Transit密钥名称记录
+	TransitKeyVersions map[string]int `json:"transit_key_versions,omitempty" gorm:"type:text;serializer:json"` // 各Transit密钥的目标版本
+	MinKeyVersions     map[string]int `json:"min_key_versions,omitempty" gorm:"type:text;serializer:json"`     // 各Transit密钥仍在使用的最低版本
+}
+
+// BeforeCreate 钩子函数，在创建记录之前设置ID
+func (j *KeyRotationJob) BeforeCreate(tx *gorm.DB) (err error) {
+	j.ID = uuid.New().String()
+	return
+}
+
+// Progress 获取任务进度百分比
+func (j *KeyRotationJob) Progress() float64 {
+	if j.TotalRows == 0 {
+		return 100
+	}
+	return float64(j.ProcessedRows) * 100 / float64(j.TotalRows)
+}
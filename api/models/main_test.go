@@ -0,0 +1,27 @@
+package models
+
+import (
+	"os"
+	"testing"
+
+	"github.com/akinoccc/hysaif/api/config"
+	"github.com/akinoccc/hysaif/api/packages/permission"
+
+	"gorm.io/gorm/logger"
+)
+
+// TestMain 使用内存SQLite数据库和本地AES主密钥初始化模型测试
+func TestMain(m *testing.M) {
+	config.AppConfig = &config.Config{}
+	config.AppConfig.Database.Type = "sqlite"
+	config.AppConfig.Database.Path = "file:models_test?mode=memory&cache=shared"
+	config.AppConfig.Security.EncryptionKey = "0123456789abcdef0123456789abcdef"
+	config.AppConfig.Security.KeyProvider = "aes"
+	config.AppConfig.RBACConfig = "../rbac_model.conf"
+
+	InitDB()
+	DB.Logger = logger.Discard
+	permission.GetCasbinManager(DB)
+
+	os.Exit(m.Run())
+}
@@ -48,8 +48,13 @@ func InitDB() {
 		panic(fmt.Sprintf("数据库连接失败: %v", err))
 	}
 
+	// 批量解密多行查询结果中的敏感数据
+	if err = registerBatchOpenCallback(DB); err != nil {
+		panic(fmt.Sprintf("注册查询回调失败: %v", err))
+	}
+
 	// 自动迁移 - User 模型必须首先创建，因为其他模型都依赖于它
-	err = DB.AutoMigrate(&User{}, &SecretItem{}, &SecretItemHistory{}, &AccessRequest{}, &Notification{}, &WebAuthnCredential{}, &AuditLog{})
+	err = DB.AutoMigrate(&User{}, &SecretItem{}, &SecretItemHistory{}, &AccessRequest{}, &Notification{}, &WebAuthnCredential{}, &AuditLog{}, &KeyRotationJob{}, &SecretBlindIndex{}, &SecretItemRecipient{}, &TransitKey{}, &TransitKeyVersion{}, &SecretType{}, &Folder{}, &DatabaseLease{}, &SecretRotationPolicy{})
 	if err != nil {
 		panic("failed to migrate database")
 	}
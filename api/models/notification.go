@@ -16,6 +16,7 @@ const (
 	NotificationTypeAccessRequestRevoked  = "access_request_revoked"  // 申请已作废
 	NotificationTypeSecretItemExpiring    = "secret_item_expiring"    // 密钥项即将过期
 	NotificationTypeSecretItemExpired     = "secret_item_expired"     // 密钥项已过期
+	NotificationTypeSecretItemRotated     = "secret_item_rotated"     // 密钥项已轮换
 	NotificationTypeSystemMaintenance     = "system_maintenance"      // 系统维护通知
 	NotificationTypeSecurityAlert         = "security_alert"          // 安全警报
 )
@@ -101,4 +102,5 @@ type NotificationData struct {
 	MaintenanceTime string
 	Duration        string
 	AlertDetails    string
+	RotatedAt       string
 }
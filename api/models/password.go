@@ -0,0 +1,27 @@
+package models
+
+import (
+	"github.com/akinoccc/hysaif/api/packages/password"
+)
+
+// SecretTypePassword 密码类型信息项
+const SecretTypePassword = "password"
+
+// CheckPasswordPolicy 校验密码类型信息项的密码是否满足所属分类的强度策略，返回所有不满足的规则；
+// 用户名、信息项名称和分类作为估算强度时的关联信息，密码包含这些内容时评分降低
+func (si *SecretItem) CheckPasswordPolicy() []string {
+	if si.Type != SecretTypePassword || si.E2E || si.Data == nil {
+		return nil
+	}
+	// 引用其他信息项的密码在被引用的信息项上校验
+	if HasSecretReference(si.Data.Password) {
+		return nil
+	}
+	var userInputs []string
+	for _, input := range []string{si.Name, si.Category, si.Data.Username} {
+		if input != "" {
+			userInputs = append(userInputs, input)
+		}
+	}
+	return password.Check(si.Data.Password, password.PolicyFor(si.Category), userInputs)
+}
@@ -0,0 +1,140 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/akinoccc/hysaif/api/config"
+	"github.com/akinoccc/hysaif/api/packages/crypto"
+
+	"gorm.io/gorm"
+)
+
+// DefaultRecycleBinRetentionDays 未配置保留天数时，删除的信息项在回收站中保留的天数
+const DefaultRecycleBinRetentionDays = 30
+
+var (
+	// ErrRetentionExpired 信息项在回收站中已超过保留期限，等待清除，无法恢复
+	ErrRetentionExpired = errors.New("信息项已超过回收站保留期限，无法恢复")
+	// ErrVaultKVNotDestroyed 数据库记录已清除，但Vault KV中的数据删除失败，需要手动删除
+	ErrVaultKVNotDestroyed = errors.New("信息项已清除，但Vault KV中的数据删除失败，需要手动删除")
+)
+
+// RecycleBinRetention 删除的信息项在回收站中的保留期限
+func RecycleBinRetention() time.Duration {
+	days := config.AppConfig.RecycleBin.RetentionDays
+	if days <= 0 {
+		days = DefaultRecycleBinRetentionDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// DeletedSecretItems 回收站中的信息项查询
+func DeletedSecretItems() *gorm.DB {
+	return DB.Unscoped().Model(&SecretItem{}).Where("deleted_at IS NOT NULL")
+}
+
+// GetDeletedSecretItem 获取回收站中的信息项
+func GetDeletedSecretItem(id string) (*SecretItem, error) {
+	var item SecretItem
+	if err := DeletedSecretItems().Where("id = ?", id).First(&item).Error; err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// ExpiredDeletedSecretItems 获取超过保留期限的回收站信息项，只加载清除和审计所需的字段
+func ExpiredDeletedSecretItems(now time.Time) ([]SecretItem, error) {
+	var items []SecretItem
+	err := DeletedSecretItems().
+		Select("id", "name", "type", "created_by_id", "deleted_at", "deleted_by_id").
+		Where("deleted_at <= ?", now.Add(-RecycleBinRetention())).
+		Order("deleted_at").
+		Find(&items).Error
+	return items, err
+}
+
+// PurgeAt 回收站中的信息项被自动清除的时间
+func (si *SecretItem) PurgeAt() time.Time {
+	return si.DeletedAt.Time.Add(RecycleBinRetention())
+}
+
+// CanManageDeleted 检查用户能否恢复或清除回收站中的信息项：创建者或管理员
+func (u *User) CanManageDeleted(item *SecretItem) bool {
+	return item.CreatedByID == u.ID || u.IsAdmin()
+}
+
+// MoveToRecycleBin 将信息项移入回收站，敏感数据、历史版本、盲索引、端到端加密接收者和轮换策略保留到清除时
+func (si *SecretItem) MoveToRecycleBin(deletedByID string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(si).UpdateColumn("deleted_by_id", deletedByID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(si).Error
+	})
+}
+
+// Restore 从回收站恢复信息项；所在文件夹已被删除时恢复到根目录
+func (si *SecretItem) Restore() error {
+	if !si.DeletedAt.Valid {
+		return errors.New("信息项不在回收站中")
+	}
+	if time.Now().After(si.PurgeAt()) {
+		return ErrRetentionExpired
+	}
+
+	if si.FolderID != "" {
+		if _, err := GetFolder(si.FolderID); errors.Is(err, gorm.ErrRecordNotFound) {
+			si.FolderID = ""
+		} else if err != nil {
+			return err
+		}
+	}
+
+	// 只更新回收站相关的列，不触发重新加密和更新盲索引的钩子
+	err := DB.Unscoped().Model(si).UpdateColumns(map[string]interface{}{
+		"deleted_at":    nil,
+		"deleted_by_id": "",
+		"folder_id":     si.FolderID,
+	}).Error
+	if err != nil {
+		return err
+	}
+	si.DeletedAt = gorm.DeletedAt{}
+	si.DeletedByID = ""
+	return nil
+}
+
+// Purge 永久删除信息项及其所有历史版本、盲索引、端到端加密接收者、轮换策略、临时用户记录和访问申请；
+// 存储后端为Vault KV时在数据库记录删除后再删除KV中的所有版本，避免KV已删除而数据库中的引用仍在；
+// KV删除失败时返回 ErrVaultKVNotDestroyed，数据库记录不会恢复
+func (si *SecretItem) Purge() error {
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("secret_item_id = ?", si.ID).Delete(&SecretItemHistory{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("secret_item_id = ?", si.ID).Delete(&AccessRequest{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("secret_item_id = ?", si.ID).Delete(&SecretRotationPolicy{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("secret_item_id = ?", si.ID).Delete(&DatabaseLease{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(si).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	if crypto.UsesVaultKV() {
+		if err := crypto.DestroyVaultKV(si.ID); err != nil {
+			log.Printf("删除信息项 %s 的Vault KV数据失败，需要手动删除: %v", si.ID, err)
+			return fmt.Errorf("%w: %v", ErrVaultKVNotDestroyed, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// 内置轮换器
+const (
+	RotatorPassword = "password" // 重新生成随机密码
+	RotatorToken    = "token"    // 重新生成随机令牌或API Key
+	RotatorSSHKey   = "ssh_key"  // 重新生成SSH密钥对
+	RotatorWebhook  = "webhook"  // 生成新值后调用HTTP Webhook应用并确认
+	RotatorScript   = "script"   // 生成新值后执行本地脚本应用并确认
+)
+
+// rotationRetryDelay 轮换失败后的重试间隔，不超过轮换间隔
+const rotationRetryDelay = time.Hour
+
+// maxRotationIntervalHours 轮换间隔上限（小时）
+const maxRotationIntervalHours = 24 * 366
+
+var rotationWindowPattern = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+
+// SecretRotationPolicy 信息项的自动轮换策略，到期后在轮换窗口内使用轮换器生成新的敏感数据
+type SecretRotationPolicy struct {
+	ModelBase
+	SecretItemID   string         `json:"secret_item_id" gorm:"type:varchar(36);uniqueIndex;not null"` // 信息项ID
+	Enabled        bool           `json:"enabled"`                                                     // 是否启用自动轮换，未启用时仍可手动轮换
+	Rotator        string         `json:"rotator" gorm:"not null"`                                     // 轮换器
+	IntervalHours  int            `json:"interval_hours" gorm:"not null"`                              // 轮换间隔（小时）
+	WindowStart    string         `json:"window_start"`                                                // 轮换窗口开始时间，HH:MM 格式的UTC时间，为空表示任意时间
+	WindowEnd      string         `json:"window_end"`                                                  // 轮换窗口结束时间，早于开始时间表示跨越零点
+	Options        RotatorOptions `json:"options" gorm:"type:text;serializer:json"`                    // 轮换器选项
+	LastRotatedAt  uint64         `json:"last_rotated_at"`                                             // 最近一次成功轮换的时间
+	NextRotationAt uint64         `json:"next_rotation_at" gorm:"index"`                               // 下次轮换时间
+	FailureCount   int            `json:"failure_count"`                                               // 连续失败次数
+	LastError      string         `json:"last_error,omitempty"`                                        // 最近一次失败的原因
+	CreatedByID    string         `json:"-" gorm:"index"`                                              // 创建者ID
+	UpdatedByID    string         `json:"-" gorm:"index"`                                              // 更新者ID，自动轮换的历史版本记录在该用户名下
+}
+
+// RotatorOptions 轮换器选项，不同轮换器使用其中的部分字段
+type RotatorOptions struct {
+	Field          string `json:"field,omitempty"`           // 密码和令牌轮换器：轮换的字段，默认按信息项类型选择，自定义类型使用 fields.<字段名>
+	Length         int    `json:"length,omitempty"`          // 密码和令牌轮换器：生成的长度
+	ExcludeSymbols bool   `json:"exclude_symbols,omitempty"` // 密码轮换器：不包含特殊字符
+	Algorithm      string `json:"algorithm,omitempty"`       // SSH密钥轮换器：密钥算法，默认与当前密钥相同
+	Bits           int    `json:"bits,omitempty"`            // SSH密钥轮换器：密钥长度
+	Generator      string `json:"generator,omitempty"`       // Webhook和脚本轮换器：生成新值使用的轮换器，password、token 或 ssh_key
+	URL            string `json:"url,omitempty"`             // Webhook轮换器：接收新值的地址
+	Script         string `json:"script,omitempty"`          // 脚本轮换器：脚本目录中的脚本文件名
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"` // Webhook和脚本轮换器：等待确认的超时时间，默认30秒
+}
+
+// BeforeCreate 钩子函数，在创建记录之前设置ID
+func (p *SecretRotationPolicy) BeforeCreate(tx *gorm.DB) (err error) {
+	p.ID = uuid.New().String()
+	return
+}
+
+// GetSecretRotationPolicy 获取信息项的轮换策略
+func GetSecretRotationPolicy(secretItemID string) (*SecretRotationPolicy, error) {
+	var policy SecretRotationPolicy
+	if err := DB.Where("secret_item_id = ?", secretItemID).First(&policy).Error; err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// ValidateSchedule 校验轮换间隔和轮换窗口
+func (p *SecretRotationPolicy) ValidateSchedule() error {
+	if p.IntervalHours < 1 || p.IntervalHours > maxRotationIntervalHours {
+		return fmt.Errorf("轮换间隔必须在 1 到 %d 小时之间", maxRotationIntervalHours)
+	}
+	if (p.WindowStart == "") != (p.WindowEnd == "") {
+		return errors.New("轮换窗口的开始时间和结束时间需要同时设置")
+	}
+	if p.WindowStart != "" {
+		if !rotationWindowPattern.MatchString(p.WindowStart) || !rotationWindowPattern.MatchString(p.WindowEnd) {
+			return errors.New("轮换窗口时间必须为 HH:MM 格式")
+		}
+		if p.WindowStart == p.WindowEnd {
+			return errors.New("轮换窗口的开始时间和结束时间不能相同")
+		}
+	}
+	return nil
+}
+
+// InWindow 检查时间是否在轮换窗口内，未设置窗口时总是返回 true
+func (p *SecretRotationPolicy) InWindow(t time.Time) bool {
+	if p.WindowStart == "" {
+		return true
+	}
+	now := t.UTC().Format("15:04")
+	if p.WindowStart < p.WindowEnd {
+		return now >= p.WindowStart && now < p.WindowEnd
+	}
+	// 窗口跨越零点，如 22:00-02:00
+	return now >= p.WindowStart || now < p.WindowEnd
+}
+
+// Schedule 从指定时间开始计算下次轮换时间
+func (p *SecretRotationPolicy) Schedule(from time.Time) {
+	p.NextRotationAt = uint64(from.Add(time.Duration(p.IntervalHours) * time.Hour).UnixMilli())
+}
+
+// RecordSuccess 记录成功的轮换并重新计算下次轮换时间
+func (p *SecretRotationPolicy) RecordSuccess(rotatedAt time.Time) error {
+	p.LastRotatedAt = uint64(rotatedAt.UnixMilli())
+	p.FailureCount = 0
+	p.LastError = ""
+	p.Schedule(rotatedAt)
+	return DB.Model(p).UpdateColumns(map[string]interface{}{
+		"last_rotated_at":  p.LastRotatedAt,
+		"next_rotation_at": p.NextRotationAt,
+		"failure_count":    p.FailureCount,
+		"last_error":       p.LastError,
+	}).Error
+}
+
+// RecordFailure 记录失败的轮换，稍后重试
+func (p *SecretRotationPolicy) RecordFailure(cause error, failedAt time.Time) error {
+	retryDelay := min(rotationRetryDelay, time.Duration(p.IntervalHours)*time.Hour)
+	p.FailureCount++
+	p.LastError = cause.Error()
+	p.NextRotationAt = uint64(failedAt.Add(retryDelay).UnixMilli())
+	return DB.Model(p).UpdateColumns(map[string]interface{}{
+		"next_rotation_at": p.NextRotationAt,
+		"failure_count":    p.FailureCount,
+		"last_error":       p.LastError,
+	}).Error
+}
+
+// DueSecretRotationPolicies 获取已到期且当前在轮换窗口内的轮换策略
+func DueSecretRotationPolicies(now time.Time) ([]SecretRotationPolicy, error) {
+	var policies []SecretRotationPolicy
+	// 回收站中信息项的轮换策略保留到清除时，恢复后继续生效
+	err := DB.Where("enabled = ? AND next_rotation_at <= ?", true, uint64(now.UnixMilli())).
+		Where("secret_item_id NOT IN (?)", DeletedSecretItems().Select("id")).
+		Order("next_rotation_at").
+		Find(&policies).Error
+	if err != nil {
+		return nil, err
+	}
+
+	due := policies[:0]
+	for _, policy := range policies {
+		if policy.InWindow(now) {
+			due = append(due, policy)
+		}
+	}
+	return due, nil
+}
+
+// ErrRotationConflict 轮换期间信息项被修改、移动或删除，轮换结果未保存
+var ErrRotationConflict = errors.New("轮换期间信息项已被修改")
+
+// CreatePendingRotation 在外部系统应用新值前将其保存为 rotation_pending 类型的历史版本，
+// 外部系统应用后信息项保存失败时可以从该版本恢复当前生效的值
+func (si *SecretItem) CreatePendingRotation(data *SecretItemData, reason, rotatedByID string) (*SecretItemHistory, error) {
+	pending := *si
+	pending.Data = data.clone()
+	pending.UpdatedByID = rotatedByID
+	if err := pending.RefreshMetadata(); err != nil {
+		return nil, err
+	}
+	return createSecretItemHistory(DB, &pending, HistoryChangeTypeRotationPending, reason, rotatedByID)
+}
+
+// DiscardPendingRotation 删除外部系统确认未应用的待确认新值
+func DiscardPendingRotation(pending *SecretItemHistory) error {
+	return DB.Where("change_type = ?", HistoryChangeTypeRotationPending).Delete(pending).Error
+}
+
+// ApplyRotation 保存轮换生成的敏感数据，版本号加一并记录 rotated 类型的历史版本，pending 不为空时将该待确认版本改为 rotated；
+// 仅当版本号与读取信息项时一致才写入，轮换期间信息项被修改、移动或删除时返回 ErrRotationConflict
+func (si *SecretItem) ApplyRotation(data *SecretItemData, reason, rotatedByID string, pending *SecretItemHistory) error {
+	rotated := *si
+	rotated.Data = data
+	rotated.UpdatedByID = rotatedByID
+	rotated.Version = si.Version + 1
+	if err := rotated.RefreshMetadata(); err != nil {
+		return err
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&rotated).
+			Select("data", "metadata", "expires_at", "version", "updated_by_id", "quarantined_at", "quarantine_reason", "last_modified_at", "updated_at").
+			Where("version = ?", si.Version).
+			Updates(&rotated)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRotationConflict
+		}
+
+		if pending != nil {
+			return tx.Model(pending).UpdateColumn("change_type", HistoryChangeTypeRotated).Error
+		}
+		_, err := createSecretItemHistory(tx, &rotated, HistoryChangeTypeRotated, reason, rotatedByID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	*si = rotated
+	return nil
+}
@@ -1,8 +1,10 @@
 package models
 
 import (
+	"crypto/sha256"
 	"database/sql/driver"
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/akinoccc/hysaif/api/packages/crypto"
@@ -16,14 +18,15 @@ type SecretItem struct {
 	ModelBase
 	Name        string          `json:"name" gorm:"not null"`
 	Description string          `json:"description"`
-	Type        string          `json:"type" gorm:"not null"`        // password, api_key, access_key, ssh_key, certificate, token, kv
-	Category    string          `json:"category"`                    // 分类：aws, aliyun, github等
-	Tags        []string        `json:"tags" gorm:"serializer:json"` // JSON格式的标签数组
-	Data        *SecretItemData `json:"data" gorm:"type:text"`       // 加密后的敏感数据
-	ExpiresAt   uint64          `json:"expires_at"`                  // 过期时间, 0表示永不过期
-	Environment string          `json:"environment"`                 // 环境：development, test, production, staging, local
-	CreatedByID string          `json:"-" gorm:"index"`              // 创建者ID
-	UpdatedByID string          `json:"-" gorm:"index"`              // 更新者ID
+	Type        string          `json:"type" gorm:"not null"`                    // 内置类型见 BuiltinSecretTypes，或管理员定义的自定义类型
+	Category    string          `json:"category"`                                // 分类：aws, aliyun, github等
+	Tags        []string        `json:"tags" gorm:"serializer:json"`             // JSON格式的标签数组
+	Data        *SecretItemData `json:"data" gorm:"type:text"`                   // 加密后的敏感数据
+	ExpiresAt   uint64          `json:"expires_at"`                              // 过期时间, 0表示永不过期
+	Environment string          `json:"environment"`                             // 环境：development, test, production, staging, local
+	FolderID    string          `json:"folder_id" gorm:"type:varchar(36);index"` // 所在文件夹ID，为空表示位于根目录
+	CreatedByID string          `json:"-" gorm:"index"`                          // 创建者ID
+	UpdatedByID string          `json:"-" gorm:"index"`                          // 更新者ID
 
 	// 版本控制字段
 	Version        int    `json:"version" gorm:"default:1"`                     // 当前版本号
@@ -31,14 +34,47 @@ type SecretItem struct {
 	HistoryCount   int    `json:"history_count" gorm:"-"`                       // 历史版本数量（不存储在数据库中）
 	LastModifiedAt uint64 `json:"last_modified_at" gorm:"autoUpdateTime:milli"` // 最后修改时间
 
+	// 端到端加密，启用后敏感数据由客户端加密，服务端只保存密文和各接收者的包装密钥，Data为空
+	E2E               bool   `json:"e2e" gorm:"column:e2e;default:false"`            // 是否为端到端加密信息项
+	Payload           string `json:"payload,omitempty" gorm:"type:text"`             // 客户端加密的数据
+	WrappedKey        string `json:"wrapped_key,omitempty" gorm:"-"`                 // 当前用户的包装密钥（不存储在数据库中）
+	ContentKeyVersion int    `json:"content_key_version,omitempty" gorm:"default:0"` // 内容密钥版本，创建时为1，每次更换内容密钥加一，0表示未知
+
+	// 按类型从敏感数据中解析的非敏感元数据，明文保存
+	Metadata SecretItemMetadata `json:"metadata" gorm:"type:text;serializer:json"`
+
+	// 完整性校验，隔离的数据无法解密，读取时不再尝试解密，重新填写敏感数据后自动解除隔离
+	QuarantinedAt    uint64 `json:"quarantined_at"`              // 隔离时间，0表示未隔离
+	QuarantineReason string `json:"quarantine_reason,omitempty"` // 隔离原因
+
+	// 回收站，删除的信息项在保留期限内可以恢复，查询时自动排除
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"` // 删除时间
+	DeletedByID string         `json:"-"`              // 删除者ID
+
 	// 访问权限相关（不存储在数据库中）
-	HasApprovedAccess bool `json:"has_approved_access" gorm:"-"` // 是否有已批准的访问申请
+	HasApprovedAccess bool `json:"has_approved_access" gorm:"-"` // 是否有已批准的访问申请，包括对所在文件夹的申请
+
+	// 由根目录到所在文件夹的面包屑路径（不存储在数据库中）
+	Breadcrumbs []FolderBreadcrumb `json:"breadcrumbs" gorm:"-"`
+
+	// 读取时解析引用所读取的信息项（不存储在数据库中）
+	References []SecretItemReference `json:"references,omitempty" gorm:"-"`
+
+	// 通过访问申请读取数据库连接时签发的临时用户（不存储在数据库中）
+	Lease *DatabaseLease `json:"lease,omitempty" gorm:"-"`
 
 	// 关联用户
 	Creator *User `json:"creator" gorm:"foreignKey:CreatedByID;references:ID"`
 	Updater *User `json:"updater" gorm:"foreignKey:UpdatedByID;references:ID"`
+	Deleter *User `json:"-" gorm:"foreignKey:DeletedByID;references:ID"` // 将信息项移入回收站的用户
 }
 
+// SecretItemTable 敏感信息项数据表名
+const SecretItemTable = "secret_items"
+
+// SecretDataField 加密数据字段名，用于构造密文绑定的附加认证数据
+const SecretDataField = "data"
+
 // BeforeCreate 钩子函数，在创建记录之前设置ID
 func (si *SecretItem) BeforeCreate(tx *gorm.DB) (err error) {
 	si.ID = uuid.New().String()
@@ -46,14 +82,152 @@ func (si *SecretItem) BeforeCreate(tx *gorm.DB) (err error) {
 	if si.Version == 0 {
 		si.Version = 1
 	}
+	// 创建时BeforeSave先于ID生成执行，需要重新绑定
+	si.bindData()
+	return si.storeData()
+}
+
+// BeforeSave 钩子函数，将所属数据行和环境传递给敏感数据
+func (si *SecretItem) BeforeSave(tx *gorm.DB) (err error) {
+	// 环境独立主密钥：迁移到其他环境时需要使用新环境的主密钥重新加密，未解密的数据无法重新加密
+	if si.Data != nil && !si.Data.opened() && si.Data.options.Bound() && si.Data.options.Environment != si.Environment {
+		return fmt.Errorf("信息项数据未解密，无法从环境 '%s' 迁移到 '%s'", si.Data.options.Environment, si.Environment)
+	}
+	si.bindData()
+	// 写入了新的敏感数据，解除隔离
+	if si.Data != nil && si.Data.opened() {
+		si.QuarantinedAt = 0
+		si.QuarantineReason = ""
+	}
+	return
+}
+
+// BeforeUpdate 钩子函数，存储后端为Vault KV时写入敏感数据的新版本
+func (si *SecretItem) BeforeUpdate(tx *gorm.DB) (err error) {
+	return si.storeData()
+}
+
+// AfterSave 钩子函数，使用保存后的敏感数据更新盲索引
+func (si *SecretItem) AfterSave(tx *gorm.DB) (err error) {
+	return si.updateBlindIndexes(tx)
+}
+
+// AfterDelete 钩子函数，清除密钥项时删除其盲索引和端到端加密接收者；移入回收站时保留，以便恢复
+func (si *SecretItem) AfterDelete(tx *gorm.DB) (err error) {
+	if !tx.Statement.Unscoped {
+		return
+	}
+	if err = tx.Where("secret_item_id = ?", si.ID).Delete(&SecretBlindIndex{}).Error; err != nil {
+		return
+	}
+	return tx.Where("secret_item_id = ?", si.ID).Delete(&SecretItemRecipient{}).Error
+}
+
+// AfterFind 钩子函数，使用所属数据行信息解密敏感数据，已隔离的数据保持密文
+func (si *SecretItem) AfterFind(tx *gorm.DB) (err error) {
+	si.bindData()
+	if si.Data != nil && si.QuarantinedAt == 0 {
+		return si.Data.openAvailable()
+	}
 	return
 }
 
+// DataUnavailable 检查当前部署是否因未持有所属环境的主密钥而无法解密敏感数据
+func (si *SecretItem) DataUnavailable() bool {
+	return si.Data != nil && si.QuarantinedAt == 0 && !si.Data.opened()
+}
+
+// bindData 设置敏感数据的加密选项，密文与所属数据行绑定
+func (si *SecretItem) bindData() {
+	if si.Data != nil {
+		si.Data.bind(SecretItemTable, si.ID, si.ID, si.Environment, si.actorID())
+	}
+}
+
+// actorID 最近一次写入密钥项的用户
+func (si *SecretItem) actorID() string {
+	if si.UpdatedByID != "" {
+		return si.UpdatedByID
+	}
+	return si.CreatedByID
+}
+
+// storeData 将敏感数据写入以密钥项ID为路径的Vault KV
+func (si *SecretItem) storeData() error {
+	if si.Data == nil {
+		return nil
+	}
+	return si.Data.store(si.ID)
+}
+
 // CreateHistory 创建历史版本记录
 func (si *SecretItem) CreateHistory(changeType, reason, createdByID string) error {
 	return CreateSecretItemHistory(si, changeType, reason, createdByID)
 }
 
+// LoadWrappedKey 加载用户在端到端加密信息项中的包装密钥
+func (si *SecretItem) LoadWrappedKey(userID string) error {
+	if !si.E2E {
+		return nil
+	}
+
+	wrappedKey, err := GetWrappedKey(si.ID, userID)
+	if err != nil {
+		return err
+	}
+	si.WrappedKey = wrappedKey
+	return nil
+}
+
+// RefreshMetadata 按类型校验敏感数据并重新解析元数据，证书类型的过期时间取自证书的 NotAfter，
+// 自定义类型按字段定义校验 data.fields 并将非敏感字段移入元数据；
+// 端到端加密信息项的数据由客户端加密，不解析元数据
+func (si *SecretItem) RefreshMetadata() error {
+	si.Metadata = SecretItemMetadata{}
+	if si.E2E && si.Type == SecretTypeDatabase {
+		return errors.New("数据库连接不能使用端到端加密，服务端需要使用管理员凭据创建临时用户")
+	}
+	if si.E2E && si.Type == SecretTypeTOTP {
+		return errors.New("TOTP种子不能使用端到端加密，服务端需要使用种子生成验证码")
+	}
+	if si.E2E || si.Data == nil {
+		return nil
+	}
+
+	switch si.Type {
+	case SecretTypeCertificate:
+		certificate, err := parseCertificateData(si.Data)
+		if err != nil {
+			return err
+		}
+		si.Metadata.Certificate = certificate
+		si.ExpiresAt = certificate.NotAfter
+	case SecretTypeSSHKey:
+		sshKey, err := parseSSHKeyData(si.Data)
+		if err != nil {
+			return err
+		}
+		si.Metadata.SSHKey = sshKey
+	case SecretTypeDatabase:
+		database, err := parseDatabaseData(si.Data)
+		if err != nil {
+			return err
+		}
+		si.Metadata.Database = database
+	case SecretTypeTOTP:
+		totp, err := parseTOTPData(si.Data)
+		if err != nil {
+			return err
+		}
+		si.Metadata.TOTP = totp
+	default:
+		if !IsBuiltinSecretType(si.Type) {
+			return si.applySecretTypeSchema()
+		}
+	}
+	return nil
+}
+
 // LoadHistoryInfo 加载历史信息
 func (si *SecretItem) LoadHistoryInfo() error {
 	var count int64
@@ -67,6 +241,15 @@ func (si *SecretItem) LoadHistoryInfo() error {
 	return nil
 }
 
+// SecretItemMetadata 从敏感数据中解析的非敏感元数据，无需访问授权即可在列表中查看和搜索
+type SecretItemMetadata struct {
+	Certificate *CertificateMetadata `json:"certificate,omitempty"` // 证书信息
+	SSHKey      *SSHKeyMetadata      `json:"ssh_key,omitempty"`     // SSH密钥信息
+	Database    *DatabaseMetadata    `json:"database,omitempty"`    // 数据库连接信息
+	TOTP        *TOTPMetadata        `json:"totp,omitempty"`        // TOTP参数
+	Fields      map[string]string    `json:"fields,omitempty"`      // 自定义类型的非敏感字段
+}
+
 // SecretItemData 敏感信息数据结构（用于前端展示，不包含加密数据）
 type SecretItemData struct {
 	// 密码相关
@@ -90,17 +273,105 @@ type SecretItemData struct {
 	PublicKey  string `json:"public_key,omitempty"`
 	Passphrase string `json:"passphrase,omitempty"`
 
+	// 证书相关，私钥使用 PrivateKey 字段
+	Certificate string `json:"certificate,omitempty"` // PEM格式的证书链，第一个为叶子证书
+
 	// Token 相关
 	Token        string `json:"token,omitempty"`
 	TokenType    string `json:"token_type,omitempty"`
 	RefreshToken string `json:"refresh_token,omitempty"`
 
+	// 数据库连接相关，管理员凭据使用 Username 和 Password，地址使用 Address（host:port）
+	Engine               string `json:"engine,omitempty"`                // 数据库引擎：postgres, mysql
+	Database             string `json:"database,omitempty"`              // 连接的数据库名
+	SSLMode              string `json:"ssl_mode,omitempty"`              // Postgres 的 sslmode 或 MySQL 的 tls 参数
+	CreationStatements   string `json:"creation_statements,omitempty"`   // 创建临时用户的语句模板
+	RevocationStatements string `json:"revocation_statements,omitempty"` // 删除临时用户的语句模板
+
+	// TOTP相关，otpauth:// URI 和base32种子只能提供一个
+	OTPAuthURI string `json:"otpauth_uri,omitempty"` // otpauth://totp/ 格式的URI
+	Seed       string `json:"seed,omitempty"`        // base32编码的种子
+
 	// 自定义数据
 	CustomData []map[string]string `json:"custom_data,omitempty"`
+
+	// 自定义类型的敏感字段，按类型的字段定义校验
+	Fields map[string]string `json:"fields,omitempty"`
+
+	// 加密选项，由模型钩子设置，包含所属数据行和环境
+	options crypto.Options
+	// 数据所属的密钥项ID，历史版本为对应的密钥项；Vault KV引用必须指向该密钥项的路径
+	itemID string
+	// 从数据库读取、尚未解密的密文或Vault KV引用
+	ciphertext string
+	// 存储后端为Vault KV时数据所在KV版本的引用，数据库中只保存该引用
+	ref string
+	// 引用对应数据的摘要，数据未修改时保存不会写入新的KV版本
+	refDigest [sha256.Size]byte
+}
+
+// bind 设置加密选项和所属的密钥项，userID 为执行写入的用户，用于记录加密回退的审计日志
+func (s *SecretItemData) bind(table, id, itemID, environment, userID string) {
+	s.options = crypto.Options{Environment: environment, Table: table, ID: id, Field: SecretDataField, UserID: userID}
+	s.itemID = itemID
+}
+
+// opened 检查数据是否已解密或为新写入的数据，未解密的数据保存时原样写回
+func (s *SecretItemData) opened() bool {
+	return s.ciphertext == ""
+}
+
+// clone 复制数据，副本保存时会写入新的KV版本
+func (s *SecretItemData) clone() *SecretItemData {
+	if s == nil {
+		return nil
+	}
+	data := *s
+	data.ref = ""
+	return &data
+}
+
+// store 存储后端为Vault KV时将数据写入密钥项路径下的新版本，并记录版本引用
+func (s *SecretItemData) store(itemID string) error {
+	if !crypto.UsesVaultKV() || !s.opened() {
+		return nil
+	}
+
+	jsonData, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to marshal SecretItemData: %w", err)
+	}
+
+	digest := sha256.Sum256(jsonData)
+	if s.ref != "" && digest == s.refDigest {
+		return nil
+	}
+
+	ref, err := crypto.WriteVaultKV(itemID, jsonData)
+	if err != nil {
+		return fmt.Errorf("failed to store SecretItemData: %w", err)
+	}
+
+	s.ref = ref
+	s.refDigest = digest
+	return nil
 }
 
 // Value 实现 driver.Valuer 接口，用于将 SecretItemData 序列化为数据库存储格式
 func (s SecretItemData) Value() (driver.Value, error) {
+	// 未解密的数据（如已隔离）原样写回
+	if !s.opened() {
+		return s.ciphertext, nil
+	}
+
+	// 存储在Vault KV中时数据库只保存版本引用，数据由模型钩子写入
+	if crypto.UsesVaultKV() {
+		if s.ref == "" {
+			return nil, fmt.Errorf("SecretItemData has not been stored in Vault KV")
+		}
+		return s.ref, nil
+	}
+
 	// 将结构体转换为JSON
 	jsonData, err := json.Marshal(s)
 	if err != nil {
@@ -108,7 +379,7 @@ func (s SecretItemData) Value() (driver.Value, error) {
 	}
 
 	// 加密JSON数据
-	encryptedData, err := crypto.Encrypt(jsonData)
+	encryptedData, err := crypto.EncryptWith(jsonData, s.options)
 	if err != nil {
 		return nil, fmt.Errorf("failed to encrypt SecretItemData: %w", err)
 	}
@@ -117,6 +388,8 @@ func (s SecretItemData) Value() (driver.Value, error) {
 }
 
 // Scan 实现 sql.Scanner 接口，用于从数据库读取并反序列化 SecretItemData
+//
+// 与数据行绑定的密文在 Scan 时还不知道所属数据行，也无法判断数据行是否已隔离，先保存密文，由模型的 AfterFind 钩子解密
 func (s *SecretItemData) Scan(value interface{}) error {
 	if value == nil {
 		return nil
@@ -138,17 +411,84 @@ func (s *SecretItemData) Scan(value interface{}) error {
 		return nil
 	}
 
+	s.ciphertext = encryptedData
+	return nil
+}
+
+// OpenSecretItemData 按读取数据时相同的流程解密数据库中的原始数据，用于完整性校验；itemID 为数据所属的密钥项
+func OpenSecretItemData(raw string, opts crypto.Options, itemID string) (*SecretItemData, error) {
+	data := &SecretItemData{options: opts, itemID: itemID, ciphertext: raw}
+	if err := data.open(); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// open 使用加密选项解密尚未解密的密文，或读取Vault KV引用指向的版本
+func (s *SecretItemData) open() error {
+	if s.opened() {
+		return nil
+	}
+	if crypto.IsVaultKVRef(s.ciphertext) {
+		return s.load(s.ciphertext)
+	}
+
 	// 解密数据
-	decryptedData, err := crypto.Decrypt(encryptedData)
+	decryptedData, err := crypto.DecryptWith(s.ciphertext, s.options)
 	if err != nil {
 		return fmt.Errorf("failed to decrypt SecretItemData: %w", err)
 	}
 
-	// 反序列化JSON到结构体
-	err = json.Unmarshal(decryptedData, s)
+	return s.decode(decryptedData)
+}
+
+// decode 反序列化解密后的JSON数据，保留加密选项
+func (s *SecretItemData) decode(decryptedData []byte) error {
+	var data SecretItemData
+	if err := json.Unmarshal(decryptedData, &data); err != nil {
+		return fmt.Errorf("failed to unmarshal SecretItemData: %w", err)
+	}
+
+	data.options = s.options
+	data.itemID = s.itemID
+	*s = data
+	return nil
+}
+
+// openAvailable 解密敏感数据，当前部署未持有所属环境的主密钥时保持密文，不影响查询其他环境的数据
+func (s *SecretItemData) openAvailable() error {
+	if err := s.open(); err != nil && !errors.Is(err, crypto.ErrEnvironmentKeyUnavailable) {
+		return err
+	}
+	return nil
+}
+
+// load 读取Vault KV引用指向的版本
+func (s *SecretItemData) load(ref string) error {
+	plaintext, err := crypto.ReadVaultKV(ref, s.itemID)
 	if err != nil {
+		return fmt.Errorf("failed to load SecretItemData: %w", err)
+	}
+	return s.decodeRef(ref, plaintext)
+}
+
+// decodeRef 反序列化从Vault KV读取的数据，并记录版本引用和摘要
+func (s *SecretItemData) decodeRef(ref string, plaintext []byte) error {
+	var data SecretItemData
+	if err := json.Unmarshal(plaintext, &data); err != nil {
 		return fmt.Errorf("failed to unmarshal SecretItemData: %w", err)
 	}
 
+	// 摘要基于重新序列化的结果计算，与保存时的计算方式一致
+	jsonData, err := json.Marshal(&data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal SecretItemData: %w", err)
+	}
+
+	data.options = s.options
+	data.itemID = s.itemID
+	data.ref = ref
+	data.refDigest = sha256.Sum256(jsonData)
+	*s = data
 	return nil
 }
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"reflect"
+
+	"github.com/akinoccc/hysaif/api/packages/crypto"
+
+	"gorm.io/gorm"
+)
+
+// pendingDataHolder 查询结果中包含待解密敏感数据的模型
+type pendingDataHolder interface {
+	// pendingData 绑定加密选项并返回尚未解密的敏感数据，无需解密时返回nil
+	pendingData() *SecretItemData
+}
+
+// pendingData 返回尚未解密且未隔离的敏感数据
+func (si *SecretItem) pendingData() *SecretItemData {
+	si.bindData()
+	if si.Data != nil && si.QuarantinedAt == 0 && !si.Data.opened() {
+		return si.Data
+	}
+	return nil
+}
+
+// pendingData 返回尚未解密且未隔离的敏感数据
+func (sih *SecretItemHistory) pendingData() *SecretItemData {
+	sih.bindData()
+	if sih.Data != nil && sih.QuarantinedAt == 0 && !sih.Data.opened() {
+		return sih.Data
+	}
+	return nil
+}
+
+// registerBatchOpenCallback 注册查询回调，在 AfterFind 钩子之前批量解密查询结果中的敏感数据
+func registerBatchOpenCallback(db *gorm.DB) error {
+	return db.Callback().Query().Before("gorm:after_query").Register("hysaif:batch_open_secret_data", batchOpenSecretData)
+}
+
+// batchOpenSecretData 批量解密列表、历史等多行查询结果中的敏感数据，
+// 数据密钥合并为批量解包请求，Vault KV中的数据限制并发读取；
+// 批量解密失败的记录保持密文，由 AfterFind 钩子逐条解密并按原有方式处理错误
+func batchOpenSecretData(db *gorm.DB) {
+	if db.Error != nil || db.Statement.ReflectValue.Kind() != reflect.Slice {
+		return
+	}
+
+	var pending []*SecretItemData
+	rows := db.Statement.ReflectValue
+	for i := 0; i < rows.Len(); i++ {
+		row := reflect.Indirect(rows.Index(i))
+		if !row.CanAddr() {
+			continue
+		}
+		holder, ok := row.Addr().Interface().(pendingDataHolder)
+		if !ok {
+			// 同一查询结果的类型相同
+			return
+		}
+		if data := holder.pendingData(); data != nil {
+			pending = append(pending, data)
+		}
+	}
+
+	// 单条记录由 AfterFind 钩子解密
+	if len(pending) < 2 {
+		return
+	}
+
+	openSecretItemDataBatch(pending)
+}
+
+// openSecretItemDataBatch 批量解密敏感数据，失败的数据保持密文
+func openSecretItemDataBatch(pending []*SecretItemData) {
+	var (
+		encrypted   []*SecretItemData
+		ciphertexts []string
+		options     []crypto.Options
+		stored      []*SecretItemData
+		refs        []string
+		itemIDs     []string
+	)
+	for _, data := range pending {
+		if crypto.IsVaultKVRef(data.ciphertext) {
+			stored = append(stored, data)
+			refs = append(refs, data.ciphertext)
+			itemIDs = append(itemIDs, data.itemID)
+			continue
+		}
+		encrypted = append(encrypted, data)
+		ciphertexts = append(ciphertexts, data.ciphertext)
+		options = append(options, data.options)
+	}
+
+	if len(encrypted) > 0 {
+		plaintexts, errs := crypto.DecryptBatch(ciphertexts, options)
+		for i, data := range encrypted {
+			if errs[i] == nil {
+				_ = data.decode(plaintexts[i])
+			}
+		}
+	}
+
+	if len(stored) > 0 {
+		plaintexts, errs := crypto.ReadVaultKVBatch(refs, itemIDs)
+		for i, data := range stored {
+			if errs[i] == nil {
+				_ = data.decodeRef(refs[i], plaintexts[i])
+			}
+		}
+	}
+}
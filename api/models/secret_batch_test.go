@@ -0,0 +1,81 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func TestBatchOpenSecretData(t *testing.T) {
+	user := &User{Name: "batch-owner", Email: "batch-owner-" + uuid.NewString()[:8] + "@example.com", Role: "sec_mgr", Status: "active"}
+	if err := DB.Omit("created_by", "updated_by").Create(user).Error; err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+
+	prefix := "batch-" + uuid.NewString()[:8] + "-"
+	ids := make(map[string]string)
+	for _, name := range []string{"a", "b", "c", "copied", "quarantined"} {
+		item := &SecretItem{Name: prefix + name, Type: SecretTypePassword, Environment: "development", Data: &SecretItemData{Password: "secret-" + name}, CreatedByID: user.ID}
+		if err := DB.Create(item).Error; err != nil {
+			t.Fatalf("创建信息项 %s 失败: %v", name, err)
+		}
+		ids[name] = item.ID
+	}
+
+	// 复制到其他数据行的密文无法通过绑定校验
+	var raw string
+	if err := DB.Table(SecretItemTable).Select("data").Where("id = ?", ids["a"]).Scan(&raw).Error; err != nil {
+		t.Fatalf("读取密文失败: %v", err)
+	}
+	if err := DB.Table(SecretItemTable).Where("id = ?", ids["copied"]).UpdateColumn("data", raw).Error; err != nil {
+		t.Fatalf("复制密文失败: %v", err)
+	}
+	if err := DB.Model(&SecretItem{}).Where("id = ?", ids["quarantined"]).UpdateColumn("quarantined_at", 1).Error; err != nil {
+		t.Fatalf("隔离信息项失败: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		items      []string
+		wantOpened map[string]bool
+	}{
+		{"多行查询批量解密", []string{"a", "b", "c"}, map[string]bool{"a": true, "b": true, "c": true}},
+		{"无法解密的记录保持密文", []string{"a", "copied"}, map[string]bool{"a": true, "copied": false}},
+		{"已隔离的记录不解密", []string{"b", "c", "quarantined"}, map[string]bool{"b": true, "c": true, "quarantined": false}},
+		{"单行查询由AfterFind钩子解密", []string{"a"}, map[string]bool{"a": false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queryIDs := make([]string, len(tt.items))
+			for i, name := range tt.items {
+				queryIDs[i] = ids[name]
+			}
+
+			// 跳过 AfterFind 钩子，只检查批量解密回调的结果
+			var items []SecretItem
+			if err := DB.Session(&gorm.Session{SkipHooks: true}).Where("id IN ?", queryIDs).Find(&items).Error; err != nil {
+				t.Fatalf("查询失败: %v", err)
+			}
+			if len(items) != len(tt.items) {
+				t.Fatalf("查询到 %d 条，期望 %d 条", len(items), len(tt.items))
+			}
+
+			for _, item := range items {
+				name := item.Name[len(prefix):]
+				if opened := item.Data.opened(); opened != tt.wantOpened[name] {
+					t.Fatalf("%s 已解密 = %v，期望 %v", name, opened, tt.wantOpened[name])
+				}
+				if item.Data.opened() && item.Data.Password != "secret-"+name {
+					t.Fatalf("%s 的密码 = %q，期望 %q", name, item.Data.Password, "secret-"+name)
+				}
+			}
+		})
+	}
+
+	// 正常查询时批量解密失败的记录由 AfterFind 钩子返回错误
+	var items []SecretItem
+	if err := DB.Where("id IN ?", []string{ids["a"], ids["copied"]}).Find(&items).Error; err == nil {
+		t.Fatal("期望查询包含无法解密的记录时返回错误")
+	}
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/google/uuid"
@@ -10,50 +11,113 @@ import (
 
 // SecretItemHistory 密钥历史版本模型
 type SecretItemHistory struct {
-	ID           string          `json:"id" gorm:"primaryKey"`
-	SecretItemID string          `json:"secret_item_id" gorm:"index;not null"`   // 关联的密钥项ID
-	Version      int             `json:"version" gorm:"not null"`                // 版本号
-	Name         string          `json:"name" gorm:"not null"`                   // 当时的名称
-	Description  string          `json:"description"`                            // 当时的描述
-	Type         string          `json:"type" gorm:"not null"`                   // 当时的类型
-	Category     string          `json:"category"`                               // 当时的分类
-	Tags         []string        `json:"tags" gorm:"serializer:json"`            // 当时的标签
-	Data         *SecretItemData `json:"data" gorm:"type:text"`                  // 当时的敏感数据
-	ExpiresAt    uint64          `json:"expires_at"`                             // 当时的过期时间
-	Environment  string          `json:"environment"`                            // 当时的环境
-	ChangeType   string          `json:"change_type" gorm:"not null"`            // 变更类型：created, updated, deleted
-	ChangeReason string          `json:"change_reason"`                          // 变更原因
-	CreatedAt    uint64          `json:"created_at" gorm:"autoCreateTime:milli"` // 创建时间
-	CreatedByID  string          `json:"created_by_id" gorm:"index;not null"`    // 创建者ID
+	ID           string             `json:"id" gorm:"primaryKey"`
+	SecretItemID string             `json:"secret_item_id" gorm:"index;not null"`      // 关联的密钥项ID
+	Version      int                `json:"version" gorm:"not null"`                   // 版本号
+	Name         string             `json:"name" gorm:"not null"`                      // 当时的名称
+	Description  string             `json:"description"`                               // 当时的描述
+	Type         string             `json:"type" gorm:"not null"`                      // 当时的类型
+	Category     string             `json:"category"`                                  // 当时的分类
+	Tags         []string           `json:"tags" gorm:"serializer:json"`               // 当时的标签
+	Data         *SecretItemData    `json:"data" gorm:"type:text"`                     // 当时的敏感数据
+	ExpiresAt    uint64             `json:"expires_at"`                                // 当时的过期时间
+	Metadata     SecretItemMetadata `json:"metadata" gorm:"type:text;serializer:json"` // 当时的元数据
+	Environment  string             `json:"environment"`                               // 当时的环境
+	FolderID     string             `json:"folder_id"`                                 // 当时所在的文件夹ID，恢复历史版本时不改变信息项所在文件夹
+	ChangeType   string             `json:"change_type" gorm:"not null"`               // 变更类型：created, updated, deleted, moved, rotated, rotation_pending
+	ChangeReason string             `json:"change_reason"`                             // 变更原因
+	CreatedAt    uint64             `json:"created_at" gorm:"autoCreateTime:milli"`    // 创建时间
+	CreatedByID  string             `json:"created_by_id" gorm:"index;not null"`       // 创建者ID
+
+	// 端到端加密
+	E2E               bool   `json:"e2e" gorm:"column:e2e;default:false"`            // 当时是否为端到端加密信息项
+	Payload           string `json:"payload,omitempty" gorm:"type:text"`             // 当时客户端加密的数据
+	ContentKeyVersion int    `json:"content_key_version,omitempty" gorm:"default:0"` // 当时加密数据使用的内容密钥版本
+
+	// 完整性校验，隔离的数据无法解密，读取时不再尝试解密
+	QuarantinedAt    uint64 `json:"quarantined_at"`              // 隔离时间，0表示未隔离
+	QuarantineReason string `json:"quarantine_reason,omitempty"` // 隔离原因
 
 	// 关联数据
 	SecretItem *SecretItem `json:"secret_item,omitempty" gorm:"foreignKey:SecretItemID;references:ID"`
 	CreatedBy  *User       `json:"created_by,omitempty" gorm:"foreignKey:CreatedByID;references:ID"`
 }
 
+// SecretItemHistoryTable 密钥历史版本数据表名
+const SecretItemHistoryTable = "secret_item_histories"
+
 // BeforeCreate 钩子函数，在创建记录之前设置ID
 func (sih *SecretItemHistory) BeforeCreate(tx *gorm.DB) (err error) {
 	sih.ID = uuid.New().String()
+	sih.bindData()
+	// 历史版本与密钥项共用KV路径，数据与密钥项当前版本一致时直接引用该版本
+	if sih.Data != nil {
+		return sih.Data.store(sih.SecretItemID)
+	}
+	return
+}
+
+// BeforeSave 钩子函数，将所属数据行和环境传递给敏感数据
+func (sih *SecretItemHistory) BeforeSave(tx *gorm.DB) (err error) {
+	sih.bindData()
+	return
+}
+
+// AfterFind 钩子函数，使用所属数据行信息解密敏感数据，已隔离的数据保持密文
+func (sih *SecretItemHistory) AfterFind(tx *gorm.DB) (err error) {
+	sih.bindData()
+	if sih.Data != nil && sih.QuarantinedAt == 0 {
+		return sih.Data.openAvailable()
+	}
 	return
 }
 
+// DataUnavailable 检查当前部署是否因未持有所属环境的主密钥而无法解密敏感数据
+func (sih *SecretItemHistory) DataUnavailable() bool {
+	return sih.Data != nil && sih.QuarantinedAt == 0 && !sih.Data.opened()
+}
+
+// bindData 设置敏感数据的加密选项，密文与所属数据行绑定
+func (sih *SecretItemHistory) bindData() {
+	if sih.Data != nil {
+		sih.Data.bind(SecretItemHistoryTable, sih.ID, sih.SecretItemID, sih.Environment, sih.CreatedByID)
+	}
+}
+
 // 变更类型常量
 const (
-	HistoryChangeTypeCreated = "created"
-	HistoryChangeTypeUpdated = "updated"
-	HistoryChangeTypeDeleted = "deleted"
+	HistoryChangeTypeCreated  = "created"
+	HistoryChangeTypeUpdated  = "updated"
+	HistoryChangeTypeDeleted  = "deleted"
+	HistoryChangeTypeMoved    = "moved"
+	HistoryChangeTypeRotated  = "rotated"
+	HistoryChangeTypeRestored = "restored"
+
+	// HistoryChangeTypeRotationPending 轮换生成、等待外部系统确认的新值，确认并保存后改为 rotated
+	HistoryChangeTypeRotationPending = "rotation_pending"
 )
 
 // CreateSecretItemHistory 创建密钥历史版本记录
 func CreateSecretItemHistory(secretItem *SecretItem, changeType, reason string, createdByID string) error {
+	_, err := createSecretItemHistory(DB, secretItem, changeType, reason, createdByID)
+	return err
+}
+
+// createSecretItemHistory 在指定的事务中创建历史版本
+func createSecretItemHistory(tx *gorm.DB, secretItem *SecretItem, changeType, reason string, createdByID string) (*SecretItemHistory, error) {
+	// 未解密的密文与信息项数据行绑定，无法复制到历史记录
+	if secretItem.DataUnavailable() {
+		return nil, fmt.Errorf("当前部署未持有环境 '%s' 的主密钥，无法创建历史版本", secretItem.Environment)
+	}
+
 	// 获取当前最大版本号
 	var maxVersion int
-	err := DB.Model(&SecretItemHistory{}).
+	err := tx.Model(&SecretItemHistory{}).
 		Where("secret_item_id = ?", secretItem.ID).
 		Select("COALESCE(MAX(version), 0)").
 		Scan(&maxVersion).Error
 	if err != nil {
-		return fmt.Errorf("获取版本号失败: %w", err)
+		return nil, fmt.Errorf("获取版本号失败: %w", err)
 	}
 
 	// 创建历史记录
@@ -67,13 +131,26 @@ func CreateSecretItemHistory(secretItem *SecretItem, changeType, reason string,
 		Tags:         secretItem.Tags,
 		Data:         secretItem.Data,
 		ExpiresAt:    secretItem.ExpiresAt,
+		Metadata:     secretItem.Metadata,
 		Environment:  secretItem.Environment,
+		FolderID:     secretItem.FolderID,
 		ChangeType:   changeType,
 		ChangeReason: reason,
 		CreatedByID:  createdByID,
+		E2E:          secretItem.E2E,
+		Payload:      secretItem.Payload,
+
+		ContentKeyVersion: secretItem.ContentKeyVersion,
+
+		// 隔离的数据原样保存，历史记录同样无法解密
+		QuarantinedAt:    secretItem.QuarantinedAt,
+		QuarantineReason: secretItem.QuarantineReason,
 	}
 
-	return DB.Create(history).Error
+	if err := tx.Create(history).Error; err != nil {
+		return nil, err
+	}
+	return history, nil
 }
 
 // GetSecretItemHistory 获取密钥历史版本列表
@@ -86,7 +163,7 @@ func GetSecretItemHistory(secretItemID string) ([]SecretItemHistory, error) {
 	return histories, err
 }
 
-// GetSecretItemHistoryByVersion 获取指定版本的密钥历史记录
+// GetSecretItemHistoryByVersion 获取指定版本的密钥历史记录，存储后端为Vault KV时敏感数据读取记录引用的KV版本
 func GetSecretItemHistoryByVersion(secretItemID string, version int) (*SecretItemHistory, error) {
 	var history SecretItemHistory
 	err := DB.Where("secret_item_id = ? AND version = ?", secretItemID, version).
@@ -98,6 +175,9 @@ func GetSecretItemHistoryByVersion(secretItemID string, version int) (*SecretIte
 	return &history, nil
 }
 
+// ErrContentKeyChanged 端到端加密信息项的历史版本使用的内容密钥已更换，无法恢复
+var ErrContentKeyChanged = errors.New("端到端加密信息项的内容密钥已更换，无法恢复更换前的历史版本，请在客户端解密后重新保存")
+
 // RestoreSecretItemFromHistory 从历史版本恢复密钥项
 func RestoreSecretItemFromHistory(secretItemID string, version int, restoredByID string) (*SecretItem, error) {
 	// 获取历史版本
@@ -105,6 +185,12 @@ func RestoreSecretItemFromHistory(secretItemID string, version int, restoredByID
 	if err != nil {
 		return nil, fmt.Errorf("获取历史版本失败: %w", err)
 	}
+	if history.QuarantinedAt != 0 {
+		return nil, fmt.Errorf("历史版本 %d 的数据无法解密，已被隔离", version)
+	}
+	if history.DataUnavailable() {
+		return nil, fmt.Errorf("当前部署未持有环境 '%s' 的主密钥，无法恢复历史版本 %d", history.Environment, version)
+	}
 
 	// 获取当前密钥项
 	var currentItem SecretItem
@@ -113,6 +199,11 @@ func RestoreSecretItemFromHistory(secretItemID string, version int, restoredByID
 		return nil, fmt.Errorf("获取当前密钥项失败: %w", err)
 	}
 
+	// 当前接收者的包装密钥只能解开当前的内容密钥，更换内容密钥前的数据恢复后所有接收者都无法解密
+	if history.E2E && (history.ContentKeyVersion == 0 || history.ContentKeyVersion != currentItem.ContentKeyVersion) {
+		return nil, fmt.Errorf("%w: 历史版本 %d 使用的内容密钥已更换", ErrContentKeyChanged, version)
+	}
+
 	// 先保存当前状态到历史记录
 	err = CreateSecretItemHistory(&currentItem, HistoryChangeTypeUpdated, "恢复到历史版本", restoredByID)
 	if err != nil {
@@ -125,8 +216,10 @@ func RestoreSecretItemFromHistory(secretItemID string, version int, restoredByID
 	currentItem.Type = history.Type
 	currentItem.Category = history.Category
 	currentItem.Tags = history.Tags
-	currentItem.Data = history.Data
+	currentItem.Data = history.Data.clone()
+	currentItem.Payload = history.Payload
 	currentItem.ExpiresAt = history.ExpiresAt
+	currentItem.Metadata = history.Metadata
 	currentItem.Environment = history.Environment
 	currentItem.UpdatedByID = restoredByID
 
@@ -173,6 +266,9 @@ func CompareSecretItemVersions(secretItemID string, version1, version2 int) (map
 	if history1.Environment != history2.Environment {
 		diff["environment"] = map[string]string{"old": history1.Environment, "new": history2.Environment}
 	}
+	if history1.FolderID != history2.FolderID {
+		diff["folder_id"] = map[string]string{"old": history1.FolderID, "new": history2.FolderID}
+	}
 	if history1.ExpiresAt != history2.ExpiresAt {
 		diff["expires_at"] = map[string]uint64{"old": history1.ExpiresAt, "new": history2.ExpiresAt}
 	}
@@ -187,7 +283,7 @@ func CompareSecretItemVersions(secretItemID string, version1, version2 int) (map
 	// 比较数据（敏感数据不直接比较，只标记是否有变化）
 	data1Json, _ := json.Marshal(history1.Data)
 	data2Json, _ := json.Marshal(history2.Data)
-	if string(data1Json) != string(data2Json) {
+	if string(data1Json) != string(data2Json) || history1.Payload != history2.Payload {
 		diff["data"] = map[string]string{"old": "***", "new": "***"}
 	}
 
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// x25519PublicKeySize X25519公钥长度
+const x25519PublicKeySize = 32
+
+// SecretItemRecipient 端到端加密信息项的接收者，保存由客户端使用接收者公钥包装的内容密钥，服务端无法解包
+type SecretItemRecipient struct {
+	ModelBase
+	SecretItemID    string `json:"secret_item_id" gorm:"type:varchar(36);uniqueIndex:idx_recipient_item_user;not null"` // 信息项ID
+	UserID          string `json:"user_id" gorm:"type:varchar(36);uniqueIndex:idx_recipient_item_user;not null"`        // 接收者ID
+	WrappedKey      string `json:"wrapped_key" gorm:"type:text;not null"`                                               // 包装后的内容密钥
+	AddedByID       string `json:"added_by_id" gorm:"index"`                                                            // 添加者ID
+	AccessRequestID string `json:"access_request_id,omitempty" gorm:"index"`                                            // 通过访问申请添加时的申请ID
+}
+
+// BeforeCreate 钩子函数，在创建记录之前设置ID
+func (r *SecretItemRecipient) BeforeCreate(tx *gorm.DB) (err error) {
+	r.ID = uuid.New().String()
+	return
+}
+
+// ValidatePublicKey 校验base64编码的X25519公钥
+func ValidatePublicKey(publicKey string) error {
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil {
+		return fmt.Errorf("公钥必须为base64编码: %w", err)
+	}
+	if len(key) != x25519PublicKeySize {
+		return fmt.Errorf("X25519公钥长度必须为%d字节，当前长度: %d", x25519PublicKeySize, len(key))
+	}
+	return nil
+}
+
+// ValidateRecipientUsers 校验接收者均存在且已注册公钥
+func ValidateRecipientUsers(userIDs []string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	var users []User
+	if err := DB.Select("id", "name", "public_key").Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		return err
+	}
+
+	registered := make(map[string]bool, len(users))
+	for _, user := range users {
+		if user.PublicKey == "" {
+			return fmt.Errorf("用户 %s 尚未注册公钥", user.Name)
+		}
+		registered[user.ID] = true
+	}
+	for _, id := range userIDs {
+		if !registered[id] {
+			return fmt.Errorf("接收者 %s 不存在", id)
+		}
+	}
+	return nil
+}
+
+// ReplaceSecretItemRecipients 替换信息项的全部接收者，用于创建信息项或客户端更换内容密钥
+func ReplaceSecretItemRecipients(tx *gorm.DB, secretItemID, addedByID string, recipients []SecretItemRecipient) error {
+	if err := tx.Where("secret_item_id = ?", secretItemID).Delete(&SecretItemRecipient{}).Error; err != nil {
+		return fmt.Errorf("删除接收者失败: %w", err)
+	}
+
+	for i := range recipients {
+		recipients[i].SecretItemID = secretItemID
+		recipients[i].AddedByID = addedByID
+	}
+	if len(recipients) == 0 {
+		return nil
+	}
+	if err := tx.Create(&recipients).Error; err != nil {
+		return fmt.Errorf("保存接收者失败: %w", err)
+	}
+	return nil
+}
+
+// AddSecretItemRecipient 添加或更新单个接收者的包装密钥
+func AddSecretItemRecipient(tx *gorm.DB, recipient *SecretItemRecipient) error {
+	if err := tx.Where("secret_item_id = ? AND user_id = ?", recipient.SecretItemID, recipient.UserID).
+		Delete(&SecretItemRecipient{}).Error; err != nil {
+		return fmt.Errorf("删除接收者失败: %w", err)
+	}
+	if err := tx.Create(recipient).Error; err != nil {
+		return fmt.Errorf("保存接收者失败: %w", err)
+	}
+	return nil
+}
+
+// RemoveAccessRequestRecipient 删除通过访问申请添加的接收者
+func RemoveAccessRequestRecipient(tx *gorm.DB, accessRequestID string) error {
+	return tx.Where("access_request_id = ?", accessRequestID).Delete(&SecretItemRecipient{}).Error
+}
+
+// GetWrappedKey 获取用户在信息项中的包装密钥，用户不是接收者时返回空字符串
+func GetWrappedKey(secretItemID, userID string) (string, error) {
+	var recipient SecretItemRecipient
+	err := DB.Where("secret_item_id = ? AND user_id = ?", secretItemID, userID).First(&recipient).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return recipient.WrappedKey, nil
+}
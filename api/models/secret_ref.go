@@ -0,0 +1,340 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// secretReferencePattern 引用语法：${ref:<信息项ID或路径>#<字段>}
+//
+// 路径为所在文件夹路径加信息项名称，如 payments/prod/db-password，位于根目录的信息项直接使用名称；
+// 字段为敏感数据的JSON字段名，如 password，自定义类型的字段使用 fields.<字段名>
+var secretReferencePattern = regexp.MustCompile(`\$\{ref:([^#}]+)#([A-Za-z0-9_.]+)\}`)
+
+// maxSecretReferenceDepth 引用的最大嵌套层数
+const maxSecretReferenceDepth = 8
+
+// ErrSecretReferenceForbidden 调用者无权访问被引用的信息项
+var ErrSecretReferenceForbidden = errors.New("无权访问引用的信息项")
+
+// SecretItemReference 解析引用时读取的信息项
+type SecretItemReference struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// HasSecretReference 检查字段值中是否包含引用
+func HasSecretReference(value string) bool {
+	return secretReferencePattern.MatchString(value)
+}
+
+// ResolveReferences 将敏感数据中的引用替换为被引用字段的当前值，被引用的字段包含引用时递归解析；
+// 用户必须可以访问每个被引用的信息项。返回解析过程中读取的所有信息项，按首次读取的顺序排列
+func (si *SecretItem) ResolveReferences(user *User) ([]SecretItemReference, error) {
+	if si.E2E || si.Data == nil {
+		return nil, nil
+	}
+
+	resolver := newSecretReferenceResolver(user, si)
+	data, err := resolver.resolveData(si)
+	if err != nil {
+		return nil, err
+	}
+	si.Data = data
+	return resolver.touched, nil
+}
+
+// ValidateReferences 在保存前校验敏感数据中的引用：被引用的信息项和字段存在、用户可以访问且没有循环引用
+func (si *SecretItem) ValidateReferences(user *User) error {
+	if si.E2E || si.Data == nil {
+		return nil
+	}
+
+	_, err := newSecretReferenceResolver(user, si).resolveData(si)
+	return err
+}
+
+// secretReferenceResolver 解析一次读取中的所有引用，按字段检测循环引用
+type secretReferenceResolver struct {
+	user     *User
+	items    map[string]*SecretItem // 已加载的信息项，按ID缓存，包含正在读取的信息项本身
+	targets  map[string]*SecretItem // 引用目标到信息项的映射
+	resolved map[string]string      // 已解析的字段值，键为 <信息项ID>#<字段>
+	stack    []string               // 正在解析的字段，键为 <信息项ID>#<字段>
+	touched  []SecretItemReference  // 解析过程中读取的信息项
+}
+
+func newSecretReferenceResolver(user *User, root *SecretItem) *secretReferenceResolver {
+	resolver := &secretReferenceResolver{
+		user:     user,
+		items:    make(map[string]*SecretItem),
+		targets:  make(map[string]*SecretItem),
+		resolved: make(map[string]string),
+	}
+	// 引用信息项自身的其他字段时使用当前（可能尚未保存的）数据
+	if root.ID != "" {
+		resolver.items[root.ID] = root
+	}
+	return resolver
+}
+
+// resolveData 返回解析引用后的敏感数据副本，原数据不变
+func (r *secretReferenceResolver) resolveData(item *SecretItem) (*SecretItemData, error) {
+	data := *item.Data
+	err := data.rewriteStrings(func(field, value string) (string, error) {
+		return r.resolveField(item.ID, field, value)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// resolveField 解析字段值中的所有引用
+func (r *secretReferenceResolver) resolveField(itemID, field, value string) (string, error) {
+	if !HasSecretReference(value) {
+		return value, nil
+	}
+
+	key := itemID + "#" + field
+	if resolved, ok := r.resolved[key]; ok {
+		return resolved, nil
+	}
+	if slices.Contains(r.stack, key) {
+		return "", fmt.Errorf("检测到循环引用: %s -> %s", strings.Join(r.stack, " -> "), key)
+	}
+	if len(r.stack) >= maxSecretReferenceDepth {
+		return "", fmt.Errorf("引用嵌套超过%d层", maxSecretReferenceDepth)
+	}
+
+	r.stack = append(r.stack, key)
+	defer func() { r.stack = r.stack[:len(r.stack)-1] }()
+
+	var resolveErr error
+	resolved := secretReferencePattern.ReplaceAllStringFunc(value, func(match string) string {
+		if resolveErr != nil {
+			return match
+		}
+		parts := secretReferencePattern.FindStringSubmatch(match)
+		target, err := r.load(parts[1])
+		if err != nil {
+			resolveErr = err
+			return match
+		}
+		targetValue, ok := target.Data.referenceFieldValue(parts[2], target.Metadata)
+		if !ok {
+			resolveErr = fmt.Errorf("引用的信息项 %s 没有字段 %s", parts[1], parts[2])
+			return match
+		}
+		targetValue, err = r.resolveField(target.ID, parts[2], targetValue)
+		if err != nil {
+			resolveErr = err
+			return match
+		}
+		return targetValue
+	})
+	if resolveErr != nil {
+		return "", resolveErr
+	}
+
+	r.resolved[key] = resolved
+	return resolved, nil
+}
+
+// load 加载引用目标，检查用户的访问权限，并记录读取的信息项
+func (r *secretReferenceResolver) load(target string) (*SecretItem, error) {
+	if item, ok := r.targets[target]; ok {
+		return item, nil
+	}
+
+	found, err := findSecretReferenceTarget(target)
+	if err != nil {
+		return nil, err
+	}
+
+	item, ok := r.items[found.ID]
+	if !ok {
+		canAccess, err := r.user.CanAccessSecretItem(found)
+		if err != nil {
+			return nil, err
+		}
+		if !canAccess {
+			return nil, fmt.Errorf("%w: %s", ErrSecretReferenceForbidden, target)
+		}
+
+		item = &SecretItem{}
+		if err := DB.Where("id = ?", found.ID).First(item).Error; err != nil {
+			return nil, err
+		}
+		switch {
+		case item.E2E:
+			return nil, fmt.Errorf("引用的信息项 %s 为端到端加密信息项，服务端无法读取", target)
+		case item.QuarantinedAt != 0:
+			return nil, fmt.Errorf("引用的信息项 %s 的数据已被隔离", target)
+		case item.DataUnavailable():
+			return nil, fmt.Errorf("当前部署未持有环境 '%s' 的主密钥，无法读取引用的信息项 %s", item.Environment, target)
+		case item.Data == nil:
+			return nil, fmt.Errorf("引用的信息项 %s 没有敏感数据", target)
+		}
+		r.items[item.ID] = item
+		r.touched = append(r.touched, SecretItemReference{ID: item.ID, Name: item.Name, Type: item.Type})
+	}
+
+	r.targets[target] = item
+	return item, nil
+}
+
+// findSecretReferenceTarget 按ID或路径查找引用目标，路径对应多个同名信息项时只能使用ID引用
+func findSecretReferenceTarget(target string) (*SecretItem, error) {
+	query := DB.Select("id", "name", "type", "created_by_id", "folder_id")
+	if _, err := uuid.Parse(target); err == nil {
+		query = query.Where("id = ?", target)
+	} else {
+		folderPath, name := "", target
+		if i := strings.LastIndex(target, "/"); i >= 0 {
+			folderPath, name = target[:i], target[i+1:]
+		}
+		query = query.Where("name = ?", name)
+		if folderPath == "" {
+			query = query.Where("(folder_id = '' OR folder_id IS NULL)")
+		} else {
+			query = query.Where("folder_id IN (?)", DB.Model(&Folder{}).Select("id").Where("path = ?", folderPath))
+		}
+	}
+
+	var items []SecretItem
+	if err := query.Limit(2).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	switch len(items) {
+	case 0:
+		return nil, fmt.Errorf("引用的信息项 %s 不存在", target)
+	case 1:
+		return &items[0], nil
+	default:
+		return nil, fmt.Errorf("路径 %s 对应多个信息项，请使用信息项ID引用", target)
+	}
+}
+
+// rewriteStrings 使用函数替换所有字符串字段的值，map 和切片替换为新的副本
+func (s *SecretItemData) rewriteStrings(rewrite func(field, value string) (string, error)) error {
+	for _, field := range s.stringFields() {
+		value, err := rewrite(field.name, *field.value)
+		if err != nil {
+			return err
+		}
+		*field.value = value
+	}
+
+	if s.Fields != nil {
+		fields := make(map[string]string, len(s.Fields))
+		for name, value := range s.Fields {
+			rewritten, err := rewrite("fields."+name, value)
+			if err != nil {
+				return err
+			}
+			fields[name] = rewritten
+		}
+		s.Fields = fields
+	}
+
+	if s.CustomData != nil {
+		customData := make([]map[string]string, len(s.CustomData))
+		for i, entry := range s.CustomData {
+			customData[i] = make(map[string]string, len(entry))
+			for key, value := range entry {
+				rewritten, err := rewrite(fmt.Sprintf("custom_data.%d.%s", i, key), value)
+				if err != nil {
+					return err
+				}
+				customData[i][key] = rewritten
+			}
+		}
+		s.CustomData = customData
+	}
+	return nil
+}
+
+// referenceFieldValue 获取可以被引用的字段值，自定义类型的非敏感字段从元数据中读取
+func (s *SecretItemData) referenceFieldValue(field string, metadata SecretItemMetadata) (string, bool) {
+	if name, ok := strings.CutPrefix(field, "fields."); ok {
+		if value, ok := s.Fields[name]; ok {
+			return value, true
+		}
+		value, ok := metadata.Fields[name]
+		return value, ok
+	}
+	for _, f := range s.stringFields() {
+		if f.name == field {
+			return *f.value, true
+		}
+	}
+	return "", false
+}
+
+// FieldValue 按JSON字段名获取敏感数据中的字符串字段，自定义类型的字段使用 fields.<字段名>
+func (s *SecretItemData) FieldValue(field string) (string, bool) {
+	return s.referenceFieldValue(field, SecretItemMetadata{})
+}
+
+// SetFieldValue 按JSON字段名设置敏感数据中的字符串字段，自定义类型的字段写入新的 map；字段不存在时返回 false
+func (s *SecretItemData) SetFieldValue(field, value string) bool {
+	if name, ok := strings.CutPrefix(field, "fields."); ok {
+		if _, ok := s.Fields[name]; !ok {
+			return false
+		}
+		s.Fields = maps.Clone(s.Fields)
+		s.Fields[name] = value
+		return true
+	}
+	for _, f := range s.stringFields() {
+		if f.name == field {
+			*f.value = value
+			return true
+		}
+	}
+	return false
+}
+
+// stringFields 内置类型的字符串字段及其JSON字段名
+func (s *SecretItemData) stringFields() []struct {
+	name  string
+	value *string
+} {
+	return []struct {
+		name  string
+		value *string
+	}{
+		{"username", &s.Username},
+		{"password", &s.Password},
+		{"address", &s.Address},
+		{"notes", &s.Notes},
+		{"api_key", &s.APIKey},
+		{"api_secret", &s.APISecret},
+		{"endpoint", &s.Endpoint},
+		{"access_key", &s.AccessKey},
+		{"secret_key", &s.SecretKey},
+		{"region", &s.Region},
+		{"private_key", &s.PrivateKey},
+		{"public_key", &s.PublicKey},
+		{"passphrase", &s.Passphrase},
+		{"certificate", &s.Certificate},
+		{"token", &s.Token},
+		{"token_type", &s.TokenType},
+		{"refresh_token", &s.RefreshToken},
+		{"engine", &s.Engine},
+		{"database", &s.Database},
+		{"ssl_mode", &s.SSLMode},
+		{"creation_statements", &s.CreationStatements},
+		{"revocation_statements", &s.RevocationStatements},
+		{"otpauth_uri", &s.OTPAuthURI},
+		{"seed", &s.Seed},
+	}
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestResolveReferencesDetectsCycles(t *testing.T) {
+	user := &User{Name: "ref-owner", Email: "ref-owner-" + uuid.NewString()[:8] + "@example.com", Role: "sec_mgr", Status: "active"}
+	if err := DB.Omit("created_by", "updated_by").Create(user).Error; err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+
+	// 信息项名称加前缀，避免与其他测试冲突
+	prefix := "ref-" + uuid.NewString()[:8] + "-"
+	ref := func(name, field string) string {
+		return fmt.Sprintf("${ref:%s%s#%s}", prefix, name, field)
+	}
+	create := func(name string, data SecretItemData) *SecretItem {
+		t.Helper()
+		item := &SecretItem{Name: prefix + name, Type: SecretTypePassword, Environment: "development", Data: &data, CreatedByID: user.ID}
+		if err := DB.Create(item).Error; err != nil {
+			t.Fatalf("创建信息项 %s 失败: %v", name, err)
+		}
+		return item
+	}
+
+	create("base", SecretItemData{Password: "base-secret"})
+	create("chain", SecretItemData{Password: ref("base", "password")})
+	create("diamond", SecretItemData{Username: ref("base", "password"), Password: ref("chain", "password") + "/" + ref("base", "password")})
+	create("own-field", SecretItemData{Username: ref("own-field", "password"), Password: "own-secret"})
+	create("self", SecretItemData{Password: ref("self", "password")})
+	create("self-pair", SecretItemData{Username: ref("self-pair", "password"), Password: ref("self-pair", "username")})
+	create("cycle-a", SecretItemData{Password: ref("cycle-b", "password")})
+	create("cycle-b", SecretItemData{Password: ref("cycle-c", "password")})
+	create("cycle-c", SecretItemData{Password: ref("cycle-a", "password")})
+	create("deep-0", SecretItemData{Password: "deep-secret"})
+	for i := 1; i <= maxSecretReferenceDepth+1; i++ {
+		create(fmt.Sprintf("deep-%d", i), SecretItemData{Password: ref(fmt.Sprintf("deep-%d", i-1), "password")})
+	}
+
+	tests := []struct {
+		name         string
+		item         string
+		wantUsername string
+		wantPassword string
+		wantErr      string
+	}{
+		{name: "引用其他信息项", item: "chain", wantPassword: "base-secret"},
+		{name: "多条路径引用同一字段", item: "diamond", wantUsername: "base-secret", wantPassword: "base-secret/base-secret"},
+		{name: "引用自身的其他字段", item: "own-field", wantUsername: "own-secret", wantPassword: "own-secret"},
+		{name: "引用自身的同一字段", item: "self", wantErr: "循环引用"},
+		{name: "自身字段相互引用", item: "self-pair", wantErr: "循环引用"},
+		{name: "多个信息项形成环", item: "cycle-a", wantErr: "循环引用"},
+		{name: "达到最大嵌套层数", item: fmt.Sprintf("deep-%d", maxSecretReferenceDepth), wantPassword: "deep-secret"},
+		{name: "超过最大嵌套层数", item: fmt.Sprintf("deep-%d", maxSecretReferenceDepth+1), wantErr: "嵌套超过"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var item SecretItem
+			if err := DB.Where("name = ?", prefix+tt.item).First(&item).Error; err != nil {
+				t.Fatalf("读取信息项失败: %v", err)
+			}
+			_, err := item.ResolveReferences(user)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("期望错误包含 %q，实际错误: %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("解析引用失败: %v", err)
+			}
+			if item.Data.Username != tt.wantUsername || item.Data.Password != tt.wantPassword {
+				t.Fatalf("解析结果 username=%q password=%q，期望 %q %q", item.Data.Username, item.Data.Password, tt.wantUsername, tt.wantPassword)
+			}
+		})
+	}
+}
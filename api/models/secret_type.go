@@ -0,0 +1,284 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// BuiltinSecretTypes 内置信息项类型，敏感数据字段由 SecretItemData 定义
+var BuiltinSecretTypes = []string{SecretTypePassword, "api_key", "access_key", SecretTypeSSHKey, SecretTypeCertificate, "token", "kv", SecretTypeDatabase, SecretTypeTOTP}
+
+// reservedSecretTypeNames 与前端菜单路径冲突的名称，不能用作自定义类型
+var reservedSecretTypeNames = []string{"dashboard", "users", "policy", "audit", "access_requests", "notifications", "custom"}
+
+// maxSecretTypeFields 自定义类型的最大字段数量
+const maxSecretTypeFields = 100
+
+var (
+	secretTypeNamePattern      = regexp.MustCompile(`^[a-z][a-z0-9_]{1,49}$`)
+	secretTypeFieldNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+)
+
+var (
+	// ErrSecretTypeExists 信息项类型名称已存在
+	ErrSecretTypeExists = errors.New("信息项类型已存在")
+	// ErrSecretTypeInUse 信息项类型仍有信息项使用
+	ErrSecretTypeInUse = errors.New("信息项类型仍有信息项使用，无法删除")
+)
+
+// SecretType 管理员在运行时定义的信息项类型，信息项的数据按类型的字段定义校验
+type SecretType struct {
+	ModelBase
+	Name        string           `json:"name" gorm:"type:varchar(50);uniqueIndex;not null"` // 类型标识，即信息项的type字段，创建后不能修改
+	Title       string           `json:"title" gorm:"not null"`                             // 显示名称，用于菜单
+	Icon        string           `json:"icon"`                                              // 菜单图标
+	Description string           `json:"description"`                                       // 描述
+	Schema      SecretTypeSchema `json:"schema" gorm:"type:text;serializer:json"`           // 字段定义
+	CreatedByID string           `json:"-" gorm:"index"`                                    // 创建者ID
+	UpdatedByID string           `json:"-" gorm:"index"`                                    // 更新者ID
+
+	Creator *User `json:"creator,omitempty" gorm:"foreignKey:CreatedByID;references:ID"`
+	Updater *User `json:"updater,omitempty" gorm:"foreignKey:UpdatedByID;references:ID"`
+}
+
+// SecretTypeSchema 自定义类型的字段定义，字段按定义顺序展示
+type SecretTypeSchema struct {
+	Fields []SecretTypeField `json:"fields"`
+}
+
+// SecretTypeField 自定义类型的单个字段，字段值均为字符串
+type SecretTypeField struct {
+	Name        string   `json:"name"`                  // 字段名，只能包含小写字母、数字和下划线
+	Title       string   `json:"title,omitempty"`       // 显示名称
+	Description string   `json:"description,omitempty"` // 描述
+	Secret      bool     `json:"secret"`                // 敏感字段加密保存在 data.fields；非敏感字段作为元数据明文保存在 metadata.fields，可在列表中查看和搜索
+	Required    bool     `json:"required,omitempty"`    // 是否必填
+	Pattern     string   `json:"pattern,omitempty"`     // 字段值需要匹配的正则表达式（RE2语法），需要完整匹配时使用 ^ 和 $
+	Enum        []string `json:"enum,omitempty"`        // 字段值只能为其中之一
+}
+
+// SecretTypeFieldErrors 信息项数据不符合自定义类型字段定义时的错误，按字段名记录错误信息
+type SecretTypeFieldErrors map[string][]string
+
+func (e SecretTypeFieldErrors) Error() string {
+	fields := make([]string, 0, len(e))
+	for field := range e {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	messages := make([]string, 0, len(fields))
+	for _, field := range fields {
+		messages = append(messages, strings.Join(e[field], "; "))
+	}
+	return strings.Join(messages, "; ")
+}
+
+// BeforeCreate 钩子函数，在创建记录之前设置ID
+func (st *SecretType) BeforeCreate(tx *gorm.DB) (err error) {
+	st.ID = uuid.New().String()
+	return
+}
+
+// IsBuiltinSecretType 检查是否为内置信息项类型
+func IsBuiltinSecretType(name string) bool {
+	return slices.Contains(BuiltinSecretTypes, name)
+}
+
+// IsCustomSecretType 检查是否为已定义的自定义信息项类型
+func IsCustomSecretType(name string) bool {
+	if name == "" || IsBuiltinSecretType(name) {
+		return false
+	}
+	var count int64
+	if err := DB.Model(&SecretType{}).Where("name = ?", name).Count(&count).Error; err != nil {
+		return false
+	}
+	return count > 0
+}
+
+// IsSecretType 检查是否为内置或已定义的自定义信息项类型
+func IsSecretType(name string) bool {
+	return IsBuiltinSecretType(name) || IsCustomSecretType(name)
+}
+
+// ValidateSecretTypeName 校验自定义类型名称，不能与内置类型和菜单路径冲突
+func ValidateSecretTypeName(name string) error {
+	if !secretTypeNamePattern.MatchString(name) {
+		return errors.New("类型名称必须以小写字母开头，只能包含小写字母、数字和下划线，长度为2-50")
+	}
+	if IsBuiltinSecretType(name) || slices.Contains(reservedSecretTypeNames, name) {
+		return fmt.Errorf("类型名称 '%s' 为保留名称", name)
+	}
+	return nil
+}
+
+// Validate 校验字段定义：字段名唯一且格式正确，正则表达式可以编译，枚举值不重复
+func (s *SecretTypeSchema) Validate() error {
+	if len(s.Fields) == 0 {
+		return errors.New("至少需要定义一个字段")
+	}
+	if len(s.Fields) > maxSecretTypeFields {
+		return fmt.Errorf("字段数量不能超过%d个", maxSecretTypeFields)
+	}
+
+	names := make(map[string]bool, len(s.Fields))
+	for _, field := range s.Fields {
+		if !secretTypeFieldNamePattern.MatchString(field.Name) {
+			return fmt.Errorf("字段名 '%s' 无效，必须以小写字母开头，只能包含小写字母、数字和下划线", field.Name)
+		}
+		if names[field.Name] {
+			return fmt.Errorf("字段名 '%s' 重复", field.Name)
+		}
+		names[field.Name] = true
+
+		if field.Pattern != "" {
+			if _, err := regexp.Compile(field.Pattern); err != nil {
+				return fmt.Errorf("字段 '%s' 的正则表达式无效: %w", field.Name, err)
+			}
+		}
+		enum := make(map[string]bool, len(field.Enum))
+		for _, value := range field.Enum {
+			if value == "" || enum[value] {
+				return fmt.Errorf("字段 '%s' 的枚举值不能为空或重复", field.Name)
+			}
+			enum[value] = true
+		}
+	}
+	return nil
+}
+
+// ValidateUpdate 校验对已有字段定义的修改：已有字段不能切换是否为敏感字段，
+// 否则已保存的敏感字段会作为元数据明文返回，或已有的元数据字段被当作敏感数据
+func (s *SecretTypeSchema) ValidateUpdate(previous SecretTypeSchema) error {
+	secret := make(map[string]bool, len(previous.Fields))
+	for _, field := range previous.Fields {
+		secret[field.Name] = field.Secret
+	}
+	for _, field := range s.Fields {
+		if was, ok := secret[field.Name]; ok && was != field.Secret {
+			return fmt.Errorf("字段 '%s' 创建后不能修改是否为敏感字段", field.Name)
+		}
+	}
+	return nil
+}
+
+// CheckFields 按字段定义校验字段值，并拆分为敏感字段和元数据字段；未定义的字段和空值被拒绝或忽略
+func (s *SecretTypeSchema) CheckFields(values map[string]string) (secret, metadata map[string]string, err error) {
+	fieldErrors := make(SecretTypeFieldErrors)
+	for name := range values {
+		if !slices.ContainsFunc(s.Fields, func(field SecretTypeField) bool { return field.Name == name }) {
+			fieldErrors[name] = append(fieldErrors[name], fmt.Sprintf("%s不是该类型定义的字段", name))
+		}
+	}
+
+	secret = make(map[string]string)
+	metadata = make(map[string]string)
+	for _, field := range s.Fields {
+		value := values[field.Name]
+		if value == "" {
+			if field.Required {
+				fieldErrors[field.Name] = append(fieldErrors[field.Name], fmt.Sprintf("%s是必填字段", field.Name))
+			}
+			continue
+		}
+		if len(field.Enum) > 0 && !slices.Contains(field.Enum, value) {
+			fieldErrors[field.Name] = append(fieldErrors[field.Name],
+				fmt.Sprintf("%s必须是以下值之一: %s", field.Name, strings.Join(field.Enum, ", ")))
+			continue
+		}
+		if field.Pattern != "" {
+			pattern, err := regexp.Compile(field.Pattern)
+			if err != nil || !pattern.MatchString(value) {
+				fieldErrors[field.Name] = append(fieldErrors[field.Name], fmt.Sprintf("%s格式不正确", field.Name))
+				continue
+			}
+		}
+
+		if field.Secret {
+			secret[field.Name] = value
+		} else {
+			metadata[field.Name] = value
+		}
+	}
+
+	if len(fieldErrors) > 0 {
+		return nil, nil, fieldErrors
+	}
+	return secret, metadata, nil
+}
+
+// GetSecretType 根据名称获取自定义信息项类型
+func GetSecretType(name string) (*SecretType, error) {
+	var secretType SecretType
+	if err := DB.Where("name = ?", name).First(&secretType).Error; err != nil {
+		return nil, err
+	}
+	return &secretType, nil
+}
+
+// ListSecretTypes 获取所有自定义信息项类型
+func ListSecretTypes() ([]SecretType, error) {
+	var secretTypes []SecretType
+	if err := DB.Order("created_at").Find(&secretTypes).Error; err != nil {
+		return nil, err
+	}
+	return secretTypes, nil
+}
+
+// CreateSecretType 校验并创建自定义信息项类型
+func CreateSecretType(secretType *SecretType) error {
+	if err := ValidateSecretTypeName(secretType.Name); err != nil {
+		return err
+	}
+	if err := secretType.Schema.Validate(); err != nil {
+		return err
+	}
+
+	var count int64
+	if err := DB.Model(&SecretType{}).Where("name = ?", secretType.Name).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrSecretTypeExists
+	}
+	return DB.Create(secretType).Error
+}
+
+// Delete 删除自定义信息项类型，仍有信息项（包括回收站中的信息项）使用该类型时拒绝删除
+func (st *SecretType) Delete() error {
+	var count int64
+	if err := DB.Unscoped().Model(&SecretItem{}).Where("type = ?", st.Name).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrSecretTypeInUse
+	}
+	return DB.Delete(st).Error
+}
+
+// applySecretTypeSchema 按自定义类型的字段定义校验 data.fields，非敏感字段移入元数据明文保存
+func (si *SecretItem) applySecretTypeSchema() error {
+	secretType, err := GetSecretType(si.Type)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("未知的信息项类型: %s", si.Type)
+	}
+	if err != nil {
+		return err
+	}
+
+	secret, metadata, err := secretType.Schema.CheckFields(si.Data.Fields)
+	if err != nil {
+		return err
+	}
+	si.Data.Fields = secret
+	si.Metadata.Fields = metadata
+	return nil
+}
@@ -0,0 +1,30 @@
+package models
+
+import "testing"
+
+func TestSecretTypeSchemaValidateUpdate(t *testing.T) {
+	previous := SecretTypeSchema{Fields: []SecretTypeField{
+		{Name: "host"},
+		{Name: "password", Secret: true},
+	}}
+
+	tests := []struct {
+		name    string
+		fields  []SecretTypeField
+		wantErr bool
+	}{
+		{"修改显示信息", []SecretTypeField{{Name: "host", Title: "主机", Required: true}, {Name: "password", Secret: true}}, false},
+		{"新增敏感字段", []SecretTypeField{{Name: "host"}, {Name: "password", Secret: true}, {Name: "token", Secret: true}}, false},
+		{"删除字段", []SecretTypeField{{Name: "host"}}, false},
+		{"敏感字段改为元数据", []SecretTypeField{{Name: "host"}, {Name: "password"}}, true},
+		{"元数据改为敏感字段", []SecretTypeField{{Name: "host", Secret: true}, {Name: "password", Secret: true}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema := SecretTypeSchema{Fields: tt.fields}
+			if err := schema.ValidateUpdate(previous); (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateUpdate() error = %v，期望错误 %v", err, tt.wantErr)
+			}
+		})
+	}
+}
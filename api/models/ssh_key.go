@@ -0,0 +1,197 @@
+package models
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SecretTypeSSHKey SSH密钥类型信息项
+const SecretTypeSSHKey = "ssh_key"
+
+// SSH密钥算法
+const (
+	SSHKeyAlgorithmEd25519 = "ed25519"
+	SSHKeyAlgorithmRSA     = "rsa"
+	SSHKeyAlgorithmECDSA   = "ecdsa"
+)
+
+// SSHKeyMetadata SSH密钥的非敏感元数据，保存时从公钥或私钥解析
+type SSHKeyMetadata struct {
+	KeyType           string `json:"key_type"`           // 密钥类型，如 ssh-ed25519、ssh-rsa
+	Bits              int    `json:"bits,omitempty"`     // RSA和ECDSA密钥长度
+	FingerprintSHA256 string `json:"fingerprint_sha256"` // SHA256指纹，格式与 ssh-keygen -l 一致
+	AuthorizedKey     string `json:"authorized_key"`     // authorized_keys格式的公钥
+	HasPrivateKey     bool   `json:"has_private_key"`    // 是否保存了私钥
+	Encrypted         bool   `json:"encrypted"`          // 私钥是否使用passphrase加密
+}
+
+// SSHKeyPair 服务端生成的SSH密钥对
+type SSHKeyPair struct {
+	PrivateKey        string `json:"private_key"`        // OpenSSH格式的私钥
+	PublicKey         string `json:"public_key"`         // authorized_keys格式的公钥
+	FingerprintSHA256 string `json:"fingerprint_sha256"` // SHA256指纹
+}
+
+// parseSSHKeyData 解析SSH公钥和私钥，同时提供时校验两者是否匹配；只提供私钥时补全公钥
+func parseSSHKeyData(data *SecretItemData) (*SSHKeyMetadata, error) {
+	if strings.TrimSpace(data.PrivateKey) == "" && strings.TrimSpace(data.PublicKey) == "" {
+		return nil, errors.New("SSH密钥类型必须提供公钥或私钥")
+	}
+
+	var (
+		publicKey ssh.PublicKey
+		comment   string
+		metadata  SSHKeyMetadata
+	)
+
+	if strings.TrimSpace(data.PublicKey) != "" {
+		var err error
+		publicKey, comment, _, _, err = ssh.ParseAuthorizedKey([]byte(data.PublicKey))
+		if err != nil {
+			return nil, fmt.Errorf("公钥不是有效的authorized_keys格式: %w", err)
+		}
+	}
+
+	if strings.TrimSpace(data.PrivateKey) != "" {
+		signer, encrypted, err := parseSSHPrivateKey(data.PrivateKey, data.Passphrase)
+		if err != nil {
+			return nil, err
+		}
+		if publicKey != nil && !bytes.Equal(publicKey.Marshal(), signer.PublicKey().Marshal()) {
+			return nil, errors.New("公钥与私钥不匹配")
+		}
+		publicKey = signer.PublicKey()
+		metadata.HasPrivateKey = true
+		metadata.Encrypted = encrypted
+	}
+
+	metadata.KeyType = publicKey.Type()
+	metadata.Bits = sshKeyBits(publicKey)
+	metadata.FingerprintSHA256 = ssh.FingerprintSHA256(publicKey)
+	metadata.AuthorizedKey = formatAuthorizedKey(publicKey, comment)
+
+	if strings.TrimSpace(data.PublicKey) == "" {
+		data.PublicKey = metadata.AuthorizedKey
+	}
+	return &metadata, nil
+}
+
+// parseSSHPrivateKey 解析PEM或OpenSSH格式的私钥，加密的私钥使用passphrase解密
+func parseSSHPrivateKey(privateKey, passphrase string) (ssh.Signer, bool, error) {
+	signer, err := ssh.ParsePrivateKey([]byte(privateKey))
+	var missing *ssh.PassphraseMissingError
+	if !errors.As(err, &missing) {
+		if err != nil {
+			return nil, false, fmt.Errorf("解析私钥失败: %w", err)
+		}
+		return signer, false, nil
+	}
+
+	if passphrase == "" {
+		return nil, true, errors.New("私钥已加密，需要提供passphrase")
+	}
+	signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(privateKey), []byte(passphrase))
+	if err != nil {
+		return nil, true, fmt.Errorf("使用passphrase解密私钥失败: %w", err)
+	}
+	return signer, true, nil
+}
+
+// GenerateSSHKey 生成SSH密钥对，bits为0时使用默认长度（RSA 4096，ECDSA 256）；
+// passphrase不为空时私钥使用passphrase加密
+func GenerateSSHKey(algorithm string, bits int, comment, passphrase string) (*SSHKeyPair, error) {
+	var (
+		privateKey crypto.PrivateKey
+		err        error
+	)
+	switch algorithm {
+	case SSHKeyAlgorithmEd25519:
+		if bits != 0 {
+			return nil, errors.New("ed25519密钥不支持指定长度")
+		}
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	case SSHKeyAlgorithmRSA:
+		if bits == 0 {
+			bits = 4096
+		}
+		if bits != 2048 && bits != 3072 && bits != 4096 {
+			return nil, errors.New("RSA密钥长度只能为2048、3072或4096")
+		}
+		privateKey, err = rsa.GenerateKey(rand.Reader, bits)
+	case SSHKeyAlgorithmECDSA:
+		var curve elliptic.Curve
+		switch bits {
+		case 0, 256:
+			curve = elliptic.P256()
+		case 384:
+			curve = elliptic.P384()
+		case 521:
+			curve = elliptic.P521()
+		default:
+			return nil, errors.New("ECDSA密钥长度只能为256、384或521")
+		}
+		privateKey, err = ecdsa.GenerateKey(curve, rand.Reader)
+	default:
+		return nil, fmt.Errorf("不支持的SSH密钥算法: %s", algorithm)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("生成SSH密钥失败: %w", err)
+	}
+
+	var block *pem.Block
+	if passphrase != "" {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(privateKey, comment, []byte(passphrase))
+	} else {
+		block, err = ssh.MarshalPrivateKey(privateKey, comment)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("序列化SSH私钥失败: %w", err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("生成SSH公钥失败: %w", err)
+	}
+
+	return &SSHKeyPair{
+		PrivateKey:        string(pem.EncodeToMemory(block)),
+		PublicKey:         formatAuthorizedKey(signer.PublicKey(), comment),
+		FingerprintSHA256: ssh.FingerprintSHA256(signer.PublicKey()),
+	}, nil
+}
+
+// formatAuthorizedKey 生成authorized_keys格式的公钥，注释不为空时追加在末尾
+func formatAuthorizedKey(publicKey ssh.PublicKey, comment string) string {
+	authorizedKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey)))
+	if comment = strings.TrimSpace(comment); comment != "" {
+		authorizedKey += " " + comment
+	}
+	return authorizedKey
+}
+
+// sshKeyBits 获取RSA和ECDSA公钥的长度，其他类型返回0
+func sshKeyBits(publicKey ssh.PublicKey) int {
+	cryptoPublicKey, ok := publicKey.(ssh.CryptoPublicKey)
+	if !ok {
+		return 0
+	}
+	switch key := cryptoPublicKey.CryptoPublicKey().(type) {
+	case *rsa.PublicKey:
+		return key.N.BitLen()
+	case *ecdsa.PublicKey:
+		return key.Curve.Params().BitSize
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateSSHKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		algorithm  string
+		bits       int
+		passphrase string
+		wantType   string
+		wantBits   int
+		wantErr    string
+	}{
+		{name: "ed25519", algorithm: SSHKeyAlgorithmEd25519, wantType: "ssh-ed25519"},
+		{name: "RSA 2048", algorithm: SSHKeyAlgorithmRSA, bits: 2048, wantType: "ssh-rsa", wantBits: 2048},
+		{name: "ECDSA默认长度", algorithm: SSHKeyAlgorithmECDSA, wantType: "ecdsa-sha2-nistp256", wantBits: 256},
+		{name: "ECDSA 384", algorithm: SSHKeyAlgorithmECDSA, bits: 384, wantType: "ecdsa-sha2-nistp384", wantBits: 384},
+		{name: "使用passphrase加密私钥", algorithm: SSHKeyAlgorithmEd25519, passphrase: "correct horse", wantType: "ssh-ed25519"},
+		{name: "ed25519不支持指定长度", algorithm: SSHKeyAlgorithmEd25519, bits: 256, wantErr: "不支持指定长度"},
+		{name: "RSA长度过短", algorithm: SSHKeyAlgorithmRSA, bits: 1024, wantErr: "RSA密钥长度"},
+		{name: "ECDSA长度无效", algorithm: SSHKeyAlgorithmECDSA, bits: 300, wantErr: "ECDSA密钥长度"},
+		{name: "不支持的算法", algorithm: "dsa", wantErr: "不支持的SSH密钥算法"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pair, err := GenerateSSHKey(tt.algorithm, tt.bits, "deploy@example.com", tt.passphrase)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("GenerateSSHKey() error = %v，期望包含 %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GenerateSSHKey() error = %v", err)
+			}
+			if !strings.HasSuffix(pair.PublicKey, " deploy@example.com") {
+				t.Fatalf("公钥 = %s，期望以注释结尾", pair.PublicKey)
+			}
+
+			// 生成的私钥和公钥可以作为信息项保存
+			data := &SecretItemData{PrivateKey: pair.PrivateKey, PublicKey: pair.PublicKey, Passphrase: tt.passphrase}
+			metadata, err := parseSSHKeyData(data)
+			if err != nil {
+				t.Fatalf("parseSSHKeyData() error = %v", err)
+			}
+			if metadata.KeyType != tt.wantType || metadata.Bits != tt.wantBits {
+				t.Fatalf("密钥类型 = %s，长度 = %d，期望 %s 和 %d", metadata.KeyType, metadata.Bits, tt.wantType, tt.wantBits)
+			}
+			if metadata.FingerprintSHA256 != pair.FingerprintSHA256 || !strings.HasPrefix(pair.FingerprintSHA256, "SHA256:") {
+				t.Fatalf("指纹 = %s，期望 %s", metadata.FingerprintSHA256, pair.FingerprintSHA256)
+			}
+			if metadata.Encrypted != (tt.passphrase != "") {
+				t.Fatalf("私钥加密 = %v，期望 %v", metadata.Encrypted, tt.passphrase != "")
+			}
+		})
+	}
+}
+
+func TestParseSSHKeyData(t *testing.T) {
+	pair, err := GenerateSSHKey(SSHKeyAlgorithmEd25519, 0, "app@example.com", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := GenerateSSHKey(SSHKeyAlgorithmEd25519, 0, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := GenerateSSHKey(SSHKeyAlgorithmECDSA, 0, "", "secret-passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		data          SecretItemData
+		wantErr       string
+		wantPublicKey string
+		wantPrivate   bool
+	}{
+		{name: "公钥与私钥匹配", data: SecretItemData{PrivateKey: pair.PrivateKey, PublicKey: pair.PublicKey}, wantPublicKey: pair.PublicKey, wantPrivate: true},
+		{name: "只提供私钥时补全公钥", data: SecretItemData{PrivateKey: other.PrivateKey}, wantPublicKey: other.PublicKey, wantPrivate: true},
+		{name: "只提供公钥", data: SecretItemData{PublicKey: pair.PublicKey}, wantPublicKey: pair.PublicKey},
+		{name: "公钥与私钥不匹配", data: SecretItemData{PrivateKey: pair.PrivateKey, PublicKey: other.PublicKey}, wantErr: "公钥与私钥不匹配"},
+		{name: "加密私钥缺少passphrase", data: SecretItemData{PrivateKey: encrypted.PrivateKey}, wantErr: "需要提供passphrase"},
+		{name: "passphrase错误", data: SecretItemData{PrivateKey: encrypted.PrivateKey, Passphrase: "wrong"}, wantErr: "使用passphrase解密私钥失败"},
+		{name: "公钥格式无效", data: SecretItemData{PublicKey: "ssh-ed25519 invalid"}, wantErr: "公钥不是有效的authorized_keys格式"},
+		{name: "私钥格式无效", data: SecretItemData{PrivateKey: "not a key"}, wantErr: "解析私钥失败"},
+		{name: "缺少公钥和私钥", data: SecretItemData{PublicKey: " "}, wantErr: "必须提供公钥或私钥"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata, err := parseSSHKeyData(&tt.data)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseSSHKeyData() error = %v，期望包含 %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSSHKeyData() error = %v", err)
+			}
+			if tt.data.PublicKey != tt.wantPublicKey || metadata.AuthorizedKey != tt.wantPublicKey {
+				t.Fatalf("公钥 = %s，元数据中的公钥 = %s，期望 %s", tt.data.PublicKey, metadata.AuthorizedKey, tt.wantPublicKey)
+			}
+			if metadata.HasPrivateKey != tt.wantPrivate {
+				t.Fatalf("保存私钥 = %v，期望 %v", metadata.HasPrivateKey, tt.wantPrivate)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/akinoccc/hysaif/api/packages/totp"
+)
+
+// SecretTypeTOTP TOTP种子类型信息项，种子只对创建者和可以修改信息项的用户开放，申请人只能获取当前验证码
+const SecretTypeTOTP = "totp"
+
+// TOTPMetadata TOTP种子的非敏感元数据，保存时从 otpauth:// URI 或种子解析
+type TOTPMetadata struct {
+	Issuer      string `json:"issuer,omitempty"`       // 签发方
+	AccountName string `json:"account_name,omitempty"` // 账号名称
+	Algorithm   string `json:"algorithm"`              // HMAC算法
+	Digits      int    `json:"digits"`                 // 验证码位数
+	Period      int    `json:"period"`                 // 验证码有效期（秒）
+}
+
+// parseTOTPData 解析 otpauth:// URI 或base32种子，两者只能提供一个
+func parseTOTPData(data *SecretItemData) (*TOTPMetadata, error) {
+	key, err := parseTOTPKey(data)
+	if err != nil {
+		return nil, err
+	}
+	return &TOTPMetadata{
+		Issuer:      key.Issuer,
+		AccountName: key.AccountName,
+		Algorithm:   key.Algorithm,
+		Digits:      key.Digits,
+		Period:      key.Period,
+	}, nil
+}
+
+func parseTOTPKey(data *SecretItemData) (*totp.Key, error) {
+	uri, seed := strings.TrimSpace(data.OTPAuthURI), strings.TrimSpace(data.Seed)
+	switch {
+	case uri != "" && seed != "":
+		return nil, errors.New("otpauth_uri 和 seed 只能提供一个")
+	case uri != "":
+		return totp.ParseURI(uri)
+	case seed != "":
+		return totp.ParseSeed(seed)
+	default:
+		return nil, errors.New("TOTP类型必须提供 otpauth_uri 或 seed")
+	}
+}
+
+// TOTPKey 获取TOTP信息项的密钥，用于生成当前验证码
+func (si *SecretItem) TOTPKey() (*totp.Key, error) {
+	if si.Type != SecretTypeTOTP {
+		return nil, errors.New("信息项不是TOTP类型")
+	}
+	if si.E2E {
+		return nil, errors.New("端到端加密的TOTP种子由客户端加密，服务端无法生成验证码")
+	}
+	if si.Data == nil {
+		return nil, errors.New("信息项没有敏感数据")
+	}
+	return parseTOTPKey(si.Data)
+}
+
+// CanGetTOTPCode 检查用户能否获取TOTP信息项的当前验证码：可以查看种子的用户，以及持有有效访问申请或文件夹读取权限的用户
+func (u *User) CanGetTOTPCode(item *SecretItem) (bool, error) {
+	if item.CreatedByID == u.ID || u.HasPermission("secret", "update") {
+		return true, nil
+	}
+	return u.HasGrantedAccess(item)
+}
@@ -0,0 +1,237 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/akinoccc/hysaif/api/packages/crypto"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TransitKeyVersionTable 托管密钥版本数据表名
+const TransitKeyVersionTable = "transit_key_versions"
+
+// TransitKeyTypeAES256GCM 托管密钥类型
+const TransitKeyTypeAES256GCM = "aes256-gcm96"
+
+// TransitPermissionResource 托管密钥的Casbin资源名，transit 授权所有密钥，transit:<密钥名> 授权单个密钥
+const TransitPermissionResource = "transit"
+
+var transitKeyNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+var (
+	// ErrTransitKeyExists 托管密钥名称已存在
+	ErrTransitKeyExists = errors.New("托管密钥已存在")
+	// ErrTransitKeyVersionDisabled 密文使用的密钥版本低于最低解密版本或不存在
+	ErrTransitKeyVersionDisabled = errors.New("密文使用的密钥版本不可用于解密")
+)
+
+// TransitKey 托管密钥，应用通过接口使用密钥加解密数据，密钥材料不会离开服务
+type TransitKey struct {
+	ModelBase
+	Name                 string `json:"name" gorm:"type:varchar(64);uniqueIndex;not null"` // 密钥名称
+	Type                 string `json:"type" gorm:"not null"`                              // 密钥类型
+	LatestVersion        int    `json:"latest_version"`                                    // 最新版本，用于加密
+	MinDecryptionVersion int    `json:"min_decryption_version"`                            // 允许解密的最低版本
+	CreatedByID          string `json:"-" gorm:"index"`                                    // 创建者ID
+
+	Creator *User `json:"creator,omitempty" gorm:"foreignKey:CreatedByID;references:ID"`
+}
+
+// TransitKeyVersion 托管密钥的一个版本，密钥材料使用主密钥加密并与数据行绑定
+type TransitKeyVersion struct {
+	ModelBase
+	TransitKeyID string `json:"transit_key_id" gorm:"type:varchar(36);uniqueIndex:idx_transit_key_version;not null"` // 托管密钥ID
+	Version      int    `json:"version" gorm:"uniqueIndex:idx_transit_key_version;not null"`                         // 版本号
+	Data         string `json:"-" gorm:"type:text;not null"`                                                         // 主密钥加密后的密钥材料
+
+	material    []byte // 创建时待加密的密钥材料
+	createdByID string // 创建或轮换密钥的用户，用于记录加密回退的审计日志
+}
+
+// BeforeCreate 钩子函数，在创建记录之前设置ID
+func (k *TransitKey) BeforeCreate(tx *gorm.DB) (err error) {
+	k.ID = uuid.New().String()
+	return
+}
+
+// BeforeCreate 钩子函数，设置ID并使用主密钥加密密钥材料
+func (v *TransitKeyVersion) BeforeCreate(tx *gorm.DB) (err error) {
+	v.ID = uuid.New().String()
+	v.Data, err = crypto.EncryptWith(v.material, v.options())
+	if err != nil {
+		return fmt.Errorf("加密托管密钥失败: %w", err)
+	}
+	return
+}
+
+// options 密钥材料的加密选项，密文与数据行绑定
+func (v *TransitKeyVersion) options() crypto.Options {
+	return crypto.Options{Table: TransitKeyVersionTable, ID: v.ID, Field: SecretDataField, UserID: v.createdByID}
+}
+
+// key 解密密钥材料
+func (v *TransitKeyVersion) key() ([]byte, error) {
+	key, err := crypto.DecryptWith(v.Data, v.options())
+	if err != nil {
+		return nil, fmt.Errorf("解密托管密钥版本 %d 失败: %w", v.Version, err)
+	}
+	return key, nil
+}
+
+// TransitKeyPermissionResource 获取单个托管密钥的Casbin资源名
+func TransitKeyPermissionResource(name string) string {
+	return TransitPermissionResource + ":" + name
+}
+
+// HasTransitKeyPermission 检查用户对托管密钥的权限，transit 资源的权限适用于所有密钥
+func (u *User) HasTransitKeyPermission(name, action string) bool {
+	return u.HasPermission(TransitPermissionResource, action) ||
+		u.HasPermission(TransitKeyPermissionResource(name), action)
+}
+
+// ValidateTransitKeyName 校验托管密钥名称，只允许字母、数字、下划线和连字符
+func ValidateTransitKeyName(name string) error {
+	if !transitKeyNamePattern.MatchString(name) {
+		return fmt.Errorf("托管密钥名称只能包含字母、数字、下划线和连字符，长度不超过64")
+	}
+	return nil
+}
+
+// CreateTransitKey 创建托管密钥及其第一个版本
+func CreateTransitKey(name, createdByID string) (*TransitKey, error) {
+	if err := ValidateTransitKeyName(name); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := DB.Model(&TransitKey{}).Where("name = ?", name).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrTransitKeyExists
+	}
+
+	material, err := crypto.GenerateTransitKey()
+	if err != nil {
+		return nil, err
+	}
+
+	key := &TransitKey{
+		Name:                 name,
+		Type:                 TransitKeyTypeAES256GCM,
+		LatestVersion:        1,
+		MinDecryptionVersion: 1,
+		CreatedByID:          createdByID,
+	}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(key).Error; err != nil {
+			return err
+		}
+		return tx.Create(&TransitKeyVersion{TransitKeyID: key.ID, Version: 1, material: material, createdByID: createdByID}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// GetTransitKey 根据名称获取托管密钥
+func GetTransitKey(name string) (*TransitKey, error) {
+	var key TransitKey
+	if err := DB.Where("name = ?", name).First(&key).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// Rotate 生成新的密钥版本，之后的加密使用新版本，旧版本仍可解密
+func (k *TransitKey) Rotate(rotatedByID string) error {
+	material, err := crypto.GenerateTransitKey()
+	if err != nil {
+		return err
+	}
+
+	version := k.LatestVersion + 1
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		// 仅当版本未被并发轮换时更新
+		result := tx.Model(&TransitKey{}).
+			Where("id = ? AND latest_version = ?", k.ID, k.LatestVersion).
+			Update("latest_version", version)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("托管密钥 %s 正在被并发轮换，请重试", k.Name)
+		}
+		return tx.Create(&TransitKeyVersion{TransitKeyID: k.ID, Version: version, material: material, createdByID: rotatedByID}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	k.LatestVersion = version
+	return nil
+}
+
+// SetMinDecryptionVersion 设置允许解密的最低版本，低于该版本的密文无法再解密
+func (k *TransitKey) SetMinDecryptionVersion(version int) error {
+	if version < 1 || version > k.LatestVersion {
+		return fmt.Errorf("最低解密版本必须在 1 到 %d 之间", k.LatestVersion)
+	}
+	if err := DB.Model(k).Update("min_decryption_version", version).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// Encrypt 使用最新版本加密数据
+func (k *TransitKey) Encrypt(plaintext, associatedData []byte) (string, error) {
+	key, err := k.versionKey(k.LatestVersion)
+	if err != nil {
+		return "", err
+	}
+	return crypto.TransitEncrypt(key, k.LatestVersion, plaintext, associatedData)
+}
+
+// Decrypt 使用密文中记录的版本解密数据
+func (k *TransitKey) Decrypt(ciphertext string, associatedData []byte) ([]byte, error) {
+	version, err := crypto.TransitCiphertextVersion(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	if version < k.MinDecryptionVersion || version > k.LatestVersion {
+		return nil, fmt.Errorf("%w: 版本 %d", ErrTransitKeyVersionDisabled, version)
+	}
+
+	key, err := k.versionKey(version)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.TransitDecrypt(key, ciphertext, associatedData)
+}
+
+// Rewrap 将密文重新加密到最新版本，明文不会返回给调用方
+func (k *TransitKey) Rewrap(ciphertext string, associatedData []byte) (string, error) {
+	plaintext, err := k.Decrypt(ciphertext, associatedData)
+	if err != nil {
+		return "", err
+	}
+	return k.Encrypt(plaintext, associatedData)
+}
+
+// versionKey 获取指定版本的密钥材料
+func (k *TransitKey) versionKey(version int) ([]byte, error) {
+	var keyVersion TransitKeyVersion
+	err := DB.Where("transit_key_id = ? AND version = ?", k.ID, version).First(&keyVersion).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: 版本 %d", ErrTransitKeyVersionDisabled, version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return keyVersion.key()
+}
@@ -55,6 +55,9 @@ type User struct {
 	Position     string `json:"position"`                          // 职位
 	LoginType    string `json:"login_type" gorm:"default:'local'"` // 登录类型：local, wework
 
+	// 端到端加密
+	PublicKey string `json:"public_key" gorm:"type:varchar(64)"` // base64编码的X25519公钥，用于接收端到端加密信息项的内容密钥
+
 	Creator *User `json:"creator" gorm:"foreignKey:CreatedByUserID;references:ID"`
 	Updater *User `json:"updater" gorm:"foreignKey:UpdatedByUserID;references:ID"`
 
@@ -0,0 +1,189 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/akinoccc/hysaif/api/config"
+)
+
+// legacyKeyVersion 旧版 encryption_key 对应的密钥版本，其密文不带版本前缀
+const legacyKeyVersion = 0
+
+// aesProvider 使用配置文件中的本地主密钥环进行AES-GCM包装，包装结果格式为 v<版本>:<base64(nonce+密文)>
+//
+// environment 不为空时使用该环境独立的密钥环
+type aesProvider struct {
+	environment string
+}
+
+// ID 返回提供者ID
+func (p *aesProvider) ID() string {
+	return environmentProviderID(ProviderAES, p.environment)
+}
+
+// WrapKey 使用本地活动主密钥包装数据密钥
+func (p *aesProvider) WrapKey(dataKey []byte) (string, error) {
+	if p.environment != "" {
+		version, key, err := getActiveEnvironmentKey(p.environment)
+		if err != nil {
+			return "", err
+		}
+		return sealVersioned(version, key, dataKey)
+	}
+	return encryptWithAES(dataKey)
+}
+
+// UnwrapKey 使用本地主密钥解包数据密钥
+func (p *aesProvider) UnwrapKey(wrappedKey string) ([]byte, error) {
+	if p.environment != "" {
+		return openVersioned(wrappedKey, func(version int) ([]byte, error) {
+			return getEnvironmentKey(p.environment, version)
+		})
+	}
+	return decryptWithAES(wrappedKey)
+}
+
+// KeyVersion 获取包装数据密钥所用的主密钥版本
+func (p *aesProvider) KeyVersion(wrappedKey string) string {
+	version, _ := splitAESVersion(wrappedKey)
+	return strconv.Itoa(version)
+}
+
+// ActiveKeyVersion 获取当前用于加密的主密钥版本
+func (p *aesProvider) ActiveKeyVersion() (string, error) {
+	getActiveKey := getActiveEncryptionKey
+	if p.environment != "" {
+		getActiveKey = func() (int, []byte, error) { return getActiveEnvironmentKey(p.environment) }
+	}
+
+	version, _, err := getActiveKey()
+	if err != nil {
+		return "", err
+	}
+	return strconv.Itoa(version), nil
+}
+
+// getEncryptionKey 根据版本获取本地主密钥，版本0为旧版 encryption_key；密封模式下对应版本使用解封后的主密钥
+func getEncryptionKey(version int) ([]byte, error) {
+	if seal := config.AppConfig.Security.Seal; seal.Enabled && version == seal.KeyVersion {
+		return unsealedKey()
+	}
+
+	var key string
+	if version == legacyKeyVersion {
+		key = config.AppConfig.Security.EncryptionKey
+	} else {
+		key = findKeyringKey(config.AppConfig.Security.Keyring, version)
+	}
+
+	if key == "" {
+		return nil, fmt.Errorf("本地密钥环中不存在版本为 %d 的密钥", version)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("加密密钥长度必须为32字节，版本: %d，当前长度: %d", version, len(key))
+	}
+	return []byte(key), nil
+}
+
+// findKeyringKey 查找密钥环中指定版本的密钥，不存在时返回空字符串
+func findKeyringKey(keyring config.KeyringConfig, version int) string {
+	for _, k := range keyring.Keys {
+		if k.Version == version {
+			return k.Key
+		}
+	}
+	return ""
+}
+
+// latestKeyringVersion 获取密钥环的活动版本，未配置时为最高版本
+func latestKeyringVersion(keyring config.KeyringConfig) int {
+	version := keyring.ActiveVersion
+	if version == 0 {
+		for _, k := range keyring.Keys {
+			if k.Version > version {
+				version = k.Version
+			}
+		}
+	}
+	return version
+}
+
+// getActiveEncryptionKey 获取活动主密钥：优先使用配置的活动版本，其次为密钥环中的最高版本，最后为旧版密钥
+func getActiveEncryptionKey() (int, []byte, error) {
+	keyring := config.AppConfig.Security.Keyring
+
+	version := keyring.ActiveVersion
+	if version == 0 && config.AppConfig.Security.Seal.Enabled {
+		version = config.AppConfig.Security.Seal.KeyVersion
+	}
+	if version == 0 {
+		version = latestKeyringVersion(keyring)
+	}
+
+	key, err := getEncryptionKey(version)
+	if err != nil {
+		return 0, nil, err
+	}
+	return version, key, nil
+}
+
+// splitAESVersion 拆分密文中的版本前缀，不带前缀的密文为旧版密钥加密
+func splitAESVersion(encryptedData string) (int, string) {
+	if !strings.HasPrefix(encryptedData, "v") {
+		return legacyKeyVersion, encryptedData
+	}
+
+	prefix, body, ok := strings.Cut(encryptedData[1:], ":")
+	if !ok {
+		return legacyKeyVersion, encryptedData
+	}
+
+	version, err := strconv.Atoi(prefix)
+	if err != nil {
+		return legacyKeyVersion, encryptedData
+	}
+	return version, body
+}
+
+// encryptWithAES 使用本地活动主密钥进行AES加密，密文带有密钥版本前缀
+func encryptWithAES(data []byte) (string, error) {
+	version, key, err := getActiveEncryptionKey()
+	if err != nil {
+		return "", err
+	}
+	return sealVersioned(version, key, data)
+}
+
+// decryptWithAES 根据密文中的版本前缀选择本地主密钥进行AES解密
+func decryptWithAES(encryptedData string) ([]byte, error) {
+	return openVersioned(encryptedData, getEncryptionKey)
+}
+
+// sealVersioned 使用指定版本的密钥加密，密文带有密钥版本前缀
+func sealVersioned(version int, key, data []byte) (string, error) {
+	ciphertext, err := sealWithKey(key, data, nil)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("v%d:%s", version, base64.StdEncoding.EncodeToString(ciphertext)), nil
+}
+
+// openVersioned 根据密文中的版本前缀获取密钥并解密
+func openVersioned(encryptedData string, getKey func(version int) ([]byte, error)) ([]byte, error) {
+	version, body := splitAESVersion(encryptedData)
+
+	data, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := getKey(version)
+	if err != nil {
+		return nil, err
+	}
+
+	return openWithKey(key, data, nil)
+}
@@ -0,0 +1,154 @@
+package crypto
+
+import (
+	"fmt"
+	"sync"
+)
+
+const (
+	// batchUnwrapSize 单次批量解包请求包含的最大数据密钥数量
+	batchUnwrapSize = 100
+	// batchConcurrency 无法批量处理时并发请求的最大数量
+	batchConcurrency = 8
+)
+
+// BatchKeyProvider 可选接口，支持在一次请求中解包多个数据密钥的提供者实现该接口
+type BatchKeyProvider interface {
+	// UnwrapKeys 批量解包数据密钥，contexts 与 wrappedKeys 一一对应，未绑定上下文的数据密钥对应nil；
+	// 返回结果与输入一一对应，单条失败时记录在错误列表中，请求本身失败时返回error
+	UnwrapKeys(wrappedKeys []string, contexts [][]byte) ([][]byte, []error, error)
+}
+
+// DecryptBatch 批量解密数据，opts 与密文一一对应，返回结果与输入一一对应
+//
+// 同一提供者包装的数据密钥合并为批量解包请求，不支持批量解包的提供者和旧格式数据限制并发逐条解密，
+// 使列表等批量读取的耗时不随主密钥提供者的往返延迟线性增长
+func DecryptBatch(encryptedData []string, opts []Options) ([][]byte, []error) {
+	results := make([][]byte, len(encryptedData))
+	errs := make([]error, len(encryptedData))
+
+	if IsSealed() {
+		for i := range errs {
+			errs[i] = ErrSealed
+		}
+		return results, errs
+	}
+
+	var (
+		headers  = make([]*envelopeHeader, len(encryptedData))
+		sealed   = make([][]byte, len(encryptedData))
+		aads     = make([][]byte, len(encryptedData))
+		contexts = make([][]byte, len(encryptedData))
+		dataKeys = make([][]byte, len(encryptedData))
+		pending  = make(map[string][]int)
+		legacy   []int
+	)
+
+	for i, data := range encryptedData {
+		if !IsEnvelope(data) {
+			legacy = append(legacy, i)
+			continue
+		}
+
+		header, sealedData, err := parseEnvelope(data)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		if aads[i], err = headerAssociatedData(header, opts[i]); err != nil {
+			errs[i] = err
+			continue
+		}
+		if contexts[i], err = headerKeyContext(header, opts[i]); err != nil {
+			errs[i] = err
+			continue
+		}
+		headers[i], sealed[i] = header, sealedData
+
+		if key, ok := getCachedDataKey(dataKeyCacheKey(header, contexts[i])); ok {
+			dataKeys[i] = key
+			continue
+		}
+		pending[header.Provider] = append(pending[header.Provider], i)
+	}
+
+	for providerID, indexes := range pending {
+		unwrapBatch(providerID, indexes, headers, contexts, opts, dataKeys, errs)
+	}
+
+	for i := range encryptedData {
+		if dataKeys[i] != nil {
+			results[i], errs[i] = openEnvelope(dataKeys[i], sealed[i], aads[i], opts[i])
+		}
+	}
+
+	// 旧格式数据直接由主密钥加密，逐条解密
+	forEachConcurrent(len(legacy), func(j int) {
+		i := legacy[j]
+		results[i], errs[i] = DecryptWith(encryptedData[i], opts[i])
+	})
+
+	return results, errs
+}
+
+// unwrapBatch 解包同一提供者包装的数据密钥：支持批量解包的提供者分批发送请求，其他提供者限制并发逐条解包
+func unwrapBatch(providerID string, indexes []int, headers []*envelopeHeader, contexts [][]byte, opts []Options, dataKeys [][]byte, errs []error) {
+	provider, err := GetProvider(providerID)
+	if err != nil {
+		for _, i := range indexes {
+			errs[i] = err
+		}
+		return
+	}
+
+	batchProvider, ok := provider.(BatchKeyProvider)
+	if !ok {
+		forEachConcurrent(len(indexes), func(j int) {
+			i := indexes[j]
+			dataKeys[i], errs[i] = unwrapDataKey(headers[i], opts[i])
+		})
+		return
+	}
+
+	for start := 0; start < len(indexes); start += batchUnwrapSize {
+		chunk := indexes[start:min(start+batchUnwrapSize, len(indexes))]
+
+		wrappedKeys := make([]string, len(chunk))
+		keyContexts := make([][]byte, len(chunk))
+		for j, i := range chunk {
+			wrappedKeys[j] = headers[i].WrappedKey
+			keyContexts[j] = contexts[i]
+		}
+
+		keys, keyErrs, err := batchProvider.UnwrapKeys(wrappedKeys, keyContexts)
+		for j, i := range chunk {
+			switch {
+			case err != nil:
+				errs[i] = fmt.Errorf("解包数据密钥失败: %w", err)
+			case keyErrs[j] != nil:
+				errs[i] = fmt.Errorf("解包数据密钥失败: %w", keyErrs[j])
+			case len(keys[j]) != dataKeySize:
+				errs[i] = fmt.Errorf("数据密钥长度无效: %d", len(keys[j]))
+			default:
+				dataKeys[i] = keys[j]
+				putCachedDataKey(dataKeyCacheKey(headers[i], contexts[i]), keys[j])
+			}
+		}
+	}
+}
+
+// forEachConcurrent 以不超过 batchConcurrency 的并发数对 [0, n) 执行 fn，所有调用完成后返回
+func forEachConcurrent(n int, fn func(i int)) {
+	sem := make(chan struct{}, batchConcurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(i)
+		}()
+	}
+	wg.Wait()
+}
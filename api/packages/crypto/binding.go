@@ -0,0 +1,53 @@
+package crypto
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/akinoccc/hysaif/api/config"
+)
+
+// associatedDataVersion 附加认证数据格式版本，记录在信封头部
+const associatedDataVersion = 1
+
+// Options 加密选项
+type Options struct {
+	Environment string // 密钥项所属环境，用于选择加密策略
+
+	// 密文所属的数据行，三者共同构成附加认证数据（AAD），密文被复制到其他行或字段时无法解密
+	Table string // 数据表
+	ID    string // 行ID
+	Field string // 字段
+
+	UserID string // 执行写入的用户ID，只用于回退事件的审计日志，不参与绑定
+}
+
+// ErrUnboundCiphertext 已启用 require_bound_ciphertext，按数据行读取时遇到未绑定数据行的旧密文
+var ErrUnboundCiphertext = errors.New("密文未与数据行绑定，已禁止读取未绑定的旧密文")
+
+// requireBound 是否拒绝按数据行读取未绑定的旧密文
+func requireBound(opts Options) bool {
+	return opts.Bound() && config.AppConfig.Security.RequireBoundCiphertext
+}
+
+// Bound 判断是否提供了数据行绑定信息
+func (o Options) Bound() bool {
+	return o.Table != "" && o.ID != ""
+}
+
+// associatedData 构造附加认证数据，未提供绑定信息时返回nil
+func (o Options) associatedData() []byte {
+	if !o.Bound() {
+		return nil
+	}
+	return []byte(fmt.Sprintf("hysaif:v%d:%s:%s:%s", associatedDataVersion, o.Table, o.ID, o.Field))
+}
+
+// IsBound 判断密文是否与数据行绑定，绑定的密文必须提供相同的绑定信息才能解密
+func IsBound(encryptedData string) bool {
+	if !IsEnvelope(encryptedData) {
+		return false
+	}
+	header, _, err := parseEnvelope(encryptedData)
+	return err == nil && header.AAD != 0
+}
@@ -0,0 +1,63 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/akinoccc/hysaif/api/config"
+)
+
+const (
+	// blindIndexDerivationInfo 由本地主密钥派生盲索引密钥时使用的域分隔信息
+	blindIndexDerivationInfo = "hysaif-blind-index:v1"
+	// minBlindIndexKeySize 配置的盲索引密钥最小长度
+	minBlindIndexKeySize = 32
+)
+
+// BlindIndexEnabled 检查是否可以计算盲索引：配置了盲索引密钥，或存在可派生索引密钥的版本0本地主密钥
+func BlindIndexEnabled() bool {
+	security := config.AppConfig.Security
+	if security.BlindIndexKey != "" || security.EncryptionKey != "" {
+		return true
+	}
+	return security.Seal.Enabled && security.Seal.KeyVersion == legacyKeyVersion
+}
+
+// BlindIndex 计算字段值的盲索引，即 HMAC-SHA256(索引密钥, 字段名 + 0x00 + 值)
+//
+// 字段名参与计算，同一个值出现在不同字段时索引不同，无法跨字段关联
+func BlindIndex(field, value string) (string, error) {
+	key, err := blindIndexKey()
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(field))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// blindIndexKey 获取盲索引密钥
+//
+// 未配置时由版本0的本地主密钥派生；版本0不参与主密钥轮换，轮换后已有索引仍然有效
+func blindIndexKey() ([]byte, error) {
+	if key := config.AppConfig.Security.BlindIndexKey; key != "" {
+		if len(key) < minBlindIndexKeySize {
+			return nil, fmt.Errorf("盲索引密钥长度至少为%d字节，当前长度: %d", minBlindIndexKeySize, len(key))
+		}
+		return []byte(key), nil
+	}
+
+	masterKey, err := getEncryptionKey(legacyKeyVersion)
+	if err != nil {
+		return nil, fmt.Errorf("派生盲索引密钥失败: %w", err)
+	}
+
+	mac := hmac.New(sha256.New, masterKey)
+	mac.Write([]byte(blindIndexDerivationInfo))
+	return mac.Sum(nil), nil
+}
@@ -0,0 +1,54 @@
+package crypto
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/akinoccc/hysaif/api/config"
+)
+
+func TestBlindIndex(t *testing.T) {
+	useLocalKey(t)
+
+	derived, err := BlindIndex("password", "secret")
+	if err != nil {
+		t.Fatalf("计算盲索引失败: %v", err)
+	}
+	if len(derived) != 64 {
+		t.Fatalf("盲索引长度为 %d，期望64个十六进制字符", len(derived))
+	}
+
+	tests := []struct {
+		name     string
+		key      string
+		field    string
+		value    string
+		wantSame bool
+	}{
+		{"相同的字段和值", "", "password", "secret", true},
+		{"不同的值", "", "password", "Secret", false},
+		{"不同的字段", "", "username", "secret", false},
+		{"字段和值的边界", "", "passwords", "ecret", false},
+		{"配置的盲索引密钥", strings.Repeat("k", 32), "password", "secret", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.AppConfig.Security.BlindIndexKey = tt.key
+			t.Cleanup(func() { config.AppConfig.Security.BlindIndexKey = "" })
+
+			index, err := BlindIndex(tt.field, tt.value)
+			if err != nil {
+				t.Fatalf("计算盲索引失败: %v", err)
+			}
+			if same := index == derived; same != tt.wantSame {
+				t.Fatalf("盲索引与基准相同 = %v，期望 %v", same, tt.wantSame)
+			}
+		})
+	}
+
+	config.AppConfig.Security.BlindIndexKey = "too-short"
+	defer func() { config.AppConfig.Security.BlindIndexKey = "" }()
+	if _, err := BlindIndex("password", "secret"); err == nil {
+		t.Fatal("盲索引密钥过短时期望失败")
+	}
+}
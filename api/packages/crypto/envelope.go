@@ -0,0 +1,382 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 信封加密数据格式：env:<base64url(头部JSON)>.<base64(nonce+密文)>
+const (
+	envelopePrefix  = "env:"
+	envelopeVersion = 1
+	dataKeySize     = 32
+)
+
+// envelopeHeader 信封头部，记录包装数据密钥的提供者和被包装后的数据密钥
+type envelopeHeader struct {
+	Version    int    `json:"v"`           // 格式版本
+	Provider   string `json:"p"`           // 主密钥提供者ID：aes, vault, file, pkcs11
+	WrappedKey string `json:"k"`           // 被主密钥包装后的数据密钥
+	AAD        int    `json:"a,omitempty"` // 附加认证数据格式版本，0表示未与数据行绑定的旧数据
+	Context    bool   `json:"c,omitempty"` // 包装数据密钥时是否向主密钥提供者传递了绑定上下文
+
+	// Wrapper 最初版本的信封使用的字段名，值为 aes 或 vault，与提供者ID一致；只用于读取
+	Wrapper string `json:"w,omitempty"`
+}
+
+// dataKeyCacheTTL 解包后的数据密钥在内存中的缓存时间
+const (
+	dataKeyCacheTTL  = 5 * time.Minute
+	dataKeyCacheSize = 1024
+)
+
+type cachedDataKey struct {
+	key       []byte
+	expiresAt time.Time
+}
+
+var (
+	dataKeyCache   = make(map[string]cachedDataKey)
+	dataKeyCacheMu sync.Mutex
+)
+
+// IsEnvelope 判断密文是否为信封加密格式
+func IsEnvelope(encryptedData string) bool {
+	return strings.HasPrefix(encryptedData, envelopePrefix)
+}
+
+// encryptEnvelope 生成随机数据密钥加密数据，并使用主密钥包装数据密钥
+func encryptEnvelope(data []byte, opts Options) (string, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", fmt.Errorf("生成数据密钥失败: %w", err)
+	}
+
+	header, err := wrapDataKey(dataKey, opts)
+	if err != nil {
+		return "", err
+	}
+
+	aad := opts.associatedData()
+	if aad != nil {
+		header.AAD = associatedDataVersion
+	}
+
+	sealed, err := sealWithKey(dataKey, data, aad)
+	if err != nil {
+		return "", err
+	}
+
+	return formatEnvelope(header, sealed)
+}
+
+// decryptEnvelope 解析信封头部，解包数据密钥后解密数据；与数据行绑定的密文需要提供相同的绑定信息
+func decryptEnvelope(encryptedData string, opts Options) ([]byte, error) {
+	header, sealed, err := parseEnvelope(encryptedData)
+	if err != nil {
+		return nil, err
+	}
+
+	aad, err := headerAssociatedData(header, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := unwrapDataKey(header, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return openEnvelope(dataKey, sealed, aad, opts)
+}
+
+// openEnvelope 使用解包后的数据密钥解密信封中的密文
+func openEnvelope(dataKey, sealed, aad []byte, opts Options) ([]byte, error) {
+	plaintext, err := openWithKey(dataKey, sealed, aad)
+	if err != nil && aad != nil {
+		return nil, fmt.Errorf("密文与数据行 %s/%s 不匹配: %w", opts.Table, opts.ID, err)
+	}
+	return plaintext, err
+}
+
+// headerAssociatedData 根据信封头部获取解密所需的附加认证数据，旧数据不使用附加认证数据；
+// 启用 require_bound_ciphertext 后按数据行读取时拒绝旧数据，避免其他行的旧密文被复制到该行后仍能解密
+func headerAssociatedData(header *envelopeHeader, opts Options) ([]byte, error) {
+	switch header.AAD {
+	case 0:
+		if requireBound(opts) {
+			return nil, ErrUnboundCiphertext
+		}
+		return nil, nil
+	case associatedDataVersion:
+		if !opts.Bound() {
+			return nil, fmt.Errorf("密文已与数据行绑定，解密时必须提供数据行信息")
+		}
+		return opts.associatedData(), nil
+	default:
+		return nil, fmt.Errorf("不支持的附加认证数据版本: %d", header.AAD)
+	}
+}
+
+// wrapDataKey 按环境的加密策略选择主密钥提供者包装数据密钥，prefer_vault 策略下失败时回退到本地AES并记录；
+// 环境配置了独立主密钥时只使用该环境的主密钥，不会回退到共享主密钥
+func wrapDataKey(dataKey []byte, opts Options) (*envelopeHeader, error) {
+	policy := PolicyFor(opts.Environment)
+	if err := ValidatePolicy(policy); err != nil {
+		return nil, err
+	}
+
+	providerID := targetProviderID(policy, opts.Environment)
+	if isLocalProvider(providerID) && policy == PolicyRequireVault {
+		return nil, fmt.Errorf("%w: 环境 '%s' 要求使用外部主密钥提供者，但当前未配置", ErrBackendUnavailable, opts.Environment)
+	}
+
+	localID := localProviderID(opts.Environment)
+	if providerID != localID {
+		provider, err := GetProvider(providerID)
+		if err == nil {
+			var header *envelopeHeader
+			if header, err = wrapWithProvider(provider, dataKey, opts); err == nil {
+				return header, nil
+			}
+		}
+
+		if policy == PolicyRequireVault {
+			return nil, fmt.Errorf("%w: 主密钥提供者 %s 包装数据密钥失败: %v", ErrBackendUnavailable, providerID, err)
+		}
+
+		recordFallback(FallbackEvent{
+			Provider:    providerID,
+			Environment: opts.Environment,
+			Policy:      policy,
+			Error:       err.Error(),
+			Time:        time.Now(),
+			UserID:      opts.UserID,
+			Table:       opts.Table,
+			ID:          opts.ID,
+		})
+	}
+
+	provider, err := GetProvider(localID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBackendUnavailable, err)
+	}
+	wrapped, err := provider.WrapKey(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("包装数据密钥失败: %w", err)
+	}
+	return &envelopeHeader{Version: envelopeVersion, Provider: localID, WrappedKey: wrapped}, nil
+}
+
+// wrapWithProvider 使用指定提供者包装数据密钥，提供者支持绑定上下文时传入数据行信息
+func wrapWithProvider(provider KeyProvider, dataKey []byte, opts Options) (*envelopeHeader, error) {
+	header := &envelopeHeader{Version: envelopeVersion, Provider: provider.ID()}
+
+	var err error
+	if contextProvider, ok := provider.(ContextKeyProvider); ok && contextProvider.UsesContext() && opts.Bound() {
+		header.WrappedKey, err = contextProvider.WrapKeyWithContext(dataKey, opts.associatedData())
+		header.Context = true
+	} else {
+		header.WrappedKey, err = provider.WrapKey(dataKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// unwrapDataKey 根据头部中的提供者ID解包数据密钥，优先从缓存中读取
+func unwrapDataKey(header *envelopeHeader, opts Options) ([]byte, error) {
+	keyContext, err := headerKeyContext(header, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheKey := dataKeyCacheKey(header, keyContext)
+	if key, ok := getCachedDataKey(cacheKey); ok {
+		return key, nil
+	}
+
+	provider, err := GetProvider(header.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	var dataKey []byte
+	if header.Context {
+		contextProvider, ok := provider.(ContextKeyProvider)
+		if !ok {
+			return nil, fmt.Errorf("主密钥提供者 %s 不支持绑定上下文", header.Provider)
+		}
+		dataKey, err = contextProvider.UnwrapKeyWithContext(header.WrappedKey, keyContext)
+	} else {
+		dataKey, err = provider.UnwrapKey(header.WrappedKey)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解包数据密钥失败: %w", err)
+	}
+
+	if len(dataKey) != dataKeySize {
+		return nil, fmt.Errorf("数据密钥长度无效: %d", len(dataKey))
+	}
+
+	putCachedDataKey(cacheKey, dataKey)
+	return dataKey, nil
+}
+
+// headerKeyContext 获取解包数据密钥时传递给提供者的上下文，包装时未绑定上下文的返回nil
+func headerKeyContext(header *envelopeHeader, opts Options) ([]byte, error) {
+	if !header.Context {
+		return nil, nil
+	}
+	if !opts.Bound() {
+		return nil, fmt.Errorf("数据密钥已与数据行绑定，解包时必须提供数据行信息")
+	}
+	return opts.associatedData(), nil
+}
+
+// dataKeyCacheKey 数据密钥缓存的键，包含提供者、被包装的数据密钥和上下文
+func dataKeyCacheKey(header *envelopeHeader, keyContext []byte) string {
+	return header.Provider + ":" + header.WrappedKey + ":" + string(keyContext)
+}
+
+// formatEnvelope 序列化信封头部和密文
+func formatEnvelope(header *envelopeHeader, sealed []byte) (string, error) {
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", fmt.Errorf("序列化信封头部失败: %w", err)
+	}
+
+	return envelopePrefix +
+		base64.RawURLEncoding.EncodeToString(headerJSON) + "." +
+		base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// parseEnvelope 解析信封格式的密文
+func parseEnvelope(encryptedData string) (*envelopeHeader, []byte, error) {
+	body := strings.TrimPrefix(encryptedData, envelopePrefix)
+	headerPart, sealedPart, ok := strings.Cut(body, ".")
+	if !ok {
+		return nil, nil, fmt.Errorf("无效的信封格式")
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(headerPart)
+	if err != nil {
+		return nil, nil, fmt.Errorf("信封头部解码失败: %w", err)
+	}
+
+	var header envelopeHeader
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, nil, fmt.Errorf("信封头部解析失败: %w", err)
+	}
+
+	if header.Version != envelopeVersion {
+		return nil, nil, fmt.Errorf("不支持的信封版本: %d", header.Version)
+	}
+	if header.Provider == "" {
+		header.Provider, header.Wrapper = header.Wrapper, ""
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(sealedPart)
+	if err != nil {
+		return nil, nil, fmt.Errorf("密文解码失败: %w", err)
+	}
+
+	return &header, sealed, nil
+}
+
+// sealWithKey 使用指定密钥和附加认证数据进行AES-GCM加密，返回 nonce+密文
+func sealWithKey(key, data, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, data, aad), nil
+}
+
+// openWithKey 使用指定密钥和附加认证数据解密 nonce+密文
+func openWithKey(key, data, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	nonceSize := gcm.NonceSize()
+	if len(data) < nonceSize {
+		return nil, fmt.Errorf("加密数据长度不足")
+	}
+
+	nonce, ciphertext := data[:nonceSize], data[nonceSize:]
+	return gcm.Open(nil, nonce, ciphertext, aad)
+}
+
+// clearDataKeyCache 清空数据密钥缓存
+func clearDataKeyCache() {
+	dataKeyCacheMu.Lock()
+	defer dataKeyCacheMu.Unlock()
+	dataKeyCache = make(map[string]cachedDataKey)
+}
+
+// getCachedDataKey 从缓存读取未过期的数据密钥
+func getCachedDataKey(cacheKey string) ([]byte, bool) {
+	dataKeyCacheMu.Lock()
+	defer dataKeyCacheMu.Unlock()
+
+	entry, ok := dataKeyCache[cacheKey]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(dataKeyCache, cacheKey)
+		return nil, false
+	}
+	return entry.key, true
+}
+
+// putCachedDataKey 缓存数据密钥，超过容量时清理过期或任意条目
+func putCachedDataKey(cacheKey string, key []byte) {
+	dataKeyCacheMu.Lock()
+	defer dataKeyCacheMu.Unlock()
+
+	if len(dataKeyCache) >= dataKeyCacheSize {
+		now := time.Now()
+		for k, v := range dataKeyCache {
+			if now.After(v.expiresAt) {
+				delete(dataKeyCache, k)
+			}
+		}
+		// 仍然超过容量时随机淘汰一个条目
+		if len(dataKeyCache) >= dataKeyCacheSize {
+			for k := range dataKeyCache {
+				delete(dataKeyCache, k)
+				break
+			}
+		}
+	}
+
+	dataKeyCache[cacheKey] = cachedDataKey{key: key, expiresAt: time.Now().Add(dataKeyCacheTTL)}
+}
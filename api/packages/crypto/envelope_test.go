@@ -0,0 +1,146 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/akinoccc/hysaif/api/config"
+)
+
+// testKey 测试使用的本地主密钥
+const testKey = "0123456789abcdef0123456789abcdef"
+
+// useLocalKey 使用本地AES主密钥初始化配置
+func useLocalKey(t *testing.T) {
+	t.Helper()
+	config.AppConfig = &config.Config{}
+	config.AppConfig.Security.EncryptionKey = testKey
+	config.AppConfig.Security.KeyProvider = ProviderAES
+}
+
+func TestDecryptLegacyWrapperHeader(t *testing.T) {
+	useLocalKey(t)
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		t.Fatal(err)
+	}
+	wrapped, err := encryptWithAES(dataKey)
+	if err != nil {
+		t.Fatalf("包装数据密钥失败: %v", err)
+	}
+	sealed, err := sealWithKey(dataKey, []byte("legacy"), nil)
+	if err != nil {
+		t.Fatalf("加密失败: %v", err)
+	}
+
+	// 最初版本的信封头部使用 w 记录包装方式
+	header, _ := json.Marshal(map[string]any{"v": envelopeVersion, "w": "aes", "k": wrapped})
+	ciphertext := envelopePrefix + base64.RawURLEncoding.EncodeToString(header) + "." + base64.StdEncoding.EncodeToString(sealed)
+
+	plaintext, err := Decrypt(ciphertext)
+	if err != nil {
+		t.Fatalf("解密旧格式信封失败: %v", err)
+	}
+	if string(plaintext) != "legacy" {
+		t.Fatalf("解密结果为 %q", plaintext)
+	}
+
+	info, err := Inspect(ciphertext)
+	if err != nil || info.Provider != ProviderAES {
+		t.Fatalf("旧格式信封的提供者为 %+v，错误: %v", info, err)
+	}
+}
+
+func TestEnvelopeAssociatedDataMismatch(t *testing.T) {
+	useLocalKey(t)
+
+	bound := Options{Table: "secret_items", ID: "item-a", Field: "data"}
+	ciphertext, err := EncryptWith([]byte("bound"), bound)
+	if err != nil {
+		t.Fatalf("加密失败: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr bool
+	}{
+		{"相同的数据行", bound, false},
+		{"忽略环境", Options{Environment: "production", Table: "secret_items", ID: "item-a", Field: "data"}, false},
+		{"其他行", Options{Table: "secret_items", ID: "item-b", Field: "data"}, true},
+		{"其他字段", Options{Table: "secret_items", ID: "item-a", Field: "password"}, true},
+		{"其他数据表", Options{Table: "secret_item_histories", ID: "item-a", Field: "data"}, true},
+		{"未提供数据行", Options{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plaintext, err := DecryptWith(ciphertext, tt.opts)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("期望解密失败")
+				}
+				return
+			}
+			if err != nil || string(plaintext) != "bound" {
+				t.Fatalf("解密结果 %q，错误: %v", plaintext, err)
+			}
+		})
+	}
+}
+
+func TestRequireBoundCiphertext(t *testing.T) {
+	useLocalKey(t)
+
+	row := Options{Table: "secret_items", ID: "item-a", Field: "data"}
+	legacy, err := EncryptWith([]byte("legacy"), Options{})
+	if err != nil {
+		t.Fatalf("加密失败: %v", err)
+	}
+	legacyAES, err := encryptWithAES([]byte("legacy"))
+	if err != nil {
+		t.Fatalf("加密失败: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		ciphertext string
+		opts       Options
+		require    bool
+		wantErr    error
+	}{
+		{"未启用时读取未绑定信封", legacy, row, false, nil},
+		{"未启用时读取信封之前的格式", legacyAES, row, false, nil},
+		{"启用后按数据行读取未绑定信封", legacy, row, true, ErrUnboundCiphertext},
+		{"启用后按数据行读取信封之前的格式", legacyAES, row, true, ErrUnboundCiphertext},
+		{"启用后不按数据行读取", legacy, Options{}, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.AppConfig.Security.RequireBoundCiphertext = tt.require
+			_, err := DecryptWith(tt.ciphertext, tt.opts)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("解密失败: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("期望错误 %v，实际错误: %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	// 启用后主密钥轮换仍能将未绑定的密文迁移为绑定格式
+	config.AppConfig.Security.RequireBoundCiphertext = true
+	rewrapped, err := Rewrap(legacy, row)
+	if err != nil {
+		t.Fatalf("迁移未绑定的密文失败: %v", err)
+	}
+	if !IsBound(rewrapped) {
+		t.Fatal("迁移后的密文应与数据行绑定")
+	}
+	if plaintext, err := DecryptWith(rewrapped, row); err != nil || string(plaintext) != "legacy" {
+		t.Fatalf("解密迁移后的密文结果 %q，错误: %v", plaintext, err)
+	}
+}
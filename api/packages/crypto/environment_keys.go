@@ -0,0 +1,145 @@
+package crypto
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/akinoccc/hysaif/api/config"
+)
+
+// environmentSeparator 环境独立主密钥的提供者ID分隔符，如 "aes:production"、"vault:production"
+const environmentSeparator = ":"
+
+// ErrEnvironmentKeyUnavailable 当前部署未持有环境独立的主密钥
+var ErrEnvironmentKeyUnavailable = errors.New("当前部署未持有该环境的主密钥")
+
+// environmentProviderID 获取环境独立主密钥的提供者ID，环境为空时返回共享的提供者ID
+func environmentProviderID(providerID, environment string) string {
+	if environment == "" {
+		return providerID
+	}
+	return providerID + environmentSeparator + environment
+}
+
+// environmentKeyConfig 获取环境独立的主密钥配置，ok为false表示该环境使用共享主密钥
+func environmentKeyConfig(environment string) (config.EnvironmentKeyConfig, bool) {
+	if environment == "" {
+		return config.EnvironmentKeyConfig{}, false
+	}
+	environmentKey, ok := config.AppConfig.Security.EnvironmentKeys[environment]
+	return environmentKey, ok
+}
+
+// HasEnvironmentKeys 检查环境是否配置了独立的主密钥
+func HasEnvironmentKeys(environment string) bool {
+	_, ok := environmentKeyConfig(environment)
+	return ok
+}
+
+// ValidateEnvironmentKeys 校验各环境独立的主密钥配置
+func ValidateEnvironmentKeys() error {
+	for environment, environmentKey := range config.AppConfig.Security.EnvironmentKeys {
+		if environment == "" || strings.Contains(environment, environmentSeparator) {
+			return fmt.Errorf("无效的环境名称: '%s'", environment)
+		}
+
+		if environmentKey.VaultKeyName != "" && !IsVaultEnabled() {
+			return fmt.Errorf("环境 '%s' 配置了Transit密钥，但未启用Vault", environment)
+		}
+
+		keyring := environmentKey.Keyring
+		if len(keyring.Keys) == 0 {
+			continue
+		}
+		for _, k := range keyring.Keys {
+			if k.Version <= 0 {
+				return fmt.Errorf("环境 '%s' 的密钥版本必须大于0", environment)
+			}
+			if len(k.Key) != 32 {
+				return fmt.Errorf("环境 '%s' 的密钥长度必须为32字节，版本: %d，当前长度: %d", environment, k.Version, len(k.Key))
+			}
+		}
+		if findKeyringKey(keyring, latestKeyringVersion(keyring)) == "" {
+			return fmt.Errorf("环境 '%s' 的密钥环中不存在活动版本 %d", environment, keyring.ActiveVersion)
+		}
+	}
+	return nil
+}
+
+// environmentProvider 根据提供者ID创建环境独立的主密钥提供者，仅支持本地密钥环和Vault Transit
+func environmentProvider(id string) (KeyProvider, error) {
+	providerID, environment, ok := strings.Cut(id, environmentSeparator)
+	if !ok {
+		return nil, fmt.Errorf("未注册的主密钥提供者: %s", id)
+	}
+
+	environmentKey, ok := environmentKeyConfig(environment)
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("%w: 环境 '%s' 未配置独立主密钥", ErrEnvironmentKeyUnavailable, environment)
+	case providerID == ProviderAES && len(environmentKey.Keyring.Keys) > 0:
+		return &aesProvider{environment: environment}, nil
+	case providerID == ProviderVault && environmentKey.VaultKeyName != "" && IsVaultEnabled():
+		return &vaultProvider{environment: environment}, nil
+	case providerID == ProviderAES || providerID == ProviderVault:
+		return nil, fmt.Errorf("%w: 环境 '%s' 未配置 %s 主密钥", ErrEnvironmentKeyUnavailable, environment, providerID)
+	default:
+		return nil, fmt.Errorf("环境独立主密钥不支持提供者: %s", providerID)
+	}
+}
+
+// environmentTargetProviderID 获取环境独立主密钥对应的提供者ID：
+// 优先使用与共享配置相同的提供者，该环境未配置对应主密钥时使用本地密钥环；
+// 只配置了Transit密钥而共享提供者不是Vault时（如文件KMS、PKCS#11）使用共享提供者；
+// 空配置表示当前部署不持有该环境的主密钥，返回无法创建的环境提供者ID，使写入失败
+func environmentTargetProviderID(providerID, environment string) string {
+	environmentKey, _ := environmentKeyConfig(environment)
+	switch {
+	case providerID == ProviderVault && environmentKey.VaultKeyName != "":
+		return environmentProviderID(ProviderVault, environment)
+	case len(environmentKey.Keyring.Keys) > 0:
+		return environmentProviderID(ProviderAES, environment)
+	case environmentKey.VaultKeyName != "":
+		return providerID
+	default:
+		return environmentProviderID(providerID, environment)
+	}
+}
+
+// localProviderID 获取回退时使用的本地提供者ID，环境配置了独立主密钥时只能回退到该环境的密钥环
+func localProviderID(environment string) string {
+	if HasEnvironmentKeys(environment) {
+		return environmentProviderID(ProviderAES, environment)
+	}
+	return ProviderAES
+}
+
+// isLocalProvider 检查提供者是否为本地密钥环
+func isLocalProvider(providerID string) bool {
+	return providerID == ProviderAES || strings.HasPrefix(providerID, ProviderAES+environmentSeparator)
+}
+
+// getEnvironmentKey 获取环境独立密钥环中指定版本的密钥
+func getEnvironmentKey(environment string, version int) ([]byte, error) {
+	environmentKey, _ := environmentKeyConfig(environment)
+	key := findKeyringKey(environmentKey.Keyring, version)
+	if key == "" {
+		return nil, fmt.Errorf("环境 '%s' 的密钥环中不存在版本为 %d 的密钥", environment, version)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("加密密钥长度必须为32字节，环境: %s，版本: %d，当前长度: %d", environment, version, len(key))
+	}
+	return []byte(key), nil
+}
+
+// getActiveEnvironmentKey 获取环境独立密钥环的活动密钥
+func getActiveEnvironmentKey(environment string) (int, []byte, error) {
+	environmentKey, _ := environmentKeyConfig(environment)
+	version := latestKeyringVersion(environmentKey.Keyring)
+	key, err := getEnvironmentKey(environment, version)
+	if err != nil {
+		return 0, nil, err
+	}
+	return version, key, nil
+}
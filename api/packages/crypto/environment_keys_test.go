@@ -0,0 +1,81 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/akinoccc/hysaif/api/config"
+)
+
+func TestTargetProviderIDWithEnvironmentKeys(t *testing.T) {
+	useLocalKey(t)
+	config.AppConfig.Security.Vault.Enabled = true
+	config.AppConfig.Security.EnvironmentKeys = map[string]config.EnvironmentKeyConfig{
+		"keyring": {Keyring: config.KeyringConfig{ActiveVersion: 1, Keys: []config.KeyringKeyConfig{{Version: 1, Key: testKey}}}},
+		"transit": {VaultKeyName: "hysaif-transit"},
+		"both":    {Keyring: config.KeyringConfig{ActiveVersion: 1, Keys: []config.KeyringKeyConfig{{Version: 1, Key: testKey}}}, VaultKeyName: "hysaif-both"},
+		"empty":   {},
+	}
+
+	tests := []struct {
+		name        string
+		provider    string
+		environment string
+		want        string
+	}{
+		{"未配置环境密钥", ProviderFile, "development", ProviderFile},
+		{"Vault使用环境Transit密钥", ProviderVault, "transit", "vault:transit"},
+		{"Vault优先使用环境Transit密钥", ProviderVault, "both", "vault:both"},
+		{"Vault未配置环境Transit密钥时使用环境密钥环", ProviderVault, "keyring", "aes:keyring"},
+		{"文件KMS使用环境密钥环", ProviderFile, "keyring", "aes:keyring"},
+		{"文件KMS只配置了Transit密钥时使用共享提供者", ProviderFile, "transit", ProviderFile},
+		{"PKCS#11只配置了Transit密钥时使用共享提供者", ProviderPKCS11, "transit", ProviderPKCS11},
+		{"本地AES只配置了Transit密钥时使用共享主密钥", ProviderAES, "transit", ProviderAES},
+		{"空配置不回退到共享主密钥", ProviderFile, "empty", "file:empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.AppConfig.Security.KeyProvider = tt.provider
+			if got := targetProviderID(PolicyPreferVault, tt.environment); got != tt.want {
+				t.Fatalf("targetProviderID() = %s，期望 %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileProviderWithTransitOnlyEnvironment(t *testing.T) {
+	useLocalKey(t)
+
+	keyring, _ := json.Marshal(fileKeyring{
+		ActiveKey: "2024-06",
+		Keys:      map[string]string{"2024-06": base64.StdEncoding.EncodeToString([]byte(testKey))},
+	})
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	if err := os.WriteFile(path, keyring, 0600); err != nil {
+		t.Fatal(err)
+	}
+	config.AppConfig.Security.KeyProvider = ProviderFile
+	config.AppConfig.Security.FileKMS.Path = path
+	config.AppConfig.Security.EncryptionPolicy = PolicyRequireVault
+	config.AppConfig.Security.Vault.Enabled = true
+	config.AppConfig.Security.EnvironmentKeys = map[string]config.EnvironmentKeyConfig{
+		"production": {VaultKeyName: "hysaif-production"},
+	}
+
+	opts := Options{Environment: "production", Table: "secret_items", ID: "item-a", Field: "data"}
+	ciphertext, err := EncryptWith([]byte("secret"), opts)
+	if err != nil {
+		t.Fatalf("require_vault 策略下加密失败: %v", err)
+	}
+	info, err := Inspect(ciphertext)
+	if err != nil || info.Provider != ProviderFile {
+		t.Fatalf("密文的提供者为 %+v，错误: %v", info, err)
+	}
+	plaintext, err := DecryptWith(ciphertext, opts)
+	if err != nil || string(plaintext) != "secret" {
+		t.Fatalf("解密结果为 %q，错误: %v", plaintext, err)
+	}
+}
@@ -0,0 +1,168 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akinoccc/hysaif/api/config"
+)
+
+// fileKeyring 文件密钥环格式
+//
+//	{
+//	  "active_key": "2024-06",
+//	  "keys": {
+//	    "2024-01": "<base64编码的32字节密钥>",
+//	    "2024-06": "<base64编码的32字节密钥>"
+//	  }
+//	}
+type fileKeyring struct {
+	ActiveKey string            `json:"active_key"`
+	Keys      map[string]string `json:"keys"`
+}
+
+// fileProvider 从磁盘上的密钥环文件读取主密钥，包装后的数据密钥格式为 <密钥ID>:<base64(nonce+密文)>
+type fileProvider struct {
+	mu      sync.Mutex
+	path    string
+	modTime time.Time
+	active  string
+	keys    map[string][]byte
+}
+
+// ID 返回提供者ID
+func (p *fileProvider) ID() string {
+	return ProviderFile
+}
+
+// WrapKey 使用密钥环中的活动密钥包装数据密钥
+func (p *fileProvider) WrapKey(dataKey []byte) (string, error) {
+	keyID, key, err := p.activeKey()
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := sealWithKey(key, dataKey, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return keyID + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// UnwrapKey 根据密钥ID选择密钥环中的密钥解包数据密钥
+func (p *fileProvider) UnwrapKey(wrappedKey string) ([]byte, error) {
+	keyID, encoded, ok := strings.Cut(wrappedKey, ":")
+	if !ok {
+		return nil, fmt.Errorf("无效的文件密钥环密文格式")
+	}
+
+	key, err := p.key(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("base64解码失败: %w", err)
+	}
+
+	return openWithKey(key, sealed, nil)
+}
+
+// KeyVersion 获取包装数据密钥所用的密钥ID
+func (p *fileProvider) KeyVersion(wrappedKey string) string {
+	keyID, _, _ := strings.Cut(wrappedKey, ":")
+	return keyID
+}
+
+// ActiveKeyVersion 获取密钥环中的活动密钥ID
+func (p *fileProvider) ActiveKeyVersion() (string, error) {
+	keyID, _, err := p.activeKey()
+	return keyID, err
+}
+
+// activeKey 获取活动密钥
+func (p *fileProvider) activeKey() (string, []byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.load(); err != nil {
+		return "", nil, err
+	}
+
+	key, ok := p.keys[p.active]
+	if !ok {
+		return "", nil, fmt.Errorf("密钥环中不存在活动密钥: %s", p.active)
+	}
+	return p.active, key, nil
+}
+
+// key 根据密钥ID获取密钥
+func (p *fileProvider) key(keyID string) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.load(); err != nil {
+		return nil, err
+	}
+
+	key, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("密钥环中不存在密钥: %s", keyID)
+	}
+	return key, nil
+}
+
+// load 加载密钥环文件，文件路径或修改时间变化时重新加载
+func (p *fileProvider) load() error {
+	path := config.AppConfig.Security.FileKMS.Path
+	if path == "" {
+		return fmt.Errorf("未配置文件密钥环路径")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("读取密钥环文件失败: %w", err)
+	}
+
+	if p.keys != nil && p.path == path && info.ModTime().Equal(p.modTime) {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取密钥环文件失败: %w", err)
+	}
+
+	var keyring fileKeyring
+	if err := json.Unmarshal(data, &keyring); err != nil {
+		return fmt.Errorf("解析密钥环文件失败: %w", err)
+	}
+
+	keys := make(map[string][]byte, len(keyring.Keys))
+	for id, encoded := range keyring.Keys {
+		if strings.Contains(id, ":") {
+			return fmt.Errorf("密钥ID不能包含冒号: %s", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("密钥 %s 解码失败: %w", id, err)
+		}
+		if len(key) != 32 {
+			return fmt.Errorf("密钥 %s 长度必须为32字节，当前长度: %d", id, len(key))
+		}
+		keys[id] = key
+	}
+
+	p.path = path
+	p.modTime = info.ModTime()
+	p.active = keyring.ActiveKey
+	p.keys = keys
+	return nil
+}
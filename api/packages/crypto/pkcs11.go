@@ -0,0 +1,223 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/akinoccc/hysaif/api/config"
+	"github.com/miekg/pkcs11"
+)
+
+const (
+	pkcs11GCMIVSize   = 12
+	pkcs11GCMTagBits  = 128
+	pkcs11MaxKeyMatch = 2
+)
+
+// pkcs11Provider 使用HSM中的AES密钥（CKM_AES_GCM）包装数据密钥，可使用SoftHSM进行测试
+//
+// 包装后的数据密钥格式为 base64(iv+密文)
+type pkcs11Provider struct {
+	mu      sync.Mutex
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+	key     pkcs11.ObjectHandle
+}
+
+// ID 返回提供者ID
+func (p *pkcs11Provider) ID() string {
+	return ProviderPKCS11
+}
+
+// WrapKey 使用HSM中的AES密钥包装数据密钥
+func (p *pkcs11Provider) WrapKey(dataKey []byte) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.open(); err != nil {
+		return "", err
+	}
+
+	iv := make([]byte, pkcs11GCMIVSize)
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return "", err
+	}
+
+	params := pkcs11.NewGCMParams(iv, nil, pkcs11GCMTagBits)
+	defer params.Free()
+
+	mechanism := []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_AES_GCM, params)}
+	if err := p.ctx.EncryptInit(p.session, mechanism, p.key); err != nil {
+		p.handleError(err)
+		return "", fmt.Errorf("PKCS#11加密初始化失败: %w", err)
+	}
+
+	ciphertext, err := p.ctx.Encrypt(p.session, dataKey)
+	if err != nil {
+		p.handleError(err)
+		return "", fmt.Errorf("PKCS#11加密失败: %w", err)
+	}
+
+	// 部分HSM会忽略传入的IV并自行生成，以实际使用的IV为准
+	if actualIV := params.IV(); len(actualIV) == pkcs11GCMIVSize {
+		iv = actualIV
+	}
+
+	return base64.StdEncoding.EncodeToString(append(iv, ciphertext...)), nil
+}
+
+// UnwrapKey 使用HSM中的AES密钥解包数据密钥
+func (p *pkcs11Provider) UnwrapKey(wrappedKey string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(wrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("base64解码失败: %w", err)
+	}
+	if len(data) <= pkcs11GCMIVSize {
+		return nil, fmt.Errorf("加密数据长度不足")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.open(); err != nil {
+		return nil, err
+	}
+
+	params := pkcs11.NewGCMParams(data[:pkcs11GCMIVSize], nil, pkcs11GCMTagBits)
+	defer params.Free()
+
+	mechanism := []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_AES_GCM, params)}
+	if err := p.ctx.DecryptInit(p.session, mechanism, p.key); err != nil {
+		p.handleError(err)
+		return nil, fmt.Errorf("PKCS#11解密初始化失败: %w", err)
+	}
+
+	plaintext, err := p.ctx.Decrypt(p.session, data[pkcs11GCMIVSize:])
+	if err != nil {
+		p.handleError(err)
+		return nil, fmt.Errorf("PKCS#11解密失败: %w", err)
+	}
+
+	return plaintext, nil
+}
+
+// open 加载PKCS#11模块、登录令牌并查找密钥对象，初始化失败时下次调用会重试
+func (p *pkcs11Provider) open() error {
+	if p.ctx != nil {
+		return nil
+	}
+
+	cfg := config.AppConfig.Security.PKCS11
+	if cfg.Library == "" {
+		return fmt.Errorf("未配置PKCS#11动态库路径")
+	}
+
+	ctx := pkcs11.New(cfg.Library)
+	if ctx == nil {
+		return fmt.Errorf("加载PKCS#11动态库失败: %s", cfg.Library)
+	}
+
+	if err := ctx.Initialize(); err != nil && !errors.Is(err, pkcs11.Error(pkcs11.CKR_CRYPTOKI_ALREADY_INITIALIZED)) {
+		ctx.Destroy()
+		return fmt.Errorf("初始化PKCS#11模块失败: %w", err)
+	}
+
+	session, key, err := openPKCS11Key(ctx, cfg)
+	if err != nil {
+		ctx.Finalize()
+		ctx.Destroy()
+		return err
+	}
+
+	p.ctx = ctx
+	p.session = session
+	p.key = key
+	log.Printf("PKCS#11提供者初始化成功，令牌: %s", cfg.TokenLabel)
+	return nil
+}
+
+// openPKCS11Key 打开令牌会话并查找指定标签的AES密钥
+func openPKCS11Key(ctx *pkcs11.Ctx, cfg config.PKCS11Config) (pkcs11.SessionHandle, pkcs11.ObjectHandle, error) {
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return 0, 0, fmt.Errorf("获取PKCS#11插槽失败: %w", err)
+	}
+
+	var (
+		slotID uint
+		found  bool
+	)
+	for _, slot := range slots {
+		info, err := ctx.GetTokenInfo(slot)
+		if err != nil {
+			continue
+		}
+		if info.Label == cfg.TokenLabel {
+			slotID = slot
+			found = true
+			break
+		}
+	}
+	if !found {
+		return 0, 0, fmt.Errorf("未找到标签为 '%s' 的PKCS#11令牌", cfg.TokenLabel)
+	}
+
+	session, err := ctx.OpenSession(slotID, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		return 0, 0, fmt.Errorf("打开PKCS#11会话失败: %w", err)
+	}
+
+	if err := ctx.Login(session, pkcs11.CKU_USER, cfg.PIN); err != nil && !errors.Is(err, pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN)) {
+		ctx.CloseSession(session)
+		return 0, 0, fmt.Errorf("登录PKCS#11令牌失败: %w", err)
+	}
+
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_AES),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, cfg.KeyLabel),
+	}
+	if err := ctx.FindObjectsInit(session, template); err != nil {
+		ctx.CloseSession(session)
+		return 0, 0, fmt.Errorf("查找PKCS#11密钥失败: %w", err)
+	}
+	objects, _, err := ctx.FindObjects(session, pkcs11MaxKeyMatch)
+	ctx.FindObjectsFinal(session)
+	if err != nil {
+		ctx.CloseSession(session)
+		return 0, 0, fmt.Errorf("查找PKCS#11密钥失败: %w", err)
+	}
+
+	switch len(objects) {
+	case 0:
+		ctx.CloseSession(session)
+		return 0, 0, fmt.Errorf("未找到标签为 '%s' 的AES密钥", cfg.KeyLabel)
+	case 1:
+		return session, objects[0], nil
+	default:
+		ctx.CloseSession(session)
+		return 0, 0, fmt.Errorf("存在多个标签为 '%s' 的AES密钥", cfg.KeyLabel)
+	}
+}
+
+// handleError 会话或设备失效时释放资源，下次调用时重新初始化
+func (p *pkcs11Provider) handleError(err error) {
+	var pkcs11Err pkcs11.Error
+	if !errors.As(err, &pkcs11Err) {
+		return
+	}
+
+	switch pkcs11Err {
+	case pkcs11.CKR_SESSION_HANDLE_INVALID, pkcs11.CKR_SESSION_CLOSED,
+		pkcs11.CKR_DEVICE_REMOVED, pkcs11.CKR_TOKEN_NOT_PRESENT, pkcs11.CKR_USER_NOT_LOGGED_IN:
+		p.ctx.CloseSession(p.session)
+		p.ctx.Finalize()
+		p.ctx.Destroy()
+		p.ctx = nil
+	}
+}
@@ -0,0 +1,69 @@
+package crypto
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/akinoccc/hysaif/api/config"
+)
+
+// TestPKCS11ProviderSoftHSM 使用SoftHSM测试PKCS#11提供者，需要先按README初始化令牌和密钥，并设置：
+// SIMS_TEST_PKCS11_LIBRARY（如 /usr/lib/softhsm/libsofthsm2.so）、SIMS_TEST_PKCS11_TOKEN_LABEL、
+// SIMS_TEST_PKCS11_PIN 和 SIMS_TEST_PKCS11_KEY_LABEL
+func TestPKCS11ProviderSoftHSM(t *testing.T) {
+	library := os.Getenv("SIMS_TEST_PKCS11_LIBRARY")
+	if library == "" {
+		t.Skip("未设置 SIMS_TEST_PKCS11_LIBRARY，跳过SoftHSM测试")
+	}
+
+	useLocalKey(t)
+	config.AppConfig.Security.KeyProvider = ProviderPKCS11
+	config.AppConfig.Security.PKCS11 = config.PKCS11Config{
+		Library:    library,
+		TokenLabel: os.Getenv("SIMS_TEST_PKCS11_TOKEN_LABEL"),
+		PIN:        os.Getenv("SIMS_TEST_PKCS11_PIN"),
+		KeyLabel:   os.Getenv("SIMS_TEST_PKCS11_KEY_LABEL"),
+	}
+
+	provider := &pkcs11Provider{}
+	RegisterProvider(provider)
+	t.Cleanup(func() { RegisterProvider(&pkcs11Provider{}) })
+
+	t.Run("包装和解包数据密钥", func(t *testing.T) {
+		dataKey := bytes.Repeat([]byte{0x5a}, dataKeySize)
+		wrapped, err := provider.WrapKey(dataKey)
+		if err != nil {
+			t.Fatalf("包装失败: %v", err)
+		}
+		unwrapped, err := provider.UnwrapKey(wrapped)
+		if err != nil {
+			t.Fatalf("解包失败: %v", err)
+		}
+		if !bytes.Equal(unwrapped, dataKey) {
+			t.Fatal("解包后的数据密钥不一致")
+		}
+
+		tampered := []byte(wrapped)
+		tampered[len(tampered)-2] ^= 1
+		if _, err := provider.UnwrapKey(string(tampered)); err == nil {
+			t.Fatal("篡改后的包装密钥不应解包成功")
+		}
+	})
+
+	t.Run("信封加密使用PKCS#11提供者", func(t *testing.T) {
+		opts := Options{Table: "secret_items", ID: "item-1", Field: "data"}
+		ciphertext, err := EncryptWith([]byte("hsm"), opts)
+		if err != nil {
+			t.Fatalf("加密失败: %v", err)
+		}
+		info, err := Inspect(ciphertext)
+		if err != nil || info.Provider != ProviderPKCS11 {
+			t.Fatalf("信封的提供者为 %+v，错误: %v", info, err)
+		}
+		plaintext, err := DecryptWith(ciphertext, opts)
+		if err != nil || string(plaintext) != "hsm" {
+			t.Fatalf("解密结果 %q，错误: %v", plaintext, err)
+		}
+	})
+}
@@ -0,0 +1,117 @@
+package crypto
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/akinoccc/hysaif/api/config"
+)
+
+// 加密策略
+const (
+	PolicyRequireVault = "require_vault" // 必须使用外部主密钥提供者（Vault、文件KMS或PKCS#11），不可用时拒绝写入
+	PolicyPreferVault  = "prefer_vault"  // 优先使用外部主密钥提供者，不可用时回退到本地AES并记录
+	PolicyLocalOnly    = "local_only"    // 仅使用本地AES主密钥
+)
+
+// ErrBackendUnavailable 加密策略要求的主密钥提供者不可用
+var ErrBackendUnavailable = errors.New("加密策略要求的主密钥提供者不可用")
+
+// FallbackEvent 外部主密钥提供者不可用、回退到本地AES的事件
+type FallbackEvent struct {
+	Provider    string    // 失败的主密钥提供者
+	Environment string    // 密钥项所属环境
+	Policy      string    // 生效的加密策略
+	Error       string    // 失败原因
+	Time        time.Time // 发生时间
+	UserID      string    // 执行写入的用户ID，后台任务为空
+	Table       string    // 写入的数据表
+	ID          string    // 写入的数据行ID
+}
+
+var (
+	fallbackCount    atomic.Uint64
+	fallbackHandlers []func(FallbackEvent)
+	fallbackMu       sync.RWMutex
+)
+
+// OnFallback 注册回退事件处理函数，用于写入审计日志等；处理函数在加密调用中同步执行，不应阻塞
+func OnFallback(handler func(FallbackEvent)) {
+	fallbackMu.Lock()
+	defer fallbackMu.Unlock()
+	fallbackHandlers = append(fallbackHandlers, handler)
+}
+
+// FallbackCount 获取进程启动以来回退到本地AES的次数
+func FallbackCount() uint64 {
+	return fallbackCount.Load()
+}
+
+// PolicyFor 获取环境生效的加密策略：优先使用环境覆盖配置，其次为全局配置，默认为prefer_vault
+func PolicyFor(environment string) string {
+	security := config.AppConfig.Security
+	if policy, ok := security.EnvironmentPolicies[environment]; ok && environment != "" && policy != "" {
+		return policy
+	}
+	if security.EncryptionPolicy != "" {
+		return security.EncryptionPolicy
+	}
+	return PolicyPreferVault
+}
+
+// ValidatePolicy 校验加密策略名称
+func ValidatePolicy(policy string) error {
+	switch policy {
+	case PolicyRequireVault, PolicyPreferVault, PolicyLocalOnly:
+		return nil
+	default:
+		return fmt.Errorf("未知的加密策略: %s", policy)
+	}
+}
+
+// ValidateConfiguredPolicies 校验全局及各环境配置的加密策略
+func ValidateConfiguredPolicies() error {
+	security := config.AppConfig.Security
+	if security.EncryptionPolicy != "" {
+		if err := ValidatePolicy(security.EncryptionPolicy); err != nil {
+			return err
+		}
+	}
+	for environment, policy := range security.EnvironmentPolicies {
+		if err := ValidatePolicy(policy); err != nil {
+			return fmt.Errorf("环境 '%s': %w", environment, err)
+		}
+	}
+	return nil
+}
+
+// targetProviderID 获取环境的加密策略对应的主密钥提供者，环境配置了独立主密钥时使用该环境的提供者
+func targetProviderID(policy, environment string) string {
+	providerID := ActiveProviderID()
+	if policy == PolicyLocalOnly {
+		providerID = ProviderAES
+	}
+	if HasEnvironmentKeys(environment) {
+		return environmentTargetProviderID(providerID, environment)
+	}
+	return providerID
+}
+
+// recordFallback 记录回退事件并通知处理函数
+func recordFallback(event FallbackEvent) {
+	fallbackCount.Add(1)
+	log.Printf("警告: 主密钥提供者 %s 不可用，环境 '%s' 的数据回退到本地AES加密（策略: %s）: %s",
+		event.Provider, event.Environment, event.Policy, event.Error)
+
+	fallbackMu.RLock()
+	handlers := fallbackHandlers
+	fallbackMu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
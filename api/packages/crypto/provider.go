@@ -0,0 +1,101 @@
+package crypto
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/akinoccc/hysaif/api/config"
+)
+
+// 内置主密钥提供者ID
+const (
+	ProviderAES    = "aes"
+	ProviderVault  = "vault"
+	ProviderFile   = "file"
+	ProviderPKCS11 = "pkcs11"
+)
+
+// KeyProvider 主密钥提供者接口，负责包装和解包信封加密中的数据密钥
+type KeyProvider interface {
+	// ID 返回提供者唯一标识，该标识会写入密文头部，用于解密时路由到对应的提供者
+	ID() string
+	// WrapKey 使用主密钥包装数据密钥
+	WrapKey(dataKey []byte) (string, error)
+	// UnwrapKey 使用主密钥解包数据密钥
+	UnwrapKey(wrappedKey string) ([]byte, error)
+}
+
+// KeyVersioner 可选接口，支持主密钥版本化的提供者实现该接口以支持密钥轮换
+type KeyVersioner interface {
+	// KeyVersion 获取包装数据密钥所用的主密钥版本
+	KeyVersion(wrappedKey string) string
+	// ActiveKeyVersion 获取当前用于包装的主密钥版本
+	ActiveKeyVersion() (string, error)
+}
+
+// ContextKeyProvider 可选接口，支持在包装数据密钥时绑定上下文的提供者实现该接口，使包装结果与数据行绑定
+type ContextKeyProvider interface {
+	// UsesContext 是否启用上下文绑定
+	UsesContext() bool
+	// WrapKeyWithContext 使用上下文包装数据密钥
+	WrapKeyWithContext(dataKey, context []byte) (string, error)
+	// UnwrapKeyWithContext 使用相同的上下文解包数据密钥
+	UnwrapKeyWithContext(wrappedKey string, context []byte) ([]byte, error)
+}
+
+var (
+	providers   = make(map[string]KeyProvider)
+	providersMu sync.RWMutex
+)
+
+// RegisterProvider 按ID注册主密钥提供者，相同ID的提供者会被覆盖
+func RegisterProvider(provider KeyProvider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[provider.ID()] = provider
+}
+
+// GetProvider 根据ID获取主密钥提供者，未注册的 "<提供者>:<环境>" 形式的ID按环境独立主密钥配置创建
+func GetProvider(id string) (KeyProvider, error) {
+	providersMu.RLock()
+	provider, ok := providers[id]
+	providersMu.RUnlock()
+
+	if !ok {
+		return environmentProvider(id)
+	}
+	return provider, nil
+}
+
+// ListProviders 获取所有已注册的主密钥提供者ID
+func ListProviders() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	ids := make([]string, 0, len(providers))
+	for id := range providers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// ActiveProviderID 获取当前用于加密的主密钥提供者ID
+func ActiveProviderID() string {
+	if id := config.AppConfig.Security.KeyProvider; id != "" {
+		return id
+	}
+
+	// 向后兼容：未配置提供者时根据Vault开关选择
+	if IsVaultEnabled() {
+		return ProviderVault
+	}
+	return ProviderAES
+}
+
+func init() {
+	RegisterProvider(&aesProvider{})
+	RegisterProvider(&vaultProvider{})
+	RegisterProvider(&fileProvider{})
+	RegisterProvider(&pkcs11Provider{})
+}
@@ -0,0 +1,196 @@
+package crypto
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 密文格式
+const (
+	FormatEnvelope    = "envelope"     // 信封加密
+	FormatLegacyVault = "legacy_vault" // 旧版直接使用Vault加密
+	FormatLegacyAES   = "legacy_aes"   // 旧版直接使用本地主密钥加密
+	FormatVaultKV     = "vault_kv"     // 存储在Vault KV中，数据库只保存引用
+)
+
+const activeVersionCacheTTL = 30 * time.Second
+
+type cachedKeyVersion struct {
+	version   string
+	expiresAt time.Time
+}
+
+var (
+	activeVersionCache = make(map[string]cachedKeyVersion)
+	activeVersionMu    sync.Mutex
+)
+
+// KeyInfo 密文所使用的加密格式、主密钥提供者和密钥版本
+type KeyInfo struct {
+	Format     string `json:"format"`
+	Provider   string `json:"provider"`
+	KeyVersion string `json:"key_version"`
+}
+
+// Inspect 解析密文使用的加密格式和主密钥信息，不进行解密
+func Inspect(encryptedData string) (*KeyInfo, error) {
+	if IsVaultKVRef(encryptedData) {
+		return &KeyInfo{Format: FormatVaultKV, Provider: ProviderVault}, nil
+	}
+
+	if IsEnvelope(encryptedData) {
+		header, _, err := parseEnvelope(encryptedData)
+		if err != nil {
+			return nil, err
+		}
+		return &KeyInfo{
+			Format:     FormatEnvelope,
+			Provider:   header.Provider,
+			KeyVersion: providerKeyVersion(header.Provider, header.WrappedKey),
+		}, nil
+	}
+
+	if strings.HasPrefix(encryptedData, "vault:") {
+		return &KeyInfo{
+			Format:     FormatLegacyVault,
+			Provider:   ProviderVault,
+			KeyVersion: providerKeyVersion(ProviderVault, encryptedData),
+		}, nil
+	}
+
+	return &KeyInfo{
+		Format:     FormatLegacyAES,
+		Provider:   ProviderAES,
+		KeyVersion: providerKeyVersion(ProviderAES, encryptedData),
+	}, nil
+}
+
+// ActiveKeyInfo 获取当前用于加密的主密钥提供者和密钥版本
+func ActiveKeyInfo() (*KeyInfo, error) {
+	providerID := ActiveProviderID()
+	version, _, err := cachedActiveKeyVersion(providerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyInfo{Format: FormatEnvelope, Provider: providerID, KeyVersion: version}, nil
+}
+
+// NeedsRewrap 判断密文是否需要使用环境加密策略对应的活动主密钥重新包装，或需要迁移为与数据行绑定的密文
+func NeedsRewrap(encryptedData string, opts Options) (bool, error) {
+	info, err := Inspect(encryptedData)
+	if err != nil {
+		return false, err
+	}
+
+	// Vault KV中的数据由Vault负责加密，无需重新包装
+	if info.Format == FormatVaultKV {
+		return false, nil
+	}
+
+	if info.Format != FormatEnvelope {
+		return true, nil
+	}
+
+	// 未与数据行绑定的密文需要迁移
+	if opts.Bound() && !IsBound(encryptedData) {
+		return true, nil
+	}
+
+	activeID := targetProviderID(PolicyFor(opts.Environment), opts.Environment)
+	if info.Provider != activeID {
+		return true, nil
+	}
+
+	activeVersion, versioned, err := cachedActiveKeyVersion(activeID)
+	if err != nil {
+		return false, err
+	}
+	if !versioned {
+		return false, nil
+	}
+	return info.KeyVersion != activeVersion, nil
+}
+
+// cachedActiveKeyVersion 获取提供者的活动密钥版本，短时间缓存避免批量处理时频繁请求远端KMS
+func cachedActiveKeyVersion(providerID string) (string, bool, error) {
+	activeVersionMu.Lock()
+	defer activeVersionMu.Unlock()
+
+	if entry, ok := activeVersionCache[providerID]; ok && time.Now().Before(entry.expiresAt) {
+		return entry.version, true, nil
+	}
+
+	provider, err := GetProvider(providerID)
+	if err != nil {
+		return "", false, err
+	}
+
+	versioner, ok := provider.(KeyVersioner)
+	if !ok {
+		return "", false, nil
+	}
+
+	version, err := versioner.ActiveKeyVersion()
+	if err != nil {
+		return "", false, err
+	}
+
+	activeVersionCache[providerID] = cachedKeyVersion{version: version, expiresAt: time.Now().Add(activeVersionCacheTTL)}
+	return version, true, nil
+}
+
+// Rewrap 使用环境加密策略对应的活动主密钥重新包装密文中的数据密钥，数据本身不重新加密；
+// 旧格式密文及未与数据行绑定的密文会使用绑定信息重新加密为信封格式
+func Rewrap(encryptedData string, opts Options) (string, error) {
+	if !IsEnvelope(encryptedData) {
+		plaintext, err := Decrypt(encryptedData)
+		if err != nil {
+			return "", fmt.Errorf("解密旧格式数据失败: %w", err)
+		}
+		return EncryptWith(plaintext, opts)
+	}
+
+	header, sealed, err := parseEnvelope(encryptedData)
+	if err != nil {
+		return "", err
+	}
+
+	// 迁移未绑定数据行的密文，按未绑定方式读取，启用 require_bound_ciphertext 后仍可迁移
+	if header.AAD == 0 && opts.Bound() {
+		plaintext, err := decryptEnvelope(encryptedData, Options{Environment: opts.Environment})
+		if err != nil {
+			return "", fmt.Errorf("解密未绑定数据行的密文失败: %w", err)
+		}
+		return EncryptWith(plaintext, opts)
+	}
+
+	dataKey, err := unwrapDataKey(header, opts)
+	if err != nil {
+		return "", err
+	}
+
+	newHeader, err := wrapDataKey(dataKey, opts)
+	if err != nil {
+		return "", err
+	}
+	newHeader.AAD = header.AAD
+
+	return formatEnvelope(newHeader, sealed)
+}
+
+// providerKeyVersion 获取提供者包装结果中的主密钥版本，提供者不支持版本化时返回空字符串
+func providerKeyVersion(providerID, wrappedKey string) string {
+	provider, err := GetProvider(providerID)
+	if err != nil {
+		return ""
+	}
+
+	versioner, ok := provider.(KeyVersioner)
+	if !ok {
+		return ""
+	}
+	return versioner.KeyVersion(wrappedKey)
+}
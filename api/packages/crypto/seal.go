@@ -0,0 +1,232 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/akinoccc/hysaif/api/config"
+	"github.com/akinoccc/hysaif/api/packages/shamir"
+)
+
+// sealKeyCheckPrefix 计算主密钥校验值时使用的域分隔前缀
+const sealKeyCheckPrefix = "hysaif-seal-check:"
+
+// ErrSealed 系统处于密封状态，无法加解密
+var ErrSealed = errors.New("系统已密封，需要提交解封分片后才能访问敏感数据")
+
+// SealStatus 密封状态
+type SealStatus struct {
+	Enabled   bool `json:"enabled"`   // 是否启用密封模式
+	Sealed    bool `json:"sealed"`    // 是否处于密封状态
+	Shares    int  `json:"shares"`    // 分片总数
+	Threshold int  `json:"threshold"` // 解封所需的分片数
+	Progress  int  `json:"progress"`  // 已提交的有效分片数
+}
+
+var (
+	sealMu         sync.Mutex
+	sealKey        []byte
+	pendingShares  [][]byte
+	unsealHandlers []func()
+)
+
+// SealEnabled 检查是否启用了密封模式
+func SealEnabled() bool {
+	return config.AppConfig.Security.Seal.Enabled
+}
+
+// IsSealed 检查系统是否处于密封状态，未启用密封模式时始终返回false
+func IsSealed() bool {
+	if !SealEnabled() {
+		return false
+	}
+
+	sealMu.Lock()
+	defer sealMu.Unlock()
+	return sealKey == nil
+}
+
+// GetSealStatus 获取密封状态和解封进度
+func GetSealStatus() SealStatus {
+	cfg := config.AppConfig.Security.Seal
+
+	sealMu.Lock()
+	defer sealMu.Unlock()
+
+	return SealStatus{
+		Enabled:   cfg.Enabled,
+		Sealed:    cfg.Enabled && sealKey == nil,
+		Shares:    cfg.Shares,
+		Threshold: cfg.Threshold,
+		Progress:  len(pendingShares),
+	}
+}
+
+// ValidateSealConfig 校验密封模式配置
+func ValidateSealConfig() error {
+	cfg := config.AppConfig.Security.Seal
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Threshold < 2 || cfg.Shares < cfg.Threshold {
+		return fmt.Errorf("分片配置无效：阈值至少为2且不能大于分片总数")
+	}
+	if cfg.KeyCheck == "" {
+		return fmt.Errorf("未配置主密钥校验值，请先执行 operator init 生成分片")
+	}
+	return nil
+}
+
+// OnUnseal 注册解封后执行的回调，如恢复中断的后台任务
+func OnUnseal(handler func()) {
+	sealMu.Lock()
+	defer sealMu.Unlock()
+	unsealHandlers = append(unsealHandlers, handler)
+}
+
+// SubmitUnsealShare 提交一个base64编码的解封分片，分片数达到阈值后恢复主密钥并解封
+func SubmitUnsealShare(encodedShare string) (*SealStatus, error) {
+	if !SealEnabled() {
+		return nil, fmt.Errorf("未启用密封模式")
+	}
+
+	share, err := base64.StdEncoding.DecodeString(encodedShare)
+	if err != nil {
+		return nil, fmt.Errorf("解封分片格式无效: %w", err)
+	}
+	if len(share) != dataKeySize+1 {
+		return nil, fmt.Errorf("解封分片长度无效")
+	}
+
+	cfg := config.AppConfig.Security.Seal
+
+	sealMu.Lock()
+	if sealKey != nil {
+		sealMu.Unlock()
+		status := GetSealStatus()
+		return &status, nil
+	}
+
+	// 同一分片重复提交不计入进度
+	for _, pending := range pendingShares {
+		if shamir.ShareID(pending) == shamir.ShareID(share) {
+			sealMu.Unlock()
+			return nil, fmt.Errorf("该分片已提交")
+		}
+	}
+	pendingShares = append(pendingShares, share)
+
+	if len(pendingShares) < cfg.Threshold {
+		sealMu.Unlock()
+		status := GetSealStatus()
+		return &status, nil
+	}
+
+	// 无论成功与否都清空已提交的分片，失败时需要重新提交
+	key, err := shamir.Combine(pendingShares)
+	pendingShares = nil
+	if err != nil {
+		sealMu.Unlock()
+		return nil, fmt.Errorf("恢复主密钥失败: %w", err)
+	}
+	if !checkSealKey(key, cfg.KeyCheck) {
+		sealMu.Unlock()
+		return nil, fmt.Errorf("解封失败：分片与主密钥校验值不匹配，请重新提交")
+	}
+
+	sealKey = key
+	handlers := unsealHandlers
+	sealMu.Unlock()
+
+	log.Println("系统已解封")
+	for _, handler := range handlers {
+		go handler()
+	}
+
+	status := GetSealStatus()
+	return &status, nil
+}
+
+// ResetUnseal 清空已提交的解封分片
+func ResetUnseal() {
+	sealMu.Lock()
+	defer sealMu.Unlock()
+	pendingShares = nil
+}
+
+// Seal 清除内存中的主密钥和数据密钥缓存，使系统回到密封状态
+func Seal() error {
+	if !SealEnabled() {
+		return fmt.Errorf("未启用密封模式")
+	}
+
+	sealMu.Lock()
+	for i := range sealKey {
+		sealKey[i] = 0
+	}
+	sealKey = nil
+	pendingShares = nil
+	sealMu.Unlock()
+
+	clearDataKeyCache()
+	log.Println("系统已密封")
+	return nil
+}
+
+// GenerateSealShares 生成随机主密钥并拆分为解封分片，返回base64编码的分片和配置所需的校验值
+func GenerateSealShares(shares, threshold int) ([]string, string, error) {
+	key := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, "", fmt.Errorf("生成主密钥失败: %w", err)
+	}
+	return SplitSealKey(key, shares, threshold)
+}
+
+// SplitSealKey 将已有的32字节主密钥拆分为解封分片，用于将明文配置的主密钥迁移到密封模式
+func SplitSealKey(key []byte, shares, threshold int) ([]string, string, error) {
+	if len(key) != dataKeySize {
+		return nil, "", fmt.Errorf("主密钥长度必须为%d字节，当前长度: %d", dataKeySize, len(key))
+	}
+
+	parts, err := shamir.Split(key, shares, threshold)
+	if err != nil {
+		return nil, "", err
+	}
+
+	encoded := make([]string, len(parts))
+	for i, part := range parts {
+		encoded[i] = base64.StdEncoding.EncodeToString(part)
+	}
+	return encoded, sealKeyCheck(key), nil
+}
+
+// unsealedKey 获取解封后的主密钥副本；Seal 会原地清零主密钥，返回副本避免正在进行的加解密使用全零密钥
+func unsealedKey() ([]byte, error) {
+	sealMu.Lock()
+	defer sealMu.Unlock()
+
+	if sealKey == nil {
+		return nil, ErrSealed
+	}
+	return bytes.Clone(sealKey), nil
+}
+
+// sealKeyCheck 计算主密钥校验值
+func sealKeyCheck(key []byte) string {
+	sum := sha256.Sum256(append([]byte(sealKeyCheckPrefix), key...))
+	return hex.EncodeToString(sum[:])
+}
+
+// checkSealKey 校验恢复的主密钥
+func checkSealKey(key []byte, expected string) bool {
+	return subtle.ConstantTimeCompare([]byte(sealKeyCheck(key)), []byte(expected)) == 1
+}
@@ -0,0 +1,82 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// 托管密钥（加密即服务）的密文格式：hysaif:v<版本>:<base64(nonce+密文)>
+const (
+	transitCiphertextPrefix = "hysaif:"
+	transitKeySize          = 32
+)
+
+// ErrInvalidTransitCiphertext 托管密钥密文格式无效
+var ErrInvalidTransitCiphertext = errors.New("无效的托管密钥密文")
+
+// GenerateTransitKey 生成托管密钥的随机密钥材料
+func GenerateTransitKey() ([]byte, error) {
+	key := make([]byte, transitKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("生成托管密钥失败: %w", err)
+	}
+	return key, nil
+}
+
+// TransitEncrypt 使用托管密钥的指定版本进行AES-GCM加密，associatedData 不为空时解密需要提供相同的值
+func TransitEncrypt(key []byte, version int, plaintext, associatedData []byte) (string, error) {
+	sealed, err := sealWithKey(key, plaintext, associatedData)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%sv%d:%s", transitCiphertextPrefix, version, base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+// TransitCiphertextVersion 获取托管密钥密文使用的密钥版本
+func TransitCiphertextVersion(ciphertext string) (int, error) {
+	version, _, err := parseTransitCiphertext(ciphertext)
+	return version, err
+}
+
+// TransitDecrypt 使用托管密钥解密密文，密钥版本需与密文中的版本一致
+func TransitDecrypt(key []byte, ciphertext string, associatedData []byte) ([]byte, error) {
+	_, sealed, err := parseTransitCiphertext(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := openWithKey(key, sealed, associatedData)
+	if err != nil {
+		// 密文被篡改或附加认证数据不匹配
+		return nil, fmt.Errorf("%w: 密文或附加认证数据无效", ErrInvalidTransitCiphertext)
+	}
+	return plaintext, nil
+}
+
+// parseTransitCiphertext 解析托管密钥密文中的版本和 nonce+密文
+func parseTransitCiphertext(ciphertext string) (int, []byte, error) {
+	body, ok := strings.CutPrefix(ciphertext, transitCiphertextPrefix+"v")
+	if !ok {
+		return 0, nil, ErrInvalidTransitCiphertext
+	}
+
+	versionPart, sealedPart, ok := strings.Cut(body, ":")
+	if !ok {
+		return 0, nil, ErrInvalidTransitCiphertext
+	}
+
+	version, err := strconv.Atoi(versionPart)
+	if err != nil || version <= 0 {
+		return 0, nil, ErrInvalidTransitCiphertext
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(sealedPart)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: %v", ErrInvalidTransitCiphertext, err)
+	}
+	return version, sealed, nil
+}
@@ -0,0 +1,86 @@
+package crypto
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTransitCiphertextVersion(t *testing.T) {
+	tests := []struct {
+		ciphertext string
+		want       int
+		wantErr    bool
+	}{
+		{ciphertext: "hysaif:v1:AAAA", want: 1},
+		{ciphertext: "hysaif:v12:AAAA", want: 12},
+		{ciphertext: "hysaif:v0:AAAA", wantErr: true},
+		{ciphertext: "hysaif:v-1:AAAA", wantErr: true},
+		{ciphertext: "hysaif:vx:AAAA", wantErr: true},
+		{ciphertext: "hysaif:v1", wantErr: true},
+		{ciphertext: "hysaif:v1:not-base64!", wantErr: true},
+		{ciphertext: "vault:v1:AAAA", wantErr: true},
+		{ciphertext: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ciphertext, func(t *testing.T) {
+			version, err := TransitCiphertextVersion(tt.ciphertext)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidTransitCiphertext) {
+					t.Fatalf("期望 ErrInvalidTransitCiphertext，实际错误: %v", err)
+				}
+				return
+			}
+			if err != nil || version != tt.want {
+				t.Fatalf("版本为 %d，期望 %d，错误: %v", version, tt.want, err)
+			}
+		})
+	}
+}
+
+func TestTransitDecrypt(t *testing.T) {
+	key, err := GenerateTransitKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := GenerateTransitKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := TransitEncrypt(key, 3, []byte("payload"), []byte("tenant-a"))
+	if err != nil {
+		t.Fatalf("加密失败: %v", err)
+	}
+	if version, _ := TransitCiphertextVersion(ciphertext); version != 3 {
+		t.Fatalf("密文中的版本为 %d，期望 3", version)
+	}
+	tampered := []byte(ciphertext)
+	tampered[len(tampered)-3] ^= 1
+
+	tests := []struct {
+		name           string
+		key            []byte
+		ciphertext     string
+		associatedData []byte
+		wantErr        bool
+	}{
+		{"相同的密钥和附加认证数据", key, ciphertext, []byte("tenant-a"), false},
+		{"附加认证数据不匹配", key, ciphertext, []byte("tenant-b"), true},
+		{"缺少附加认证数据", key, ciphertext, nil, true},
+		{"其他版本的密钥", otherKey, ciphertext, []byte("tenant-a"), true},
+		{"密文被篡改", key, string(tampered), []byte("tenant-a"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plaintext, err := TransitDecrypt(tt.key, tt.ciphertext, tt.associatedData)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidTransitCiphertext) {
+					t.Fatalf("期望 ErrInvalidTransitCiphertext，实际错误: %v", err)
+				}
+				return
+			}
+			if err != nil || string(plaintext) != "payload" {
+				t.Fatalf("解密结果 %q，错误: %v", plaintext, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,457 @@
+package crypto
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akinoccc/hysaif/api/config"
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// vaultRetryInterval Vault初始化失败后的重试间隔
+const vaultRetryInterval = 10 * time.Second
+
+var (
+	vaultClient *vaultapi.Client
+	vaultMu     sync.Mutex
+	initErr     error
+	lastInitAt  time.Time
+	// vaultConnecting 正在进行的连接，连接结束时关闭；连接期间不持有 vaultMu，其他请求等待该连接的结果
+	vaultConnecting chan struct{}
+)
+
+// vaultProvider 使用Vault Transit引擎包装数据密钥
+//
+// environment 不为空时使用该环境独立的Transit密钥
+type vaultProvider struct {
+	environment string
+}
+
+// ID 返回提供者ID
+func (p *vaultProvider) ID() string {
+	return environmentProviderID(ProviderVault, p.environment)
+}
+
+// keyName 获取包装数据密钥使用的Transit密钥名称
+func (p *vaultProvider) keyName() string {
+	if p.environment != "" {
+		return config.AppConfig.Security.EnvironmentKeys[p.environment].VaultKeyName
+	}
+	return config.AppConfig.Security.Vault.KeyName
+}
+
+// WrapKey 使用Vault Transit引擎包装数据密钥
+func (p *vaultProvider) WrapKey(dataKey []byte) (string, error) {
+	return encryptWithVault(p.keyName(), dataKey, nil)
+}
+
+// UnwrapKey 使用Vault Transit引擎解包数据密钥
+func (p *vaultProvider) UnwrapKey(wrappedKey string) ([]byte, error) {
+	return decryptWithVault(p.keyName(), wrappedKey, nil)
+}
+
+// UsesContext Transit密钥启用密钥派生时，包装数据密钥需要传递context
+func (p *vaultProvider) UsesContext() bool {
+	return config.AppConfig.Security.Vault.Derived
+}
+
+// WrapKeyWithContext 使用context派生的Transit密钥包装数据密钥
+func (p *vaultProvider) WrapKeyWithContext(dataKey, context []byte) (string, error) {
+	return encryptWithVault(p.keyName(), dataKey, context)
+}
+
+// UnwrapKeyWithContext 使用相同的context解包数据密钥
+func (p *vaultProvider) UnwrapKeyWithContext(wrappedKey string, context []byte) ([]byte, error) {
+	return decryptWithVault(p.keyName(), wrappedKey, context)
+}
+
+// UnwrapKeys 通过一次 transit/decrypt 批量请求解包多个数据密钥
+func (p *vaultProvider) UnwrapKeys(wrappedKeys []string, contexts [][]byte) ([][]byte, []error, error) {
+	return decryptBatchWithVault(p.keyName(), wrappedKeys, contexts)
+}
+
+// KeyVersion 从 vault:v<N>:... 格式的密文中获取Transit密钥版本
+func (p *vaultProvider) KeyVersion(wrappedKey string) string {
+	parts := strings.SplitN(wrappedKey, ":", 3)
+	if len(parts) < 3 {
+		return ""
+	}
+	return strings.TrimPrefix(parts[1], "v")
+}
+
+// ActiveKeyVersion 获取Transit密钥的最新版本
+func (p *vaultProvider) ActiveKeyVersion() (string, error) {
+	info, err := transitKeyInfo(p.keyName())
+	if err != nil {
+		return "", err
+	}
+	return strconv.Itoa(info.LatestVersion), nil
+}
+
+// getVaultClient 获取Vault客户端实例，初始化失败时在重试间隔后重新连接；
+// 登录和连接测试不持有 vaultMu，同一时间只有一个请求发起连接
+func getVaultClient() (*vaultapi.Client, error) {
+	if !config.AppConfig.Security.Vault.Enabled {
+		return nil, fmt.Errorf("未启用Vault")
+	}
+
+	for {
+		vaultMu.Lock()
+		if vaultClient != nil {
+			client := vaultClient
+			vaultMu.Unlock()
+			return client, nil
+		}
+
+		// 距上次失败未超过重试间隔时直接返回上次的错误，避免每次请求都阻塞在连接上
+		if initErr != nil && time.Since(lastInitAt) < vaultRetryInterval {
+			err := initErr
+			vaultMu.Unlock()
+			return nil, err
+		}
+
+		// 其他请求正在连接时等待其结果
+		if connecting := vaultConnecting; connecting != nil {
+			vaultMu.Unlock()
+			<-connecting
+			continue
+		}
+
+		connecting := make(chan struct{})
+		vaultConnecting = connecting
+		lastInitAt = time.Now()
+		vaultMu.Unlock()
+
+		client, secret, err := newVaultClient()
+
+		vaultMu.Lock()
+		vaultConnecting = nil
+		close(connecting)
+		if err != nil {
+			initErr = err
+			vaultMu.Unlock()
+			log.Printf("警告: Vault连接失败，将在 %s 后重试: %v", vaultRetryInterval, err)
+			return nil, err
+		}
+		vaultClient = client
+		initErr = nil
+		vaultMu.Unlock()
+		log.Println("Vault客户端初始化成功")
+
+		// 启动令牌续期
+		if secret != nil {
+			go watchVaultToken(client, secret)
+		}
+		return client, nil
+	}
+}
+
+// newVaultClient 创建Vault客户端、登录并测试连接
+func newVaultClient() (*vaultapi.Client, *vaultapi.Secret, error) {
+	client, err := createVaultClient()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 登录并设置认证令牌
+	secret, err := vaultLogin(client)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Vault认证失败: %w", err)
+	}
+
+	// 测试连接和权限
+	if err := testVaultConnection(client); err != nil {
+		return nil, nil, fmt.Errorf("Vault连接测试失败: %w", err)
+	}
+
+	return client, secret, nil
+}
+
+// createVaultClient 按配置创建未登录的Vault客户端
+func createVaultClient() (*vaultapi.Client, error) {
+	// 创建Vault客户端配置
+	vaultConfig := vaultapi.DefaultConfig()
+	vaultConfig.Address = config.AppConfig.Security.Vault.Address
+
+	// 配置TLS
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: config.AppConfig.Security.Vault.TLSConfig.Insecure,
+	}
+
+	// 设置CA证书
+	if config.AppConfig.Security.Vault.TLSConfig.CACert != "" {
+		vaultConfig.ConfigureTLS(&vaultapi.TLSConfig{
+			CACert: config.AppConfig.Security.Vault.TLSConfig.CACert,
+		})
+	}
+
+	// 设置客户端证书
+	if config.AppConfig.Security.Vault.TLSConfig.ClientCert != "" &&
+		config.AppConfig.Security.Vault.TLSConfig.ClientKey != "" {
+		vaultConfig.ConfigureTLS(&vaultapi.TLSConfig{
+			ClientCert: config.AppConfig.Security.Vault.TLSConfig.ClientCert,
+			ClientKey:  config.AppConfig.Security.Vault.TLSConfig.ClientKey,
+		})
+	}
+
+	// 设置自定义TLS配置
+	if config.AppConfig.Security.Vault.TLSConfig.Insecure {
+		vaultConfig.HttpClient.Transport = &http.Transport{
+			TLSClientConfig: tlsConfig,
+		}
+	}
+
+	// 创建客户端
+	client, err := vaultapi.NewClient(vaultConfig)
+	if err != nil {
+		return nil, fmt.Errorf("创建Vault客户端失败: %w", err)
+	}
+
+	// 设置命名空间（企业版功能）
+	if config.AppConfig.Security.Vault.Namespace != "" {
+		client.SetNamespace(config.AppConfig.Security.Vault.Namespace)
+	}
+
+	return client, nil
+}
+
+// resetVaultClient 丢弃失效的客户端，下次调用时重新连接
+func resetVaultClient(client *vaultapi.Client) {
+	vaultMu.Lock()
+	defer vaultMu.Unlock()
+
+	if vaultClient == client {
+		vaultClient = nil
+		log.Println("Vault客户端已失效，将在下次请求时重新连接")
+	}
+}
+
+// handleVaultError 请求因令牌失效被拒绝时重置客户端
+func handleVaultError(client *vaultapi.Client, err error) {
+	var respErr *vaultapi.ResponseError
+	if errors.As(err, &respErr) && respErr.StatusCode == http.StatusForbidden {
+		resetVaultClient(client)
+	}
+}
+
+// testVaultConnection 测试Vault连接和权限
+func testVaultConnection(client *vaultapi.Client) error {
+	// 检查Health状态
+	resp, err := client.Sys().Health()
+	if err != nil {
+		return fmt.Errorf("无法获取Vault健康状态: %w", err)
+	}
+
+	if !resp.Initialized {
+		return fmt.Errorf("Vault服务器未初始化")
+	}
+
+	if resp.Sealed {
+		return fmt.Errorf("Vault服务器已密封")
+	}
+
+	// 检查Transit引擎是否挂载
+	mounts, err := client.Sys().ListMounts()
+	if err != nil {
+		return fmt.Errorf("无法列出挂载点: %w", err)
+	}
+
+	mountPath := strings.TrimSuffix(config.AppConfig.Security.Vault.MountPath, "/") + "/"
+	if _, exists := mounts[mountPath]; !exists {
+		return fmt.Errorf("Transit引擎未在路径 '%s' 挂载", mountPath)
+	}
+
+	// 验证密钥是否存在，如果不存在则创建；包括各环境独立的Transit密钥
+	keyNames := []string{config.AppConfig.Security.Vault.KeyName}
+	for _, environmentKey := range config.AppConfig.Security.EnvironmentKeys {
+		if environmentKey.VaultKeyName != "" {
+			keyNames = append(keyNames, environmentKey.VaultKeyName)
+		}
+	}
+	for _, keyName := range keyNames {
+		if err := ensureTransitKey(client, keyName); err != nil {
+			return fmt.Errorf("无法确保Transit密钥 %s 存在: %w", keyName, err)
+		}
+	}
+
+	return nil
+}
+
+// ensureTransitKey 确保Transit密钥存在
+func ensureTransitKey(client *vaultapi.Client, keyName string) error {
+	mountPath := config.AppConfig.Security.Vault.MountPath
+
+	// 检查密钥是否存在
+	path := fmt.Sprintf("%s/keys/%s", mountPath, keyName)
+	_, err := client.Logical().Read(path)
+	if err == nil {
+		// 密钥存在
+		return nil
+	}
+
+	// 尝试创建密钥
+	createPath := fmt.Sprintf("%s/keys/%s", mountPath, keyName)
+	data := map[string]interface{}{
+		"type": "aes256-gcm96",
+	}
+	if config.AppConfig.Security.Vault.Derived {
+		data["derived"] = true
+	}
+
+	_, err = client.Logical().Write(createPath, data)
+	if err != nil {
+		return fmt.Errorf("创建Transit密钥失败: %w", err)
+	}
+
+	log.Printf("成功创建Transit密钥: %s", keyName)
+	return nil
+}
+
+// encryptWithVault 使用指定的Transit密钥加密，context不为空时用于派生密钥
+func encryptWithVault(keyName string, data, context []byte) (string, error) {
+	client, err := getVaultClient()
+	if err != nil {
+		return "", err
+	}
+
+	mountPath := config.AppConfig.Security.Vault.MountPath
+
+	// 对数据进行base64编码
+	encodedData := base64.StdEncoding.EncodeToString(data)
+
+	// 构造加密请求
+	path := fmt.Sprintf("%s/encrypt/%s", mountPath, keyName)
+	requestData := map[string]interface{}{
+		"plaintext": encodedData,
+	}
+	if context != nil {
+		requestData["context"] = base64.StdEncoding.EncodeToString(context)
+	}
+
+	// 执行加密
+	resp, err := client.Logical().Write(path, requestData)
+	if err != nil {
+		handleVaultError(client, err)
+		return "", fmt.Errorf("Vault加密请求失败: %w", err)
+	}
+
+	if resp == nil || resp.Data == nil {
+		return "", fmt.Errorf("Vault加密响应为空")
+	}
+
+	ciphertext, ok := resp.Data["ciphertext"].(string)
+	if !ok {
+		return "", fmt.Errorf("无效的Vault加密响应格式")
+	}
+
+	return ciphertext, nil
+}
+
+// decryptWithVault 使用指定的Transit密钥解密，context必须与加密时一致
+func decryptWithVault(keyName, encryptedData string, context []byte) ([]byte, error) {
+	client, err := getVaultClient()
+	if err != nil {
+		return nil, err
+	}
+
+	mountPath := config.AppConfig.Security.Vault.MountPath
+
+	// 构造解密请求
+	path := fmt.Sprintf("%s/decrypt/%s", mountPath, keyName)
+	requestData := map[string]interface{}{
+		"ciphertext": encryptedData,
+	}
+	if context != nil {
+		requestData["context"] = base64.StdEncoding.EncodeToString(context)
+	}
+
+	// 执行解密
+	resp, err := client.Logical().Write(path, requestData)
+	if err != nil {
+		handleVaultError(client, err)
+		return nil, fmt.Errorf("Vault解密请求失败: %w", err)
+	}
+
+	if resp == nil || resp.Data == nil {
+		return nil, fmt.Errorf("Vault解密响应为空")
+	}
+
+	plaintext, ok := resp.Data["plaintext"].(string)
+	if !ok {
+		return nil, fmt.Errorf("无效的Vault解密响应格式")
+	}
+
+	// 解码base64
+	data, err := base64.StdEncoding.DecodeString(plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("base64解码失败: %w", err)
+	}
+
+	return data, nil
+}
+
+// decryptBatchWithVault 使用 batch_input 在一次请求中解密多个密文，contexts 与密文一一对应，未使用context的密文对应nil；
+// 返回结果与输入一一对应，单条失败时记录在错误列表中
+func decryptBatchWithVault(keyName string, ciphertexts []string, contexts [][]byte) ([][]byte, []error, error) {
+	client, err := getVaultClient()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	batchInput := make([]map[string]interface{}, len(ciphertexts))
+	for i, ciphertext := range ciphertexts {
+		batchInput[i] = map[string]interface{}{"ciphertext": ciphertext}
+		if i < len(contexts) && contexts[i] != nil {
+			batchInput[i]["context"] = base64.StdEncoding.EncodeToString(contexts[i])
+		}
+	}
+
+	path := fmt.Sprintf("%s/decrypt/%s", config.AppConfig.Security.Vault.MountPath, keyName)
+	resp, err := client.Logical().Write(path, map[string]interface{}{
+		"batch_input": batchInput,
+	})
+	if err != nil {
+		handleVaultError(client, err)
+		return nil, nil, fmt.Errorf("Vault批量解密请求失败: %w", err)
+	}
+	if resp == nil || resp.Data == nil {
+		return nil, nil, fmt.Errorf("Vault批量解密响应为空")
+	}
+
+	batchResults, ok := resp.Data["batch_results"].([]interface{})
+	if !ok || len(batchResults) != len(ciphertexts) {
+		return nil, nil, fmt.Errorf("无效的Vault批量解密响应格式")
+	}
+
+	results := make([][]byte, len(ciphertexts))
+	errs := make([]error, len(ciphertexts))
+	for i, item := range batchResults {
+		result, ok := item.(map[string]interface{})
+		if !ok {
+			errs[i] = fmt.Errorf("无效的Vault批量解密结果")
+			continue
+		}
+		if message, _ := result["error"].(string); message != "" {
+			errs[i] = fmt.Errorf("Vault解密失败: %s", message)
+			continue
+		}
+		plaintext, ok := result["plaintext"].(string)
+		if !ok {
+			errs[i] = fmt.Errorf("无效的Vault批量解密结果")
+			continue
+		}
+		if results[i], err = base64.StdEncoding.DecodeString(plaintext); err != nil {
+			errs[i] = fmt.Errorf("base64解码失败: %w", err)
+		}
+	}
+
+	return results, errs, nil
+}
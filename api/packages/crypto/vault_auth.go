@@ -0,0 +1,243 @@
+package crypto
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/akinoccc/hysaif/api/config"
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// Vault认证方式
+const (
+	VaultAuthToken      = "token"
+	VaultAuthAppRole    = "approle"
+	VaultAuthKubernetes = "kubernetes"
+	VaultAuthJWT        = "jwt"
+)
+
+const (
+	// defaultKubernetesTokenPath Kubernetes服务账户令牌的默认路径
+	defaultKubernetesTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	// vaultReloginAttempts 令牌过期后重新登录的最大尝试次数
+	vaultReloginAttempts = 5
+)
+
+// vaultAuthMethod 获取配置的认证方式，默认为静态令牌
+func vaultAuthMethod() string {
+	method := strings.ToLower(config.AppConfig.Security.Vault.AuthMethod)
+	if method == "" {
+		return VaultAuthToken
+	}
+	return method
+}
+
+// vaultLogin 按配置的认证方式登录并设置客户端令牌，返回用于续期的认证信息，无法续期时返回nil
+func vaultLogin(client *vaultapi.Client) (*vaultapi.Secret, error) {
+	cfg := config.AppConfig.Security.Vault
+
+	switch method := vaultAuthMethod(); method {
+	case VaultAuthToken:
+		if cfg.Token == "" {
+			return nil, fmt.Errorf("未配置Vault访问令牌")
+		}
+		client.SetToken(cfg.Token)
+		return renewableStaticToken(client)
+	case VaultAuthAppRole:
+		secretID, err := readCredential(cfg.AppRole.SecretID, cfg.AppRole.SecretIDFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取AppRole Secret ID失败: %w", err)
+		}
+		return loginWithAuthMethod(client, authMountPath(cfg.AppRole.MountPath, VaultAuthAppRole), map[string]interface{}{
+			"role_id":   cfg.AppRole.RoleID,
+			"secret_id": secretID,
+		})
+	case VaultAuthKubernetes:
+		tokenPath := cfg.Kubernetes.TokenPath
+		if tokenPath == "" && cfg.Kubernetes.Token == "" {
+			tokenPath = defaultKubernetesTokenPath
+		}
+		jwt, err := readCredential(cfg.Kubernetes.Token, tokenPath)
+		if err != nil {
+			return nil, fmt.Errorf("读取Kubernetes服务账户令牌失败: %w", err)
+		}
+		return loginWithAuthMethod(client, authMountPath(cfg.Kubernetes.MountPath, VaultAuthKubernetes), map[string]interface{}{
+			"role": cfg.Kubernetes.Role,
+			"jwt":  jwt,
+		})
+	case VaultAuthJWT:
+		jwt, err := readCredential(cfg.JWT.Token, cfg.JWT.TokenPath)
+		if err != nil {
+			return nil, fmt.Errorf("读取JWT令牌失败: %w", err)
+		}
+		return loginWithAuthMethod(client, authMountPath(cfg.JWT.MountPath, VaultAuthJWT), map[string]interface{}{
+			"role": cfg.JWT.Role,
+			"jwt":  jwt,
+		})
+	default:
+		return nil, fmt.Errorf("不支持的Vault认证方式: %s", method)
+	}
+}
+
+// loginWithAuthMethod 调用 auth/<挂载路径>/login 登录并设置客户端令牌，client 必须是尚未共享的新客户端
+func loginWithAuthMethod(client *vaultapi.Client, mountPath string, data map[string]interface{}) (*vaultapi.Secret, error) {
+	// 登录请求不能携带旧令牌
+	client.ClearToken()
+
+	secret, err := client.Logical().Write(fmt.Sprintf("auth/%s/login", mountPath), data)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return nil, fmt.Errorf("Vault登录响应中没有令牌")
+	}
+
+	client.SetToken(secret.Auth.ClientToken)
+	log.Printf("Vault登录成功，认证路径: auth/%s，令牌有效期: %ds", mountPath, secret.Auth.LeaseDuration)
+	return secret, nil
+}
+
+// renewableStaticToken 静态令牌可续期时续期一次以获取租约信息，不可续期时返回nil
+func renewableStaticToken(client *vaultapi.Client) (*vaultapi.Secret, error) {
+	lookup, err := client.Auth().Token().LookupSelf()
+	if err != nil {
+		return nil, fmt.Errorf("查询Vault令牌信息失败: %w", err)
+	}
+
+	renewable, _ := lookup.TokenIsRenewable()
+	if !renewable {
+		return nil, nil
+	}
+
+	secret, err := client.Auth().Token().RenewSelf(0)
+	if err != nil {
+		return nil, fmt.Errorf("续期Vault令牌失败: %w", err)
+	}
+	return secret, nil
+}
+
+// watchVaultToken 在后台续期令牌租约，租约无法继续续期时重新登录；重新登录失败则丢弃客户端，下次请求时重新连接
+func watchVaultToken(client *vaultapi.Client, secret *vaultapi.Secret) {
+	for {
+		watcher, err := client.NewLifetimeWatcher(&vaultapi.LifetimeWatcherInput{Secret: secret})
+		if err != nil {
+			log.Printf("创建Vault令牌续期器失败: %v", err)
+			resetVaultClient(client)
+			return
+		}
+
+		go watcher.Start()
+		err = waitVaultLease(client, watcher)
+		watcher.Stop()
+
+		if !isCurrentVaultClient(client) {
+			return
+		}
+		if err != nil {
+			log.Printf("Vault令牌续期失败: %v", err)
+		} else {
+			log.Println("Vault令牌已达到最长有效期")
+		}
+
+		// 静态令牌无法重新获取
+		if vaultAuthMethod() == VaultAuthToken {
+			log.Println("警告: Vault静态令牌即将过期，请更新 SIMS_VAULT_TOKEN 或改用AppRole/Kubernetes/JWT认证")
+			resetVaultClient(client)
+			return
+		}
+
+		fresh, freshSecret, err := reloginVault()
+		if err != nil {
+			log.Printf("Vault重新登录失败: %v", err)
+			resetVaultClient(client)
+			return
+		}
+		// 客户端已被替换或丢弃时，新登录的客户端不再使用
+		if !swapVaultClient(client, fresh) {
+			return
+		}
+		client, secret = fresh, freshSecret
+	}
+}
+
+// waitVaultLease 等待租约续期结束，客户端被替换时提前返回
+func waitVaultLease(client *vaultapi.Client, watcher *vaultapi.LifetimeWatcher) error {
+	for {
+		select {
+		case err := <-watcher.DoneCh():
+			return err
+		case <-watcher.RenewCh():
+			if !isCurrentVaultClient(client) {
+				return nil
+			}
+		}
+	}
+}
+
+// reloginVault 使用新的客户端重新登录，正在使用的客户端在登录期间保持原令牌；失败时按指数退避重试
+func reloginVault() (*vaultapi.Client, *vaultapi.Secret, error) {
+	var lastErr error
+	backoff := time.Second
+	for attempt := 1; attempt <= vaultReloginAttempts; attempt++ {
+		client, err := createVaultClient()
+		if err != nil {
+			return nil, nil, err
+		}
+		secret, err := vaultLogin(client)
+		if err == nil {
+			log.Println("Vault重新登录成功")
+			return client, secret, nil
+		}
+
+		lastErr = err
+		log.Printf("Vault重新登录失败（第%d次）: %v", attempt, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	return nil, nil, lastErr
+}
+
+// swapVaultClient 将正在使用的客户端替换为重新登录的客户端，正在使用的客户端已被替换或丢弃时返回false
+func swapVaultClient(current, fresh *vaultapi.Client) bool {
+	vaultMu.Lock()
+	defer vaultMu.Unlock()
+	if vaultClient != current {
+		return false
+	}
+	vaultClient = fresh
+	return true
+}
+
+// isCurrentVaultClient 判断客户端是否仍在使用
+func isCurrentVaultClient(client *vaultapi.Client) bool {
+	vaultMu.Lock()
+	defer vaultMu.Unlock()
+	return vaultClient == client
+}
+
+// authMountPath 获取认证挂载路径
+func authMountPath(mountPath, defaultPath string) string {
+	mountPath = strings.Trim(mountPath, "/")
+	if mountPath == "" {
+		return defaultPath
+	}
+	return mountPath
+}
+
+// readCredential 读取凭证，配置了文件路径时优先从文件读取，以便获取轮换后的凭证
+func readCredential(value, path string) (string, error) {
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		value = strings.TrimSpace(string(data))
+	}
+	if value == "" {
+		return "", fmt.Errorf("凭证为空")
+	}
+	return value, nil
+}
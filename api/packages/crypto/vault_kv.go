@@ -0,0 +1,199 @@
+package crypto
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/akinoccc/hysaif/api/config"
+)
+
+// 敏感数据存储后端
+const (
+	StorageDatabase = "database" // 加密后存入数据库
+	StorageVaultKV  = "vault_kv" // 存入Vault KV v2，数据库只保存引用
+)
+
+const (
+	// vaultKVRefPrefix 数据库中保存的KV引用前缀，格式为 kv:<挂载路径>:<路径>@<版本>
+	vaultKVRefPrefix = "kv:"
+	// defaultKVMount KV v2引擎默认挂载路径
+	defaultKVMount = "secret"
+	// defaultKVPathPrefix 密钥项在KV中的默认路径前缀
+	defaultKVPathPrefix = "hysaif/items"
+)
+
+// StorageBackend 获取配置的敏感数据存储后端，默认为数据库
+func StorageBackend() string {
+	if backend := config.AppConfig.Storage.Backend; backend != "" {
+		return backend
+	}
+	return StorageDatabase
+}
+
+// UsesVaultKV 检查敏感数据是否存储在Vault KV中
+func UsesVaultKV() bool {
+	return StorageBackend() == StorageVaultKV
+}
+
+// ValidateStorageConfig 校验存储后端配置
+func ValidateStorageConfig() error {
+	switch backend := StorageBackend(); backend {
+	case StorageDatabase:
+		return nil
+	case StorageVaultKV:
+		if !config.AppConfig.Security.Vault.Enabled {
+			return fmt.Errorf("存储后端 %s 需要启用Vault", backend)
+		}
+		return nil
+	default:
+		return fmt.Errorf("未知的存储后端: %s", backend)
+	}
+}
+
+// IsVaultKVRef 检查数据库中保存的是否为Vault KV引用
+func IsVaultKVRef(data string) bool {
+	return strings.HasPrefix(data, vaultKVRefPrefix)
+}
+
+// WriteVaultKV 将JSON对象写入密钥项对应的KV路径，生成新版本并返回指向该版本的引用
+func WriteVaultKV(itemID string, data []byte) (string, error) {
+	if IsSealed() {
+		return "", ErrSealed
+	}
+	if itemID == "" {
+		return "", fmt.Errorf("密钥项ID为空，无法写入Vault KV")
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return "", fmt.Errorf("KV数据必须是JSON对象: %w", err)
+	}
+
+	client, err := getVaultClient()
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrBackendUnavailable, err)
+	}
+
+	mount := vaultKVMount()
+	secretPath := vaultKVPath(itemID)
+	secret, err := client.KVv2(mount).Put(context.Background(), secretPath, payload)
+	if err != nil {
+		handleVaultError(client, err)
+		return "", fmt.Errorf("%w: 写入Vault KV失败: %v", ErrBackendUnavailable, err)
+	}
+	if secret == nil || secret.VersionMetadata == nil {
+		return "", fmt.Errorf("Vault KV写入响应中没有版本信息")
+	}
+
+	return formatVaultKVRef(mount, secretPath, secret.VersionMetadata.Version), nil
+}
+
+// ReadVaultKV 读取引用指向的KV版本，返回JSON对象；引用必须指向所属密钥项的KV路径，
+// 防止篡改数据库中的引用读取其他密钥项的数据
+func ReadVaultKV(ref, itemID string) ([]byte, error) {
+	if IsSealed() {
+		return nil, ErrSealed
+	}
+
+	mount, secretPath, version, err := parseVaultKVRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	if itemID == "" || secretPath != vaultKVPath(itemID) {
+		return nil, fmt.Errorf("Vault KV引用不属于密钥项 %s", itemID)
+	}
+
+	client, err := getVaultClient()
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := client.KVv2(mount).GetVersion(context.Background(), secretPath, version)
+	if err != nil {
+		handleVaultError(client, err)
+		return nil, fmt.Errorf("读取Vault KV失败: %w", err)
+	}
+
+	data, err := json.Marshal(secret.Data)
+	if err != nil {
+		return nil, fmt.Errorf("序列化Vault KV数据失败: %w", err)
+	}
+	return data, nil
+}
+
+// ReadVaultKVBatch 读取多个引用指向的KV版本，itemIDs 为各引用所属的密钥项ID；
+// KV引擎不支持批量读取，限制并发逐条读取；返回结果与输入一一对应
+func ReadVaultKVBatch(refs, itemIDs []string) ([][]byte, []error) {
+	results := make([][]byte, len(refs))
+	errs := make([]error, len(refs))
+	forEachConcurrent(len(refs), func(i int) {
+		results[i], errs[i] = ReadVaultKV(refs[i], itemIDs[i])
+	})
+	return results, errs
+}
+
+// DestroyVaultKV 永久删除密钥项在当前配置的KV路径下的所有版本，路径不存在时不报错
+func DestroyVaultKV(itemID string) error {
+	if IsSealed() {
+		return ErrSealed
+	}
+	if itemID == "" {
+		return fmt.Errorf("密钥项ID为空，无法删除Vault KV")
+	}
+
+	client, err := getVaultClient()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBackendUnavailable, err)
+	}
+
+	if err := client.KVv2(vaultKVMount()).DeleteMetadata(context.Background(), vaultKVPath(itemID)); err != nil {
+		handleVaultError(client, err)
+		return fmt.Errorf("%w: 删除Vault KV失败: %v", ErrBackendUnavailable, err)
+	}
+	return nil
+}
+
+// vaultKVMount 获取KV v2引擎挂载路径
+func vaultKVMount() string {
+	if mount := strings.Trim(config.AppConfig.Storage.KVMount, "/"); mount != "" {
+		return mount
+	}
+	return defaultKVMount
+}
+
+// vaultKVPath 获取密钥项在KV中的路径
+func vaultKVPath(itemID string) string {
+	prefix := strings.Trim(config.AppConfig.Storage.KVPathPrefix, "/")
+	if prefix == "" {
+		prefix = defaultKVPathPrefix
+	}
+	return path.Join(prefix, itemID)
+}
+
+// formatVaultKVRef 构造KV引用
+func formatVaultKVRef(mount, secretPath string, version int) string {
+	return fmt.Sprintf("%s%s:%s@%d", vaultKVRefPrefix, mount, secretPath, version)
+}
+
+// parseVaultKVRef 解析KV引用，引用中记录了写入时的挂载路径，修改挂载路径后旧引用仍可读取
+func parseVaultKVRef(ref string) (string, string, int, error) {
+	body := strings.TrimPrefix(ref, vaultKVRefPrefix)
+	at := strings.LastIndex(body, "@")
+	if at < 0 || !IsVaultKVRef(ref) {
+		return "", "", 0, fmt.Errorf("无效的Vault KV引用")
+	}
+	location, versionText := body[:at], body[at+1:]
+	mount, secretPath, ok := strings.Cut(location, ":")
+	if !ok || mount == "" || secretPath == "" {
+		return "", "", 0, fmt.Errorf("无效的Vault KV引用")
+	}
+	version, err := strconv.Atoi(versionText)
+	if err != nil || version <= 0 {
+		return "", "", 0, fmt.Errorf("无效的Vault KV版本: %s", versionText)
+	}
+	return mount, secretPath, version, nil
+}
@@ -0,0 +1,80 @@
+package crypto
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/akinoccc/hysaif/api/config"
+
+	"github.com/google/uuid"
+)
+
+// TestVaultKVDevServer 使用开发模式的Vault测试KV存储后端，需要先启动
+// vault server -dev -dev-root-token-id=root，并设置 SIMS_TEST_VAULT_ADDR（如 http://127.0.0.1:8200）和 SIMS_TEST_VAULT_TOKEN
+func TestVaultKVDevServer(t *testing.T) {
+	address := os.Getenv("SIMS_TEST_VAULT_ADDR")
+	if address == "" {
+		t.Skip("未设置 SIMS_TEST_VAULT_ADDR，跳过Vault集成测试")
+	}
+
+	resetVaultState(t)
+	useLocalKey(t)
+	config.AppConfig.Security.Vault = config.VaultConfig{Enabled: true, Address: address, Token: os.Getenv("SIMS_TEST_VAULT_TOKEN")}
+	config.AppConfig.Storage = config.StorageConfig{Backend: StorageVaultKV, KVPathPrefix: "hysaif-test/" + uuid.NewString()}
+
+	itemA, itemB := uuid.NewString(), uuid.NewString()
+	refA, err := WriteVaultKV(itemA, []byte(`{"password":"a-1"}`))
+	if err != nil {
+		t.Fatalf("写入Vault KV失败: %v", err)
+	}
+	refA2, err := WriteVaultKV(itemA, []byte(`{"password":"a-2"}`))
+	if err != nil {
+		t.Fatalf("写入Vault KV失败: %v", err)
+	}
+	refB, err := WriteVaultKV(itemB, []byte(`{"password":"b-1"}`))
+	if err != nil {
+		t.Fatalf("写入Vault KV失败: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = DestroyVaultKV(itemA)
+		_ = DestroyVaultKV(itemB)
+	})
+
+	tests := []struct {
+		name    string
+		ref     string
+		itemID  string
+		want    string
+		wantErr bool
+	}{
+		{name: "读取旧版本", ref: refA, itemID: itemA, want: "a-1"},
+		{name: "读取新版本", ref: refA2, itemID: itemA, want: "a-2"},
+		{name: "读取其他密钥项的引用", ref: refB, itemID: itemA, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := ReadVaultKV(tt.ref, tt.itemID)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("期望读取失败")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("读取Vault KV失败: %v", err)
+			}
+			var payload map[string]string
+			if err := json.Unmarshal(data, &payload); err != nil || payload["password"] != tt.want {
+				t.Fatalf("读取结果 %s，期望 password=%s", data, tt.want)
+			}
+		})
+	}
+
+	if err := DestroyVaultKV(itemA); err != nil {
+		t.Fatalf("删除Vault KV失败: %v", err)
+	}
+	if _, err := ReadVaultKV(refA2, itemA); err == nil {
+		t.Fatal("删除后期望无法读取")
+	}
+}
@@ -0,0 +1,65 @@
+package crypto
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/akinoccc/hysaif/api/config"
+)
+
+func TestReadVaultKVRejectsForeignRef(t *testing.T) {
+	config.AppConfig = &config.Config{}
+
+	tests := []struct {
+		name   string
+		ref    string
+		itemID string
+	}{
+		{"其他密钥项的路径", "kv:secret:hysaif/items/item-b@1", "item-a"},
+		{"路径前缀不同", "kv:secret:other/items/item-a@1", "item-a"},
+		{"路径穿越", "kv:secret:hysaif/items/item-a/../item-b@1", "item-a"},
+		{"所属密钥项为空", "kv:secret:hysaif/items/item-a@1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadVaultKV(tt.ref, tt.itemID)
+			if err == nil || !strings.Contains(err.Error(), "不属于密钥项") {
+				t.Fatalf("期望拒绝不属于密钥项的引用，实际错误: %v", err)
+			}
+		})
+	}
+}
+
+func TestParseVaultKVRef(t *testing.T) {
+	tests := []struct {
+		ref       string
+		mount     string
+		path      string
+		version   int
+		wantError bool
+	}{
+		{ref: "kv:secret:hysaif/items/a@3", mount: "secret", path: "hysaif/items/a", version: 3},
+		{ref: "kv:kv-v2:team/x@12", mount: "kv-v2", path: "team/x", version: 12},
+		{ref: "kv:secret:hysaif/items/a", wantError: true},
+		{ref: "kv:secret:hysaif/items/a@0", wantError: true},
+		{ref: "kv::hysaif/items/a@1", wantError: true},
+		{ref: "vault:v1:abc", wantError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			mount, path, version, err := parseVaultKVRef(tt.ref)
+			if tt.wantError {
+				if err == nil {
+					t.Fatalf("期望解析失败")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("解析失败: %v", err)
+			}
+			if mount != tt.mount || path != tt.path || version != tt.version {
+				t.Fatalf("解析结果 %s %s %d，期望 %s %s %d", mount, path, version, tt.mount, tt.path, tt.version)
+			}
+		})
+	}
+}
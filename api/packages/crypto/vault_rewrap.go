@@ -0,0 +1,258 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/akinoccc/hysaif/api/config"
+)
+
+// TransitKeyInfo Transit密钥版本信息
+type TransitKeyInfo struct {
+	Name                 string `json:"name"`
+	LatestVersion        int    `json:"latest_version"`
+	MinDecryptionVersion int    `json:"min_decryption_version"`
+	MinEncryptionVersion int    `json:"min_encryption_version"`
+}
+
+// TransitKeyNames 获取所有用于包装数据密钥的Transit密钥名称：全局Transit密钥及各环境独立的Transit密钥
+func TransitKeyNames() []string {
+	names := []string{config.AppConfig.Security.Vault.KeyName}
+	for _, environmentKey := range config.AppConfig.Security.EnvironmentKeys {
+		if environmentKey.VaultKeyName != "" && !slices.Contains(names, environmentKey.VaultKeyName) {
+			names = append(names, environmentKey.VaultKeyName)
+		}
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// TransitKeyName 获取Vault提供者（vault 或 vault:<环境>）包装数据密钥使用的Transit密钥名称
+func TransitKeyName(providerID string) (string, error) {
+	if !isVaultProvider(providerID) {
+		return "", fmt.Errorf("主密钥提供者 %s 不使用Vault Transit密钥", providerID)
+	}
+	provider, err := GetProvider(providerID)
+	if err != nil {
+		return "", err
+	}
+	vault, ok := provider.(*vaultProvider)
+	if !ok {
+		return "", fmt.Errorf("主密钥提供者 %s 不使用Vault Transit密钥", providerID)
+	}
+	return vault.keyName(), nil
+}
+
+// GetTransitKeyInfo 读取Transit密钥的版本信息
+func GetTransitKeyInfo(keyName string) (*TransitKeyInfo, error) {
+	return transitKeyInfo(keyName)
+}
+
+// transitKeyInfo 读取指定Transit密钥的版本信息
+func transitKeyInfo(keyName string) (*TransitKeyInfo, error) {
+	client, err := getVaultClient()
+	if err != nil {
+		return nil, err
+	}
+
+	path := fmt.Sprintf("%s/keys/%s", config.AppConfig.Security.Vault.MountPath, keyName)
+	resp, err := client.Logical().Read(path)
+	if err != nil {
+		handleVaultError(client, err)
+		return nil, fmt.Errorf("读取Transit密钥信息失败: %w", err)
+	}
+	if resp == nil || resp.Data == nil {
+		return nil, fmt.Errorf("Transit密钥不存在")
+	}
+
+	return &TransitKeyInfo{
+		Name:                 keyName,
+		LatestVersion:        vaultInt(resp.Data["latest_version"]),
+		MinDecryptionVersion: vaultInt(resp.Data["min_decryption_version"]),
+		MinEncryptionVersion: vaultInt(resp.Data["min_encryption_version"]),
+	}, nil
+}
+
+// RotateTransitKey 轮换Transit密钥，返回轮换后的最新版本
+func RotateTransitKey(keyName string) (*TransitKeyInfo, error) {
+	client, err := getVaultClient()
+	if err != nil {
+		return nil, err
+	}
+
+	path := fmt.Sprintf("%s/keys/%s/rotate", config.AppConfig.Security.Vault.MountPath, keyName)
+	if _, err := client.Logical().Write(path, nil); err != nil {
+		handleVaultError(client, err)
+		return nil, fmt.Errorf("轮换Transit密钥 %s 失败: %w", keyName, err)
+	}
+
+	// 清除使用该密钥的提供者的活动版本缓存，使后续加密和检查使用新版本
+	activeVersionMu.Lock()
+	for providerID := range activeVersionCache {
+		if name, err := TransitKeyName(providerID); err == nil && name == keyName {
+			delete(activeVersionCache, providerID)
+		}
+	}
+	activeVersionMu.Unlock()
+
+	return GetTransitKeyInfo(keyName)
+}
+
+// SetTransitMinDecryptionVersion 设置Transit密钥允许解密的最低版本
+func SetTransitMinDecryptionVersion(keyName string, version int) error {
+	client, err := getVaultClient()
+	if err != nil {
+		return err
+	}
+
+	path := fmt.Sprintf("%s/keys/%s/config", config.AppConfig.Security.Vault.MountPath, keyName)
+	_, err = client.Logical().Write(path, map[string]interface{}{
+		"min_decryption_version": version,
+	})
+	if err != nil {
+		handleVaultError(client, err)
+		return fmt.Errorf("设置Transit密钥 %s 的最低解密版本失败: %w", keyName, err)
+	}
+	return nil
+}
+
+// RewrapWithVault 通过 transit/rewrap 批量将同一Transit密钥的密文重新包装到最新密钥版本，明文不会离开Vault
+//
+// contexts 与密文一一对应，启用密钥派生时为加密时使用的context，未使用context的密文对应nil；
+// 返回结果与输入一一对应，单条失败时对应结果为空字符串并记录在错误列表中
+func RewrapWithVault(keyName string, ciphertexts []string, contexts [][]byte) ([]string, []error, error) {
+	client, err := getVaultClient()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	batchInput := make([]map[string]interface{}, len(ciphertexts))
+	for i, ciphertext := range ciphertexts {
+		batchInput[i] = map[string]interface{}{"ciphertext": ciphertext}
+		if i < len(contexts) && contexts[i] != nil {
+			batchInput[i]["context"] = base64.StdEncoding.EncodeToString(contexts[i])
+		}
+	}
+
+	path := fmt.Sprintf("%s/rewrap/%s", config.AppConfig.Security.Vault.MountPath, keyName)
+	resp, err := client.Logical().Write(path, map[string]interface{}{
+		"batch_input": batchInput,
+	})
+	if err != nil {
+		handleVaultError(client, err)
+		return nil, nil, fmt.Errorf("Vault批量重新包装请求失败: %w", err)
+	}
+	if resp == nil || resp.Data == nil {
+		return nil, nil, fmt.Errorf("Vault批量重新包装响应为空")
+	}
+
+	batchResults, ok := resp.Data["batch_results"].([]interface{})
+	if !ok || len(batchResults) != len(ciphertexts) {
+		return nil, nil, fmt.Errorf("无效的Vault批量重新包装响应格式")
+	}
+
+	results := make([]string, len(ciphertexts))
+	errs := make([]error, len(ciphertexts))
+	for i, item := range batchResults {
+		result, ok := item.(map[string]interface{})
+		if !ok {
+			errs[i] = fmt.Errorf("无效的Vault批量重新包装结果")
+			continue
+		}
+		if message, _ := result["error"].(string); message != "" {
+			errs[i] = fmt.Errorf("Vault重新包装失败: %s", message)
+			continue
+		}
+		ciphertext, ok := result["ciphertext"].(string)
+		if !ok {
+			errs[i] = fmt.Errorf("无效的Vault批量重新包装结果")
+			continue
+		}
+		results[i] = ciphertext
+	}
+
+	return results, errs, nil
+}
+
+// ExtractVaultCiphertext 从存储的密文中提取Vault Transit密文（vault:v<N>:...）、包装时使用的context及Vault提供者ID，
+// 全局Transit密钥和环境独立Transit密钥（vault:<环境>）包装的密文都会返回
+func ExtractVaultCiphertext(encryptedData string, opts Options) (string, string, []byte, bool) {
+	if IsEnvelope(encryptedData) {
+		header, _, err := parseEnvelope(encryptedData)
+		if err != nil || !isVaultProvider(header.Provider) {
+			return "", "", nil, false
+		}
+		if header.Context {
+			if !opts.Bound() {
+				return "", "", nil, false
+			}
+			return header.Provider, header.WrappedKey, opts.associatedData(), true
+		}
+		return header.Provider, header.WrappedKey, nil, true
+	}
+
+	// 旧版格式为 "vault:" 前缀加全局Transit密钥的密文
+	if strings.HasPrefix(encryptedData, "vault:") {
+		return ProviderVault, strings.TrimPrefix(encryptedData, "vault:"), nil, true
+	}
+
+	return "", "", nil, false
+}
+
+// ReplaceVaultCiphertext 将存储密文中的Vault Transit密文替换为重新包装后的密文
+func ReplaceVaultCiphertext(encryptedData, vaultCiphertext string) (string, error) {
+	if IsEnvelope(encryptedData) {
+		header, sealed, err := parseEnvelope(encryptedData)
+		if err != nil {
+			return "", err
+		}
+		if !isVaultProvider(header.Provider) {
+			return "", fmt.Errorf("密文不是由Vault包装")
+		}
+		header.WrappedKey = vaultCiphertext
+		return formatEnvelope(header, sealed)
+	}
+
+	if strings.HasPrefix(encryptedData, "vault:") {
+		return "vault:" + vaultCiphertext, nil
+	}
+
+	return "", fmt.Errorf("密文不是由Vault加密")
+}
+
+// isVaultProvider 检查提供者是否使用Vault Transit密钥
+func isVaultProvider(providerID string) bool {
+	return providerID == ProviderVault || strings.HasPrefix(providerID, ProviderVault+environmentSeparator)
+}
+
+// VaultCiphertextVersion 获取Vault Transit密文使用的密钥版本，无法解析时返回0
+func VaultCiphertextVersion(vaultCiphertext string) int {
+	version, err := strconv.Atoi((&vaultProvider{}).KeyVersion(vaultCiphertext))
+	if err != nil {
+		return 0
+	}
+	return version
+}
+
+// vaultInt 将Vault响应中的数字转换为int
+func vaultInt(value interface{}) int {
+	switch v := value.(type) {
+	case json.Number:
+		n, _ := v.Int64()
+		return int(n)
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case string:
+		n, _ := strconv.Atoi(v)
+		return n
+	default:
+		return 0
+	}
+}
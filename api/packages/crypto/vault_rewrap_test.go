@@ -0,0 +1,73 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/akinoccc/hysaif/api/config"
+)
+
+func TestExtractVaultCiphertext(t *testing.T) {
+	useLocalKey(t)
+	config.AppConfig.Security.Vault.Enabled = true
+	config.AppConfig.Security.Vault.KeyName = "hysaif"
+	config.AppConfig.Security.EnvironmentKeys = map[string]config.EnvironmentKeyConfig{
+		"production": {VaultKeyName: "hysaif-production"},
+	}
+
+	envelope := func(provider string) string {
+		data, err := formatEnvelope(&envelopeHeader{Version: envelopeVersion, Provider: provider, WrappedKey: "vault:v2:abc"}, []byte("sealed"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	tests := []struct {
+		name         string
+		data         string
+		wantProvider string
+		wantKeyName  string
+		wantOK       bool
+	}{
+		{"全局Transit密钥", envelope(ProviderVault), ProviderVault, "hysaif", true},
+		{"环境独立Transit密钥", envelope("vault:production"), "vault:production", "hysaif-production", true},
+		{"旧版Vault密文", "vault:vault:v2:abc", ProviderVault, "hysaif", true},
+		{"本地密钥环", envelope(ProviderAES), "", "", false},
+		{"环境密钥环", envelope("aes:production"), "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, ciphertext, _, ok := ExtractVaultCiphertext(tt.data, Options{})
+			if ok != tt.wantOK || provider != tt.wantProvider {
+				t.Fatalf("ExtractVaultCiphertext() = %s, %v，期望 %s, %v", provider, ok, tt.wantProvider, tt.wantOK)
+			}
+			if !ok {
+				if _, err := ReplaceVaultCiphertext(tt.data, "vault:v3:def"); err == nil {
+					t.Fatal("非Vault密文不应被替换")
+				}
+				return
+			}
+			if ciphertext != "vault:v2:abc" || VaultCiphertextVersion(ciphertext) != 2 {
+				t.Fatalf("提取的密文为 %s", ciphertext)
+			}
+
+			keyName, err := TransitKeyName(provider)
+			if err != nil || keyName != tt.wantKeyName {
+				t.Fatalf("TransitKeyName() = %s，错误: %v，期望 %s", keyName, err, tt.wantKeyName)
+			}
+
+			replaced, err := ReplaceVaultCiphertext(tt.data, "vault:v3:def")
+			if err != nil {
+				t.Fatalf("替换密文失败: %v", err)
+			}
+			replacedProvider, ciphertext, _, _ := ExtractVaultCiphertext(replaced, Options{})
+			if replacedProvider != provider || VaultCiphertextVersion(ciphertext) != 3 {
+				t.Fatalf("替换后的密文为 %s/%s", replacedProvider, ciphertext)
+			}
+		})
+	}
+
+	if names := TransitKeyNames(); len(names) != 2 || names[0] != "hysaif" || names[1] != "hysaif-production" {
+		t.Fatalf("TransitKeyNames() = %v", names)
+	}
+}
@@ -0,0 +1,85 @@
+package crypto
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/akinoccc/hysaif/api/config"
+)
+
+// resetVaultState 清除Vault客户端状态，测试结束后恢复
+func resetVaultState(t *testing.T) {
+	t.Helper()
+	reset := func() {
+		vaultMu.Lock()
+		vaultClient, initErr, lastInitAt, vaultConnecting = nil, nil, time.Time{}, nil
+		vaultMu.Unlock()
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+func TestGetVaultClientDoesNotHoldLockWhileConnecting(t *testing.T) {
+	resetVaultState(t)
+
+	var requests atomic.Int32
+	arrived := make(chan struct{}, 1)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		select {
+		case arrived <- struct{}{}:
+		default:
+		}
+		<-release
+		http.Error(w, `{"errors":["permission denied"]}`, http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	config.AppConfig = &config.Config{}
+	config.AppConfig.Security.Vault = config.VaultConfig{Enabled: true, Address: server.URL, Token: "test-token"}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = getVaultClient()
+		}(i)
+	}
+
+	select {
+	case <-arrived:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Vault未收到登录请求")
+	}
+
+	// 连接期间其他需要 vaultMu 的操作不能被阻塞
+	done := make(chan struct{})
+	go func() {
+		isCurrentVaultClient(nil)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("连接Vault期间持有 vaultMu")
+	}
+
+	close(release)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err == nil {
+			t.Fatalf("第%d个请求期望连接失败", i)
+		}
+	}
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("并发请求发起了 %d 次连接，期望1次", n)
+	}
+}
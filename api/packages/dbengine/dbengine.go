@@ -0,0 +1,210 @@
+// Package dbengine 连接 Postgres 和 MySQL 目标数据库，执行动态凭据的创建和撤销语句
+package dbengine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// 支持的数据库引擎
+const (
+	EnginePostgres = "postgres"
+	EngineMySQL    = "mysql"
+)
+
+// Engines 支持的数据库引擎
+var Engines = []string{EnginePostgres, EngineMySQL}
+
+// 语句模板中的占位符
+const (
+	PlaceholderName       = "{{name}}"       // 临时用户名
+	PlaceholderPassword   = "{{password}}"   // 临时用户密码
+	PlaceholderExpiration = "{{expiration}}" // 到期时间，RFC3339格式的UTC时间
+	PlaceholderDatabase   = "{{database}}"   // 数据库名
+)
+
+// execTimeout 连接目标数据库并执行语句的超时时间
+const execTimeout = 15 * time.Second
+
+// ErrUnsupportedEngine 不支持的数据库引擎
+var ErrUnsupportedEngine = errors.New("不支持的数据库引擎，仅支持 postgres 和 mysql")
+
+// defaultRevocationStatements 各引擎默认的撤销语句，断开临时用户的连接并删除用户
+var defaultRevocationStatements = map[string]string{
+	EnginePostgres: `SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE usename = '{{name}}';
+DROP OWNED BY "{{name}}";
+DROP ROLE IF EXISTS "{{name}}";`,
+	EngineMySQL: `DROP USER IF EXISTS '{{name}}'@'%';`,
+}
+
+// Connection 目标数据库的管理员连接信息
+type Connection struct {
+	Engine   string // 数据库引擎
+	Address  string // 地址，格式为 host:port
+	Database string // 连接的数据库名
+	Username string // 管理员用户名
+	Password string // 管理员密码
+	SSLMode  string // Postgres 的 sslmode 或 MySQL 的 tls 参数，为空时 Postgres 使用 prefer，MySQL 不使用TLS
+}
+
+// Validate 校验连接信息
+func (c Connection) Validate() error {
+	if !slices.Contains(Engines, c.Engine) {
+		return ErrUnsupportedEngine
+	}
+	if _, _, err := net.SplitHostPort(c.Address); err != nil {
+		return fmt.Errorf("数据库地址必须为 host:port 格式: %w", err)
+	}
+	if c.Username == "" || c.Password == "" {
+		return errors.New("数据库连接需要管理员用户名和密码")
+	}
+	return nil
+}
+
+// DefaultRevocationStatements 获取引擎默认的撤销语句
+func DefaultRevocationStatements(engine string) string {
+	return defaultRevocationStatements[engine]
+}
+
+// Render 替换语句模板中的占位符，并按分号拆分为多条语句；引号、反引号、注释和 Postgres 美元符号引用中的分号不作为语句分隔符
+func Render(template string, values map[string]string) []string {
+	pairs := make([]string, 0, len(values)*2)
+	for placeholder, value := range values {
+		pairs = append(pairs, placeholder, value)
+	}
+	return splitStatements(strings.NewReplacer(pairs...).Replace(template))
+}
+
+// dollarQuoteTag 匹配 Postgres 美元符号引用的开始标记，如 $$ 和 $body$
+var dollarQuoteTag = regexp.MustCompile(`^\$([A-Za-z_][A-Za-z0-9_]*)?\$`)
+
+// splitStatements 按引号、注释和美元符号引用之外的分号拆分语句，忽略空语句；未闭合的引用延续到末尾
+func splitStatements(sql string) []string {
+	var statements []string
+	appendStatement := func(statement string) {
+		if statement = strings.TrimSpace(statement); statement != "" {
+			statements = append(statements, statement)
+		}
+	}
+
+	start := 0
+	for i := 0; i < len(sql); {
+		var end string
+		switch {
+		case sql[i] == ';':
+			appendStatement(sql[start:i])
+			start = i + 1
+			i++
+			continue
+		case sql[i] == '\'' || sql[i] == '"' || sql[i] == '`':
+			// 转义的引号（如 ''）视为连续的两段引用，不影响拆分
+			end = sql[i : i+1]
+			i++
+		case strings.HasPrefix(sql[i:], "--"):
+			end = "\n"
+			i += 2
+		case strings.HasPrefix(sql[i:], "/*"):
+			end = "*/"
+			i += 2
+		case sql[i] == '$' && (i == 0 || !isIdentifierByte(sql[i-1])):
+			tag := dollarQuoteTag.FindString(sql[i:])
+			if tag == "" {
+				i++
+				continue
+			}
+			end = tag
+			i += len(tag)
+		default:
+			i++
+			continue
+		}
+
+		if j := strings.Index(sql[i:], end); j >= 0 {
+			i += j + len(end)
+		} else {
+			i = len(sql)
+		}
+	}
+	appendStatement(sql[start:])
+	return statements
+}
+
+// isIdentifierByte 判断字节是否可以出现在标识符中，标识符中的 $ 不是美元符号引用
+func isIdentifierByte(b byte) bool {
+	return b == '_' || b == '$' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+// Exec 使用管理员凭据连接目标数据库并依次执行语句，任一语句失败时停止执行
+func (c Connection) Exec(statements []string) error {
+	dialector, err := c.dialector()
+	if err != nil {
+		return err
+	}
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		return fmt.Errorf("连接数据库失败: %w", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), execTimeout)
+	defer cancel()
+	for i, statement := range statements {
+		if err := db.WithContext(ctx).Exec(statement).Error; err != nil {
+			return fmt.Errorf("执行第%d条语句失败: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// dialector 根据引擎构造数据库驱动
+func (c Connection) dialector() (gorm.Dialector, error) {
+	host, port, err := net.SplitHostPort(c.Address)
+	if err != nil {
+		return nil, fmt.Errorf("数据库地址必须为 host:port 格式: %w", err)
+	}
+
+	switch c.Engine {
+	case EnginePostgres:
+		sslMode := c.SSLMode
+		if sslMode == "" {
+			sslMode = "prefer"
+		}
+		dsn := url.URL{
+			Scheme:   "postgres",
+			User:     url.UserPassword(c.Username, c.Password),
+			Host:     net.JoinHostPort(host, port),
+			Path:     "/" + c.Database,
+			RawQuery: url.Values{"sslmode": {sslMode}, "connect_timeout": {"10"}}.Encode(),
+		}
+		return postgres.Open(dsn.String()), nil
+	case EngineMySQL:
+		config := mysqldriver.NewConfig()
+		config.User = c.Username
+		config.Passwd = c.Password
+		config.Net = "tcp"
+		config.Addr = net.JoinHostPort(host, port)
+		config.DBName = c.Database
+		config.Timeout = 10 * time.Second
+		config.TLSConfig = c.SSLMode
+		return mysql.Open(config.FormatDSN()), nil
+	default:
+		return nil, ErrUnsupportedEngine
+	}
+}
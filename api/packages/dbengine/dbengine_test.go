@@ -0,0 +1,117 @@
+package dbengine
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	values := map[string]string{
+		PlaceholderName:       "v_app_1",
+		PlaceholderPassword:   "Secret123",
+		PlaceholderExpiration: "2030-01-01T00:00:00Z",
+		PlaceholderDatabase:   "app",
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		want     []string
+	}{
+		{
+			name:     "替换所有占位符",
+			template: `CREATE ROLE "{{name}}" WITH LOGIN PASSWORD '{{password}}' VALID UNTIL '{{expiration}}'; GRANT CONNECT ON DATABASE "{{database}}" TO "{{name}}";`,
+			want: []string{
+				`CREATE ROLE "v_app_1" WITH LOGIN PASSWORD 'Secret123' VALID UNTIL '2030-01-01T00:00:00Z'`,
+				`GRANT CONNECT ON DATABASE "app" TO "v_app_1"`,
+			},
+		},
+		{
+			name:     "忽略空语句和空白",
+			template: "\n  DROP USER IF EXISTS '{{name}}'@'%';\n;  ;\n",
+			want:     []string{`DROP USER IF EXISTS 'v_app_1'@'%'`},
+		},
+		{
+			name:     "未知占位符保持原样",
+			template: "SELECT '{{unknown}}'",
+			want:     []string{"SELECT '{{unknown}}'"},
+		},
+		{
+			name:     "引号中的分号不拆分",
+			template: `CREATE ROLE "{{name}}" WITH LOGIN PASSWORD 'a;b''c;'; COMMENT ON ROLE "{{name}}" IS 'x;y'`,
+			want: []string{
+				`CREATE ROLE "v_app_1" WITH LOGIN PASSWORD 'a;b''c;'`,
+				`COMMENT ON ROLE "v_app_1" IS 'x;y'`,
+			},
+		},
+		{
+			name:     "标识符和反引号中的分号不拆分",
+			template: "GRANT SELECT ON \"a;b\" TO \"{{name}}\"; GRANT SELECT ON `c;d`.* TO '{{name}}'@'%'",
+			want: []string{
+				`GRANT SELECT ON "a;b" TO "v_app_1"`,
+				"GRANT SELECT ON `c;d`.* TO 'v_app_1'@'%'",
+			},
+		},
+		{
+			name: "美元符号引用中的分号不拆分",
+			template: `DO $$ BEGIN CREATE ROLE "{{name}}"; EXCEPTION WHEN duplicate_object THEN NULL; END $$;
+DO $body$ BEGIN EXECUTE 'GRANT x TO y; '; END $body$; SELECT 1`,
+			want: []string{
+				`DO $$ BEGIN CREATE ROLE "v_app_1"; EXCEPTION WHEN duplicate_object THEN NULL; END $$`,
+				`DO $body$ BEGIN EXECUTE 'GRANT x TO y; '; END $body$`,
+				"SELECT 1",
+			},
+		},
+		{
+			name:     "注释中的分号不拆分",
+			template: "-- 撤销; 临时用户\nDROP ROLE \"{{name}}\"; /* a; b */ SELECT 1",
+			want: []string{
+				"-- 撤销; 临时用户\nDROP ROLE \"v_app_1\"",
+				"/* a; b */ SELECT 1",
+			},
+		},
+		{
+			name:     "标识符中的美元符号不是引用",
+			template: "SELECT a$b$ FROM t; SELECT 2",
+			want:     []string{"SELECT a$b$ FROM t", "SELECT 2"},
+		},
+		{
+			name:     "未闭合的引号延续到末尾",
+			template: "SELECT 'a; b",
+			want:     []string{"SELECT 'a; b"},
+		},
+		{
+			name:     "空模板",
+			template: "  ",
+			want:     nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Render(tt.template, values); !slices.Equal(got, tt.want) {
+				t.Fatalf("Render() = %q，期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConnectionValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		connection Connection
+		wantErr    bool
+	}{
+		{"postgres", Connection{Engine: EnginePostgres, Address: "127.0.0.1:5432", Username: "postgres", Password: "postgres"}, false},
+		{"mysql", Connection{Engine: EngineMySQL, Address: "db.internal:3306", Username: "root", Password: "root"}, false},
+		{"不支持的引擎", Connection{Engine: "oracle", Address: "127.0.0.1:1521", Username: "system", Password: "oracle"}, true},
+		{"地址缺少端口", Connection{Engine: EnginePostgres, Address: "127.0.0.1", Username: "postgres", Password: "postgres"}, true},
+		{"缺少管理员密码", Connection{Engine: EnginePostgres, Address: "127.0.0.1:5432", Username: "postgres"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.connection.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v，期望错误 %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package notification
 import (
 	"bytes"
 	"fmt"
+	"slices"
 	"text/template"
 	"time"
 
@@ -54,6 +55,12 @@ func GetNotificationTemplates() map[string]models.NotificationTemplate {
 			Content:  "密钥项 {{.SecretItemName}} 已过期，请立即更新",
 			Priority: models.NotificationPriorityUrgent,
 		},
+		models.NotificationTypeSecretItemRotated: {
+			Type:     models.NotificationTypeSecretItemRotated,
+			Title:    "密钥项已轮换",
+			Content:  "密钥项 {{.SecretItemName}} 已于 {{.RotatedAt}} 轮换，旧的值已失效，请重新获取",
+			Priority: models.NotificationPriorityHigh,
+		},
 		models.NotificationTypeSystemMaintenance: {
 			Type:     models.NotificationTypeSystemMaintenance,
 			Title:    "系统维护通知",
@@ -132,11 +139,11 @@ func NotifyAccessRequestCreated(accessRequest *models.AccessRequest) error {
 	}
 
 	// 加载关联数据
-	models.DB.Preload("Applicant").Preload("SecretItem").First(accessRequest, "id = ?", accessRequest.ID)
+	models.DB.Preload("Applicant").Preload("SecretItem").Preload("Folder").First(accessRequest, "id = ?", accessRequest.ID)
 
 	data := models.NotificationData{
 		ApplicantName:  accessRequest.Applicant.Name,
-		SecretItemName: accessRequest.SecretItem.Name,
+		SecretItemName: accessRequest.TargetName(),
 		Reason:         accessRequest.Reason,
 	}
 
@@ -172,13 +179,13 @@ func NotifyAccessRequestCreated(accessRequest *models.AccessRequest) error {
 // NotifyAccessRequestApproved 通知申请已批准
 func NotifyAccessRequestApproved(accessRequest *models.AccessRequest) error {
 	// 加载关联数据
-	models.DB.Preload("Applicant").Preload("SecretItem").Preload("Approver").First(accessRequest, "id = ?", accessRequest.ID)
+	models.DB.Preload("Applicant").Preload("SecretItem").Preload("Folder").Preload("Approver").First(accessRequest, "id = ?", accessRequest.ID)
 
 	validUntil := time.UnixMilli(int64(accessRequest.ValidUntil)).Format("2006-01-02 15:04:05")
 
 	data := models.NotificationData{
 		ApproverName:   accessRequest.Approver.Name,
-		SecretItemName: accessRequest.SecretItem.Name,
+		SecretItemName: accessRequest.TargetName(),
 		ValidUntil:     validUntil,
 	}
 
@@ -206,11 +213,11 @@ func NotifyAccessRequestApproved(accessRequest *models.AccessRequest) error {
 // NotifyAccessRequestRejected 通知申请已拒绝
 func NotifyAccessRequestRejected(accessRequest *models.AccessRequest) error {
 	// 加载关联数据
-	models.DB.Preload("Applicant").Preload("SecretItem").First(accessRequest, "id = ?", accessRequest.ID)
+	models.DB.Preload("Applicant").Preload("SecretItem").Preload("Folder").First(accessRequest, "id = ?", accessRequest.ID)
 
 	data := models.NotificationData{
 		ApproverName:   accessRequest.Approver.Name,
-		SecretItemName: accessRequest.SecretItem.Name,
+		SecretItemName: accessRequest.TargetName(),
 		RejectReason:   accessRequest.RejectReason,
 	}
 
@@ -235,12 +242,12 @@ func NotifyAccessRequestRejected(accessRequest *models.AccessRequest) error {
 
 // NotifyAccessRequestRevoked 通知申请已作废
 func NotifyAccessRequestRevoked(accessRequest *models.AccessRequest) error {
-	models.DB.Preload("Applicant").Preload("SecretItem").First(accessRequest, "id = ?", accessRequest.ID)
+	models.DB.Preload("Applicant").Preload("SecretItem").Preload("Folder").First(accessRequest, "id = ?", accessRequest.ID)
 
 	data := models.NotificationData{
 		ApproverName:   accessRequest.Approver.Name,
 		RejectReason:   accessRequest.RejectReason,
-		SecretItemName: accessRequest.SecretItem.Name,
+		SecretItemName: accessRequest.TargetName(),
 	}
 
 	err := SendAccessRevokedNotification(
@@ -265,10 +272,10 @@ func NotifyAccessRequestRevoked(accessRequest *models.AccessRequest) error {
 // NotifyAccessRequestExpired 通知申请已过期
 func NotifyAccessRequestExpired(accessRequest *models.AccessRequest) error {
 	// 加载关联数据
-	models.DB.Preload("Applicant").Preload("SecretItem").First(accessRequest, "id = ?", accessRequest.ID)
+	models.DB.Preload("Applicant").Preload("SecretItem").Preload("Folder").First(accessRequest, "id = ?", accessRequest.ID)
 
 	data := models.NotificationData{
-		SecretItemName: accessRequest.SecretItem.Name,
+		SecretItemName: accessRequest.TargetName(),
 	}
 
 	err := SendAccessExpiredNotification(
@@ -328,6 +335,47 @@ func NotifySecretItemExpiring(secretItem *models.SecretItem, expiresIn string) e
 	return nil
 }
 
+// NotifySecretItemRotated 通知当前持有访问权限的用户和创建者密钥项已轮换
+func NotifySecretItemRotated(secretItem *models.SecretItem, rotatedAt time.Time) error {
+	recipientIDs, err := models.ValidGrantHolderIDs(secretItem)
+	if err != nil {
+		return fmt.Errorf("获取授权用户失败: %v", err)
+	}
+	if secretItem.CreatedByID != "" && !slices.Contains(recipientIDs, secretItem.CreatedByID) {
+		recipientIDs = append(recipientIDs, secretItem.CreatedByID)
+	}
+
+	data := models.NotificationData{
+		SecretItemName: secretItem.Name,
+		RotatedAt:      rotatedAt.Format("2006-01-02 15:04:05"),
+	}
+
+	for _, recipientID := range recipientIDs {
+		err := CreateNotification(
+			recipientID,
+			models.NotificationTypeSecretItemRotated,
+			secretItem.ID,
+			"secret_item",
+			data,
+		)
+		if err != nil {
+			return fmt.Errorf("创建通知失败 (用户: %s): %v", recipientID, err)
+		}
+	}
+
+	err = SendSecretItemRotatedNotification(
+		data.SecretItemName,
+		data.RotatedAt,
+		secretItem.ID,
+		secretItem.Type,
+	)
+	if err != nil {
+		return fmt.Errorf("发送企业微信消息失败: %v", err)
+	}
+
+	return nil
+}
+
 // getAccessRequestApprovers 获取有审核权限的用户
 func getAccessRequestApprovers() ([]models.User, error) {
 	var users []models.User
@@ -28,7 +28,7 @@ func Stop() {
 
 // startExpiredAccessRequestChecker 检查过期的访问申请
 func startExpiredAccessRequestChecker() {
-	ticker := time.NewTicker(1 * time.Hour) // 每小时检查一次
+	ticker := time.NewTicker(5 * time.Minute) // 每5分钟检查一次，及时删除到期的数据库临时用户
 	defer ticker.Stop()
 
 	for {
@@ -105,6 +105,25 @@ func checkExpiredAccessRequests() {
 	if len(expiredRequests) > 0 {
 		log.Printf("处理了 %d 个过期的访问申请", len(expiredRequests))
 	}
+
+	revokeStaleDatabaseLeases()
+}
+
+// revokeStaleDatabaseLeases 删除访问申请已过期或被作废的数据库临时用户，包括之前删除失败的用户
+func revokeStaleDatabaseLeases() {
+	leases, err := models.StaleDatabaseLeases()
+	if err != nil {
+		log.Printf("查询需要删除的数据库临时用户失败: %v", err)
+		return
+	}
+
+	for _, lease := range leases {
+		if err := lease.Revoke(); err != nil {
+			log.Printf("删除数据库临时用户失败 (用户名: %s): %v", lease.Username, err)
+			continue
+		}
+		log.Printf("数据库临时用户已删除 (用户名: %s)", lease.Username)
+	}
 }
 
 // checkSecretItemExpiration 检查即将过期的密钥项
@@ -192,6 +192,16 @@ func SendSecretItemExpiringNotification(secretItemName, expiresIn, secretItemID,
 		Send()
 }
 
+// 便捷方法：发送密钥项已轮换通知
+func SendSecretItemRotatedNotification(secretItemName, rotatedAt, secretItemID, secretItemType string) error {
+	return NewWeComMessageBuilder().
+		SetTitle("密钥项已轮换").
+		AddContent("密钥项", secretItemName).
+		AddContent("轮换时间", rotatedAt).
+		SetJump("查看密钥项", appConfig.AppConfig.Server.Domain+"/"+secretItemType+"?id="+secretItemID).
+		Send()
+}
+
 // 便捷方法：发送自定义通知
 func SendCustomNotification(title string, contents map[string]string, jumpTitle, jumpURL string) error {
 	builder := NewWeComMessageBuilder().SetTitle(title)
@@ -0,0 +1,177 @@
+package password
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/sethvargo/go-diceware/diceware"
+)
+
+// 字符类别
+const (
+	lowercaseCharacters = "abcdefghijklmnopqrstuvwxyz"
+	uppercaseCharacters = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digitCharacters     = "0123456789"
+	symbolCharacters    = "!#$%&()*+,-./:;<=>?@[]^_{|}~"
+
+	// ambiguousCharacters 容易混淆的字符，启用排除后不会出现在生成的密码中
+	ambiguousCharacters = "0Oo1lI|"
+)
+
+// 生成参数范围
+const (
+	MinLength = 8
+	MaxLength = 128
+	MinWords  = 4
+	MaxWords  = 20
+)
+
+// 生成参数默认值
+const (
+	DefaultLength    = 20
+	DefaultWords     = 6
+	DefaultSeparator = "-"
+)
+
+// GenerateOptions 随机密码生成选项，至少需要启用一种字符类别
+type GenerateOptions struct {
+	Length           int  // 密码长度
+	Lowercase        bool // 包含小写字母
+	Uppercase        bool // 包含大写字母
+	Digits           bool // 包含数字
+	Symbols          bool // 包含特殊字符
+	ExcludeAmbiguous bool // 排除容易混淆的字符，如 0、O、1、l、I
+}
+
+// PassphraseOptions 口令短语（diceware）生成选项
+type PassphraseOptions struct {
+	Words         int    // 单词数量
+	Separator     string // 单词分隔符
+	Capitalize    bool   // 单词首字母大写
+	IncludeNumber bool   // 在随机一个单词后追加一位数字
+}
+
+// Generate 使用安全随机数生成密码，每种启用的字符类别至少出现一次
+func Generate(opts GenerateOptions) (string, error) {
+	if opts.Length < MinLength || opts.Length > MaxLength {
+		return "", fmt.Errorf("密码长度必须在 %d 到 %d 之间", MinLength, MaxLength)
+	}
+
+	var classes []string
+	for _, class := range []struct {
+		enabled    bool
+		characters string
+	}{
+		{opts.Lowercase, lowercaseCharacters},
+		{opts.Uppercase, uppercaseCharacters},
+		{opts.Digits, digitCharacters},
+		{opts.Symbols, symbolCharacters},
+	} {
+		if !class.enabled {
+			continue
+		}
+		characters := class.characters
+		if opts.ExcludeAmbiguous {
+			characters = removeCharacters(characters, ambiguousCharacters)
+		}
+		classes = append(classes, characters)
+	}
+	if len(classes) == 0 {
+		return "", errors.New("至少需要启用一种字符类别")
+	}
+
+	// 先从每种类别各取一个字符，其余从全部字符中选取，最后打乱顺序
+	password := make([]byte, 0, opts.Length)
+	for _, characters := range classes {
+		c, err := randomCharacter(characters)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, c)
+	}
+	all := strings.Join(classes, "")
+	for len(password) < opts.Length {
+		c, err := randomCharacter(all)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, c)
+	}
+	if err := shuffle(password); err != nil {
+		return "", err
+	}
+	return string(password), nil
+}
+
+// GeneratePassphrase 使用EFF长单词表生成口令短语，每个单词约12.9位熵
+func GeneratePassphrase(opts PassphraseOptions) (string, error) {
+	if opts.Words < MinWords || opts.Words > MaxWords {
+		return "", fmt.Errorf("单词数量必须在 %d 到 %d 之间", MinWords, MaxWords)
+	}
+
+	words, err := diceware.Generate(opts.Words)
+	if err != nil {
+		return "", fmt.Errorf("生成口令短语失败: %w", err)
+	}
+
+	if opts.Capitalize {
+		for i, word := range words {
+			words[i] = strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	if opts.IncludeNumber {
+		i, err := randomInt(len(words))
+		if err != nil {
+			return "", err
+		}
+		digit, err := randomCharacter(digitCharacters)
+		if err != nil {
+			return "", err
+		}
+		words[i] += string(digit)
+	}
+	return strings.Join(words, opts.Separator), nil
+}
+
+// removeCharacters 从字符集中移除指定字符
+func removeCharacters(characters, remove string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(remove, r) {
+			return -1
+		}
+		return r
+	}, characters)
+}
+
+// randomCharacter 从字符集中均匀随机选取一个字符
+func randomCharacter(characters string) (byte, error) {
+	i, err := randomInt(len(characters))
+	if err != nil {
+		return 0, err
+	}
+	return characters[i], nil
+}
+
+// shuffle 使用 Fisher-Yates 算法随机打乱顺序
+func shuffle(b []byte) error {
+	for i := len(b) - 1; i > 0; i-- {
+		j, err := randomInt(i + 1)
+		if err != nil {
+			return err
+		}
+		b[i], b[j] = b[j], b[i]
+	}
+	return nil
+}
+
+// randomInt 生成 [0, n) 范围内的安全随机数
+func randomInt(n int) (int, error) {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, fmt.Errorf("生成随机数失败: %w", err)
+	}
+	return int(v.Int64()), nil
+}
@@ -0,0 +1,94 @@
+package password
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    GenerateOptions
+		classes []string // 每种字符类别至少出现一次
+		wantErr bool
+	}{
+		{name: "全部字符类别", opts: GenerateOptions{Length: 20, Lowercase: true, Uppercase: true, Digits: true, Symbols: true}, classes: []string{lowercaseCharacters, uppercaseCharacters, digitCharacters, symbolCharacters}},
+		{name: "只包含数字", opts: GenerateOptions{Length: MinLength, Digits: true}, classes: []string{digitCharacters}},
+		{name: "最大长度", opts: GenerateOptions{Length: MaxLength, Lowercase: true, Uppercase: true}, classes: []string{lowercaseCharacters, uppercaseCharacters}},
+		{name: "排除容易混淆的字符", opts: GenerateOptions{Length: MaxLength, Lowercase: true, Uppercase: true, Digits: true, Symbols: true, ExcludeAmbiguous: true}, classes: []string{lowercaseCharacters, uppercaseCharacters, digitCharacters, symbolCharacters}},
+		{name: "长度过短", opts: GenerateOptions{Length: MinLength - 1, Lowercase: true}, wantErr: true},
+		{name: "长度过长", opts: GenerateOptions{Length: MaxLength + 1, Lowercase: true}, wantErr: true},
+		{name: "未启用字符类别", opts: GenerateOptions{Length: DefaultLength}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			password, err := Generate(tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Generate() error = %v，期望错误 %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if len(password) != tt.opts.Length {
+				t.Fatalf("密码长度 = %d，期望 %d", len(password), tt.opts.Length)
+			}
+			allowed := strings.Join(tt.classes, "")
+			for _, class := range tt.classes {
+				if tt.opts.ExcludeAmbiguous {
+					class = removeCharacters(class, ambiguousCharacters)
+				}
+				if !strings.ContainsAny(password, class) {
+					t.Fatalf("密码 %q 缺少字符类别 %q", password, class)
+				}
+			}
+			for _, c := range password {
+				if !strings.ContainsRune(allowed, c) {
+					t.Fatalf("密码 %q 包含未启用的字符 %q", password, c)
+				}
+				if tt.opts.ExcludeAmbiguous && strings.ContainsRune(ambiguousCharacters, c) {
+					t.Fatalf("密码 %q 包含容易混淆的字符 %q", password, c)
+				}
+			}
+		})
+	}
+}
+
+func TestGeneratePassphrase(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    PassphraseOptions
+		wantErr bool
+	}{
+		{name: "默认单词数量", opts: PassphraseOptions{Words: DefaultWords, Separator: DefaultSeparator}},
+		{name: "首字母大写并追加数字", opts: PassphraseOptions{Words: MinWords, Separator: " ", Capitalize: true, IncludeNumber: true}},
+		{name: "单词过少", opts: PassphraseOptions{Words: MinWords - 1, Separator: DefaultSeparator}, wantErr: true},
+		{name: "单词过多", opts: PassphraseOptions{Words: MaxWords + 1, Separator: DefaultSeparator}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			passphrase, err := GeneratePassphrase(tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GeneratePassphrase() error = %v，期望错误 %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			words := strings.Split(passphrase, tt.opts.Separator)
+			if len(words) != tt.opts.Words {
+				t.Fatalf("口令短语 %q 包含 %d 个单词，期望 %d 个", passphrase, len(words), tt.opts.Words)
+			}
+			var digits int
+			for _, word := range words {
+				if tt.opts.Capitalize && strings.ToUpper(word[:1]) != word[:1] {
+					t.Fatalf("单词 %q 首字母未大写", word)
+				}
+				digits += strings.IndexAny(word, digitCharacters) + 1
+			}
+			if tt.opts.IncludeNumber != (digits > 0) {
+				t.Fatalf("口令短语 %q 是否包含数字与选项不一致", passphrase)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package password
+
+import (
+	"fmt"
+	"math"
+	"unicode"
+
+	"github.com/akinoccc/hysaif/api/config"
+
+	"github.com/nbutton23/zxcvbn-go"
+)
+
+// MaxScore 强度评分上限
+const MaxScore = 4
+
+// maxEstimateLength 参与强度估算的最大字符数，估算耗时随长度增长，更长的部分不影响评分结果
+const maxEstimateLength = 100
+
+// DefaultPolicy 未配置 password_policy 时使用的内置强度策略
+var DefaultPolicy = config.PasswordPolicyConfig{MinLength: 8, MinScore: 2}
+
+// Strength 密码强度估算结果
+type Strength struct {
+	Score     int     `json:"score"`      // 强度评分，0-4，评分越高越难破解
+	Entropy   float64 `json:"entropy"`    // 估算的熵，单位为位
+	CrackTime string  `json:"crack_time"` // 估算的离线破解时间
+}
+
+// Estimate 估算密码强度，识别字典单词、键盘序列、重复、日期等常见模式；
+// userInputs 为与密码相关的用户名、信息项名称等，密码包含这些内容时评分降低
+func Estimate(password string, userInputs []string) Strength {
+	runes := []rune(password)
+	if len(runes) > maxEstimateLength {
+		runes = runes[:maxEstimateLength]
+	}
+
+	result := zxcvbn.PasswordStrength(string(runes), userInputs)
+	return Strength{
+		Score:     result.Score,
+		Entropy:   math.Round(result.Entropy*100) / 100,
+		CrackTime: result.CrackTimeDisplay,
+	}
+}
+
+// PolicyFor 获取信息项分类生效的强度策略：优先使用分类覆盖配置，其次为全局配置，默认为内置策略
+func PolicyFor(category string) config.PasswordPolicyConfig {
+	security := config.AppConfig.Security
+	if policy, ok := security.CategoryPasswordPolicies[category]; ok && category != "" {
+		return policy
+	}
+	if security.PasswordPolicy != nil {
+		return *security.PasswordPolicy
+	}
+	return DefaultPolicy
+}
+
+// Check 校验密码是否满足强度策略，返回所有不满足的规则
+func Check(password string, policy config.PasswordPolicyConfig, userInputs []string) []string {
+	var violations []string
+
+	if length := len([]rune(password)); length < policy.MinLength {
+		violations = append(violations, fmt.Sprintf("密码长度不能少于%d个字符", policy.MinLength))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			hasSymbol = true
+		}
+	}
+	if policy.RequireUppercase && !hasUpper {
+		violations = append(violations, "密码必须包含大写字母")
+	}
+	if policy.RequireLowercase && !hasLower {
+		violations = append(violations, "密码必须包含小写字母")
+	}
+	if policy.RequireDigits && !hasDigit {
+		violations = append(violations, "密码必须包含数字")
+	}
+	if policy.RequireSymbols && !hasSymbol {
+		violations = append(violations, "密码必须包含特殊字符")
+	}
+
+	if policy.MinScore > 0 {
+		if strength := Estimate(password, userInputs); strength.Score < policy.MinScore {
+			violations = append(violations, fmt.Sprintf("密码强度不足：评分%d，要求至少%d，预计破解时间 %s",
+				strength.Score, policy.MinScore, strength.CrackTime))
+		}
+	}
+	return violations
+}
+
+// ValidatePolicy 校验强度策略的取值范围
+func ValidatePolicy(policy config.PasswordPolicyConfig) error {
+	if policy.MinLength < 0 || policy.MinLength > MaxLength {
+		return fmt.Errorf("最小长度必须在 0 到 %d 之间", MaxLength)
+	}
+	if policy.MinScore < 0 || policy.MinScore > MaxScore {
+		return fmt.Errorf("最低强度评分必须在 0 到 %d 之间", MaxScore)
+	}
+	return nil
+}
+
+// ValidateConfiguredPolicies 校验全局及各分类配置的强度策略
+func ValidateConfiguredPolicies() error {
+	security := config.AppConfig.Security
+	if security.PasswordPolicy != nil {
+		if err := ValidatePolicy(*security.PasswordPolicy); err != nil {
+			return err
+		}
+	}
+	for category, policy := range security.CategoryPasswordPolicies {
+		if err := ValidatePolicy(policy); err != nil {
+			return fmt.Errorf("分类 '%s': %w", category, err)
+		}
+	}
+	return nil
+}
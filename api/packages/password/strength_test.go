@@ -0,0 +1,111 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/akinoccc/hysaif/api/config"
+)
+
+func TestCheck(t *testing.T) {
+	strict := config.PasswordPolicyConfig{MinLength: 12, MinScore: 3, RequireUppercase: true, RequireLowercase: true, RequireDigits: true, RequireSymbols: true}
+
+	tests := []struct {
+		name           string
+		password       string
+		policy         config.PasswordPolicyConfig
+		userInputs     []string
+		wantViolations []string
+	}{
+		{name: "满足严格策略", password: "Vq7#mZp2!rLx9@Tb", policy: strict},
+		{name: "长度不足", password: "Aa1!", policy: config.PasswordPolicyConfig{MinLength: 8}, wantViolations: []string{"长度不能少于8"}},
+		{name: "缺少各字符类别", password: "correcthorsebatterystaple", policy: config.PasswordPolicyConfig{RequireUppercase: true, RequireDigits: true, RequireSymbols: true}, wantViolations: []string{"大写字母", "数字", "特殊字符"}},
+		{name: "缺少小写字母", password: "CORRECT-HORSE-42", policy: config.PasswordPolicyConfig{RequireLowercase: true}, wantViolations: []string{"小写字母"}},
+		{name: "常见弱密码", password: "Password1!", policy: DefaultPolicy, wantViolations: []string{"密码强度不足"}},
+		{name: "键盘序列", password: "qwertyuiop", policy: DefaultPolicy, wantViolations: []string{"密码强度不足"}},
+		{name: "包含用户相关内容", password: "payments-db-2024", policy: config.PasswordPolicyConfig{MinScore: 3}, userInputs: []string{"payments-db"}, wantViolations: []string{"密码强度不足"}},
+		{name: "不校验强度评分", password: "password", policy: config.PasswordPolicyConfig{MinLength: 8}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := Check(tt.password, tt.policy, tt.userInputs)
+			if len(violations) != len(tt.wantViolations) {
+				t.Fatalf("Check() = %q，期望 %d 条不满足的规则", violations, len(tt.wantViolations))
+			}
+			for i, want := range tt.wantViolations {
+				if !strings.Contains(violations[i], want) {
+					t.Fatalf("Check()[%d] = %q，期望包含 %q", i, violations[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestEstimateLimitsLength(t *testing.T) {
+	long := strings.Repeat("Vq7#mZp2!rLx9@Tb", 20)
+	if got, want := Estimate(long, nil), Estimate(long[:maxEstimateLength], nil); got != want {
+		t.Fatalf("Estimate() = %+v，期望与前%d个字符的结果 %+v 一致", got, maxEstimateLength, want)
+	}
+}
+
+func TestPolicyFor(t *testing.T) {
+	global := config.PasswordPolicyConfig{MinLength: 16, MinScore: 3}
+	production := config.PasswordPolicyConfig{MinLength: 24, MinScore: 4, RequireSymbols: true}
+
+	tests := []struct {
+		name     string
+		global   *config.PasswordPolicyConfig
+		category string
+		want     config.PasswordPolicyConfig
+	}{
+		{"未配置时使用内置策略", nil, "production", DefaultPolicy},
+		{"使用全局策略", &global, "", global},
+		{"分类覆盖全局策略", &global, "production", production},
+		{"未覆盖的分类使用全局策略", &global, "staging", global},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.AppConfig = &config.Config{}
+			config.AppConfig.Security.PasswordPolicy = tt.global
+			if tt.global != nil {
+				config.AppConfig.Security.CategoryPasswordPolicies = map[string]config.PasswordPolicyConfig{"production": production}
+			}
+			if got := PolicyFor(tt.category); got != tt.want {
+				t.Fatalf("PolicyFor(%q) = %+v，期望 %+v", tt.category, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateConfiguredPolicies(t *testing.T) {
+	tests := []struct {
+		name       string
+		global     *config.PasswordPolicyConfig
+		categories map[string]config.PasswordPolicyConfig
+		wantErr    string
+	}{
+		{name: "有效配置", global: &config.PasswordPolicyConfig{MinLength: 12, MinScore: 3}, categories: map[string]config.PasswordPolicyConfig{"production": {MinLength: MaxLength, MinScore: MaxScore}}},
+		{name: "未配置策略"},
+		{name: "最小长度超出范围", global: &config.PasswordPolicyConfig{MinLength: MaxLength + 1}, wantErr: "最小长度"},
+		{name: "分类评分超出范围", categories: map[string]config.PasswordPolicyConfig{"production": {MinScore: MaxScore + 1}}, wantErr: "分类 'production'"},
+		{name: "负数评分", global: &config.PasswordPolicyConfig{MinScore: -1}, wantErr: "最低强度评分"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.AppConfig = &config.Config{}
+			config.AppConfig.Security.PasswordPolicy = tt.global
+			config.AppConfig.Security.CategoryPasswordPolicies = tt.categories
+
+			err := ValidateConfiguredPolicies()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ValidateConfiguredPolicies() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ValidateConfiguredPolicies() error = %v，期望包含 %q", err, tt.wantErr)
+			}
+		})
+	}
+}
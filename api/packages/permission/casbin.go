@@ -66,14 +66,88 @@ func (cm *CasbinManager) initCasbin() {
 	policies, err := enforcer.GetPolicy()
 	if err != nil {
 		log.Printf("Error getting policy: %v", err)
-	} else if len(policies) == 0 {
-		cm.initPoliciesFromCSV(enforcer)
+	} else {
+		if len(policies) == 0 {
+			cm.initPoliciesFromCSV(enforcer)
+		}
+		cm.upgradePolicies(enforcer, len(policies) == 0)
 	}
 
 	cm.enforcer = enforcer
 	log.Println("Casbin initialized successfully with GORM adapter")
 }
 
+// policyUpgrade 后续版本新增的默认策略，已有部署启动时补充一次；管理员之后删除的策略不会再次添加
+type policyUpgrade struct {
+	name     string
+	policies [][]string
+}
+
+// policyUpgrades 按发布顺序排列的默认策略升级，新增的策略同时需要写入 initPoliciesFromCSV
+var policyUpgrades = []policyUpgrade{
+	{name: "secret_lookup", policies: [][]string{{"sec_mgr", "secret", "lookup"}}},
+	{name: "key_management_read", policies: [][]string{{"sec_mgr", "key_management", "read"}}},
+	{name: "transit", policies: [][]string{
+		{"sec_mgr", "transit", "read"},
+		{"sec_mgr", "transit", "create"},
+		{"sec_mgr", "transit", "rotate"},
+		{"sec_mgr", "transit", "update"},
+	}},
+	{name: "secret_type", policies: [][]string{
+		{"sec_mgr", "secret_type", "create"},
+		{"sec_mgr", "secret_type", "update"},
+		{"sec_mgr", "secret_type", "delete"},
+	}},
+	{name: "folder", policies: [][]string{
+		{"sec_mgr", "folder", "read"},
+		{"sec_mgr", "folder", "create"},
+		{"sec_mgr", "folder", "delete"},
+	}},
+}
+
+// appliedPolicyUpgrade 已执行的默认策略升级
+type appliedPolicyUpgrade struct {
+	Name string `gorm:"type:varchar(64);primaryKey"`
+}
+
+// TableName 指定表名
+func (appliedPolicyUpgrade) TableName() string {
+	return "casbin_policy_upgrades"
+}
+
+// upgradePolicies 为已有部署补充后续版本新增的默认策略，新部署的初始策略已包含这些策略，只记录为已执行
+func (cm *CasbinManager) upgradePolicies(enforcer *casbin.Enforcer, fresh bool) {
+	if err := cm.db.AutoMigrate(&appliedPolicyUpgrade{}); err != nil {
+		log.Printf("Failed to migrate policy upgrades: %v", err)
+		return
+	}
+
+	for _, upgrade := range policyUpgrades {
+		var count int64
+		if err := cm.db.Model(&appliedPolicyUpgrade{}).Where("name = ?", upgrade.name).Count(&count).Error; err != nil {
+			log.Printf("Failed to check policy upgrade %s: %v", upgrade.name, err)
+			return
+		}
+		if count > 0 {
+			continue
+		}
+
+		if !fresh {
+			for _, policy := range upgrade.policies {
+				if _, err := enforcer.AddPolicy(policy); err != nil {
+					log.Printf("Failed to add policy %v: %v", policy, err)
+					return
+				}
+			}
+			log.Printf("Policy upgrade %s applied", upgrade.name)
+		}
+		if err := cm.db.Create(&appliedPolicyUpgrade{Name: upgrade.name}).Error; err != nil {
+			log.Printf("Failed to record policy upgrade %s: %v", upgrade.name, err)
+			return
+		}
+	}
+}
+
 // initPoliciesFromCSV 从CSV文件初始化策略到数据库
 func (cm *CasbinManager) initPoliciesFromCSV(enforcer *casbin.Enforcer) {
 	log.Println("Initializing policies from CSV file...")
@@ -94,6 +168,7 @@ func (cm *CasbinManager) initPoliciesFromCSV(enforcer *casbin.Enforcer) {
 		{"sec_mgr", "secret", "create"},
 		{"sec_mgr", "secret", "update"},
 		{"sec_mgr", "secret", "delete"},
+		{"sec_mgr", "secret", "lookup"},
 		{"sec_mgr", "access_request", "read"},
 		{"sec_mgr", "access_request", "approve"},
 		{"sec_mgr", "access_request", "reject"},
@@ -101,6 +176,17 @@ func (cm *CasbinManager) initPoliciesFromCSV(enforcer *casbin.Enforcer) {
 		{"sec_mgr", "notification", "create"},
 		{"sec_mgr", "notification", "bulk_send"},
 		{"sec_mgr", "notification", "view_templates"},
+		{"sec_mgr", "key_management", "read"},
+		{"sec_mgr", "transit", "read"},
+		{"sec_mgr", "transit", "create"},
+		{"sec_mgr", "transit", "rotate"},
+		{"sec_mgr", "transit", "update"},
+		{"sec_mgr", "secret_type", "create"},
+		{"sec_mgr", "secret_type", "update"},
+		{"sec_mgr", "secret_type", "delete"},
+		{"sec_mgr", "folder", "read"},
+		{"sec_mgr", "folder", "create"},
+		{"sec_mgr", "folder", "delete"},
 
 		// 开发人员权限
 		{"dev", "dashboard", "read"},
@@ -0,0 +1,71 @@
+package permission
+
+import (
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	gormadapter "github.com/casbin/gorm-adapter/v3"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestUpgradePoliciesAppliesOnce(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:casbin_upgrade_test?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	adapter, err := gormadapter.NewAdapterByDB(db)
+	if err != nil {
+		t.Fatalf("创建适配器失败: %v", err)
+	}
+	enforcer, err := casbin.NewEnforcer("../../rbac_model.conf", adapter)
+	if err != nil {
+		t.Fatalf("创建执行器失败: %v", err)
+	}
+	cm := &CasbinManager{db: db}
+
+	// 已有部署的策略中没有 secret:lookup
+	if _, err := enforcer.AddPolicy("sec_mgr", "secret", "read"); err != nil {
+		t.Fatal(err)
+	}
+	cm.upgradePolicies(enforcer, false)
+	if ok, _ := enforcer.HasPolicy("sec_mgr", "secret", "lookup"); !ok {
+		t.Fatal("升级后期望补充 sec_mgr 的 secret:lookup 策略")
+	}
+
+	// 管理员删除的策略不会再次添加
+	if _, err := enforcer.RemovePolicy("sec_mgr", "secret", "lookup"); err != nil {
+		t.Fatal(err)
+	}
+	cm.upgradePolicies(enforcer, false)
+	if ok, _ := enforcer.HasPolicy("sec_mgr", "secret", "lookup"); ok {
+		t.Fatal("已执行的升级不应再次添加策略")
+	}
+}
+
+func TestPolicyUpgradesIncludedInInitialPolicies(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:casbin_init_test?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	adapter, err := gormadapter.NewAdapterByDB(db)
+	if err != nil {
+		t.Fatalf("创建适配器失败: %v", err)
+	}
+	enforcer, err := casbin.NewEnforcer("../../rbac_model.conf", adapter)
+	if err != nil {
+		t.Fatalf("创建执行器失败: %v", err)
+	}
+	cm := &CasbinManager{db: db}
+	cm.initPoliciesFromCSV(enforcer)
+
+	// 新部署只记录升级为已执行，升级中的策略必须已包含在初始策略中
+	for _, upgrade := range policyUpgrades {
+		for _, policy := range upgrade.policies {
+			if ok, _ := enforcer.HasPolicy(policy); !ok {
+				t.Errorf("升级 %s 的策略 %v 未包含在初始策略中", upgrade.name, policy)
+			}
+		}
+	}
+}
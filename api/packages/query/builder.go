@@ -131,6 +131,25 @@ func (qb *QueryBuilder) SecretStatusFilter() *QueryBuilder {
 	return qb
 }
 
+// FolderFilter 文件夹过滤器，folder_id 只匹配该文件夹中的信息项（root 表示根目录），folder 匹配该路径的文件夹及其所有子文件夹
+func (qb *QueryBuilder) FolderFilter() *QueryBuilder {
+	if folderID := qb.ctx.Query("folder_id"); folderID == "root" {
+		qb.query = qb.query.Where("(folder_id = '' OR folder_id IS NULL)")
+	} else {
+		qb.WhereIf(folderID != "", "folder_id = ?", folderID)
+	}
+
+	if path := qb.ctx.Query("folder"); path != "" {
+		folderIDs, err := models.GetSubtreeFolderIDs([]string{path})
+		if err != nil {
+			qb.query.AddError(err)
+			return qb
+		}
+		qb.query = qb.query.Where("folder_id IN ?", folderIDs)
+	}
+	return qb
+}
+
 // Preload 预加载关联数据
 func (qb *QueryBuilder) Preload(associations ...string) *QueryBuilder {
 	for _, assoc := range associations {
@@ -182,8 +201,9 @@ func (qb *QueryBuilder) ApplySecretItemFilters() *QueryBuilder {
 		StringFilter("category", "category").
 		StringFilter("type", "type").
 		StringFilter("environment", "environment").
+		FolderFilter().
 		LikeFilter("tags", "tag").
-		MultiLikeFilter([]string{"name", "description"}, "search").
+		MultiLikeFilter([]string{"name", "description", "metadata"}, "search").
 		DateRangeFilter("created_at", "created_at_from", "created_at_to").
 		SecretStatusFilter().
 		InFilterByName("created_by", "creator_name", "name", "users")
@@ -203,7 +223,8 @@ func (qb *QueryBuilder) ApplyAccessRequestFilters() *QueryBuilder {
 		StringFilter("status", "status").
 		DateRangeFilter("created_at", "created_at_from", "created_at_to").
 		InFilterByName("applicant_id", "applicant_name", "name", "users").
-		InFilterByName("secret_item_id", "secret_item_name", "name", "secret_items")
+		InFilterByName("secret_item_id", "secret_item_name", "name", "secret_items").
+		StringFilter("folder_id", "folder_id")
 }
 
 // ApplyAuditLogFilters 应用审计日志过滤器
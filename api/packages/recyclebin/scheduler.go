@@ -0,0 +1,95 @@
+// Package recyclebin 定时清除回收站中超过保留期限的信息项
+package recyclebin
+
+import (
+	"log"
+	"time"
+
+	"github.com/akinoccc/hysaif/api/models"
+	"github.com/akinoccc/hysaif/api/packages/crypto"
+)
+
+// checkInterval 检查超过保留期限的信息项的间隔
+const checkInterval = time.Hour
+
+// Event 定时清除的结果
+type Event struct {
+	SecretItemID   string // 信息项ID
+	SecretItemType string // 信息项类型
+	Name           string // 信息项名称
+	DeletedByID    string // 将信息项移入回收站的用户ID
+	DeletedAt      uint64 // 移入回收站的时间
+	Error          string // 失败原因
+}
+
+var (
+	stopChan         = make(chan struct{})
+	onScheduledPurge func(Event)
+)
+
+// OnScheduledPurge 设置定时清除完成后的回调，用于记录审计日志
+func OnScheduledPurge(fn func(Event)) {
+	onScheduledPurge = fn
+}
+
+// Start 启动定时清除任务，启动时立即检查一次
+func Start() {
+	log.Println("启动回收站清除服务")
+	go run()
+}
+
+// Stop 停止定时清除任务
+func Stop() {
+	log.Println("停止回收站清除服务")
+	close(stopChan)
+}
+
+func run() {
+	purgeExpired()
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			purgeExpired()
+		case <-stopChan:
+			return
+		}
+	}
+}
+
+// purgeExpired 依次清除超过保留期限的信息项，失败的信息项在下次检查时重试；
+// 密封状态下无法访问Vault KV，跳过本次检查
+func purgeExpired() {
+	if crypto.IsSealed() {
+		return
+	}
+
+	items, err := models.ExpiredDeletedSecretItems(time.Now())
+	if err != nil {
+		log.Printf("查询超过保留期限的信息项失败: %v", err)
+		return
+	}
+
+	for i := range items {
+		item := &items[i]
+		event := Event{
+			SecretItemID:   item.ID,
+			SecretItemType: item.Type,
+			Name:           item.Name,
+			DeletedByID:    item.DeletedByID,
+			DeletedAt:      uint64(item.DeletedAt.Time.UnixMilli()),
+		}
+
+		if err := item.Purge(); err != nil {
+			log.Printf("清除信息项 %s 失败: %v", item.ID, err)
+			event.Error = err.Error()
+		}
+
+		if onScheduledPurge != nil {
+			onScheduledPurge(event)
+		}
+	}
+}
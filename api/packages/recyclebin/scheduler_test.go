@@ -0,0 +1,97 @@
+package recyclebin
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/akinoccc/hysaif/api/config"
+	"github.com/akinoccc/hysaif/api/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm/logger"
+)
+
+// TestMain 使用内存SQLite数据库和本地AES主密钥初始化回收站测试
+func TestMain(m *testing.M) {
+	config.AppConfig = &config.Config{}
+	config.AppConfig.Database.Type = "sqlite"
+	config.AppConfig.Database.Path = "file:recyclebin_test?mode=memory&cache=shared"
+	config.AppConfig.Security.EncryptionKey = "0123456789abcdef0123456789abcdef"
+	config.AppConfig.Security.KeyProvider = "aes"
+
+	models.InitDB()
+	models.DB.Logger = logger.Discard
+
+	os.Exit(m.Run())
+}
+
+func TestPurgeExpired(t *testing.T) {
+	tests := []struct {
+		name          string
+		retentionDays int
+		deletedAgo    time.Duration // 移入回收站的时长，为0时不移入回收站
+		wantPurged    bool
+	}{
+		{"超过默认保留期限的信息项被清除", 0, 31 * 24 * time.Hour, true},
+		{"默认保留期限内的信息项保留", 0, 29 * 24 * time.Hour, false},
+		{"超过配置的保留期限的信息项被清除", 7, 8 * 24 * time.Hour, true},
+		{"配置的保留期限内的信息项保留", 7, 6 * 24 * time.Hour, false},
+		{"未删除的信息项不受影响", 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.AppConfig.RecycleBin.RetentionDays = tt.retentionDays
+			t.Cleanup(func() { config.AppConfig.RecycleBin.RetentionDays = 0 })
+
+			deletedByID := uuid.NewString()
+			item := &models.SecretItem{Name: "recycle-" + uuid.NewString()[:8], Type: "token", Environment: "development", Data: &models.SecretItemData{Token: "tok-recycled"}}
+			if err := models.DB.Create(item).Error; err != nil {
+				t.Fatalf("创建信息项失败: %v", err)
+			}
+			if err := item.CreateHistory(models.HistoryChangeTypeUpdated, "测试", deletedByID); err != nil {
+				t.Fatalf("创建历史版本失败: %v", err)
+			}
+			if tt.deletedAgo > 0 {
+				if err := item.MoveToRecycleBin(deletedByID); err != nil {
+					t.Fatalf("移入回收站失败: %v", err)
+				}
+				if err := models.DB.Unscoped().Model(&models.SecretItem{}).Where("id = ?", item.ID).
+					UpdateColumn("deleted_at", time.Now().Add(-tt.deletedAgo)).Error; err != nil {
+					t.Fatalf("修改删除时间失败: %v", err)
+				}
+			}
+
+			// 之前用例保留的信息项可能在保留期限缩短后被清除，只记录本用例的信息项
+			var events []Event
+			OnScheduledPurge(func(event Event) {
+				if event.SecretItemID == item.ID {
+					events = append(events, event)
+				}
+			})
+			t.Cleanup(func() { OnScheduledPurge(nil) })
+			purgeExpired()
+
+			var items, histories int64
+			models.DB.Unscoped().Model(&models.SecretItem{}).Where("id = ?", item.ID).Count(&items)
+			models.DB.Model(&models.SecretItemHistory{}).Where("secret_item_id = ?", item.ID).Count(&histories)
+			if purged := items == 0 && histories == 0; purged != tt.wantPurged {
+				t.Fatalf("信息项已清除 = %v（剩余 %d 条记录、%d 条历史版本），期望 %v", purged, items, histories, tt.wantPurged)
+			}
+
+			if !tt.wantPurged {
+				if len(events) != 0 {
+					t.Fatalf("期望没有清除事件，实际 %+v", events)
+				}
+				return
+			}
+			if len(events) != 1 {
+				t.Fatalf("期望1个清除事件，实际 %+v", events)
+			}
+			event := events[0]
+			if event.SecretItemID != item.ID || event.Name != item.Name || event.DeletedByID != deletedByID || event.Error != "" {
+				t.Fatalf("清除事件 = %+v，期望信息项 %s 由 %s 删除且没有错误", event, item.ID, deletedByID)
+			}
+		})
+	}
+}
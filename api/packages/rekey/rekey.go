@@ -0,0 +1,484 @@
+package rekey
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/akinoccc/hysaif/api/models"
+	"github.com/akinoccc/hysaif/api/packages/crypto"
+)
+
+// batchSize 每批处理的记录数，每批处理完成后保存断点
+const batchSize = 100
+
+// encryptedTables 需要重新包装的加密数据表
+var encryptedTables = []string{models.SecretItemTable, models.SecretItemHistoryTable, models.TransitKeyVersionTable, models.DatabaseLeaseTable}
+
+// ErrJobRunning 已有密钥轮换任务在执行
+var ErrJobRunning = errors.New("已有密钥轮换任务正在执行")
+
+var (
+	mu      sync.Mutex
+	running bool
+)
+
+// encryptedRow 加密数据行，直接读取原始密文，不经过 SecretItemData 的解密逻辑
+type encryptedRow struct {
+	ID          string
+	Data        *string
+	Environment string
+}
+
+// Start 创建并在后台启动密钥轮换任务，将所有加密数据重新包装到当前活动主密钥
+func Start(createdByID string) (*models.KeyRotationJob, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if running {
+		return nil, ErrJobRunning
+	}
+
+	info, err := crypto.ActiveKeyInfo()
+	if err != nil {
+		return nil, fmt.Errorf("获取活动主密钥失败: %w", err)
+	}
+
+	job, err := newJob(models.KeyRotationTypeMasterKey, info.Provider, info.KeyVersion, createdByID)
+	if err != nil {
+		return nil, err
+	}
+	return startJob(job)
+}
+
+// StartVaultRewrap 轮换Transit密钥（可选），然后在后台通过 transit/rewrap 将所有Vault密文重新包装到各自Transit密钥的最新版本；
+// keyName 为空时轮换全局及所有环境独立的Transit密钥
+func StartVaultRewrap(createdByID, keyName string, rotate bool) (*models.KeyRotationJob, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if running {
+		return nil, ErrJobRunning
+	}
+
+	keyNames := crypto.TransitKeyNames()
+	versions := make(map[string]int, len(keyNames))
+	for _, name := range keyNames {
+		var (
+			keyInfo *crypto.TransitKeyInfo
+			err     error
+		)
+		if rotate && (keyName == "" || keyName == name) {
+			keyInfo, err = crypto.RotateTransitKey(name)
+		} else {
+			keyInfo, err = crypto.GetTransitKeyInfo(name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		versions[name] = keyInfo.LatestVersion
+	}
+
+	job, err := newJob(models.KeyRotationTypeVaultRewrap, crypto.ProviderVault, strconv.Itoa(versions[keyNames[0]]), createdByID)
+	if err != nil {
+		return nil, err
+	}
+	job.TransitKeyVersions = versions
+	return startJob(job)
+}
+
+// newJob 统计需要检查的记录总数并构造任务记录
+func newJob(jobType, providerID, version, createdByID string) (*models.KeyRotationJob, error) {
+	var total int64
+	for _, table := range encryptedTables {
+		var count int64
+		if err := models.DB.Table(table).Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("统计数据表 %s 失败: %w", table, err)
+		}
+		total += count
+	}
+
+	return &models.KeyRotationJob{
+		Type:           jobType,
+		Status:         models.KeyRotationStatusRunning,
+		TargetProvider: providerID,
+		TargetVersion:  version,
+		CurrentTable:   encryptedTables[0],
+		TotalRows:      total,
+		StartedAt:      uint64(time.Now().UnixMilli()),
+		CreatedByID:    createdByID,
+	}, nil
+}
+
+// startJob 保存任务记录并启动后台执行，调用方需持有锁
+func startJob(job *models.KeyRotationJob) (*models.KeyRotationJob, error) {
+	if err := models.DB.Create(job).Error; err != nil {
+		return nil, fmt.Errorf("创建密钥轮换任务失败: %w", err)
+	}
+
+	running = true
+	go run(job)
+
+	return job, nil
+}
+
+// Resume 恢复服务重启前中断或因密封暂停的密钥轮换任务，应在数据库初始化后及解封后调用
+func Resume() {
+	if crypto.IsSealed() {
+		return
+	}
+
+	var job models.KeyRotationJob
+	err := models.DB.Where("status = ?", models.KeyRotationStatusRunning).
+		Order("created_at DESC").
+		First(&job).Error
+	if err != nil {
+		return
+	}
+
+	mu.Lock()
+	if running {
+		mu.Unlock()
+		return
+	}
+	running = true
+	mu.Unlock()
+
+	log.Printf("恢复密钥轮换任务 (ID: %s)，断点: %s/%s", job.ID, job.CurrentTable, job.LastID)
+	go run(&job)
+}
+
+// GetLatestJob 获取最近一次密钥轮换任务，jobType为空时不限类型
+func GetLatestJob(jobType string) (*models.KeyRotationJob, error) {
+	query := models.DB.Preload("Creator").Order("created_at DESC")
+	if jobType != "" {
+		query = query.Where("type = ?", jobType)
+	}
+
+	var job models.KeyRotationJob
+	if err := query.First(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// SafeMinDecryptionVersion 根据重新包装任务计算Transit密钥可安全设置的最低解密版本，任务未完整成功或未包含该密钥时返回0
+func SafeMinDecryptionVersion(job *models.KeyRotationJob, keyName string) int {
+	if job.Type != models.KeyRotationTypeVaultRewrap ||
+		job.Status != models.KeyRotationStatusCompleted ||
+		job.FailedRows > 0 {
+		return 0
+	}
+
+	if version := job.MinKeyVersions[keyName]; version > 0 {
+		return version
+	}
+
+	// 没有该密钥的密文时，最新版本即为安全版本
+	return job.TransitKeyVersions[keyName]
+}
+
+// IsRunning 检查是否有密钥轮换任务正在执行
+func IsRunning() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return running
+}
+
+// run 从断点开始依次处理各数据表
+func run(job *models.KeyRotationJob) {
+	defer func() {
+		mu.Lock()
+		running = false
+		mu.Unlock()
+	}()
+
+	log.Printf("开始执行密钥轮换任务 (ID: %s)，目标: %s/%s", job.ID, job.TargetProvider, job.TargetVersion)
+
+	startIndex := 0
+	for i, table := range encryptedTables {
+		if table == job.CurrentTable {
+			startIndex = i
+		}
+	}
+
+	for i := startIndex; i < len(encryptedTables); i++ {
+		// 从断点所在的数据表继续，之后的数据表从头开始
+		if i != startIndex {
+			job.CurrentTable = encryptedTables[i]
+			job.LastID = ""
+		}
+
+		if err := processTable(job); err != nil {
+			// 系统被密封时保留断点，解封后自动恢复
+			if errors.Is(err, crypto.ErrSealed) {
+				saveJob(job)
+				log.Printf("系统已密封，密钥轮换任务暂停 (ID: %s)，解封后自动恢复", job.ID)
+				return
+			}
+			job.Status = models.KeyRotationStatusFailed
+			job.LastError = err.Error()
+			job.FinishedAt = uint64(time.Now().UnixMilli())
+			saveJob(job)
+			log.Printf("密钥轮换任务失败 (ID: %s): %v", job.ID, err)
+			return
+		}
+	}
+
+	job.Status = models.KeyRotationStatusCompleted
+	job.FinishedAt = uint64(time.Now().UnixMilli())
+	saveJob(job)
+
+	log.Printf("密钥轮换任务完成 (ID: %s)，检查 %d 条，重新包装 %d 条，失败 %d 条",
+		job.ID, job.ProcessedRows, job.RewrappedRows, job.FailedRows)
+}
+
+// processTable 分批处理当前数据表，每批完成后保存断点
+func processTable(job *models.KeyRotationJob) error {
+	for {
+		if crypto.IsSealed() {
+			return crypto.ErrSealed
+		}
+
+		rows, err := readBatch(job.CurrentTable, job.LastID)
+		if err != nil {
+			return fmt.Errorf("读取数据表 %s 失败: %w", job.CurrentTable, err)
+		}
+
+		if len(rows) == 0 {
+			return nil
+		}
+
+		switch job.Type {
+		case models.KeyRotationTypeVaultRewrap:
+			if err := rewrapVaultBatch(job, rows); err != nil {
+				return err
+			}
+		default:
+			rewrapBatch(job, rows)
+		}
+
+		job.ProcessedRows += int64(len(rows))
+		job.LastID = rows[len(rows)-1].ID
+
+		if err := saveJob(job); err != nil {
+			return err
+		}
+	}
+}
+
+// readBatch 按ID顺序读取一批加密数据行，临时用户密码所属的环境取自对应的数据库连接信息项
+func readBatch(table, lastID string) ([]encryptedRow, error) {
+	query := models.DB.Table(table).Select(selectColumns(table))
+	if table == models.DatabaseLeaseTable {
+		query = query.Joins("LEFT JOIN secret_items ON secret_items.id = database_leases.secret_item_id")
+	}
+
+	var rows []encryptedRow
+	err := query.
+		Where(table+".id > ?", lastID).
+		Order(table + ".id").
+		Limit(batchSize).
+		Find(&rows).Error
+	return rows, err
+}
+
+// selectColumns 读取加密数据行所需的列，密文统一读取为 data；托管密钥版本不区分环境
+func selectColumns(table string) string {
+	switch table {
+	case models.TransitKeyVersionTable:
+		return "id, data"
+	case models.DatabaseLeaseTable:
+		return "database_leases.id, database_leases.password AS data, secret_items.environment"
+	}
+	return "id, data, environment"
+}
+
+// dataColumn 密文所在的列，同时也是密文绑定的字段名
+func dataColumn(table string) string {
+	if table == models.DatabaseLeaseTable {
+		return models.DatabaseLeasePasswordField
+	}
+	return models.SecretDataField
+}
+
+// rewrapBatch 将一批记录中的数据密钥重新包装到活动主密钥
+func rewrapBatch(job *models.KeyRotationJob, rows []encryptedRow) {
+	for _, row := range rows {
+		rewrapped, err := rewrapRow(job.CurrentTable, row)
+		if err != nil {
+			recordFailure(job, row.ID, err)
+		} else if rewrapped {
+			job.RewrappedRows++
+		}
+	}
+}
+
+// rewrapRow 在需要时使用活动主密钥重新包装单行数据
+func rewrapRow(table string, row encryptedRow) (bool, error) {
+	if row.Data == nil || *row.Data == "" {
+		return false, nil
+	}
+
+	opts := rowOptions(table, row)
+	needsRewrap, err := crypto.NeedsRewrap(*row.Data, opts)
+	if err != nil {
+		return false, err
+	}
+	if !needsRewrap {
+		return false, nil
+	}
+
+	rewrapped, err := crypto.Rewrap(*row.Data, opts)
+	if err != nil {
+		return false, err
+	}
+
+	return updateRow(table, row, rewrapped)
+}
+
+// vaultBatch 同一Transit密钥下待重新包装的密文
+type vaultBatch struct {
+	rows        []encryptedRow
+	ciphertexts []string
+	contexts    [][]byte
+}
+
+// rewrapVaultBatch 按Transit密钥分组收集一批记录中低于目标版本的Vault密文，每个密钥通过一次 transit/rewrap 请求重新包装，
+// 并按密钥记录仍在使用的最低版本
+func rewrapVaultBatch(job *models.KeyRotationJob, rows []encryptedRow) error {
+	var (
+		keyNames []string
+		batches  = make(map[string]*vaultBatch)
+	)
+	for _, row := range rows {
+		if row.Data == nil {
+			continue
+		}
+		providerID, ciphertext, context, ok := crypto.ExtractVaultCiphertext(*row.Data, rowOptions(job.CurrentTable, row))
+		if !ok {
+			continue
+		}
+
+		keyName, err := crypto.TransitKeyName(providerID)
+		if err != nil {
+			recordFailure(job, row.ID, err)
+			continue
+		}
+		targetVersion, ok := job.TransitKeyVersions[keyName]
+		if !ok {
+			recordFailure(job, row.ID, fmt.Errorf("Transit密钥 %s 不在任务范围内", keyName))
+			continue
+		}
+
+		version := crypto.VaultCiphertextVersion(ciphertext)
+		if version >= targetVersion {
+			trackMinVersion(job, keyName, version)
+			continue
+		}
+
+		batch, ok := batches[keyName]
+		if !ok {
+			batch = &vaultBatch{}
+			batches[keyName] = batch
+			keyNames = append(keyNames, keyName)
+		}
+		batch.rows = append(batch.rows, row)
+		batch.ciphertexts = append(batch.ciphertexts, ciphertext)
+		batch.contexts = append(batch.contexts, context)
+	}
+
+	for _, keyName := range keyNames {
+		if err := rewrapVaultKeyBatch(job, keyName, batches[keyName]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewrapVaultKeyBatch 通过一次 transit/rewrap 请求重新包装同一Transit密钥下的密文并写回
+func rewrapVaultKeyBatch(job *models.KeyRotationJob, keyName string, batch *vaultBatch) error {
+	results, errs, err := crypto.RewrapWithVault(keyName, batch.ciphertexts, batch.contexts)
+	if err != nil {
+		return err
+	}
+
+	for i, row := range batch.rows {
+		if errs[i] != nil {
+			recordFailure(job, row.ID, errs[i])
+			trackMinVersion(job, keyName, crypto.VaultCiphertextVersion(batch.ciphertexts[i]))
+			continue
+		}
+
+		newData, err := crypto.ReplaceVaultCiphertext(*row.Data, results[i])
+		if err != nil {
+			recordFailure(job, row.ID, err)
+			trackMinVersion(job, keyName, crypto.VaultCiphertextVersion(batch.ciphertexts[i]))
+			continue
+		}
+
+		updated, err := updateRow(job.CurrentTable, row, newData)
+		if err != nil {
+			recordFailure(job, row.ID, err)
+			trackMinVersion(job, keyName, crypto.VaultCiphertextVersion(batch.ciphertexts[i]))
+			continue
+		}
+		if updated {
+			job.RewrappedRows++
+		}
+		// 行在处理期间被修改时，新数据已使用最新版本加密
+		trackMinVersion(job, keyName, crypto.VaultCiphertextVersion(results[i]))
+	}
+
+	return nil
+}
+
+// rowOptions 构造数据行的加密选项，密文与数据行绑定
+func rowOptions(table string, row encryptedRow) crypto.Options {
+	return crypto.Options{Environment: row.Environment, Table: table, ID: row.ID, Field: dataColumn(table)}
+}
+
+// updateRow 写回重新包装后的密文，仅当密文未被并发修改时才写入
+func updateRow(table string, row encryptedRow, newData string) (bool, error) {
+	column := dataColumn(table)
+	result := models.DB.Table(table).
+		Where("id = ? AND "+column+" = ?", row.ID, *row.Data).
+		UpdateColumn(column, newData)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	// 行在处理期间被修改时，新数据已使用活动主密钥加密
+	return result.RowsAffected > 0, nil
+}
+
+// recordFailure 记录单行处理失败
+func recordFailure(job *models.KeyRotationJob, rowID string, err error) {
+	job.FailedRows++
+	job.LastError = fmt.Sprintf("%s/%s: %v", job.CurrentTable, rowID, err)
+	log.Printf("重新包装失败 (%s/%s): %v", job.CurrentTable, rowID, err)
+}
+
+// trackMinVersion 记录Transit密钥仍在使用的最低版本
+func trackMinVersion(job *models.KeyRotationJob, keyName string, version int) {
+	if version <= 0 {
+		return
+	}
+	if job.MinKeyVersions == nil {
+		job.MinKeyVersions = make(map[string]int)
+	}
+	if current := job.MinKeyVersions[keyName]; current == 0 || version < current {
+		job.MinKeyVersions[keyName] = version
+	}
+}
+
+// saveJob 保存任务进度
+func saveJob(job *models.KeyRotationJob) error {
+	if err := models.DB.Save(job).Error; err != nil {
+		return fmt.Errorf("保存密钥轮换任务进度失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,301 @@
+package rekey
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/akinoccc/hysaif/api/config"
+	"github.com/akinoccc/hysaif/api/models"
+	"github.com/akinoccc/hysaif/api/packages/crypto"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// TestMain 使用内存SQLite数据库和两个版本的本地密钥环初始化密钥轮换测试
+func TestMain(m *testing.M) {
+	config.AppConfig = &config.Config{}
+	config.AppConfig.Database.Type = "sqlite"
+	config.AppConfig.Database.Path = "file:rekey_test?mode=memory&cache=shared"
+	config.AppConfig.Security.KeyProvider = "aes"
+	config.AppConfig.Security.Keyring = config.KeyringConfig{
+		ActiveVersion: 1,
+		Keys: []config.KeyringKeyConfig{
+			{Version: 1, Key: "0123456789abcdef0123456789abcdef"},
+			{Version: 2, Key: "fedcba9876543210fedcba9876543210"},
+		},
+	}
+
+	models.InitDB()
+	models.DB.Logger = logger.Discard
+
+	os.Exit(m.Run())
+}
+
+// createVersionRows 清空托管密钥后使用版本1的主密钥创建 count 条托管密钥版本记录，返回按ID排序的记录ID
+func createVersionRows(t *testing.T, count int) []string {
+	t.Helper()
+	models.DB.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(&models.TransitKeyVersion{})
+	models.DB.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(&models.TransitKey{})
+
+	config.AppConfig.Security.Keyring.ActiveVersion = 1
+	for i := 0; i < count; i++ {
+		if _, err := models.CreateTransitKey(fmt.Sprintf("rekey-%d", i), ""); err != nil {
+			t.Fatalf("创建托管密钥失败: %v", err)
+		}
+	}
+	config.AppConfig.Security.Keyring.ActiveVersion = 2
+
+	var ids []string
+	if err := models.DB.Model(&models.TransitKeyVersion{}).Order("id").Pluck("id", &ids).Error; err != nil {
+		t.Fatalf("读取托管密钥版本失败: %v", err)
+	}
+	return ids
+}
+
+// rowKeyVersions 读取各托管密钥版本记录使用的主密钥版本
+func rowKeyVersions(t *testing.T) map[string]string {
+	t.Helper()
+	var rows []encryptedRow
+	if err := models.DB.Table(models.TransitKeyVersionTable).Select("id, data").Find(&rows).Error; err != nil {
+		t.Fatalf("读取托管密钥版本失败: %v", err)
+	}
+
+	versions := make(map[string]string, len(rows))
+	for _, row := range rows {
+		info, err := crypto.Inspect(*row.Data)
+		if err != nil {
+			versions[row.ID] = "invalid"
+			continue
+		}
+		versions[row.ID] = info.KeyVersion
+	}
+	return versions
+}
+
+func TestRunRewrapsFromCheckpoint(t *testing.T) {
+	tests := []struct {
+		name          string
+		lastIndex     int // 断点所在记录的下标，-1 表示从头开始
+		corruptIndex  int // 写入无法解析的密文的记录下标，-1 表示不写入
+		wantRewrapped int64
+		wantFailed    int64
+		wantVersions  []string
+	}{
+		{"从头重新包装所有记录", -1, -1, 3, 0, []string{"2", "2", "2"}},
+		{"从断点继续只处理断点之后的记录", 0, -1, 2, 0, []string{"1", "2", "2"}},
+		{"无法解析的密文计入失败并继续处理", -1, 1, 2, 1, []string{"2", "invalid", "2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := createVersionRows(t, 3)
+			if tt.corruptIndex >= 0 {
+				models.DB.Table(models.TransitKeyVersionTable).Where("id = ?", ids[tt.corruptIndex]).UpdateColumn("data", "env:invalid")
+			}
+
+			job, err := newJob(models.KeyRotationTypeMasterKey, crypto.ProviderAES, "2", "")
+			if err != nil {
+				t.Fatalf("创建任务失败: %v", err)
+			}
+			// 模拟服务重启前已处理到断点
+			if tt.lastIndex >= 0 {
+				job.CurrentTable = models.TransitKeyVersionTable
+				job.LastID = ids[tt.lastIndex]
+			}
+			if err := models.DB.Create(job).Error; err != nil {
+				t.Fatalf("保存任务失败: %v", err)
+			}
+			run(job)
+
+			if job.Status != models.KeyRotationStatusCompleted {
+				t.Fatalf("任务状态 = %s，期望 %s，错误: %s", job.Status, models.KeyRotationStatusCompleted, job.LastError)
+			}
+			if job.RewrappedRows != tt.wantRewrapped || job.FailedRows != tt.wantFailed {
+				t.Fatalf("重新包装 %d 条、失败 %d 条，期望 %d 条、%d 条", job.RewrappedRows, job.FailedRows, tt.wantRewrapped, tt.wantFailed)
+			}
+
+			versions := rowKeyVersions(t)
+			for i, id := range ids {
+				if versions[id] != tt.wantVersions[i] {
+					t.Fatalf("第%d条记录的主密钥版本 = %s，期望 %s", i, versions[id], tt.wantVersions[i])
+				}
+			}
+
+			// 任务状态和断点已保存到数据库
+			var saved models.KeyRotationJob
+			if err := models.DB.Where("id = ?", job.ID).First(&saved).Error; err != nil {
+				t.Fatalf("读取任务失败: %v", err)
+			}
+			if saved.Status != models.KeyRotationStatusCompleted || saved.CurrentTable != models.DatabaseLeaseTable {
+				t.Fatalf("保存的任务 = %s/%s，期望已完成且处理到最后一个数据表", saved.Status, saved.CurrentTable)
+			}
+		})
+	}
+}
+
+func TestStartRejectsConcurrentJob(t *testing.T) {
+	mu.Lock()
+	running = true
+	mu.Unlock()
+	defer func() {
+		mu.Lock()
+		running = false
+		mu.Unlock()
+	}()
+
+	if _, err := Start(""); !errors.Is(err, ErrJobRunning) {
+		t.Fatalf("Start() error = %v，期望 %v", err, ErrJobRunning)
+	}
+	if _, err := StartVaultRewrap("", "", true); !errors.Is(err, ErrJobRunning) {
+		t.Fatalf("StartVaultRewrap() error = %v，期望 %v", err, ErrJobRunning)
+	}
+}
+
+// vaultEnvelope 构造由Vault Transit密钥包装数据密钥的信封密文，只用于提取和替换Transit密文
+func vaultEnvelope(providerID, ciphertext string) *string {
+	header, _ := json.Marshal(map[string]any{"v": 1, "p": providerID, "k": ciphertext})
+	data := "env:" + base64.RawURLEncoding.EncodeToString(header) + "." + base64.StdEncoding.EncodeToString([]byte("sealed"))
+	return &data
+}
+
+// useUnreachableVault 启用指向不可达地址的Vault及 staging 环境的Transit密钥，测试结束后恢复配置
+func useUnreachableVault(t *testing.T) {
+	t.Helper()
+	security := config.AppConfig.Security
+	t.Cleanup(func() { config.AppConfig.Security = security })
+
+	config.AppConfig.Security.Vault = config.VaultConfig{Enabled: true, Address: "http://127.0.0.1:1", Token: "test-token", MountPath: "transit", KeyName: "hysaif"}
+	config.AppConfig.Security.EnvironmentKeys = map[string]config.EnvironmentKeyConfig{
+		"staging": {VaultKeyName: "hysaif-staging"},
+	}
+}
+
+func TestRewrapVaultBatch(t *testing.T) {
+	useUnreachableVault(t)
+
+	tests := []struct {
+		name        string
+		rows        []encryptedRow
+		targets     map[string]int // 任务中各Transit密钥的目标版本，为空时只包含全局Transit密钥
+		wantErr     bool
+		wantFailed  int64
+		wantMinimum map[string]int
+	}{
+		{
+			name: "已是目标版本的密文按密钥记录最低版本",
+			rows: []encryptedRow{
+				{ID: "1", Data: vaultEnvelope("vault", "vault:v3:a")},
+				{ID: "2", Data: vaultEnvelope("vault:staging", "vault:v2:b"), Environment: "staging"},
+				{ID: "3", Data: func() *string { data := "vault:vault:v4:c"; return &data }()},
+			},
+			targets:     map[string]int{"hysaif": 3, "hysaif-staging": 2},
+			wantMinimum: map[string]int{"hysaif": 3, "hysaif-staging": 2},
+		},
+		{
+			name: "非Vault密文和空数据跳过",
+			rows: []encryptedRow{
+				{ID: "1", Data: vaultEnvelope("aes", "v1:a")},
+				{ID: "2"},
+			},
+		},
+		{
+			name:       "不在任务范围内的Transit密钥计入失败",
+			rows:       []encryptedRow{{ID: "1", Data: vaultEnvelope("vault:staging", "vault:v1:a"), Environment: "staging"}},
+			wantFailed: 1,
+		},
+		{
+			name:       "未配置Transit密钥的环境计入失败",
+			rows:       []encryptedRow{{ID: "1", Data: vaultEnvelope("vault:prod", "vault:v1:a"), Environment: "prod"}},
+			wantFailed: 1,
+		},
+		{
+			name:        "需要重新包装时Vault不可用则整批失败",
+			rows:        []encryptedRow{{ID: "1", Data: vaultEnvelope("vault", "vault:v3:a")}, {ID: "2", Data: vaultEnvelope("vault", "vault:v1:b")}},
+			wantErr:     true,
+			wantMinimum: map[string]int{"hysaif": 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targets := tt.targets
+			if targets == nil {
+				targets = map[string]int{"hysaif": 3}
+			}
+			job := &models.KeyRotationJob{
+				Type:               models.KeyRotationTypeVaultRewrap,
+				CurrentTable:       models.SecretItemTable,
+				TransitKeyVersions: targets,
+			}
+
+			err := rewrapVaultBatch(job, tt.rows)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("rewrapVaultBatch() error = %v，期望错误 %v", err, tt.wantErr)
+			}
+			if job.FailedRows != tt.wantFailed {
+				t.Fatalf("失败 %d 条，期望 %d 条，错误: %s", job.FailedRows, tt.wantFailed, job.LastError)
+			}
+			if len(job.MinKeyVersions) != len(tt.wantMinimum) {
+				t.Fatalf("最低版本 = %v，期望 %v", job.MinKeyVersions, tt.wantMinimum)
+			}
+			for keyName, version := range tt.wantMinimum {
+				if job.MinKeyVersions[keyName] != version {
+					t.Fatalf("最低版本 = %v，期望 %v", job.MinKeyVersions, tt.wantMinimum)
+				}
+			}
+		})
+	}
+}
+
+func TestSafeMinDecryptionVersion(t *testing.T) {
+	completed := func(modify func(job *models.KeyRotationJob)) *models.KeyRotationJob {
+		job := &models.KeyRotationJob{
+			Type:               models.KeyRotationTypeVaultRewrap,
+			Status:             models.KeyRotationStatusCompleted,
+			TransitKeyVersions: map[string]int{"hysaif": 5, "hysaif-staging": 3},
+			MinKeyVersions:     map[string]int{"hysaif": 4},
+		}
+		if modify != nil {
+			modify(job)
+		}
+		return job
+	}
+
+	tests := []struct {
+		name    string
+		job     *models.KeyRotationJob
+		keyName string
+		want    int
+	}{
+		{"使用任务记录的最低版本", completed(nil), "hysaif", 4},
+		{"没有密文的密钥使用最新版本", completed(nil), "hysaif-staging", 3},
+		{"不在任务范围内的密钥", completed(nil), "hysaif-prod", 0},
+		{"任务有失败记录", completed(func(job *models.KeyRotationJob) { job.FailedRows = 1 }), "hysaif", 0},
+		{"任务未完成", completed(func(job *models.KeyRotationJob) { job.Status = models.KeyRotationStatusRunning }), "hysaif", 0},
+		{"主密钥轮换任务", completed(func(job *models.KeyRotationJob) { job.Type = models.KeyRotationTypeMasterKey }), "hysaif", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SafeMinDecryptionVersion(tt.job, tt.keyName); got != tt.want {
+				t.Fatalf("SafeMinDecryptionVersion() = %d，期望 %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrackMinVersion(t *testing.T) {
+	job := &models.KeyRotationJob{}
+	for _, step := range []struct {
+		keyName string
+		version int
+	}{{"hysaif", 3}, {"hysaif", 0}, {"hysaif", 2}, {"hysaif", 4}, {"hysaif-staging", 5}} {
+		trackMinVersion(job, step.keyName, step.version)
+	}
+
+	if job.MinKeyVersions["hysaif"] != 2 || job.MinKeyVersions["hysaif-staging"] != 5 {
+		t.Fatalf("最低版本 = %v，期望 hysaif:2 hysaif-staging:5", job.MinKeyVersions)
+	}
+}
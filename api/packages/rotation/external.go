@@ -0,0 +1,243 @@
+package rotation
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/akinoccc/hysaif/api/config"
+	"github.com/akinoccc/hysaif/api/models"
+)
+
+// 等待外部系统确认的超时时间
+const (
+	defaultApplyTimeout = 30 * time.Second
+	maxApplyTimeout     = 300 * time.Second
+)
+
+// SignatureHeader Webhook请求的签名头，值为 sha256=<十六进制HMAC>，HMAC密钥为轮换字段的旧值
+const SignatureHeader = "X-Hysaif-Signature"
+
+// maxErrorOutput 错误信息中保留的响应或脚本输出长度
+const maxErrorOutput = 512
+
+// ApplyRequest 发送给Webhook（请求体）或脚本（标准输入）的新值，只包含需要外部系统应用的字段
+type ApplyRequest struct {
+	ItemID  string `json:"item_id"` // 信息项ID
+	Name    string `json:"name"`    // 信息项名称
+	Type    string `json:"type"`    // 信息项类型
+	Field   string `json:"field"`   // 新值所在的字段
+	Version int    `json:"version"` // 轮换后的版本号
+	Value   string `json:"value"`   // 字段的新值
+}
+
+// externalRotator 使用内置生成器生成新值，交给Webhook或脚本应用，外部系统确认后才保存新值
+type externalRotator struct {
+	apply    func(ctx context.Context, body []byte, previous string, options models.RotatorOptions) error
+	validate func(options models.RotatorOptions) error
+}
+
+func (r *externalRotator) Validate(item *models.SecretItem, options models.RotatorOptions) error {
+	gen, ok := generators[options.Generator]
+	if !ok {
+		return errors.New("generator 选项必须为 password、token 或 ssh_key")
+	}
+	if options.TimeoutSeconds < 0 || time.Duration(options.TimeoutSeconds)*time.Second > maxApplyTimeout {
+		return fmt.Errorf("超时时间不能超过 %d 秒", int(maxApplyTimeout.Seconds()))
+	}
+	if err := r.validate(options); err != nil {
+		return err
+	}
+	// 数据库连接的管理员密码由外部系统修改，可以使用密码生成器
+	if item.Type == models.SecretTypeDatabase && options.Generator != models.RotatorSSHKey {
+		return validateDatabaseField(gen.field(item, options))
+	}
+	return gen.Validate(item, options)
+}
+
+func (r *externalRotator) Rotate(ctx context.Context, item *models.SecretItem, options models.RotatorOptions) (*models.SecretItemData, error) {
+	data, err := r.generate(ctx, item, options)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.applyData(ctx, item, data, options); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// generate 使用内置生成器生成新值，不交给外部系统
+func (r *externalRotator) generate(ctx context.Context, item *models.SecretItem, options models.RotatorOptions) (*models.SecretItemData, error) {
+	return generators[options.Generator].Rotate(ctx, item, options)
+}
+
+// applyData 将新值交给Webhook或脚本应用并等待确认，超时未确认时返回的错误同时包含 ErrApplyUnconfirmed
+func (r *externalRotator) applyData(ctx context.Context, item *models.SecretItem, data *models.SecretItemData, options models.RotatorOptions) error {
+	gen := generators[options.Generator]
+	field := gen.field(item, options)
+	applied := appliedField(field, options)
+	value, _ := data.FieldValue(applied)
+	body, err := json.Marshal(ApplyRequest{
+		ItemID:  item.ID,
+		Name:    item.Name,
+		Type:    item.Type,
+		Field:   applied,
+		Version: item.Version + 1,
+		Value:   value,
+	})
+	if err != nil {
+		return err
+	}
+	previous, _ := item.Data.FieldValue(field)
+
+	timeout := defaultApplyTimeout
+	if options.TimeoutSeconds > 0 {
+		timeout = time.Duration(options.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := r.apply(ctx, body, previous, options); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("%w: %w: %v", ErrApplyFailed, ErrApplyUnconfirmed, err)
+		}
+		return fmt.Errorf("%w: %v", ErrApplyFailed, err)
+	}
+	return nil
+}
+
+// appliedField 发送给外部系统的字段，SSH密钥只发送公钥，私钥不离开服务端
+func appliedField(field string, options models.RotatorOptions) string {
+	if options.Generator == models.RotatorSSHKey {
+		return "public_key"
+	}
+	return field
+}
+
+// Sign 计算Webhook请求签名，外部系统使用轮换字段的当前值（即旧值）校验请求来源
+func Sign(previous string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(previous))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// validateDatabaseField 数据库连接只能轮换管理员密码，其他连接信息变化后无法再连接目标数据库
+func validateDatabaseField(field string) error {
+	if field != "password" {
+		return errors.New("数据库连接只能轮换 password 字段")
+	}
+	return nil
+}
+
+// webhookClient 发送Webhook请求的客户端，不跟随重定向，避免绕过允许的主机列表
+var webhookClient = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// validateWebhook Webhook地址必须为https，且主机在 rotation.webhook_hosts 中
+func validateWebhook(options models.RotatorOptions) error {
+	hosts := config.AppConfig.Rotation.WebhookHosts
+	if len(hosts) == 0 {
+		return errors.New("未配置 rotation.webhook_hosts，Webhook轮换器不可用")
+	}
+	u, err := url.Parse(options.URL)
+	if err != nil || u.Scheme != "https" || u.Host == "" || u.User != nil {
+		return errors.New("url 选项必须为 https 地址")
+	}
+	for _, host := range hosts {
+		if strings.EqualFold(host, u.Host) || strings.EqualFold(host, u.Hostname()) {
+			return nil
+		}
+	}
+	return fmt.Errorf("Webhook主机 %s 不在 rotation.webhook_hosts 中", u.Host)
+}
+
+// applyWebhook 将新值POST到Webhook，返回2xx状态码表示已应用；请求使用旧值签名，旧值为空时无法签名
+func applyWebhook(ctx context.Context, body []byte, previous string, options models.RotatorOptions) error {
+	// 配置可能在策略保存后修改，发送前重新校验
+	if err := validateWebhook(options); err != nil {
+		return err
+	}
+	if previous == "" {
+		return errors.New("轮换字段的旧值为空，无法签名Webhook请求")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, options.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(previous, body))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		output, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorOutput))
+		return fmt.Errorf("Webhook返回状态码 %d: %s", resp.StatusCode, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// scriptPath 获取脚本的完整路径，脚本必须直接位于配置的脚本目录中
+func scriptPath(name string) (string, error) {
+	dir := config.AppConfig.Rotation.ScriptDir
+	if dir == "" {
+		return "", errors.New("未配置 rotation.script_dir，脚本轮换器不可用")
+	}
+	if name == "" || name != filepath.Base(name) || name == "." || name == ".." {
+		return "", errors.New("script 选项必须为脚本目录中的文件名")
+	}
+	return filepath.Join(dir, name), nil
+}
+
+func validateScript(options models.RotatorOptions) error {
+	path, err := scriptPath(options.Script)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0o111 == 0 {
+		return fmt.Errorf("脚本 %s 不存在或不可执行", options.Script)
+	}
+	return nil
+}
+
+// applyScript 直接执行脚本（不经过shell），新值通过标准输入传入，退出码为0表示已应用
+func applyScript(ctx context.Context, body []byte, previous string, options models.RotatorOptions) error {
+	path, err := scriptPath(options.Script)
+	if err != nil {
+		return err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Dir = filepath.Dir(path)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		output := stderr.String()
+		if len(output) > maxErrorOutput {
+			output = output[:maxErrorOutput]
+		}
+		return fmt.Errorf("脚本执行失败: %v: %s", err, strings.TrimSpace(output))
+	}
+	return nil
+}
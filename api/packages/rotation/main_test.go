@@ -0,0 +1,32 @@
+package rotation
+
+import (
+	"os"
+	"testing"
+
+	"github.com/akinoccc/hysaif/api/config"
+	"github.com/akinoccc/hysaif/api/models"
+	"github.com/akinoccc/hysaif/api/packages/permission"
+
+	"gorm.io/gorm/logger"
+)
+
+// TestMain 使用内存SQLite数据库和本地AES主密钥初始化轮换测试
+func TestMain(m *testing.M) {
+	useTestConfig()
+	models.InitDB()
+	models.DB.Logger = logger.Discard
+	permission.GetCasbinManager(models.DB)
+
+	os.Exit(m.Run())
+}
+
+// useTestConfig 重置为测试数据库和本地AES主密钥的配置，其他测试替换配置后读写数据库前需要重新调用
+func useTestConfig() {
+	config.AppConfig = &config.Config{}
+	config.AppConfig.Database.Type = "sqlite"
+	config.AppConfig.Database.Path = "file:rotation_test?mode=memory&cache=shared"
+	config.AppConfig.Security.EncryptionKey = "0123456789abcdef0123456789abcdef"
+	config.AppConfig.Security.KeyProvider = "aes"
+	config.AppConfig.RBACConfig = "../../rbac_model.conf"
+}
@@ -0,0 +1,163 @@
+// Package rotation 按信息项的轮换策略生成新的敏感数据，保存为新版本并通知持有访问权限的用户
+package rotation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/akinoccc/hysaif/api/models"
+	"github.com/akinoccc/hysaif/api/packages/notification"
+)
+
+// ErrNotRotatable 信息项或轮换策略不满足轮换条件
+var ErrNotRotatable = errors.New("无法轮换信息项")
+
+// ErrApplyFailed Webhook或脚本未能应用新值，信息项保持不变
+var ErrApplyFailed = errors.New("外部系统应用新值失败")
+
+// ErrApplyUnconfirmed 等待Webhook或脚本确认超时，外部系统可能已应用新值
+var ErrApplyUnconfirmed = errors.New("等待外部系统确认超时，新值可能已被应用")
+
+// Rotator 轮换器，根据信息项当前的敏感数据生成新的敏感数据；
+// 需要外部系统配合的轮换器在返回前应用新值并等待确认
+type Rotator interface {
+	// Validate 校验轮换器选项是否适用于信息项
+	Validate(item *models.SecretItem, options models.RotatorOptions) error
+	// Rotate 返回新的敏感数据副本，不修改信息项；返回错误时信息项保持不变
+	Rotate(ctx context.Context, item *models.SecretItem, options models.RotatorOptions) (*models.SecretItemData, error)
+}
+
+var rotators = map[string]Rotator{}
+
+// itemLocks 按信息项ID串行执行轮换，避免手动轮换与定时轮换同时生成新值
+var itemLocks sync.Map
+
+// Register 注册轮换器，同名轮换器会被覆盖
+func Register(name string, rotator Rotator) {
+	rotators[name] = rotator
+}
+
+// Get 获取轮换器
+func Get(name string) (Rotator, error) {
+	rotator, ok := rotators[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: 未知的轮换器 %s", ErrNotRotatable, name)
+	}
+	return rotator, nil
+}
+
+// ValidatePolicy 保存轮换策略前校验轮换计划、信息项状态和轮换器选项
+func ValidatePolicy(item *models.SecretItem, policy *models.SecretRotationPolicy) error {
+	if err := policy.ValidateSchedule(); err != nil {
+		return fmt.Errorf("%w: %v", ErrNotRotatable, err)
+	}
+	if err := checkItem(item); err != nil {
+		return err
+	}
+	rotator, err := Get(policy.Rotator)
+	if err != nil {
+		return err
+	}
+	if err := rotator.Validate(item, policy.Options); err != nil {
+		return fmt.Errorf("%w: %v", ErrNotRotatable, err)
+	}
+	return nil
+}
+
+// Rotate 按轮换策略立即轮换信息项：生成新值（需要时由外部系统应用并确认），保存为 rotated 类型的新版本，
+// 通知当前持有访问权限的用户，并在策略上记录结果
+func Rotate(policy *models.SecretRotationPolicy, rotatedByID, reason string) (*models.SecretItem, error) {
+	lock, _ := itemLocks.LoadOrStore(policy.SecretItemID, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	item, err := rotate(policy, rotatedByID, reason)
+	now := time.Now()
+	if err != nil {
+		if recordErr := policy.RecordFailure(err, now); recordErr != nil {
+			log.Printf("记录轮换策略 %s 的失败结果失败: %v", policy.ID, recordErr)
+		}
+		return nil, err
+	}
+	if err := policy.RecordSuccess(now); err != nil {
+		log.Printf("记录轮换策略 %s 的成功结果失败: %v", policy.ID, err)
+	}
+
+	if err := notification.NotifySecretItemRotated(item, now); err != nil {
+		log.Printf("发送密钥项 %s 的轮换通知失败: %v", item.ID, err)
+	}
+	return item, nil
+}
+
+func rotate(policy *models.SecretRotationPolicy, rotatedByID, reason string) (*models.SecretItem, error) {
+	var item models.SecretItem
+	if err := models.DB.Where("id = ?", policy.SecretItemID).First(&item).Error; err != nil {
+		return nil, err
+	}
+	if err := ValidatePolicy(&item, policy); err != nil {
+		return nil, err
+	}
+
+	rotator, _ := Get(policy.Rotator)
+	if external, ok := rotator.(*externalRotator); ok {
+		return rotateExternal(external, policy, &item, rotatedByID, reason)
+	}
+
+	data, err := rotator.Rotate(context.Background(), &item, policy.Options)
+	if err != nil {
+		return nil, err
+	}
+	if err := item.ApplyRotation(data, reason, rotatedByID, nil); err != nil {
+		return nil, fmt.Errorf("保存轮换后的数据失败: %w", err)
+	}
+	return &item, nil
+}
+
+// rotateExternal 先将新值保存为待确认的历史版本，再交给外部系统应用，确认后提交到信息项；
+// 外部系统应用后信息项保存失败（如轮换期间信息项被修改）时，当前生效的值仍可从待确认版本恢复
+func rotateExternal(rotator *externalRotator, policy *models.SecretRotationPolicy, item *models.SecretItem, rotatedByID, reason string) (*models.SecretItem, error) {
+	ctx := context.Background()
+	data, err := rotator.generate(ctx, item, policy.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	pending, err := item.CreatePendingRotation(data, reason, rotatedByID)
+	if err != nil {
+		return nil, fmt.Errorf("保存待确认的新值失败: %w", err)
+	}
+
+	if err := rotator.applyData(ctx, item, data, policy.Options); err != nil {
+		if errors.Is(err, ErrApplyUnconfirmed) {
+			return nil, fmt.Errorf("%w，新值已保存为待确认的历史版本 %d", err, pending.Version)
+		}
+		if discardErr := models.DiscardPendingRotation(pending); discardErr != nil {
+			log.Printf("删除信息项 %s 待确认的历史版本 %d 失败: %v", item.ID, pending.Version, discardErr)
+		}
+		return nil, err
+	}
+
+	if err := item.ApplyRotation(data, reason, rotatedByID, pending); err != nil {
+		return nil, fmt.Errorf("新值已由外部系统应用，但保存失败，请从待确认的历史版本 %d 恢复信息项: %w", pending.Version, err)
+	}
+	return item, nil
+}
+
+// checkItem 检查服务端能否读取和修改信息项的敏感数据
+func checkItem(item *models.SecretItem) error {
+	switch {
+	case item.E2E:
+		return fmt.Errorf("%w: 端到端加密信息项的数据由客户端加密，服务端无法轮换", ErrNotRotatable)
+	case item.QuarantinedAt != 0:
+		return fmt.Errorf("%w: 信息项的数据已被隔离", ErrNotRotatable)
+	case item.DataUnavailable():
+		return fmt.Errorf("%w: 当前部署未持有环境 '%s' 的主密钥", ErrNotRotatable, item.Environment)
+	case item.Data == nil:
+		return fmt.Errorf("%w: 信息项没有敏感数据", ErrNotRotatable)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package rotation
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/akinoccc/hysaif/api/models"
+
+	"github.com/google/uuid"
+)
+
+// createPasswordItem 创建使用指定密码的密码类型信息项
+func createPasswordItem(t *testing.T, value string) *models.SecretItem {
+	t.Helper()
+	item := &models.SecretItem{
+		Name:        "rotation-" + uuid.NewString()[:8],
+		Type:        models.SecretTypePassword,
+		Environment: "development",
+		Data:        &models.SecretItemData{Username: "app", Password: value},
+		CreatedByID: uuid.NewString(),
+	}
+	if err := models.DB.Create(item).Error; err != nil {
+		t.Fatalf("创建信息项失败: %v", err)
+	}
+	return item
+}
+
+// histories 获取信息项指定变更类型的历史版本
+func histories(t *testing.T, itemID, changeType string) []models.SecretItemHistory {
+	t.Helper()
+	var result []models.SecretItemHistory
+	if err := models.DB.Where("secret_item_id = ? AND change_type = ?", itemID, changeType).Find(&result).Error; err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestRotateExternalKeepsAppliedValue(t *testing.T) {
+	useTestConfig()
+	const oldPassword = "Old#Passw0rd!xyz"
+
+	tests := []struct {
+		name        string
+		apply       func(ctx context.Context, item *models.SecretItem) error
+		timeout     int
+		wantErr     error
+		wantPending bool
+		wantRotated bool
+	}{
+		{
+			name:        "外部系统确认后提交",
+			apply:       func(ctx context.Context, item *models.SecretItem) error { return nil },
+			wantRotated: true,
+		},
+		{
+			name:    "外部系统拒绝时删除待确认版本",
+			apply:   func(ctx context.Context, item *models.SecretItem) error { return errors.New("HTTP 500") },
+			wantErr: ErrApplyFailed,
+		},
+		{
+			name: "确认超时保留待确认版本",
+			apply: func(ctx context.Context, item *models.SecretItem) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+			timeout:     1,
+			wantErr:     ErrApplyUnconfirmed,
+			wantPending: true,
+		},
+		{
+			name: "应用期间信息项被移动时不覆盖并保留待确认版本",
+			apply: func(ctx context.Context, item *models.SecretItem) error {
+				var current models.SecretItem
+				if err := models.DB.First(&current, "id = ?", item.ID).Error; err != nil {
+					return err
+				}
+				return current.MoveTo(nil, "并发移动", item.CreatedByID)
+			},
+			wantErr:     models.ErrRotationConflict,
+			wantPending: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := createPasswordItem(t, oldPassword)
+			rotator := &externalRotator{
+				apply: func(ctx context.Context, body []byte, previous string, options models.RotatorOptions) error {
+					return tt.apply(ctx, item)
+				},
+				validate: func(models.RotatorOptions) error { return nil },
+			}
+			policy := &models.SecretRotationPolicy{
+				SecretItemID: item.ID,
+				Rotator:      models.RotatorWebhook,
+				Options:      models.RotatorOptions{Generator: models.RotatorPassword, TimeoutSeconds: tt.timeout},
+			}
+
+			rotated, err := rotateExternal(rotator, policy, item, item.CreatedByID, "测试轮换")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("期望错误 %v，实际错误: %v", tt.wantErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("轮换失败: %v", err)
+			}
+
+			var current models.SecretItem
+			if err := models.DB.First(&current, "id = ?", item.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			pending := histories(t, item.ID, models.HistoryChangeTypeRotationPending)
+			committed := histories(t, item.ID, models.HistoryChangeTypeRotated)
+
+			if tt.wantRotated {
+				if len(committed) != 1 || len(pending) != 0 {
+					t.Fatalf("期望一个 rotated 版本，实际 rotated %d 个，待确认 %d 个", len(committed), len(pending))
+				}
+				if current.Data.Password == oldPassword || current.Data.Password != committed[0].Data.Password || current.Version != rotated.Version {
+					t.Fatalf("信息项未保存外部系统应用的新值: %+v", current)
+				}
+				return
+			}
+
+			if current.Data.Password != oldPassword {
+				t.Fatal("轮换失败时信息项的数据不应改变")
+			}
+			if len(committed) != 0 {
+				t.Fatalf("轮换失败时不应有 rotated 版本，实际 %d 个", len(committed))
+			}
+			if tt.wantPending {
+				if len(pending) != 1 || pending[0].Data.Password == oldPassword || pending[0].Data.Password == "" {
+					t.Fatalf("期望保留一个包含新值的待确认版本，实际 %d 个", len(pending))
+				}
+			} else if len(pending) != 0 {
+				t.Fatalf("外部系统拒绝时不应保留待确认版本，实际 %d 个", len(pending))
+			}
+		})
+	}
+}
+
+func TestApplyRotationRejectsConcurrentUpdate(t *testing.T) {
+	useTestConfig()
+	item := createPasswordItem(t, "Old#Passw0rd!xyz")
+
+	var edited models.SecretItem
+	if err := models.DB.First(&edited, "id = ?", item.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	edited.Data.Password = "Edited#Passw0rd!"
+	edited.Version++
+	if err := models.DB.Omit("Creator", "Updater").Save(&edited).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	err := item.ApplyRotation(&models.SecretItemData{Username: "app", Password: "Rotated#Passw0rd!"}, "测试轮换", item.CreatedByID, nil)
+	if !errors.Is(err, models.ErrRotationConflict) {
+		t.Fatalf("期望 ErrRotationConflict，实际错误: %v", err)
+	}
+
+	var current models.SecretItem
+	if err := models.DB.First(&current, "id = ?", item.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if current.Data.Password != "Edited#Passw0rd!" || current.Version != edited.Version {
+		t.Fatalf("并发修改被覆盖: 版本 %d，密码 %s", current.Version, current.Data.Password)
+	}
+}
@@ -0,0 +1,218 @@
+package rotation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/akinoccc/hysaif/api/models"
+	"github.com/akinoccc/hysaif/api/packages/password"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// defaultTokenLength 令牌轮换器默认生成的长度
+const defaultTokenLength = 40
+
+func init() {
+	Register(models.RotatorPassword, passwordRotator{})
+	Register(models.RotatorToken, tokenRotator{})
+	Register(models.RotatorSSHKey, sshKeyRotator{})
+	Register(models.RotatorWebhook, &externalRotator{apply: applyWebhook, validate: validateWebhook})
+	Register(models.RotatorScript, &externalRotator{apply: applyScript, validate: validateScript})
+}
+
+// generator 只生成新值的内置轮换器，Webhook和脚本轮换器使用它们生成新值
+type generator interface {
+	Rotator
+	// field 新值所在的字段，外部系统使用该字段的旧值校验请求签名
+	field(item *models.SecretItem, options models.RotatorOptions) string
+}
+
+// generators Webhook和脚本轮换器可以使用的生成器
+var generators = map[string]generator{
+	models.RotatorPassword: passwordRotator{},
+	models.RotatorToken:    tokenRotator{},
+	models.RotatorSSHKey:   sshKeyRotator{},
+}
+
+// passwordRotator 重新生成包含大小写字母、数字和特殊字符的随机密码
+type passwordRotator struct{}
+
+func (passwordRotator) field(item *models.SecretItem, options models.RotatorOptions) string {
+	if options.Field != "" {
+		return options.Field
+	}
+	switch item.Type {
+	case models.SecretTypePassword, models.SecretTypeDatabase:
+		return "password"
+	}
+	return ""
+}
+
+func (r passwordRotator) Validate(item *models.SecretItem, options models.RotatorOptions) error {
+	if options.Length != 0 && (options.Length < password.MinLength || options.Length > password.MaxLength) {
+		return fmt.Errorf("密码长度必须在 %d 到 %d 之间", password.MinLength, password.MaxLength)
+	}
+	return validateField(item, r.field(item, options))
+}
+
+func (r passwordRotator) Rotate(ctx context.Context, item *models.SecretItem, options models.RotatorOptions) (*models.SecretItemData, error) {
+	length := options.Length
+	if length == 0 {
+		length = max(password.DefaultLength, password.PolicyFor(item.Category).MinLength)
+	}
+	value, err := password.Generate(password.GenerateOptions{
+		Length:    length,
+		Lowercase: true,
+		Uppercase: true,
+		Digits:    true,
+		Symbols:   !options.ExcludeSymbols,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return withField(item, r.field(item, options), value)
+}
+
+// tokenRotator 重新生成只包含字母和数字的随机令牌或API Key
+type tokenRotator struct{}
+
+func (tokenRotator) field(item *models.SecretItem, options models.RotatorOptions) string {
+	if options.Field != "" {
+		return options.Field
+	}
+	switch item.Type {
+	case "token":
+		return "token"
+	case "api_key":
+		return "api_key"
+	case "access_key":
+		return "secret_key"
+	}
+	return ""
+}
+
+func (r tokenRotator) Validate(item *models.SecretItem, options models.RotatorOptions) error {
+	if options.Length != 0 && (options.Length < password.MinLength || options.Length > password.MaxLength) {
+		return fmt.Errorf("令牌长度必须在 %d 到 %d 之间", password.MinLength, password.MaxLength)
+	}
+	return validateField(item, r.field(item, options))
+}
+
+func (r tokenRotator) Rotate(ctx context.Context, item *models.SecretItem, options models.RotatorOptions) (*models.SecretItemData, error) {
+	length := options.Length
+	if length == 0 {
+		length = defaultTokenLength
+	}
+	value, err := password.Generate(password.GenerateOptions{Length: length, Lowercase: true, Uppercase: true, Digits: true})
+	if err != nil {
+		return nil, err
+	}
+	return withField(item, r.field(item, options), value)
+}
+
+// sshKeyRotator 重新生成SSH密钥对，默认使用与当前密钥相同的算法和长度，保留公钥注释和passphrase
+type sshKeyRotator struct{}
+
+func (sshKeyRotator) field(item *models.SecretItem, options models.RotatorOptions) string {
+	return "private_key"
+}
+
+func (sshKeyRotator) Validate(item *models.SecretItem, options models.RotatorOptions) error {
+	if item.Type != models.SecretTypeSSHKey {
+		return errors.New("SSH密钥轮换器只能用于SSH密钥类型的信息项")
+	}
+	switch options.Algorithm {
+	case "", models.SSHKeyAlgorithmEd25519, models.SSHKeyAlgorithmRSA, models.SSHKeyAlgorithmECDSA:
+	default:
+		return fmt.Errorf("不支持的密钥算法: %s", options.Algorithm)
+	}
+	if options.Bits != 0 && options.Algorithm == "" {
+		return errors.New("指定密钥长度时需要同时指定密钥算法")
+	}
+	return nil
+}
+
+func (sshKeyRotator) Rotate(ctx context.Context, item *models.SecretItem, options models.RotatorOptions) (*models.SecretItemData, error) {
+	algorithm, bits := options.Algorithm, options.Bits
+	if algorithm == "" {
+		algorithm, bits = currentSSHKeyAlgorithm(item.Metadata.SSHKey)
+	}
+
+	var comment string
+	if strings.TrimSpace(item.Data.PublicKey) != "" {
+		if _, c, _, _, err := ssh.ParseAuthorizedKey([]byte(item.Data.PublicKey)); err == nil {
+			comment = c
+		}
+	}
+
+	pair, err := models.GenerateSSHKey(algorithm, bits, comment, item.Data.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+	data := cloneData(item.Data)
+	data.PrivateKey = pair.PrivateKey
+	data.PublicKey = pair.PublicKey
+	return data, nil
+}
+
+// currentSSHKeyAlgorithm 根据当前密钥的元数据获取算法和长度，无法识别时使用ed25519
+func currentSSHKeyAlgorithm(metadata *models.SSHKeyMetadata) (string, int) {
+	if metadata == nil {
+		return models.SSHKeyAlgorithmEd25519, 0
+	}
+	switch {
+	case metadata.KeyType == ssh.KeyAlgoRSA:
+		return models.SSHKeyAlgorithmRSA, metadata.Bits
+	case strings.HasPrefix(metadata.KeyType, "ecdsa-sha2-"):
+		return models.SSHKeyAlgorithmECDSA, metadata.Bits
+	default:
+		return models.SSHKeyAlgorithmEd25519, 0
+	}
+}
+
+// validateField 校验密码和令牌轮换器的目标字段
+func validateField(item *models.SecretItem, field string) error {
+	switch item.Type {
+	case models.SecretTypeDatabase:
+		return errors.New("数据库连接的管理员密码需要在目标数据库中修改，请使用 webhook 或 script 轮换器")
+	case models.SecretTypeTOTP:
+		return errors.New("TOTP种子由服务方签发，不能轮换")
+	}
+	if field == "" {
+		return fmt.Errorf("类型为 %s 的信息项需要通过 field 选项指定轮换的字段", item.Type)
+	}
+	value, ok := item.Data.FieldValue(field)
+	if !ok {
+		return fmt.Errorf("信息项没有字段 %s", field)
+	}
+	if models.HasSecretReference(value) {
+		return fmt.Errorf("字段 %s 引用了其他信息项，请轮换被引用的信息项", field)
+	}
+	return nil
+}
+
+// withField 返回替换了指定字段的敏感数据副本；密码类型信息项的新密码需要满足强度策略
+func withField(item *models.SecretItem, field, value string) (*models.SecretItemData, error) {
+	data := cloneData(item.Data)
+	if !data.SetFieldValue(field, value) {
+		return nil, fmt.Errorf("信息项没有字段 %s", field)
+	}
+
+	candidate := *item
+	candidate.Data = data
+	if violations := candidate.CheckPasswordPolicy(); len(violations) > 0 {
+		return nil, fmt.Errorf("生成的密码不符合强度策略: %s，请调整 length 或 exclude_symbols 选项", strings.Join(violations, "；"))
+	}
+	return data, nil
+}
+
+// cloneData 复制敏感数据，自定义字段使用新的 map
+func cloneData(data *models.SecretItemData) *models.SecretItemData {
+	clone := *data
+	clone.Fields = maps.Clone(data.Fields)
+	return &clone
+}
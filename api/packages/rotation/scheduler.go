@@ -0,0 +1,93 @@
+package rotation
+
+import (
+	"log"
+	"time"
+
+	"github.com/akinoccc/hysaif/api/models"
+	"github.com/akinoccc/hysaif/api/packages/crypto"
+)
+
+// checkInterval 检查到期轮换策略的间隔
+const checkInterval = 5 * time.Minute
+
+// scheduledReason 定时轮换生成的历史版本的变更原因
+const scheduledReason = "按轮换策略自动轮换"
+
+// Event 定时轮换的结果
+type Event struct {
+	PolicyID       string // 轮换策略ID
+	SecretItemID   string // 信息项ID
+	SecretItemType string // 信息项类型
+	Rotator        string // 轮换器
+	Version        int    // 轮换后的版本号，失败时为0
+	Error          string // 失败原因
+}
+
+var (
+	stopChan            = make(chan struct{})
+	onScheduledRotation func(Event)
+)
+
+// OnScheduledRotation 设置定时轮换完成后的回调，用于记录审计日志
+func OnScheduledRotation(fn func(Event)) {
+	onScheduledRotation = fn
+}
+
+// Start 启动定时轮换任务
+func Start() {
+	log.Println("启动自动轮换服务")
+	go run()
+}
+
+// Stop 停止定时轮换任务
+func Stop() {
+	log.Println("停止自动轮换服务")
+	close(stopChan)
+}
+
+func run() {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			rotateDuePolicies()
+		case <-stopChan:
+			return
+		}
+	}
+}
+
+// rotateDuePolicies 依次轮换已到期且处于轮换窗口内的信息项，密封状态下无法读取敏感数据，跳过本次检查
+func rotateDuePolicies() {
+	if crypto.IsSealed() {
+		return
+	}
+
+	policies, err := models.DueSecretRotationPolicies(time.Now())
+	if err != nil {
+		log.Printf("查询到期的轮换策略失败: %v", err)
+		return
+	}
+
+	for i := range policies {
+		policy := &policies[i]
+		event := Event{PolicyID: policy.ID, SecretItemID: policy.SecretItemID, Rotator: policy.Rotator}
+
+		item, err := Rotate(policy, policy.UpdatedByID, scheduledReason)
+		if err != nil {
+			log.Printf("自动轮换信息项 %s 失败: %v", policy.SecretItemID, err)
+			event.Error = err.Error()
+			models.DB.Model(&models.SecretItem{}).Select("type").Where("id = ?", policy.SecretItemID).Scan(&event.SecretItemType)
+		} else {
+			event.SecretItemType = item.Type
+			event.Version = item.Version
+		}
+
+		if onScheduledRotation != nil {
+			onScheduledRotation(event)
+		}
+	}
+}
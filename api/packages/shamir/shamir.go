@@ -0,0 +1,191 @@
+package shamir
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// 分片格式：按字节计算的多项式取值 + 1字节x坐标，长度为秘密长度+1
+const (
+	minParts = 2
+	maxParts = 255
+)
+
+var (
+	expTable [255]byte
+	logTable [256]byte
+)
+
+func init() {
+	// GF(2^8) 对数表，生成元为3，不可约多项式为 x^8+x^4+x^3+x+1
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		expTable[i] = x
+		logTable[x] = byte(i)
+		x = mul(x, 3)
+	}
+}
+
+// Split 将秘密拆分为 parts 个分片，任意 threshold 个分片即可恢复秘密
+func Split(secret []byte, parts, threshold int) ([][]byte, error) {
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("秘密不能为空")
+	}
+	if parts < threshold {
+		return nil, fmt.Errorf("分片数不能小于阈值")
+	}
+	if parts > maxParts {
+		return nil, fmt.Errorf("分片数不能超过 %d", maxParts)
+	}
+	if threshold < minParts {
+		return nil, fmt.Errorf("阈值不能小于 %d", minParts)
+	}
+
+	// 随机且互不相同的非零x坐标
+	xCoordinates, err := randomXCoordinates(parts)
+	if err != nil {
+		return nil, err
+	}
+
+	shares := make([][]byte, parts)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret)+1)
+		shares[i][len(secret)] = xCoordinates[i]
+	}
+
+	// 每个字节使用独立的随机多项式，常数项为秘密字节
+	coefficients := make([]byte, threshold)
+	for idx, b := range secret {
+		coefficients[0] = b
+		if _, err := rand.Read(coefficients[1:]); err != nil {
+			return nil, fmt.Errorf("生成随机系数失败: %w", err)
+		}
+		for i := range shares {
+			shares[i][idx] = evaluate(coefficients, xCoordinates[i])
+		}
+	}
+
+	return shares, nil
+}
+
+// Combine 使用分片恢复秘密，分片数量不足阈值时得到的结果是错误的，调用方需要自行校验
+func Combine(shares [][]byte) ([]byte, error) {
+	if len(shares) < minParts {
+		return nil, fmt.Errorf("至少需要 %d 个分片", minParts)
+	}
+
+	shareLen := len(shares[0])
+	if shareLen < 2 {
+		return nil, fmt.Errorf("分片长度无效")
+	}
+
+	xSamples := make([]byte, len(shares))
+	seen := make(map[byte]bool, len(shares))
+	for i, share := range shares {
+		if len(share) != shareLen {
+			return nil, fmt.Errorf("分片长度不一致")
+		}
+		x := share[shareLen-1]
+		if x == 0 || seen[x] {
+			return nil, fmt.Errorf("分片重复或无效")
+		}
+		seen[x] = true
+		xSamples[i] = x
+	}
+
+	secret := make([]byte, shareLen-1)
+	ySamples := make([]byte, len(shares))
+	for idx := range secret {
+		for i, share := range shares {
+			ySamples[i] = share[idx]
+		}
+		secret[idx] = interpolate(xSamples, ySamples)
+	}
+
+	return secret, nil
+}
+
+// ShareID 获取分片的x坐标，用于识别重复提交的分片
+func ShareID(share []byte) byte {
+	if len(share) == 0 {
+		return 0
+	}
+	return share[len(share)-1]
+}
+
+// randomXCoordinates 生成互不相同的非零x坐标
+func randomXCoordinates(parts int) ([]byte, error) {
+	perm := make([]byte, 255)
+	for i := range perm {
+		perm[i] = byte(i + 1)
+	}
+
+	// Fisher-Yates 洗牌
+	random := make([]byte, 1)
+	for i := len(perm) - 1; i > 0; i-- {
+		if _, err := rand.Read(random); err != nil {
+			return nil, fmt.Errorf("生成随机坐标失败: %w", err)
+		}
+		j := int(random[0]) % (i + 1)
+		perm[i], perm[j] = perm[j], perm[i]
+	}
+
+	return perm[:parts], nil
+}
+
+// evaluate 使用霍纳法则计算多项式在x处的值
+func evaluate(coefficients []byte, x byte) byte {
+	result := byte(0)
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		result = add(mul(result, x), coefficients[i])
+	}
+	return result
+}
+
+// interpolate 拉格朗日插值计算多项式在0处的值
+func interpolate(xSamples, ySamples []byte) byte {
+	result := byte(0)
+	for i := range xSamples {
+		basis := byte(1)
+		for j := range xSamples {
+			if i == j {
+				continue
+			}
+			// 在0处：x_j / (x_j - x_i)，GF(2^8)中减法等同于加法
+			basis = mul(basis, div(xSamples[j], add(xSamples[j], xSamples[i])))
+		}
+		result = add(result, mul(ySamples[i], basis))
+	}
+	return result
+}
+
+// add GF(2^8) 加法
+func add(a, b byte) byte {
+	return a ^ b
+}
+
+// mul GF(2^8) 乘法（俄罗斯农夫算法，不依赖对数表）
+func mul(a, b byte) byte {
+	var result byte
+	for b > 0 {
+		if b&1 == 1 {
+			result ^= a
+		}
+		carry := a & 0x80
+		a <<= 1
+		if carry != 0 {
+			a ^= 0x1b
+		}
+		b >>= 1
+	}
+	return result
+}
+
+// div GF(2^8) 除法，b不能为0
+func div(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	diff := (int(logTable[a]) - int(logTable[b]) + 255) % 255
+	return expTable[diff]
+}
@@ -0,0 +1,107 @@
+package shamir
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSplitCombine(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+
+	tests := []struct {
+		name      string
+		parts     int
+		threshold int
+		use       []int // 用于恢复的分片下标
+		wantOK    bool
+	}{
+		{"达到阈值", 5, 3, []int{0, 2, 4}, true},
+		{"使用全部分片", 5, 3, []int{0, 1, 2, 3, 4}, true},
+		{"顺序无关", 5, 3, []int{4, 1, 3}, true},
+		{"最小配置", 2, 2, []int{1, 0}, true},
+		{"最大分片数", 255, 10, []int{0, 50, 100, 150, 200, 250, 254, 7, 8, 9}, true},
+		{"不足阈值", 5, 3, []int{0, 1}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shares, err := Split(secret, tt.parts, tt.threshold)
+			if err != nil {
+				t.Fatalf("拆分失败: %v", err)
+			}
+			if len(shares) != tt.parts {
+				t.Fatalf("得到 %d 个分片，期望 %d 个", len(shares), tt.parts)
+			}
+
+			selected := make([][]byte, len(tt.use))
+			for i, idx := range tt.use {
+				selected[i] = shares[idx]
+			}
+			combined, err := Combine(selected)
+			if err != nil {
+				t.Fatalf("恢复失败: %v", err)
+			}
+			if got := bytes.Equal(combined, secret); got != tt.wantOK {
+				t.Fatalf("恢复结果与秘密一致 = %v，期望 %v", got, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestSplitRejectsInvalidParameters(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    []byte
+		parts     int
+		threshold int
+	}{
+		{"秘密为空", nil, 5, 3},
+		{"分片数小于阈值", []byte("s"), 2, 3},
+		{"分片数超过上限", []byte("s"), 256, 3},
+		{"阈值小于2", []byte("s"), 5, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Split(tt.secret, tt.parts, tt.threshold); err == nil {
+				t.Fatal("期望拆分失败")
+			}
+		})
+	}
+}
+
+func TestCombineRejectsInvalidShares(t *testing.T) {
+	shares, err := Split([]byte("secret"), 3, 2)
+	if err != nil {
+		t.Fatalf("拆分失败: %v", err)
+	}
+	zeroX := append([]byte{}, shares[1]...)
+	zeroX[len(zeroX)-1] = 0
+
+	tests := []struct {
+		name   string
+		shares [][]byte
+	}{
+		{"分片不足2个", [][]byte{shares[0]}},
+		{"分片重复", [][]byte{shares[0], shares[0]}},
+		{"长度不一致", [][]byte{shares[0], shares[1][1:]}},
+		{"x坐标为0", [][]byte{shares[0], zeroX}},
+		{"分片过短", [][]byte{{1}, {2}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Combine(tt.shares); err == nil {
+				t.Fatal("期望恢复失败")
+			}
+		})
+	}
+}
+
+func TestFieldArithmetic(t *testing.T) {
+	for a := 1; a < 256; a++ {
+		for b := 1; b < 256; b++ {
+			product := mul(byte(a), byte(b))
+			if got := div(product, byte(b)); got != byte(a) {
+				t.Fatalf("(%d*%d)/%d = %d", a, b, b, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// RFC 6238 附录B的测试种子
+var (
+	seedSHA1   = []byte("12345678901234567890")
+	seedSHA256 = []byte("12345678901234567890123456789012")
+	seedSHA512 = []byte("1234567890123456789012345678901234567890123456789012345678901234")
+)
+
+func TestCodeRFC6238Vectors(t *testing.T) {
+	tests := []struct {
+		unix      int64
+		algorithm string
+		secret    []byte
+		want      string
+	}{
+		{59, AlgorithmSHA1, seedSHA1, "94287082"},
+		{59, AlgorithmSHA256, seedSHA256, "46119246"},
+		{59, AlgorithmSHA512, seedSHA512, "90693936"},
+		{1111111109, AlgorithmSHA1, seedSHA1, "07081804"},
+		{1111111109, AlgorithmSHA256, seedSHA256, "68084774"},
+		{1111111109, AlgorithmSHA512, seedSHA512, "25091201"},
+		{1111111111, AlgorithmSHA1, seedSHA1, "14050471"},
+		{1111111111, AlgorithmSHA256, seedSHA256, "67062674"},
+		{1111111111, AlgorithmSHA512, seedSHA512, "99943326"},
+		{1234567890, AlgorithmSHA1, seedSHA1, "89005924"},
+		{1234567890, AlgorithmSHA256, seedSHA256, "91819424"},
+		{1234567890, AlgorithmSHA512, seedSHA512, "93441116"},
+		{2000000000, AlgorithmSHA1, seedSHA1, "69279037"},
+		{2000000000, AlgorithmSHA256, seedSHA256, "90698825"},
+		{2000000000, AlgorithmSHA512, seedSHA512, "38618901"},
+		{20000000000, AlgorithmSHA1, seedSHA1, "65353130"},
+		{20000000000, AlgorithmSHA256, seedSHA256, "77737706"},
+		{20000000000, AlgorithmSHA512, seedSHA512, "47863826"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.algorithm+"/"+time.Unix(tt.unix, 0).UTC().Format(time.RFC3339), func(t *testing.T) {
+			key := &Key{Secret: tt.secret, Algorithm: tt.algorithm, Digits: 8, Period: 30}
+			code, expiresAt := key.Code(time.Unix(tt.unix, 0))
+			if code != tt.want {
+				t.Fatalf("验证码为 %s，期望 %s", code, tt.want)
+			}
+			if expiresAt.Unix()%30 != 0 || expiresAt.Unix() <= tt.unix || expiresAt.Unix()-tt.unix > 30 {
+				t.Fatalf("失效时间 %d 不是当前周期的结束时间", expiresAt.Unix())
+			}
+		})
+	}
+}
+
+func TestParseSeed(t *testing.T) {
+	encoded := base32.StdEncoding.EncodeToString(seedSHA1) // GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ
+
+	tests := []struct {
+		name    string
+		seed    string
+		wantErr bool
+	}{
+		{"标准编码", encoded, false},
+		{"小写", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", false},
+		{"空格和连字符", "GEZD GNBV-GY3T QOJQ GEZD-GNBV GY3T QOJQ", false},
+		{"为空", "", true},
+		{"非base32字符", "GEZDGNBVGY3TQOJ1", true},
+		{"种子过短", "GEZDGNBV", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ParseSeed(tt.seed)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("期望解析失败")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("解析失败: %v", err)
+			}
+			if string(key.Secret) != string(seedSHA1) {
+				t.Fatalf("种子解码结果为 %q", key.Secret)
+			}
+			if key.Algorithm != DefaultAlgorithm || key.Digits != DefaultDigits || key.Period != DefaultPeriod {
+				t.Fatalf("参数应使用默认值: %+v", key)
+			}
+		})
+	}
+}
+
+func TestParseURI(t *testing.T) {
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	tests := []struct {
+		name    string
+		uri     string
+		want    Key
+		wantErr bool
+	}{
+		{
+			name: "默认参数",
+			uri:  "otpauth://totp/Example:alice@example.com?secret=" + secret + "&issuer=Example",
+			want: Key{Issuer: "Example", AccountName: "alice@example.com", Algorithm: AlgorithmSHA1, Digits: 6, Period: 30},
+		},
+		{
+			name: "自定义参数",
+			uri:  "otpauth://totp/alice?secret=" + secret + "&algorithm=sha256&digits=8&period=60",
+			want: Key{AccountName: "alice", Algorithm: AlgorithmSHA256, Digits: 8, Period: 60},
+		},
+		{name: "HOTP", uri: "otpauth://hotp/alice?secret=" + secret + "&counter=1", wantErr: true},
+		{name: "不支持的算法", uri: "otpauth://totp/alice?secret=" + secret + "&algorithm=MD5", wantErr: true},
+		{name: "位数过多", uri: "otpauth://totp/alice?secret=" + secret + "&digits=10", wantErr: true},
+		{name: "有效期过短", uri: "otpauth://totp/alice?secret=" + secret + "&period=5", wantErr: true},
+		{name: "不是otpauth", uri: "https://example.com/?secret=" + secret, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ParseURI(tt.uri)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("期望解析失败")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("解析失败: %v", err)
+			}
+			if key.Issuer != tt.want.Issuer || key.AccountName != tt.want.AccountName ||
+				key.Algorithm != tt.want.Algorithm || key.Digits != tt.want.Digits || key.Period != tt.want.Period {
+				t.Fatalf("解析结果 %+v，期望 %+v", *key, tt.want)
+			}
+		})
+	}
+}
@@ -6,13 +6,48 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/akinoccc/hysaif/api/models"
 	"github.com/akinoccc/hysaif/api/types"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
 )
 
+// RegisterValidators 注册自定义校验规则，需要在处理请求之前调用
+//
+//   - secret_type: 内置或管理员定义的自定义信息项类型
+func RegisterValidators() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("不支持的校验引擎")
+	}
+	return v.RegisterValidation("secret_type", func(fl validator.FieldLevel) bool {
+		return models.IsSecretType(fl.Field().String())
+	})
+}
+
+// FieldErrors 请求绑定之后的业务校验错误，按字段记录错误信息，与参数验证错误以相同格式返回
+type FieldErrors map[string][]string
+
+func (e FieldErrors) Error() string {
+	var messages []string
+	for field, fieldMessages := range e {
+		messages = append(messages, field+": "+strings.Join(fieldMessages, "; "))
+	}
+	return strings.Join(messages, ", ")
+}
+
 // HandleValidationErrors 处理gin的validation错误并返回详细的错误信息
 func HandleValidationErrors(c *gin.Context, err error) {
+	var fieldErrors FieldErrors
+	if errors.As(err, &fieldErrors) {
+		c.JSON(http.StatusBadRequest, types.ValidationErrorResponse{
+			Error:   "请求参数验证失败",
+			Details: fieldErrors,
+		})
+		return
+	}
+
 	var validationErrors validator.ValidationErrors
 	if errors.As(err, &validationErrors) {
 		details := make(map[string][]string)
@@ -50,8 +85,16 @@ func GetValidationErrorMessage(fe validator.FieldError) string {
 	case "oneof":
 		values := strings.Split(fe.Param(), " ")
 		return fmt.Sprintf("%s必须是以下值之一: %s", field, strings.Join(values, ", "))
+	case "base64":
+		return fmt.Sprintf("%s必须是有效的Base64编码", field)
+	case "secret_type":
+		return fmt.Sprintf("%s不是有效的信息项类型", field)
 	case "dive":
 		return fmt.Sprintf("%s包含无效的元素", field)
+	case "required_without":
+		return fmt.Sprintf("%s和%s必须提供其中之一", field, fe.Param())
+	case "excluded_with":
+		return fmt.Sprintf("%s和%s不能同时提供", field, fe.Param())
 	default:
 		return fmt.Sprintf("%s验证失败", field)
 	}
@@ -0,0 +1,330 @@
+package verify
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/akinoccc/hysaif/api/models"
+	"github.com/akinoccc/hysaif/api/packages/crypto"
+)
+
+const (
+	// batchSize 每批读取的记录数
+	batchSize = 100
+	// maxReportedFailures 报告中最多列出的无法解密记录数，超出部分只计数
+	maxReportedFailures = 1000
+	// maxQuarantineReasonLength 隔离原因的最大长度
+	maxQuarantineReasonLength = 500
+)
+
+// encryptedTables 需要校验的加密数据表
+var encryptedTables = []string{models.SecretItemTable, models.SecretItemHistoryTable, models.TransitKeyVersionTable, models.DatabaseLeaseTable}
+
+// ErrRunning 已有校验任务在执行
+var ErrRunning = errors.New("已有数据完整性校验正在执行")
+
+var mu sync.Mutex
+
+// Options 校验选项
+type Options struct {
+	Repair    bool          // 修复模式：隔离无法解密的记录，并解除已能正常解密的记录的隔离
+	OnFailure func(Failure) // 发现无法解密的记录时回调，用于命令行实时输出
+}
+
+// Report 校验报告
+type Report struct {
+	TotalRows       int64      `json:"total_rows"`       // 检查的记录数
+	ValidRows       int64      `json:"valid_rows"`       // 能正常解密的记录数
+	FailedRows      int64      `json:"failed_rows"`      // 无法解密的记录数
+	SkippedRows     int64      `json:"skipped_rows"`     // 当前部署未持有所属环境主密钥而跳过的记录数
+	QuarantinedRows int64      `json:"quarantined_rows"` // 本次隔离的记录数
+	ReleasedRows    int64      `json:"released_rows"`    // 本次解除隔离的记录数
+	Keys            []KeyUsage `json:"keys"`             // 各主密钥提供者和密钥版本的使用情况
+	Failures        []Failure  `json:"failures"`         // 无法解密的记录
+	Truncated       bool       `json:"truncated"`        // 无法解密的记录过多，列表被截断
+	Repair          bool       `json:"repair"`           // 是否为修复模式
+	StartedAt       uint64     `json:"started_at"`
+	FinishedAt      uint64     `json:"finished_at"`
+}
+
+// KeyUsage 使用某个主密钥提供者和密钥版本的记录数
+type KeyUsage struct {
+	Format     string `json:"format"`
+	Provider   string `json:"provider"`
+	KeyVersion string `json:"key_version"`
+	Rows       int64  `json:"rows"`
+	Failed     int64  `json:"failed"`
+}
+
+// Failure 无法解密的记录
+type Failure struct {
+	Table       string `json:"table"`
+	ID          string `json:"id"`
+	Format      string `json:"format"`
+	Provider    string `json:"provider"`
+	KeyVersion  string `json:"key_version"`
+	Error       string `json:"error"`
+	Quarantined bool   `json:"quarantined"` // 是否已隔离（包括之前已隔离的记录）
+}
+
+// encryptedRow 加密数据行，直接读取原始密文，不经过 SecretItemData 的解密逻辑
+type encryptedRow struct {
+	ID            string
+	SecretItemID  string
+	Data          *string
+	Environment   string
+	QuarantinedAt uint64
+}
+
+// verifier 单次校验的状态
+type verifier struct {
+	opts   Options
+	report *Report
+	usage  map[crypto.KeyInfo]*KeyUsage
+	// 当前批次中各主密钥提供者的可用性，提供者不可用时不隔离记录，避免把临时故障当作数据损坏
+	reachable map[string]error
+}
+
+// Run 逐批读取所有加密数据行并尝试解密，报告每行使用的主密钥提供者和密钥版本，列出无法解密的记录
+func Run(opts Options) (*Report, error) {
+	if !mu.TryLock() {
+		return nil, ErrRunning
+	}
+	defer mu.Unlock()
+
+	if crypto.IsSealed() {
+		return nil, crypto.ErrSealed
+	}
+
+	v := &verifier{
+		opts:   opts,
+		report: &Report{Repair: opts.Repair, StartedAt: uint64(time.Now().UnixMilli()), Failures: []Failure{}},
+		usage:  make(map[crypto.KeyInfo]*KeyUsage),
+	}
+
+	for _, table := range encryptedTables {
+		if err := v.verifyTable(table); err != nil {
+			return nil, err
+		}
+	}
+
+	v.report.Keys = make([]KeyUsage, 0, len(v.usage))
+	for _, usage := range v.usage {
+		v.report.Keys = append(v.report.Keys, *usage)
+	}
+	sort.Slice(v.report.Keys, func(i, j int) bool {
+		a, b := v.report.Keys[i], v.report.Keys[j]
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		if a.Format != b.Format {
+			return a.Format < b.Format
+		}
+		return a.KeyVersion < b.KeyVersion
+	})
+	v.report.FinishedAt = uint64(time.Now().UnixMilli())
+
+	return v.report, nil
+}
+
+// verifyTable 按ID顺序分批校验数据表
+func (v *verifier) verifyTable(table string) error {
+	lastID := ""
+	for {
+		if crypto.IsSealed() {
+			return crypto.ErrSealed
+		}
+
+		rows, err := readBatch(table, lastID)
+		if err != nil {
+			return fmt.Errorf("读取数据表 %s 失败: %w", table, err)
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+
+		v.reachable = make(map[string]error)
+		for _, row := range rows {
+			if err := v.verifyRow(table, row); err != nil {
+				return err
+			}
+		}
+		lastID = rows[len(rows)-1].ID
+	}
+}
+
+// verifyRow 校验单行数据
+func (v *verifier) verifyRow(table string, row encryptedRow) error {
+	v.report.TotalRows++
+	if row.Data == nil || *row.Data == "" {
+		v.report.ValidRows++
+		return nil
+	}
+
+	info, err := crypto.Inspect(*row.Data)
+	if err != nil {
+		info = &crypto.KeyInfo{Format: "unknown"}
+	} else {
+		err = openRow(table, row)
+	}
+
+	usage := v.usage[*info]
+	if usage == nil {
+		usage = &KeyUsage{Format: info.Format, Provider: info.Provider, KeyVersion: info.KeyVersion}
+		v.usage[*info] = usage
+	}
+	usage.Rows++
+
+	if err == nil {
+		v.report.ValidRows++
+		if v.opts.Repair && row.QuarantinedAt != 0 {
+			if err := setQuarantine(table, row, 0, ""); err != nil {
+				return err
+			}
+			v.report.ReleasedRows++
+		}
+		return nil
+	}
+
+	// 校验过程中系统被密封
+	if errors.Is(err, crypto.ErrSealed) {
+		return err
+	}
+
+	// 环境独立主密钥：其他部署的数据不计为损坏
+	if errors.Is(err, crypto.ErrEnvironmentKeyUnavailable) {
+		v.report.SkippedRows++
+		return nil
+	}
+
+	usage.Failed++
+	v.report.FailedRows++
+
+	failure := Failure{
+		Table:       table,
+		ID:          row.ID,
+		Format:      info.Format,
+		Provider:    info.Provider,
+		KeyVersion:  info.KeyVersion,
+		Error:       err.Error(),
+		Quarantined: row.QuarantinedAt != 0,
+	}
+
+	if v.opts.Repair && row.QuarantinedAt == 0 && canQuarantine(table) {
+		if providerErr := v.providerReachable(info.Provider); providerErr != nil {
+			failure.Error = fmt.Sprintf("%s（主密钥提供者不可用，未隔离: %v）", failure.Error, providerErr)
+		} else {
+			if err := setQuarantine(table, row, uint64(time.Now().UnixMilli()), quarantineReason(failure.Error)); err != nil {
+				return err
+			}
+			failure.Quarantined = true
+			v.report.QuarantinedRows++
+		}
+	}
+
+	if v.opts.OnFailure != nil {
+		v.opts.OnFailure(failure)
+	}
+	if len(v.report.Failures) < maxReportedFailures {
+		v.report.Failures = append(v.report.Failures, failure)
+	} else {
+		v.report.Truncated = true
+	}
+	return nil
+}
+
+// readBatch 按ID顺序读取一批加密数据行，临时用户密码所属的环境取自对应的数据库连接信息项
+func readBatch(table, lastID string) ([]encryptedRow, error) {
+	query := models.DB.Table(table).Select(selectColumns(table))
+	if table == models.DatabaseLeaseTable {
+		query = query.Joins("LEFT JOIN secret_items ON secret_items.id = database_leases.secret_item_id")
+	}
+
+	var rows []encryptedRow
+	err := query.
+		Where(table+".id > ?", lastID).
+		Order(table + ".id").
+		Limit(batchSize).
+		Find(&rows).Error
+	return rows, err
+}
+
+// selectColumns 读取加密数据行所需的列，密文统一读取为 data；托管密钥版本不区分环境，托管密钥版本和临时用户密码不支持隔离
+func selectColumns(table string) string {
+	switch table {
+	case models.TransitKeyVersionTable:
+		return "id, data"
+	case models.DatabaseLeaseTable:
+		return "database_leases.id, database_leases.password AS data, secret_items.environment"
+	case models.SecretItemHistoryTable:
+		return "id, secret_item_id, data, environment, quarantined_at"
+	}
+	return "id, id AS secret_item_id, data, environment, quarantined_at"
+}
+
+// canQuarantine 数据表是否支持隔离无法解密的记录
+func canQuarantine(table string) bool {
+	return table == models.SecretItemTable || table == models.SecretItemHistoryTable
+}
+
+// openRow 尝试解密数据行，信息项数据按 SecretItemData 解析（包括读取Vault KV），托管密钥版本和临时用户密码只校验能否解密
+func openRow(table string, row encryptedRow) error {
+	switch table {
+	case models.TransitKeyVersionTable:
+		_, err := crypto.DecryptWith(*row.Data, crypto.Options{Table: table, ID: row.ID, Field: models.SecretDataField})
+		return err
+	case models.DatabaseLeaseTable:
+		opts := crypto.Options{Environment: row.Environment, Table: table, ID: row.ID, Field: models.DatabaseLeasePasswordField}
+		_, err := crypto.DecryptWith(*row.Data, opts)
+		return err
+	}
+
+	opts := crypto.Options{Environment: row.Environment, Table: table, ID: row.ID, Field: models.SecretDataField}
+	_, err := models.OpenSecretItemData(*row.Data, opts, row.SecretItemID)
+	return err
+}
+
+// providerReachable 检查主密钥提供者当前是否可用，每批只检查一次
+func (v *verifier) providerReachable(providerID string) error {
+	if providerID == "" {
+		return nil
+	}
+	if err, ok := v.reachable[providerID]; ok {
+		return err
+	}
+
+	provider, err := crypto.GetProvider(providerID)
+	if err == nil {
+		if versioner, ok := provider.(crypto.KeyVersioner); ok {
+			_, err = versioner.ActiveKeyVersion()
+		}
+	}
+	v.reachable[providerID] = err
+	return err
+}
+
+// setQuarantine 设置或解除数据行的隔离，仅当数据未被并发修改时才写入
+func setQuarantine(table string, row encryptedRow, quarantinedAt uint64, reason string) error {
+	err := models.DB.Table(table).
+		Where("id = ? AND data = ?", row.ID, *row.Data).
+		UpdateColumns(map[string]interface{}{
+			"quarantined_at":    quarantinedAt,
+			"quarantine_reason": reason,
+		}).Error
+	if err != nil {
+		return fmt.Errorf("更新数据行 %s 的隔离状态失败: %w", row.ID, err)
+	}
+	return nil
+}
+
+// quarantineReason 截断过长的错误信息
+func quarantineReason(message string) string {
+	runes := []rune(message)
+	if len(runes) > maxQuarantineReasonLength {
+		return string(runes[:maxQuarantineReasonLength])
+	}
+	return message
+}
@@ -0,0 +1,66 @@
+package verify
+
+import (
+	"os"
+	"testing"
+
+	"github.com/akinoccc/hysaif/api/config"
+	"github.com/akinoccc/hysaif/api/models"
+
+	"gorm.io/gorm/logger"
+)
+
+// TestMain 使用内存SQLite数据库和本地AES主密钥初始化校验测试
+func TestMain(m *testing.M) {
+	config.AppConfig = &config.Config{}
+	config.AppConfig.Database.Type = "sqlite"
+	config.AppConfig.Database.Path = "file:verify_test?mode=memory&cache=shared"
+	config.AppConfig.Security.EncryptionKey = "0123456789abcdef0123456789abcdef"
+	config.AppConfig.Security.KeyProvider = "aes"
+
+	models.InitDB()
+	models.DB.Logger = logger.Discard
+
+	os.Exit(m.Run())
+}
+
+func TestRunVerifiesTransitKeyVersions(t *testing.T) {
+	key, err := models.CreateTransitKey("verify-test", "")
+	if err != nil {
+		t.Fatalf("创建托管密钥失败: %v", err)
+	}
+
+	report, err := Run(Options{})
+	if err != nil {
+		t.Fatalf("校验失败: %v", err)
+	}
+	if report.FailedRows != 0 || report.TotalRows == 0 {
+		t.Fatalf("期望托管密钥版本校验通过，报告: %+v", report)
+	}
+
+	// 将版本1的密文复制到版本2，密文绑定的数据行ID不匹配，解密应失败
+	var version models.TransitKeyVersion
+	if err := models.DB.Where("transit_key_id = ?", key.ID).First(&version).Error; err != nil {
+		t.Fatalf("读取托管密钥版本失败: %v", err)
+	}
+	if err := key.Rotate(""); err != nil {
+		t.Fatalf("轮换托管密钥失败: %v", err)
+	}
+	if err := models.DB.Model(&models.TransitKeyVersion{}).
+		Where("transit_key_id = ? AND version = ?", key.ID, 2).
+		UpdateColumn("data", version.Data).Error; err != nil {
+		t.Fatalf("篡改托管密钥版本失败: %v", err)
+	}
+
+	report, err = Run(Options{Repair: true})
+	if err != nil {
+		t.Fatalf("校验失败: %v", err)
+	}
+	if report.FailedRows != 1 || len(report.Failures) != 1 {
+		t.Fatalf("期望1条无法解密的记录，报告: %+v", report)
+	}
+	failure := report.Failures[0]
+	if failure.Table != models.TransitKeyVersionTable || failure.Quarantined {
+		t.Fatalf("无法解密的托管密钥版本应只报告不隔离: %+v", failure)
+	}
+}
@@ -3,6 +3,7 @@ package router
 import (
 	"github.com/akinoccc/hysaif/api/handlers"
 	"github.com/akinoccc/hysaif/api/middleware"
+	"github.com/akinoccc/hysaif/api/models"
 	"github.com/akinoccc/hysaif/api/types"
 
 	"github.com/gin-gonic/gin"
@@ -28,10 +29,30 @@ func InitRouter(r *gin.Engine) {
 			auth.POST("/webauthn/login/finish", handlers.WebAuthnFinishLogin)
 		}
 
+		// 密封状态（无需认证，便于在解封前查看进度）
+		api.GET("/sys/seal-status", handlers.GetSealStatus)
+
 		// 需要认证的路由
 		protected := api.Group("/")
 		protected.Use(middleware.AuthRequired())
 		{
+			// 密封/解封
+			sys := protected.Group("/sys")
+			{
+				// 持有分片的用户均可提交解封分片
+				sys.POST("/unseal",
+					middleware.AuditLog(types.AuditLogActionUnseal, types.AuditLogResourceSystem),
+					handlers.Unseal)
+				sys.POST("/unseal/reset",
+					middleware.RequirePermission("system", "seal"),
+					middleware.AuditLog(types.AuditLogActionReset, types.AuditLogResourceSystem),
+					handlers.ResetUnseal)
+				sys.POST("/seal",
+					middleware.RequirePermission("system", "seal"),
+					middleware.AuditLog(types.AuditLogActionSeal, types.AuditLogResourceSystem),
+					handlers.Seal)
+			}
+
 			// 用户管理
 			users := protected.Group("/users")
 			users.Use(middleware.AutoAuditLog(types.AuditLogResourceUser))
@@ -39,6 +60,7 @@ func InitRouter(r *gin.Engine) {
 				// 个人资料相关（所有用户都可以访问自己的资料）
 				users.GET("/profile", handlers.GetProfile)
 				users.PUT("/profile", handlers.UpdateProfile)
+				users.PUT("/profile/public-key", handlers.UpdatePublicKey)
 				users.GET("/login-history", handlers.GetLoginHistory)
 
 				// WebAuthn 凭证管理
@@ -57,6 +79,7 @@ func InitRouter(r *gin.Engine) {
 
 			// 信息项管理
 			items := protected.Group("/items")
+			items.Use(middleware.RequireUnsealed())
 			{
 				items.GET("/", middleware.RequirePermission("secret", "read"), handlers.GetSecretItems)
 				items.POST("/", middleware.RequirePermission("secret", "create"), handlers.CreateSecretItem)
@@ -64,21 +87,127 @@ func InitRouter(r *gin.Engine) {
 				items.PUT("/:id", middleware.RequirePermission("secret", "update"), handlers.UpdateSecretItem)
 				items.DELETE("/:id", middleware.RequirePermission("secret", "delete"), handlers.DeleteSecretItem)
 
+				// 盲索引精确查找（请求体中的字段值不写入审计日志）
+				items.POST("/lookup",
+					middleware.RequirePermission("secret", "lookup"),
+					middleware.AuditLog(types.AuditLogActionLookup, types.AuditLogResourceBlindIndex),
+					handlers.LookupSecretItems)
+
 				// 版本历史管理
 				items.GET("/:id/history", middleware.RequirePermission("secret", "read"), handlers.GetSecretItemHistory)
 				items.GET("/:id/history/:version", middleware.RequirePermission("secret", "read"), handlers.GetSecretItemHistoryByVersion)
 				items.POST("/:id/restore", middleware.RequirePermission("secret", "update"), handlers.RestoreSecretItemFromHistory)
 				items.POST("/:id/compare", middleware.RequirePermission("secret", "read"), handlers.CompareSecretItemVersions)
 
+				// 移动到其他文件夹（在处理函数中记录包含移动前后文件夹的审计日志）
+				items.POST("/:id/move", middleware.RequirePermission("secret", "update"), handlers.MoveSecretItem)
+
+				// 自动轮换（立即轮换在处理函数中记录包含轮换结果的审计日志）
+				items.GET("/:id/rotation", middleware.RequirePermission("secret", "read"), handlers.GetRotationPolicy)
+				items.PUT("/:id/rotation", middleware.RequirePermission("secret", "update"), handlers.PutRotationPolicy)
+				items.DELETE("/:id/rotation", middleware.RequirePermission("secret", "update"), handlers.DeleteRotationPolicy)
+				items.POST("/:id/rotate", middleware.RequirePermission("secret", "update"), handlers.RotateSecretItem)
+
 				// 通过申请访问密钥项（所有用户都可以使用）
 				items.GET("/:id/access",
 					middleware.AuditLog(types.AuditLogActionAccess, types.AuditLogResourceCustom),
 					handlers.GetItemWithAccessCheck)
 
+				// 获取TOTP信息项的当前验证码（持有访问申请的用户可以使用，不返回种子）
+				items.GET("/:id/totp",
+					middleware.AuditLog(types.AuditLogActionCode, types.AuditLogResourceTOTP),
+					handlers.GetTOTPCode)
+
 				// 获取用户有访问权限的信息项
 				items.GET("/accessed", handlers.GetAccessedSecretItems)
 			}
 
+			// 回收站（恢复和清除只对创建者和管理员开放，清除在处理函数中记录包含信息项名称的审计日志）
+			recycleBin := protected.Group("/recycle-bin")
+			recycleBin.Use(middleware.RequireUnsealed(), middleware.RequirePermission("secret", "delete"))
+			{
+				recycleBin.GET("/", handlers.GetRecycleBinItems)
+				recycleBin.POST("/:id/restore", handlers.RestoreRecycleBinItem)
+				recycleBin.DELETE("/:id", handlers.PurgeRecycleBinItem)
+			}
+
+			// 密钥生成（生成结果不保存，需要创建信息项的权限）
+			generate := protected.Group("/generate")
+			generate.Use(middleware.RequirePermission("secret", "create"))
+			{
+				generate.POST("/ssh-key", handlers.GenerateSSHKey)
+				generate.POST("/password", handlers.GeneratePassword)
+			}
+
+			// 自定义信息项类型，所有可读取信息项的用户均可获取字段定义
+			secretTypes := protected.Group("/secret-types")
+			{
+				secretTypes.GET("/", middleware.RequirePermission("secret", "read"), handlers.GetSecretTypes)
+				secretTypes.GET("/:name", middleware.RequirePermission("secret", "read"), handlers.GetSecretType)
+				secretTypes.POST("/",
+					middleware.RequirePermission("secret_type", "create"),
+					middleware.AuditLog(types.AuditLogActionCreate, types.AuditLogResourceSecretType),
+					handlers.CreateSecretType)
+				secretTypes.PUT("/:name",
+					middleware.RequirePermission("secret_type", "update"),
+					middleware.AuditLog(types.AuditLogActionUpdate, types.AuditLogResourceSecretType),
+					handlers.UpdateSecretType)
+				secretTypes.DELETE("/:name",
+					middleware.RequirePermission("secret_type", "delete"),
+					middleware.AuditLog(types.AuditLogActionDelete, types.AuditLogResourceSecretType),
+					handlers.DeleteSecretType)
+			}
+
+			// 文件夹，权限可按文件夹授予：folder 适用于所有文件夹，folder:<路径> 适用于该文件夹及其所有子文件夹；
+			// 文件夹的 read 权限可以查看其中所有信息项
+			folders := protected.Group("/folders")
+			{
+				folders.GET("/", middleware.RequirePermission("secret", "read"), handlers.GetFolders)
+				folders.GET("/:id", middleware.RequirePermission("secret", "read"), handlers.GetFolder)
+				folders.POST("/",
+					middleware.AuditLog(types.AuditLogActionCreate, types.AuditLogResourceFolder),
+					handlers.CreateFolder)
+				folders.DELETE("/:id",
+					middleware.AuditLog(types.AuditLogActionDelete, types.AuditLogResourceFolder),
+					handlers.DeleteFolder)
+			}
+
+			// 托管密钥（加密即服务），权限可按密钥授予：transit 适用于所有密钥，transit:<密钥名> 仅适用于单个密钥
+			transit := protected.Group("/transit")
+			transit.Use(middleware.RequireUnsealed())
+			{
+				transit.GET("/keys", handlers.GetTransitKeys)
+				transit.POST("/keys",
+					middleware.RequirePermission(models.TransitPermissionResource, "create"),
+					middleware.AuditLog(types.AuditLogActionCreate, types.AuditLogResourceTransitKey),
+					handlers.CreateTransitKey)
+				transit.GET("/keys/:name",
+					middleware.RequireTransitKeyPermission("read"),
+					handlers.GetTransitKey)
+				transit.POST("/keys/:name/rotate",
+					middleware.RequireTransitKeyPermission("rotate"),
+					middleware.AuditLog(types.AuditLogActionRotate, types.AuditLogResourceTransitKey),
+					handlers.RotateTransitKey)
+				transit.PUT("/keys/:name/config",
+					middleware.RequireTransitKeyPermission("update"),
+					middleware.AuditLog(types.AuditLogActionUpdate, types.AuditLogResourceTransitKey),
+					handlers.UpdateTransitKeyConfig)
+
+				// 请求体中的明文和密文不写入审计日志
+				transit.POST("/encrypt/:name",
+					middleware.RequireTransitKeyPermission("encrypt"),
+					middleware.AuditLog(types.AuditLogActionEncrypt, types.AuditLogResourceTransitKey),
+					handlers.TransitEncrypt)
+				transit.POST("/decrypt/:name",
+					middleware.RequireTransitKeyPermission("decrypt"),
+					middleware.AuditLog(types.AuditLogActionDecrypt, types.AuditLogResourceTransitKey),
+					handlers.TransitDecrypt)
+				transit.POST("/rewrap/:name",
+					middleware.RequireTransitKeyPermission("rewrap"),
+					middleware.AuditLog(types.AuditLogActionRewrap, types.AuditLogResourceTransitKey),
+					handlers.TransitRewrap)
+			}
+
 			// 访问申请管理
 			access := protected.Group("/access-requests")
 			{
@@ -90,9 +219,10 @@ func InitRouter(r *gin.Engine) {
 				access.GET("/",
 					middleware.AutoAuditLog(types.AuditLogResourceAccessRequest),
 					handlers.GetAccessRequests)
-				// 审批申请（需要管理权限）
+				// 审批申请（需要管理权限）；批准和作废可能需要签发或删除数据库临时用户，系统密封时返回 503
 				access.PUT("/:id/approve",
 					middleware.RequirePermission("access_request", "approve"),
+					middleware.RequireUnsealed(),
 					middleware.AuditLog(types.AuditLogActionApprove, types.AuditLogResourceAccessRequest),
 					handlers.ApproveAccessRequest)
 				access.PUT("/:id/reject",
@@ -101,6 +231,7 @@ func InitRouter(r *gin.Engine) {
 					handlers.RejectAccessRequest)
 				access.PUT("/:id/revoke",
 					middleware.RequirePermission("access_request", "approve"),
+					middleware.RequireUnsealed(),
 					middleware.AuditLog(types.AuditLogActionRevoke, types.AuditLogResourceAccessRequest),
 					handlers.RevokeAccessRequest)
 			}
@@ -152,6 +283,43 @@ func InitRouter(r *gin.Engine) {
 			{
 				audit.GET("/logs", middleware.RequirePermission("audit", "read"), handlers.GetAuditLogs)
 			}
+
+			// 系统管理
+			admin := protected.Group("/admin")
+			{
+				// 主密钥轮换
+				admin.GET("/key-rotation", middleware.RequirePermission("key_management", "read"), handlers.GetKeyRotationStatus)
+				admin.POST("/key-rotation",
+					middleware.RequirePermission("key_management", "rotate"),
+					middleware.RequireUnsealed(),
+					middleware.AuditLog(types.AuditLogActionRotate, types.AuditLogResourceKeyRotation),
+					handlers.StartKeyRotation)
+
+				// Vault Transit密钥轮换与重新包装
+				admin.GET("/vault/transit-key", middleware.RequirePermission("key_management", "read"), handlers.GetVaultKeyStatus)
+				admin.POST("/vault/rotate",
+					middleware.RequirePermission("key_management", "rotate"),
+					middleware.AuditLog(types.AuditLogActionRotate, types.AuditLogResourceKeyRotation),
+					handlers.RotateVaultKey)
+				admin.PUT("/vault/min-decryption-version",
+					middleware.RequirePermission("key_management", "rotate"),
+					middleware.AuditLog(types.AuditLogActionUpdate, types.AuditLogResourceKeyRotation),
+					handlers.SetVaultMinDecryptionVersion)
+
+				// 盲索引重建
+				admin.POST("/blind-index/rebuild",
+					middleware.RequirePermission("key_management", "rotate"),
+					middleware.RequireUnsealed(),
+					middleware.AuditLog(types.AuditLogActionRebuild, types.AuditLogResourceBlindIndex),
+					handlers.RebuildBlindIndexes)
+
+				// 加密数据完整性校验
+				admin.POST("/verify",
+					middleware.RequirePermission("key_management", "rotate"),
+					middleware.RequireUnsealed(),
+					middleware.AuditLog(types.AuditLogActionVerify, types.AuditLogResourceIntegrity),
+					handlers.VerifyEncryptedData)
+			}
 		}
 	}
 }
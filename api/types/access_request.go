@@ -2,13 +2,15 @@ package types
 
 // 访问申请相关类型
 type CreateAccessRequestRequest struct {
-	SecretItemID string `json:"secret_item_id" binding:"required"`
+	SecretItemID string `json:"secret_item_id" binding:"required_without=FolderID,excluded_with=FolderID"`
+	FolderID     string `json:"folder_id"` // 申请访问文件夹，批准后可访问文件夹及其子文件夹中的所有信息项
 	Reason       string `json:"reason" binding:"required,min=5,max=500"`
 }
 
 type ApproveAccessRequestRequest struct {
 	ValidDuration int    `json:"valid_duration" binding:"required,min=1,max=8760"` // 有效时长（小时），最大365天
 	Note          string `json:"note" binding:"max=500"`                           // 审批备注
+	WrappedKey    string `json:"wrapped_key" binding:"omitempty,base64,max=4096"`  // 端到端加密信息项：审批人客户端使用申请人公钥包装的内容密钥
 }
 
 type RejectAccessRequestRequest struct {
@@ -25,6 +27,7 @@ type AccessRequestListParams struct {
 	Status       string `form:"status" binding:"omitempty,oneof=pending approved rejected expired revoked"` // pending, approved, rejected, expired, revoked
 	ApplicantID  string `form:"applicant_id"`                                                               // 申请人ID
 	SecretItemID string `form:"secret_item_id"`                                                             // 密钥项ID
+	FolderID     string `form:"folder_id"`                                                                  // 文件夹ID
 	SortBy       string `form:"sort_by"`
 	SortDesc     bool   `form:"sort_desc"`
 }
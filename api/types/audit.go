@@ -9,18 +9,45 @@ const (
 	AuditLogResourceToken         = "token"
 	AuditLogResourceCustom        = "custom"
 	AuditLogResourceAccessRequest = "access_request"
+	AuditLogResourceKeyRotation   = "key_rotation"
+	AuditLogResourceEncryption    = "encryption"
+	AuditLogResourceSystem        = "system"
+	AuditLogResourceBlindIndex    = "blind_index"
+	AuditLogResourceIntegrity     = "integrity"
+	AuditLogResourceTransitKey    = "transit_key"
+	AuditLogResourceSecretType    = "secret_type"
+	AuditLogResourceFolder        = "folder"
+	AuditLogResourceDatabase      = "database"
+	AuditLogResourceRotation      = "rotation_policy"
+	AuditLogResourceTOTP          = "totp"
 )
 
 const (
-	AuditLogActionLogin   = "login"
-	AuditLogActionLogout  = "logout"
-	AuditLogActionCreate  = "create"
-	AuditLogActionUpdate  = "update"
-	AuditLogActionDelete  = "delete"
-	AuditLogActionRead    = "read"
-	AuditLogActionRequest = "request" // 申请访问
-	AuditLogActionApprove = "approve" // 批准申请
-	AuditLogActionReject  = "reject"  // 拒绝申请
-	AuditLogActionRevoke  = "revoke"  // 撤销申请
-	AuditLogActionAccess  = "access"  // 通过申请访问密钥
+	AuditLogActionLogin    = "login"
+	AuditLogActionLogout   = "logout"
+	AuditLogActionCreate   = "create"
+	AuditLogActionUpdate   = "update"
+	AuditLogActionDelete   = "delete"
+	AuditLogActionRead     = "read"
+	AuditLogActionRequest  = "request"  // 申请访问
+	AuditLogActionApprove  = "approve"  // 批准申请
+	AuditLogActionReject   = "reject"   // 拒绝申请
+	AuditLogActionRevoke   = "revoke"   // 撤销申请
+	AuditLogActionAccess   = "access"   // 通过申请访问密钥
+	AuditLogActionRotate   = "rotate"   // 轮换密钥
+	AuditLogActionFallback = "fallback" // 加密回退到本地AES
+	AuditLogActionSeal     = "seal"     // 密封系统
+	AuditLogActionUnseal   = "unseal"   // 提交解封分片
+	AuditLogActionReset    = "reset"    // 清空已提交的解封分片
+	AuditLogActionLookup   = "lookup"   // 通过盲索引查找密钥项
+	AuditLogActionRebuild  = "rebuild"  // 重建盲索引
+	AuditLogActionVerify   = "verify"   // 校验加密数据完整性
+	AuditLogActionEncrypt  = "encrypt"  // 使用托管密钥加密
+	AuditLogActionDecrypt  = "decrypt"  // 使用托管密钥解密
+	AuditLogActionRewrap   = "rewrap"   // 使用托管密钥重新加密到最新版本
+	AuditLogActionMove     = "move"     // 移动信息项到其他文件夹
+	AuditLogActionRefer    = "refer"    // 通过其他信息项的引用读取信息项
+	AuditLogActionCode     = "code"     // 获取TOTP信息项的当前验证码
+	AuditLogActionRestore  = "restore"  // 从回收站恢复信息项
+	AuditLogActionPurge    = "purge"    // 清除回收站中的信息项
 )
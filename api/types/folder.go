@@ -0,0 +1,24 @@
+package types
+
+import "github.com/akinoccc/hysaif/api/models"
+
+// 文件夹相关类型
+type CreateFolderRequest struct {
+	Name        string `json:"name" binding:"required,max=64"`          // 文件夹名称，不能包含 /
+	ParentID    string `json:"parent_id,omitempty"`                     // 上级文件夹ID，为空时创建在根目录
+	Description string `json:"description,omitempty" binding:"max=500"` // 描述
+}
+
+type FolderListResponse struct {
+	Data []models.Folder `json:"data"` // 按路径排序的全部文件夹
+}
+
+type FolderResponse struct {
+	models.Folder
+	Breadcrumbs []models.FolderBreadcrumb `json:"breadcrumbs"` // 由根目录到当前文件夹的面包屑路径
+}
+
+type MoveSecretItemRequest struct {
+	FolderID string `json:"folder_id"`                // 目标文件夹ID，为空表示移动到根目录
+	Reason   string `json:"reason" binding:"max=500"` // 移动原因，记录在变更历史中
+}
@@ -0,0 +1,27 @@
+package types
+
+import "github.com/akinoccc/hysaif/api/packages/password"
+
+// 密钥生成相关类型
+type GenerateSSHKeyRequest struct {
+	Algorithm  string `json:"algorithm" binding:"required,oneof=ed25519 rsa ecdsa"`
+	Bits       int    `json:"bits,omitempty" binding:"omitempty,oneof=256 384 521 2048 3072 4096"` // RSA默认4096，ECDSA默认256，ed25519不支持指定
+	Comment    string `json:"comment,omitempty" binding:"max=255"`
+	Passphrase string `json:"passphrase,omitempty" binding:"max=1024"` // 不为空时私钥使用passphrase加密
+}
+
+type GeneratePasswordRequest struct {
+	Mode             string   `json:"mode,omitempty" binding:"omitempty,oneof=random passphrase"`                                // random（默认）或 passphrase
+	Length           int      `json:"length,omitempty" binding:"omitempty,min=8,max=128"`                                        // 随机密码长度，默认20
+	Classes          []string `json:"classes,omitempty" binding:"omitempty,max=4,dive,oneof=lowercase uppercase digits symbols"` // 字符类别，默认全部启用
+	ExcludeAmbiguous bool     `json:"exclude_ambiguous,omitempty"`                                                               // 排除容易混淆的字符
+	Words            int      `json:"words,omitempty" binding:"omitempty,min=4,max=20"`                                          // 口令短语单词数量，默认6
+	Separator        string   `json:"separator,omitempty" binding:"max=5"`                                                       // 口令短语分隔符，默认为"-"
+	Capitalize       bool     `json:"capitalize,omitempty"`                                                                      // 口令短语单词首字母大写
+	IncludeNumber    bool     `json:"include_number,omitempty"`                                                                  // 口令短语追加一位数字
+}
+
+type GeneratePasswordResponse struct {
+	Password string            `json:"password"`
+	Strength password.Strength `json:"strength"`
+}
@@ -0,0 +1,44 @@
+package types
+
+import (
+	"github.com/akinoccc/hysaif/api/models"
+	"github.com/akinoccc/hysaif/api/packages/crypto"
+)
+
+// 密钥轮换相关类型
+type KeyRotationStatusResponse struct {
+	ActiveKey *crypto.KeyInfo        `json:"active_key"` // 当前活动主密钥
+	Running   bool                   `json:"running"`    // 是否有任务正在执行
+	Progress  float64                `json:"progress"`   // 最近一次任务的进度百分比
+	Job       *models.KeyRotationJob `json:"job"`        // 最近一次任务
+
+	EncryptionPolicy string `json:"encryption_policy"` // 全局加密策略
+	FallbackCount    uint64 `json:"fallback_count"`    // 进程启动以来回退到本地AES的次数
+}
+
+type VaultRewrapRequest struct {
+	KeyName    string `json:"key_name"`    // 要轮换的Transit密钥，为空时轮换全局及所有环境独立的Transit密钥
+	SkipRotate bool   `json:"skip_rotate"` // 为true时不轮换Transit密钥，仅重新包装到当前最新版本
+}
+
+type SetMinDecryptionVersionRequest struct {
+	KeyName string `json:"key_name"`                         // Transit密钥名称，为空时为全局Transit密钥
+	Version int    `json:"version" binding:"required,min=1"` // 最低解密版本，不能高于安全版本
+}
+
+type VaultTransitKeyStatus struct {
+	*crypto.TransitKeyInfo
+	SafeMinDecryptionVersion int `json:"safe_min_decryption_version"` // 可安全设置的最低解密版本，0表示尚无完整的重新包装记录
+}
+
+type VaultKeyStatusResponse struct {
+	TransitKeys []VaultTransitKeyStatus `json:"transit_keys"` // 全局及各环境独立的Transit密钥版本信息
+	Running     bool                    `json:"running"`      // 是否有任务正在执行
+	Progress    float64                 `json:"progress"`     // 最近一次重新包装任务的进度百分比
+	Job         *models.KeyRotationJob  `json:"job"`          // 最近一次重新包装任务
+}
+
+// 数据完整性校验相关类型
+type VerifyRequest struct {
+	Repair bool `json:"repair"` // 为true时隔离无法解密的记录，并解除已能正常解密的记录的隔离
+}
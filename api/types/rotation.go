@@ -0,0 +1,17 @@
+package types
+
+import "github.com/akinoccc/hysaif/api/models"
+
+// 自动轮换相关类型
+type PutRotationPolicyRequest struct {
+	Enabled       bool                  `json:"enabled"`                                 // 是否启用自动轮换
+	Rotator       string                `json:"rotator" binding:"required"`              // 轮换器：password, token, ssh_key, webhook, script
+	IntervalHours int                   `json:"interval_hours" binding:"required,min=1"` // 轮换间隔（小时）
+	WindowStart   string                `json:"window_start,omitempty"`                  // 轮换窗口开始时间，HH:MM 格式的UTC时间
+	WindowEnd     string                `json:"window_end,omitempty"`                    // 轮换窗口结束时间，早于开始时间表示跨越零点
+	Options       models.RotatorOptions `json:"options"`                                 // 轮换器选项
+}
+
+type RotateSecretItemRequest struct {
+	Reason string `json:"reason" binding:"max=500"` // 轮换原因，记录在变更历史中
+}
@@ -5,20 +5,34 @@ import "github.com/akinoccc/hysaif/api/models"
 // 密钥项相关类型
 type PostItemRequest struct {
 	Name        string                `json:"name" binding:"required,min=1,max=100"`
-	Type        string                `json:"type" binding:"required,oneof=password api_key access_key ssh_key certificate token kv"` // password, api_key, access_key, ssh_key, certificate, token, kv
+	Type        string                `json:"type" binding:"required,secret_type"` // 内置类型或管理员定义的自定义类型
 	Description string                `json:"description,omitempty" binding:"max=500"`
 	Category    string                `json:"category" binding:"required,min=1,max=50"`
 	Environment string                `json:"environment" binding:"required,oneof=development test production staging local"`
 	Tags        []string              `json:"tags,omitempty" gorm:"type:text;serializer:json"`
 	Data        models.SecretItemData `json:"data" binding:"required" gorm:"type:text;serializer:json"`
 	ExpiresAt   uint64                `json:"expires_at,omitempty"`
+	FolderID    string                `json:"folder_id,omitempty"` // 所在文件夹ID，仅创建时使用，之后通过移动接口变更
+
+	// 端到端加密，启用后忽略data，由客户端提交加密后的数据和各接收者的包装密钥
+	E2E        bool           `json:"e2e"`
+	Payload    string         `json:"payload,omitempty" binding:"omitempty,base64,max=1048576"`
+	Recipients []RecipientKey `json:"recipients,omitempty" binding:"omitempty,max=100,dive"` // 创建时必须包含创建者；更新时提供则替换全部接收者
+}
+
+// RecipientKey 使用接收者公钥包装的内容密钥
+type RecipientKey struct {
+	UserID     string `json:"user_id" binding:"required"`
+	WrappedKey string `json:"wrapped_key" binding:"required,base64,max=4096"`
 }
 
 type ItemsListParams struct {
 	Page     int    `form:"page" binding:"omitempty,min=1"`
 	PageSize int    `form:"page_size" binding:"omitempty,min=1,max=100"`
 	Category string `form:"category"`
-	Type     string `form:"type" binding:"omitempty,oneof=password api_key access_key ssh_key certificate token kv"`
+	FolderID string `form:"folder_id"` // 只返回该文件夹中的信息项，root 表示根目录
+	Folder   string `form:"folder"`    // 返回该路径的文件夹及其所有子文件夹中的信息项
+	Type     string `form:"type" binding:"omitempty,secret_type"`
 	Search   string `form:"search"`
 	SortBy   string `form:"sort_by"`
 	SortDesc bool   `form:"sort_desc"`
@@ -45,3 +59,47 @@ type VersionComparisonResponse struct {
 	Version2 int                    `json:"version2"`
 	Changes  map[string]interface{} `json:"changes"`
 }
+
+// 盲索引查找相关类型
+type LookupSecretItemsRequest struct {
+	Value  string   `json:"value" binding:"required,max=4096"`
+	Fields []string `json:"fields,omitempty" binding:"omitempty,dive,oneof=username password api_key access_key endpoint"`
+}
+
+type SecretItemLookupResult struct {
+	ID            string   `json:"id"`
+	Name          string   `json:"name"`
+	Type          string   `json:"type"`
+	Category      string   `json:"category"`
+	Environment   string   `json:"environment"`
+	MatchedFields []string `json:"matched_fields"`
+}
+
+type LookupSecretItemsResponse struct {
+	Data []SecretItemLookupResult `json:"data"`
+}
+
+type RebuildBlindIndexResponse struct {
+	Items int `json:"items"`
+}
+
+type TOTPCodeResponse struct {
+	Code             string `json:"code"`              // 当前验证码
+	Period           int    `json:"period"`            // 验证码有效期（秒）
+	RemainingSeconds int    `json:"remaining_seconds"` // 当前验证码的剩余有效时间（秒）
+	ExpiresAt        uint64 `json:"expires_at"`        // 当前验证码的失效时间
+}
+
+// 回收站相关类型
+type RecycleBinItem struct {
+	ID          string       `json:"id"`
+	Name        string       `json:"name"`
+	Type        string       `json:"type"`
+	Category    string       `json:"category"`
+	Environment string       `json:"environment"`
+	FolderID    string       `json:"folder_id"`
+	Creator     *models.User `json:"creator"`
+	DeletedBy   *models.User `json:"deleted_by"`
+	DeletedAt   uint64       `json:"deleted_at"` // 删除时间
+	PurgeAt     uint64       `json:"purge_at"`   // 自动清除的时间
+}
@@ -0,0 +1,24 @@
+package types
+
+import "github.com/akinoccc/hysaif/api/models"
+
+// 自定义信息项类型相关类型
+type CreateSecretTypeRequest struct {
+	Name        string                  `json:"name" binding:"required,max=50"` // 类型标识，以小写字母开头，只能包含小写字母、数字和下划线
+	Title       string                  `json:"title" binding:"required,max=50"`
+	Icon        string                  `json:"icon,omitempty" binding:"max=50"`
+	Description string                  `json:"description,omitempty" binding:"max=500"`
+	Schema      models.SecretTypeSchema `json:"schema" binding:"required"`
+}
+
+type UpdateSecretTypeRequest struct {
+	Title       string                  `json:"title" binding:"required,max=50"`
+	Icon        string                  `json:"icon,omitempty" binding:"max=50"`
+	Description string                  `json:"description,omitempty" binding:"max=500"`
+	Schema      models.SecretTypeSchema `json:"schema" binding:"required"`
+}
+
+type SecretTypeListResponse struct {
+	Builtin []string            `json:"builtin"` // 内置类型
+	Data    []models.SecretType `json:"data"`    // 自定义类型
+}
@@ -0,0 +1,6 @@
+package types
+
+// 密封/解封相关类型
+type UnsealRequest struct {
+	Share string `json:"share" binding:"required"` // base64编码的解封分片
+}
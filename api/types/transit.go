@@ -0,0 +1,35 @@
+package types
+
+import "github.com/akinoccc/hysaif/api/models"
+
+// 托管密钥（加密即服务）相关类型
+type CreateTransitKeyRequest struct {
+	Name string `json:"name" binding:"required,max=64"` // 密钥名称，只能包含字母、数字、下划线和连字符
+}
+
+type TransitKeyListResponse struct {
+	Data []models.TransitKey `json:"data"` // 当前用户有读取权限的托管密钥
+}
+
+type UpdateTransitKeyConfigRequest struct {
+	MinDecryptionVersion int `json:"min_decryption_version" binding:"required,min=1"` // 允许解密的最低版本
+}
+
+type TransitEncryptRequest struct {
+	Plaintext      string `json:"plaintext" binding:"required,base64,max=1048576"`     // Base64编码的明文
+	AssociatedData string `json:"associated_data" binding:"omitempty,base64,max=4096"` // Base64编码的附加认证数据，解密时需提供相同的值
+}
+
+type TransitEncryptResponse struct {
+	Ciphertext string `json:"ciphertext"`  // 密文，格式为 hysaif:v<版本>:<Base64>
+	KeyVersion int    `json:"key_version"` // 加密使用的密钥版本
+}
+
+type TransitDecryptRequest struct {
+	Ciphertext     string `json:"ciphertext" binding:"required,max=1500000"`           // 密文
+	AssociatedData string `json:"associated_data" binding:"omitempty,base64,max=4096"` // Base64编码的附加认证数据
+}
+
+type TransitDecryptResponse struct {
+	Plaintext string `json:"plaintext"` // Base64编码的明文
+}
@@ -5,6 +5,10 @@ type UpdateProfileRequest struct {
 	Email string `json:"email" binding:"omitempty,email"`
 }
 
+type UpdatePublicKeyRequest struct {
+	PublicKey string `json:"public_key" binding:"required,base64"` // base64编码的X25519公钥
+}
+
 type CreateUserRequest struct {
 	Name        string   `json:"name" binding:"required,min=2,max=50"`
 	Password    string   `json:"password" binding:"required,min=8,max=128"`
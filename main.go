@@ -5,17 +5,37 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 
 	"github.com/akinoccc/hysaif/api/config"
+	"github.com/akinoccc/hysaif/api/middleware"
 	"github.com/akinoccc/hysaif/api/models"
+	"github.com/akinoccc/hysaif/api/packages/crypto"
 	"github.com/akinoccc/hysaif/api/packages/notification"
+	"github.com/akinoccc/hysaif/api/packages/password"
 	"github.com/akinoccc/hysaif/api/packages/permission"
+	"github.com/akinoccc/hysaif/api/packages/recyclebin"
+	"github.com/akinoccc/hysaif/api/packages/rekey"
+	"github.com/akinoccc/hysaif/api/packages/rotation"
+	"github.com/akinoccc/hysaif/api/packages/validation"
 	"github.com/akinoccc/hysaif/api/router"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	// 运维子命令
+	if len(os.Args) > 1 && os.Args[1] == "operator" {
+		runOperator(os.Args[2:])
+		return
+	}
+
+	// 数据完整性校验
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		runVerify(os.Args[2:])
+		return
+	}
+
 	// 定义命令行参数
 	var configPath string
 	flag.StringVar(&configPath, "config", "config.json", "配置文件路径")
@@ -27,16 +47,66 @@ func main() {
 		log.Fatalf("加载配置文件失败: %v", err)
 	}
 
+	// 校验加密策略配置
+	if err := crypto.ValidateConfiguredPolicies(); err != nil {
+		log.Fatalf("加密策略配置错误: %v", err)
+	}
+
+	// 校验密码强度策略配置
+	if err := password.ValidateConfiguredPolicies(); err != nil {
+		log.Fatalf("密码强度策略配置错误: %v", err)
+	}
+
+	// 校验密封模式配置
+	if err := crypto.ValidateSealConfig(); err != nil {
+		log.Fatalf("密封模式配置错误: %v", err)
+	}
+
+	// 校验存储后端配置
+	if err := crypto.ValidateStorageConfig(); err != nil {
+		log.Fatalf("存储后端配置错误: %v", err)
+	}
+
+	// 校验环境独立主密钥配置
+	if err := crypto.ValidateEnvironmentKeys(); err != nil {
+		log.Fatalf("环境主密钥配置错误: %v", err)
+	}
+
 	// 初始化数据库
 	models.InitDB()
 
+	// 加密回退到本地AES时记录审计日志
+	crypto.OnFallback(middleware.RecordEncryptionFallback)
+
+	// 注册自定义校验规则，信息项类型的校验需要查询数据库
+	if err := validation.RegisterValidators(); err != nil {
+		log.Fatalf("注册校验规则失败: %v", err)
+	}
+
 	// 初始化Casbin权限管理器
 	permission.GetCasbinManager(models.DB)
 
+	// 恢复中断的主密钥轮换任务，密封模式下解封后恢复
+	crypto.OnUnseal(rekey.Resume)
+	rekey.Resume()
+	if crypto.IsSealed() {
+		log.Println("系统以密封状态启动，请通过 POST /api/v1/sys/unseal 提交解封分片")
+	}
+
 	// 启动定时任务服务
 	notification.Start()
 	defer notification.Stop()
 
+	// 启动自动轮换服务，定时轮换记录审计日志
+	rotation.OnScheduledRotation(middleware.RecordScheduledRotation)
+	rotation.Start()
+	defer rotation.Stop()
+
+	// 启动回收站清除服务，定时清除记录审计日志
+	recyclebin.OnScheduledPurge(middleware.RecordScheduledPurge)
+	recyclebin.Start()
+	defer recyclebin.Stop()
+
 	// 创建Gin路由
 	r := gin.Default()
 
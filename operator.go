@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/akinoccc/hysaif/api/config"
+	"github.com/akinoccc/hysaif/api/packages/crypto"
+)
+
+// runOperator 执行运维子命令
+func runOperator(args []string) {
+	if len(args) == 0 {
+		fmt.Println("用法: hysaif operator init [-shares 5] [-threshold 3] [-from-config] [-config config.json]")
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "init":
+		operatorInit(args[1:])
+	default:
+		log.Fatalf("未知的运维命令: %s", args[0])
+	}
+}
+
+// operatorInit 生成密封模式的主密钥分片
+func operatorInit(args []string) {
+	fs := flag.NewFlagSet("operator init", flag.ExitOnError)
+	shares := fs.Int("shares", 5, "分片总数")
+	threshold := fs.Int("threshold", 3, "解封所需的分片数")
+	fromConfig := fs.Bool("from-config", false, "拆分配置文件中现有的 encryption_key，而不是生成新密钥，用于迁移已有数据")
+	configPath := fs.String("config", "config.json", "配置文件路径（仅 -from-config 时使用）")
+	fs.Parse(args)
+
+	var (
+		encodedShares []string
+		keyCheck      string
+		err           error
+	)
+	if *fromConfig {
+		if err := config.LoadConfig(*configPath); err != nil {
+			log.Fatalf("加载配置文件失败: %v", err)
+		}
+		encodedShares, keyCheck, err = crypto.SplitSealKey([]byte(config.AppConfig.Security.EncryptionKey), *shares, *threshold)
+	} else {
+		encodedShares, keyCheck, err = crypto.GenerateSealShares(*shares, *threshold)
+	}
+	if err != nil {
+		log.Fatalf("生成解封分片失败: %v", err)
+	}
+
+	for i, share := range encodedShares {
+		fmt.Printf("分片 %d: %s\n", i+1, share)
+	}
+	fmt.Println()
+	fmt.Println("请将以下配置写入 security.seal，并将各分片分别交给不同的持有者保管：")
+	fmt.Printf("  \"seal\": {\"enabled\": true, \"shares\": %d, \"threshold\": %d, \"key_check\": \"%s\", \"key_version\": 0}\n",
+		*shares, *threshold, keyCheck)
+	if *fromConfig {
+		fmt.Println("确认可以解封后，请从配置文件中删除 encryption_key。")
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/akinoccc/hysaif/api/config"
+	"github.com/akinoccc/hysaif/api/models"
+	"github.com/akinoccc/hysaif/api/packages/crypto"
+	"github.com/akinoccc/hysaif/api/packages/verify"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// runVerify 校验所有加密数据能否解密，存在无法解密的记录时以状态码1退出
+func runVerify(args []string) {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	configPath := fs.String("config", "config.json", "配置文件路径")
+	repair := fs.Bool("repair", false, "隔离无法解密的记录，并解除已能正常解密的记录的隔离")
+	jsonOutput := fs.Bool("json", false, "以JSON格式输出校验报告")
+	fs.Parse(args)
+
+	if err := config.LoadConfig(*configPath); err != nil {
+		log.Fatalf("加载配置文件失败: %v", err)
+	}
+	if err := crypto.ValidateStorageConfig(); err != nil {
+		log.Fatalf("存储后端配置错误: %v", err)
+	}
+	if err := crypto.ValidateEnvironmentKeys(); err != nil {
+		log.Fatalf("环境主密钥配置错误: %v", err)
+	}
+	if crypto.IsSealed() {
+		log.Fatalf("系统处于密封模式，请在服务解封后通过 POST /api/v1/admin/verify 执行校验")
+	}
+
+	models.InitDB()
+	models.DB = models.DB.Session(&gorm.Session{Logger: logger.Default.LogMode(logger.Silent)})
+
+	opts := verify.Options{Repair: *repair}
+	if !*jsonOutput {
+		opts.OnFailure = func(failure verify.Failure) {
+			status := ""
+			if failure.Quarantined {
+				status = " [已隔离]"
+			}
+			fmt.Printf("无法解密: %s/%s (%s %s v%s)%s: %s\n",
+				failure.Table, failure.ID, failure.Format, failure.Provider, failure.KeyVersion, status, failure.Error)
+		}
+	}
+
+	report, err := verify.Run(opts)
+	if err != nil {
+		log.Fatalf("数据完整性校验失败: %v", err)
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	} else {
+		fmt.Println()
+		fmt.Println("主密钥使用情况:")
+		for _, usage := range report.Keys {
+			fmt.Printf("  %-14s %-20s 版本 %-6s 记录 %d，无法解密 %d\n",
+				usage.Format, usage.Provider, usage.KeyVersion, usage.Rows, usage.Failed)
+		}
+		fmt.Printf("共检查 %d 条记录，正常 %d 条，无法解密 %d 条\n", report.TotalRows, report.ValidRows, report.FailedRows)
+		if report.SkippedRows > 0 {
+			fmt.Printf("跳过 %d 条当前部署未持有环境主密钥的记录\n", report.SkippedRows)
+		}
+		if report.Repair {
+			fmt.Printf("本次隔离 %d 条，解除隔离 %d 条\n", report.QuarantinedRows, report.ReleasedRows)
+		}
+	}
+
+	if report.FailedRows > 0 {
+		os.Exit(1)
+	}
+}